@@ -0,0 +1,118 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package main
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"react-and-go/pkd/appuser"
+	"react-and-go/pkd/config"
+	"react-and-go/pkd/database"
+	"react-and-go/pkd/database/dbmigrate"
+	fileex "react-and-go/pkd/fileexport"
+	"react-and-go/pkd/gasstation"
+	"react-and-go/pkd/notification"
+	"react-and-go/pkd/postcode"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exports the prices, it is started in the backend directory: go run ./cmd/priceexport -postcodes 20095 -from 2023-01-01 -to 2023-01-31 -format parquet -out prices.parquet
+func main() {
+	formatStr := flag.String("format", "csv", "export format: csv or parquet")
+	stidsStr := flag.String("stids", "", "comma separated station ids")
+	postCodesStr := flag.String("postcodes", "", "comma separated postcodes")
+	county := flag.String("county", "", "county name")
+	bboxStr := flag.String("bbox", "", "bounding box: minLat,minLng,maxLat,maxLng")
+	fromStr := flag.String("from", "", "first day: YYYY-MM-DD")
+	toStr := flag.String("to", "", "last day: YYYY-MM-DD")
+	outStr := flag.String("out", "", "output file, default stdout")
+	flag.Parse()
+
+	format, err := fileex.ParseExportFormat(*formatStr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	filter := gasstation.PriceExportFilter{Stids: splitList(*stidsStr), PostCodes: splitList(*postCodesStr), County: *county,
+		MinMax: parseBoundingBox(*bboxStr), From: parseDay(*fromStr), To: parseDay(*toStr).AddDate(0, 0, 1)}
+	if err := filter.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	config.LoadEnvVariables()
+	database.ConnectToDB()
+	dbmigrate.MigrateDB()
+	appUserRepo := appuser.NewAppUserDbRepo(database.DB)
+	notificationService := notification.NewNotificationService(notification.NewNotificationDbRepo(database.DB), appUserRepo)
+	gasStationService := gasstation.NewGasStationService(gasstation.NewGasStationDbRepo(database.DB), postcode.NewPostCodeDbRepo(database.DB), notificationService)
+
+	var writer io.Writer = os.Stdout
+	if len(*outStr) > 0 {
+		file, err := os.Create(*outStr)
+		if err != nil {
+			log.Fatalf("Failed to create file: %v, %v", *outStr, err)
+		}
+		defer file.Close()
+		writer = file
+	}
+	bufWriter := bufio.NewWriter(writer)
+	rowNum, err := fileex.NewPriceExporter(gasStationService).ExportPrices(bufWriter, format, filter)
+	if err == nil {
+		err = bufWriter.Flush()
+	}
+	if err != nil {
+		log.Fatalf("Price export failed after %v rows: %v", rowNum, err)
+	}
+	log.Printf("Price export done, rows: %v", rowNum)
+}
+
+func splitList(listStr string) []string {
+	var result []string
+	for _, myValue := range strings.Split(listStr, ",") {
+		if len(strings.TrimSpace(myValue)) > 0 {
+			result = append(result, strings.TrimSpace(myValue))
+		}
+	}
+	return result
+}
+
+func parseBoundingBox(bboxStr string) *gasstation.MinMaxSquare {
+	myValues := splitList(bboxStr)
+	if len(myValues) == 0 {
+		return nil
+	}
+	if len(myValues) != 4 {
+		log.Fatalf("Invalid bounding box: %v", bboxStr)
+	}
+	var myCoordinates [4]float64
+	for index, myValue := range myValues {
+		myCoordinate, err := strconv.ParseFloat(myValue, 64)
+		if err != nil {
+			log.Fatalf("Invalid bounding box: %v", bboxStr)
+		}
+		myCoordinates[index] = myCoordinate
+	}
+	return &gasstation.MinMaxSquare{MinLat: myCoordinates[0], MinLng: myCoordinates[1], MaxLat: myCoordinates[2], MaxLng: myCoordinates[3]}
+}
+
+func parseDay(dayStr string) time.Time {
+	result, err := time.ParseInLocation(time.DateOnly, dayStr, time.Local)
+	if err != nil {
+		log.Fatalf("Invalid day: %v", dayStr)
+	}
+	return result
+}
@@ -0,0 +1,61 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package main
+
+import (
+	"flag"
+	"log"
+	"react-and-go/pkd/appuser"
+	"react-and-go/pkd/config"
+	"react-and-go/pkd/database"
+	"react-and-go/pkd/database/dbmigrate"
+	fileim "react-and-go/pkd/fileimport"
+	"react-and-go/pkd/gasstation"
+	"react-and-go/pkd/notification"
+	"react-and-go/pkd/postcode"
+	"time"
+)
+
+// imports the Tankerkoenig price files, it is started in the backend directory: go run ./cmd/priceimport -from 2023-01-01 -to 2023-01-31
+func main() {
+	fromStr := flag.String("from", "", "first day to import: YYYY-MM-DD")
+	toStr := flag.String("to", "", "last day to import: YYYY-MM-DD")
+	flag.Parse()
+	from := parseDay(*fromStr)
+	to := parseDay(*toStr)
+
+	config.LoadEnvVariables()
+	database.ConnectToDB()
+	dbmigrate.MigrateDB()
+	appUserRepo := appuser.NewAppUserDbRepo(database.DB)
+	notificationService := notification.NewNotificationService(notification.NewNotificationDbRepo(database.DB), appUserRepo)
+	gasStationService := gasstation.NewGasStationService(gasstation.NewGasStationDbRepo(database.DB), postcode.NewPostCodeDbRepo(database.DB), notificationService)
+
+	result, err := fileim.NewPriceImporter(gasStationService).ImportPrices(from, to)
+	if err != nil {
+		log.Fatalf("Price import failed: %v", err)
+	}
+	log.Printf("Price import done, files: %v skipped files: %v prices: %v invalid rows: %v unknown stations: %v", result.Files, result.SkippedFiles,
+		result.Prices, result.InvalidRows, result.Unknown)
+}
+
+func parseDay(dayStr string) time.Time {
+	if len(dayStr) == 0 {
+		return time.Time{}
+	}
+	result, err := time.ParseInLocation(time.DateOnly, dayStr, time.Local)
+	if err != nil {
+		log.Fatalf("Invalid day: %v", dayStr)
+	}
+	return result
+}
@@ -18,12 +18,21 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"react-and-go/pkd/appuser"
 	"react-and-go/pkd/config"
 	"react-and-go/pkd/controller"
+	gsclient "react-and-go/pkd/controller/client"
 	"react-and-go/pkd/cron"
 	"react-and-go/pkd/database"
 	"react-and-go/pkd/database/dbmigrate"
+	fileex "react-and-go/pkd/fileexport"
+	fileim "react-and-go/pkd/fileimport"
+	"react-and-go/pkd/gasstation"
+	"react-and-go/pkd/importdiff"
 	"react-and-go/pkd/messaging"
+	"react-and-go/pkd/notification"
+	"react-and-go/pkd/pollingregion"
+	"react-and-go/pkd/postcode"
 	"runtime"
 	"syscall"
 	"time"
@@ -34,12 +43,45 @@ var start = time.Now()
 //go:embed public
 var embeddedFiles embed.FS
 
+var msgClient *messaging.MsgClient
+var auController *controller.AuController
+var gsController *controller.GsController
+var pcController *controller.PcController
+var unController *controller.UnController
+var prController *controller.PrController
+var idController *controller.IdController
+var msgController *controller.MsgController
+
 func init() {
 	config.LoadEnvVariables()
 	database.ConnectToDB()
 	dbmigrate.MigrateDB()
-	messaging.Start()
-	cron.Start()
+	appUserRepo := appuser.NewAppUserDbRepo(database.DB)
+	postCodeRepo := postcode.NewPostCodeDbRepo(database.DB)
+	notificationRepo := notification.NewNotificationDbRepo(database.DB)
+	gasStationRepo := gasstation.NewGasStationDbRepo(database.DB)
+	appUserService := appuser.NewAppUserService(appUserRepo)
+	postCodeService := postcode.NewPostCodeService(postCodeRepo)
+	notificationService := notification.NewNotificationService(notificationRepo, appUserRepo)
+	gasStationService := gasstation.NewGasStationService(gasStationRepo, postCodeRepo, notificationService)
+	pollingRegionService := pollingregion.NewPollingRegionService(pollingregion.NewPollingRegionDbRepo(database.DB))
+	if err := pollingRegionService.LoadRegionsFile(); err != nil {
+		log.Printf("Failed to load the polling regions: %v\n", err)
+	}
+	gsClient := gsclient.NewGsClient(gasStationService, gsclient.NewTkApiClient(gsclient.LoadTkApiConfig()))
+	diffStore := importdiff.NewDiffStore()
+	stationImporter := fileim.NewStationImporter(gasStationService, diffStore)
+	msgClient = messaging.NewMsgClient(gasStationService, messaging.NewMsgDbRepo(database.DB))
+	auController = controller.NewAuController(appUserService, postCodeService, fileim.NewPostCodeImporter(postCodeService, gasStationService, diffStore))
+	gsController = controller.NewGsController(gasStationService, postCodeService, gsClient, fileim.NewPriceImporter(gasStationService),
+		fileex.NewPriceExporter(gasStationService), stationImporter)
+	pcController = controller.NewPcController(postCodeService)
+	unController = controller.NewUnController(notificationService)
+	prController = controller.NewPrController(pollingRegionService)
+	idController = controller.NewIdController(diffStore)
+	msgController = controller.NewMsgController(msgClient)
+	msgClient.Start()
+	cron.NewCronJobs(gasStationService, gsClient, msgClient, pollingRegionService, stationImporter).Start()
 }
 
 func main() {
@@ -50,12 +92,12 @@ func main() {
 	// kill -2 is syscall.SIGINT
 	// kill -9 is syscall.SIGKILL but can't be catch, so don't need add it
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	go controller.Start(getPublicFolder())
+	go controller.Start(getPublicFolder(), auController, gsController, pcController, unController, prController, idController, msgController)
 
 	<-quit
 	log.Println("Shutting down server...")
 
-	messaging.Stop()
+	msgClient.Stop()
 	time.Sleep(2 * time.Second)
 
 	log.Println("Server exiting")
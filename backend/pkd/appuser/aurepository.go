@@ -0,0 +1,166 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package appuser
+
+import (
+	"fmt"
+	"maps"
+	aumodel "react-and-go/pkd/appuser/aumodel"
+	"react-and-go/pkd/memrepo"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type AppUserRepo interface {
+	FindAll() []aumodel.AppUser
+	FindByUsername(username string) (aumodel.AppUser, error)
+	Save(appUser *aumodel.AppUser) error
+	DeleteLoggedOutUsersBefore(lastLogout time.Time)
+	SaveLoggedOutUser(loggedOutUser *aumodel.LoggedOutUser) error
+	FindAllLoggedOutUsers() []aumodel.LoggedOutUser
+	Transaction(txFunc func(repo AppUserRepo) error) error
+}
+
+type appUserDbRepo struct {
+	db *gorm.DB
+}
+
+func NewAppUserDbRepo(db *gorm.DB) AppUserRepo {
+	return &appUserDbRepo{db: db}
+}
+
+func (repo *appUserDbRepo) FindAll() []aumodel.AppUser {
+	var result []aumodel.AppUser
+	repo.db.Find(&result)
+	return result
+}
+
+func (repo *appUserDbRepo) FindByUsername(username string) (aumodel.AppUser, error) {
+	var appUser aumodel.AppUser
+	result := repo.db.Where("username = ?", username).First(&appUser)
+	return appUser, result.Error
+}
+
+func (repo *appUserDbRepo) Save(appUser *aumodel.AppUser) error {
+	return repo.db.Save(appUser).Error
+}
+
+func (repo *appUserDbRepo) DeleteLoggedOutUsersBefore(lastLogout time.Time) {
+	repo.db.Where("last_logout < ?", lastLogout).Delete(&aumodel.LoggedOutUser{})
+}
+
+func (repo *appUserDbRepo) SaveLoggedOutUser(loggedOutUser *aumodel.LoggedOutUser) error {
+	return repo.db.Save(loggedOutUser).Error
+}
+
+func (repo *appUserDbRepo) FindAllLoggedOutUsers() []aumodel.LoggedOutUser {
+	var loggedOutUsers []aumodel.LoggedOutUser
+	repo.db.Find(&loggedOutUsers)
+	return loggedOutUsers
+}
+
+func (repo *appUserDbRepo) Transaction(txFunc func(repo AppUserRepo) error) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		return txFunc(&appUserDbRepo{db: tx})
+	})
+}
+
+type appUserMemRepo struct {
+	mutex          *sync.RWMutex
+	appUsers       map[string]aumodel.AppUser
+	loggedOutUsers map[string]aumodel.LoggedOutUser
+	ids            *memrepo.IdSequence[int64]
+	transactor     *memrepo.Transactor
+}
+
+func NewAppUserMemRepo() AppUserRepo {
+	return &appUserMemRepo{mutex: &sync.RWMutex{}, appUsers: make(map[string]aumodel.AppUser), loggedOutUsers: make(map[string]aumodel.LoggedOutUser),
+		ids: memrepo.NewIdSequence[int64](), transactor: memrepo.NewTransactor()}
+}
+
+func (repo *appUserMemRepo) FindAll() []aumodel.AppUser {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	result := []aumodel.AppUser{}
+	for _, myAppUser := range repo.appUsers {
+		result = append(result, myAppUser)
+	}
+	return result
+}
+
+func (repo *appUserMemRepo) FindByUsername(username string) (aumodel.AppUser, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	if myAppUser, ok := repo.appUsers[username]; ok {
+		return myAppUser, nil
+	}
+	return aumodel.AppUser{}, gorm.ErrRecordNotFound
+}
+
+func (repo *appUserMemRepo) Save(appUser *aumodel.AppUser) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	if appUser.ID == 0 {
+		if _, ok := repo.appUsers[appUser.Username]; ok {
+			return fmt.Errorf("username taken: %v", appUser.Username)
+		}
+		appUser.ID = uint(repo.ids.Next())
+		appUser.CreatedAt = time.Now()
+	}
+	appUser.UpdatedAt = time.Now()
+	repo.appUsers[appUser.Username] = *appUser
+	return nil
+}
+
+func (repo *appUserMemRepo) DeleteLoggedOutUsersBefore(lastLogout time.Time) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	for key, myLoggedOutUser := range repo.loggedOutUsers {
+		if myLoggedOutUser.LastLogout.Before(lastLogout) {
+			delete(repo.loggedOutUsers, key)
+		}
+	}
+}
+
+func (repo *appUserMemRepo) SaveLoggedOutUser(loggedOutUser *aumodel.LoggedOutUser) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	if loggedOutUser.ID == 0 {
+		loggedOutUser.ID = repo.ids.Next()
+	}
+	repo.loggedOutUsers[loggedOutUser.Uuid] = *loggedOutUser
+	return nil
+}
+
+func (repo *appUserMemRepo) FindAllLoggedOutUsers() []aumodel.LoggedOutUser {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	result := []aumodel.LoggedOutUser{}
+	for _, myLoggedOutUser := range repo.loggedOutUsers {
+		result = append(result, myLoggedOutUser)
+	}
+	return result
+}
+
+func (repo *appUserMemRepo) Transaction(txFunc func(repo AppUserRepo) error) error {
+	return repo.transactor.Run(repo.mutex, func() func() {
+		myAppUsers := maps.Clone(repo.appUsers)
+		myLoggedOutUsers := maps.Clone(repo.loggedOutUsers)
+		return func() {
+			repo.appUsers = myAppUsers
+			repo.loggedOutUsers = myLoggedOutUsers
+		}
+	}, func() error { return txFunc(repo) })
+}
@@ -16,7 +16,6 @@ import (
 	"log"
 	"net/http"
 	aumodel "react-and-go/pkd/appuser/aumodel"
-	"react-and-go/pkd/database"
 	token "react-and-go/pkd/token"
 	"strconv"
 	"strings"
@@ -24,7 +23,6 @@ import (
 
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
-	"gorm.io/gorm"
 )
 
 type AppUserIn struct {
@@ -61,18 +59,23 @@ const (
 	Failed
 )
 
-func FindAllUsers() []aumodel.AppUser {
-	var result []aumodel.AppUser
-	database.DB.Find(&result)
-	return result
+type AppUserService struct {
+	appUserRepo AppUserRepo
+}
+
+func NewAppUserService(appUserRepo AppUserRepo) *AppUserService {
+	return &AppUserService{appUserRepo: appUserRepo}
 }
 
-func StoreUserLogout(username string, uuid string) []token.LoggedOutUserOut {
-	database.DB.Delete("last_logout < ?", time.Now().Add(-4*time.Minute))
+func (service *AppUserService) FindAllUsers() []aumodel.AppUser {
+	return service.appUserRepo.FindAll()
+}
+
+func (service *AppUserService) StoreUserLogout(username string, uuid string) []token.LoggedOutUserOut {
+	service.appUserRepo.DeleteLoggedOutUsersBefore(time.Now().Add(-4 * time.Minute))
 	loggedOutUser := aumodel.LoggedOutUser{Username: username, Uuid: uuid, LastLogout: time.Now()}
-	database.DB.Save(loggedOutUser)
-	var loggedOutUsers []aumodel.LoggedOutUser
-	database.DB.Find(&loggedOutUsers)
+	service.appUserRepo.SaveLoggedOutUser(&loggedOutUser)
+	loggedOutUsers := service.appUserRepo.FindAllLoggedOutUsers()
 	var results []token.LoggedOutUserOut
 	for _, myLoggedOutUser := range loggedOutUsers {
 		loggedOutUserOut := token.LoggedOutUserOut{Username: myLoggedOutUser.Username, Uuid: myLoggedOutUser.Uuid, LastLogout: myLoggedOutUser.LastLogout}
@@ -81,13 +84,13 @@ func StoreUserLogout(username string, uuid string) []token.LoggedOutUserOut {
 	return results
 }
 
-func Login(appUserIn AppUserIn) (string, int, int32, string, float64, float64, float64, int, int, int) {
+func (service *AppUserService) Login(appUserIn AppUserIn) (string, int, int32, string, float64, float64, float64, int, int, int) {
 	result := ""
 	status := http.StatusUnauthorized
 	//log.Printf("%v", appUserIn.Username)
-	var appUser aumodel.AppUser
-	if err := database.DB.Where("username = ?", appUserIn.Username).First(&appUser); err.Error != nil {
-		log.Printf("User not found: %v error: %v\n", appUserIn.Username, err.Error)
+	appUser, err := service.appUserRepo.FindByUsername(appUserIn.Username)
+	if err != nil {
+		log.Printf("User not found: %v error: %v\n", appUserIn.Username, err)
 		return result, status, 0, "", 0.0, 0.0, 0.0, 0, 0, 0
 	}
 	if err := bcrypt.CompareHashAndPassword([]byte(appUser.Password), []byte(appUserIn.Password)); err != nil {
@@ -95,7 +98,7 @@ func Login(appUserIn AppUserIn) (string, int, int32, string, float64, float64, f
 		return result, status, 0, "", 0.0, 0.0, 0.0, 0, 0, 0
 	}
 	//jwt token creation
-	result, err := token.CreateToken(token.TokenUser{Username: appUser.Username, Roles: []string{"USERS"}})
+	result, err = token.CreateToken(token.TokenUser{Username: appUser.Username, Roles: []string{"USERS"}})
 	if err != nil {
 		log.Printf("Failed to create jwt token: %v\n", err)
 		return result, status, 0, "", 0.0, 0.0, 0.0, 0, 0, 0
@@ -105,15 +108,15 @@ func Login(appUserIn AppUserIn) (string, int, int32, string, float64, float64, f
 	return result, status, appUser.PostCode, appUser.Uuid, appUser.Longitude, appUser.Latitude, appUser.SearchRadius, appUser.TargetE5, appUser.TargetE10, appUser.TargetDiesel
 }
 
-func Signin(appUserIn AppUserIn) DbResult {
+func (service *AppUserService) Signin(appUserIn AppUserIn) DbResult {
 	var result DbResult = Invalid
 	if len(appUserIn.Username) < 4 || len(appUserIn.Password) < 8 {
 		return result
 	}
-	err := database.DB.Transaction(func(tx *gorm.DB) error {
-		var appUser aumodel.AppUser
+	err := service.appUserRepo.Transaction(func(repo AppUserRepo) error {
 		//check usernames
-		if err := tx.Where("username = ?", appUserIn.Username).First(&appUser); err.Error == nil {
+		appUser, err := repo.FindByUsername(appUserIn.Username)
+		if err == nil {
 			result = UsernameTaken
 			return nil
 		}
@@ -127,8 +130,7 @@ func Signin(appUserIn AppUserIn) DbResult {
 		appUser.Password = string(generatePasswordHash(appUserIn.Password))
 		appUser.Uuid = myUuid.String()
 		appUser.LangKey = string(appUserIn.Language)
-		tx.Save(&appUser)
-		return nil
+		return repo.Save(&appUser)
 	})
 	if err != nil {
 		result = Failed
@@ -138,16 +140,15 @@ func Signin(appUserIn AppUserIn) DbResult {
 	return result
 }
 
-func StoreLocationAndRadius(appUserIn AppUserIn) DbResult {
+func (service *AppUserService) StoreLocationAndRadius(appUserIn AppUserIn) DbResult {
 	result := Invalid
-	database.DB.Transaction(func(tx *gorm.DB) error {
-		var appUser aumodel.AppUser
-		if err := tx.Where("username = ?", appUserIn.Username).Find(&appUser); err.Error == nil {
+	service.appUserRepo.Transaction(func(repo AppUserRepo) error {
+		if appUser, err := repo.FindByUsername(appUserIn.Username); err == nil {
 			appUser.Longitude = appUserIn.Longitude
 			appUser.Latitude = appUserIn.Latitude
 			appUser.SearchRadius = appUserIn.SearchRadius
 			appUser.PostCode = appUserIn.PostCode
-			tx.Save(&appUser)
+			repo.Save(&appUser)
 			result = Ok
 		}
 		return nil
@@ -155,12 +156,11 @@ func StoreLocationAndRadius(appUserIn AppUserIn) DbResult {
 	return result
 }
 
-func StoreTargetPrices(appTargetIn AppTargetIn) DbResult {
+func (service *AppUserService) StoreTargetPrices(appTargetIn AppTargetIn) DbResult {
 	result := Invalid
-	database.DB.Transaction(func(tx *gorm.DB) error {
-		var appUser aumodel.AppUser
+	service.appUserRepo.Transaction(func(repo AppUserRepo) error {
 		var txError error = nil
-		if err := tx.Where("username = ?", appTargetIn.Username).Find(&appUser); err.Error == nil {
+		if appUser, err := repo.FindByUsername(appTargetIn.Username); err == nil {
 			if targetPrice, err := strconv.ParseInt(strings.ReplaceAll(appTargetIn.TargetDiesel, ".", ""), 10, 32); err == nil {
 				appUser.TargetDiesel = int(targetPrice)
 			} else {
@@ -180,7 +180,7 @@ func StoreTargetPrices(appTargetIn AppTargetIn) DbResult {
 				txError = err
 			}
 			if txError == nil {
-				tx.Save(&appUser)
+				repo.Save(&appUser)
 				result = Ok
 			}
 		}
@@ -20,6 +20,7 @@ import (
 	"react-and-go/pkd/appuser"
 	aubody "react-and-go/pkd/controller/aumodel"
 	fileim "react-and-go/pkd/fileimport"
+	"react-and-go/pkd/importdiff"
 	postcode "react-and-go/pkd/postcode"
 	pcmodel "react-and-go/pkd/postcode/pcmodel"
 	token "react-and-go/pkd/token"
@@ -27,13 +28,23 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func getLogout(c *gin.Context) {
+type AuController struct {
+	appUserService   *appuser.AppUserService
+	postCodeService  *postcode.PostCodeService
+	postCodeImporter *fileim.PostCodeImporter
+}
+
+func NewAuController(appUserService *appuser.AppUserService, postCodeService *postcode.PostCodeService, postCodeImporter *fileim.PostCodeImporter) *AuController {
+	return &AuController{appUserService: appUserService, postCodeService: postCodeService, postCodeImporter: postCodeImporter}
+}
+
+func (auController *AuController) getLogout(c *gin.Context) {
 	status := http.StatusUnauthorized
 	message := "Invalid"
 	username, exists1 := c.Get("user")
 	uuid, exists2 := c.Get("uuid")
 	if exists1 && exists2 {
-		token.LoggedOutUsers = appuser.StoreUserLogout(username.(string), uuid.(string))
+		token.LoggedOutUsers = auController.appUserService.StoreUserLogout(username.(string), uuid.(string))
 		if len(token.LoggedOutUsers) > 0 {
 			message = ""
 			status = http.StatusOK
@@ -42,7 +53,7 @@ func getLogout(c *gin.Context) {
 	c.JSON(status, aubody.AppUserResponse{Token: "", Message: message})
 }
 
-func getRefreshToken(c *gin.Context) {
+func (auController *AuController) getRefreshToken(c *gin.Context) {
 	status := http.StatusUnauthorized
 	message := "Invalid"
 	result := ""
@@ -62,9 +73,9 @@ func getRefreshToken(c *gin.Context) {
 	c.JSON(status, aubody.AppUserResponse{Token: result, Message: message})
 }
 
-func getLocation(c *gin.Context) {
+func (auController *AuController) getLocation(c *gin.Context) {
 	locationStr := c.Query("location")
-	postCodeLocations := postcode.FindLocation(locationStr)
+	postCodeLocations := auController.postCodeService.FindLocation(locationStr)
 	//log.Printf("Locations: %v", postCodeLocations)
 	myPostCodeLocations := mapToPostCodeLocation(postCodeLocations)
 	c.JSON(http.StatusOK, myPostCodeLocations)
@@ -88,17 +99,29 @@ func mapToPostCodeLocation(postCodeLocations []pcmodel.PostCodeLocation) []aubod
 	return result
 }
 
-func getPostCodeCoordinates(c *gin.Context) {
+func (auController *AuController) getPostCodeCoordinates(c *gin.Context) {
 	filePath := c.Query("filename")
-	fileim.UpdatePostCodeCoordinates(filePath)
+	if isDryRun(c) {
+		sendImportDiff(c, func() (importdiff.ImportDiff, error) {
+			return auController.postCodeImporter.DiffPostCodeCoordinates(filePath)
+		})
+		return
+	}
+	auController.postCodeImporter.UpdatePostCodeCoordinates(filePath)
 }
 
-func getStateCountyData(c *gin.Context) {
+func (auController *AuController) getStateCountyData(c *gin.Context) {
 	filePath := c.Query("filename")
-	fileim.UpdateStatesAndCounties(filePath)
+	if isDryRun(c) {
+		sendImportDiff(c, func() (importdiff.ImportDiff, error) {
+			return auController.postCodeImporter.DiffStatesAndCounties(filePath)
+		})
+		return
+	}
+	auController.postCodeImporter.UpdateStatesAndCounties(filePath)
 }
 
-func postSignin(c *gin.Context) {
+func (auController *AuController) postSignin(c *gin.Context) {
 	//jsonData, err := ioutil.ReadAll(c.Request.Body)
 	//fmt.Printf("Json: %v, Err: %v", string(jsonData), err)
 	var appUserRequest aubody.AppUserRequest
@@ -106,7 +129,7 @@ func postSignin(c *gin.Context) {
 		log.Printf("postSingin: %v", err.Error())
 	}
 	myAppUser := appuser.AppUserIn{Username: appUserRequest.Username, Password: appUserRequest.Password, Uuid: ""}
-	result := appuser.Signin(myAppUser)
+	result := auController.appUserService.Signin(myAppUser)
 	httpResult := http.StatusNotAcceptable
 	message := ""
 	if result == appuser.Ok {
@@ -117,13 +140,13 @@ func postSignin(c *gin.Context) {
 	c.JSON(httpResult, aubody.AppUserResponse{Token: "", Message: message})
 }
 
-func postLogin(c *gin.Context) {
+func (auController *AuController) postLogin(c *gin.Context) {
 	var appUserRequest aubody.AppUserRequest
 	if err := c.Bind(&appUserRequest); err != nil {
 		log.Printf("postLogin: %v", err.Error())
 	}
 	myAppUser := appuser.AppUserIn{Username: appUserRequest.Username, Password: appUserRequest.Password, Uuid: ""}
-	result, status, postCode, userUuid, userLongitude, userLatitude, searchRadius, targetE5, targetE10, targetDiesel := appuser.Login(myAppUser)
+	result, status, postCode, userUuid, userLongitude, userLatitude, searchRadius, targetE5, targetE10, targetDiesel := auController.appUserService.Login(myAppUser)
 	var message = ""
 	if status != http.StatusOK {
 		message = "Login failed."
@@ -133,13 +156,13 @@ func postLogin(c *gin.Context) {
 	c.JSON(status, appAuResponse)
 }
 
-func postUserLocationRadius(c *gin.Context) {
+func (auController *AuController) postUserLocationRadius(c *gin.Context) {
 	var appUserRequest aubody.AppUserRequest
 	if err := c.Bind(&appUserRequest); err != nil {
 		log.Printf("putUserLocationRadius: %v", err.Error())
 	}
 	myAppUser := appuser.AppUserIn{Username: appUserRequest.Username, PostCode: appUserRequest.PostCode, Uuid: "", Longitude: appUserRequest.Longitude, Latitude: appUserRequest.Latitude, SearchRadius: appUserRequest.SearchRadius}
-	result := appuser.StoreLocationAndRadius(myAppUser)
+	result := auController.appUserService.StoreLocationAndRadius(myAppUser)
 	httpResult := http.StatusOK
 	message := "Ok"
 	if result != appuser.Ok {
@@ -149,13 +172,13 @@ func postUserLocationRadius(c *gin.Context) {
 	c.JSON(httpResult, aubody.CodeLocationResponse{Message: message, Label: "", Longitude: appUserRequest.Longitude, Latitude: appUserRequest.Latitude, PostCode: appUserRequest.PostCode, SquareKM: 0, Population: 0})
 }
 
-func postTargetPrices(c *gin.Context) {
+func (auController *AuController) postTargetPrices(c *gin.Context) {
 	var appUserRequest aubody.AppUserRequest
 	if err := c.Bind(&appUserRequest); err != nil {
 		log.Printf("putUserLocationRadius: %v", err.Error())
 	}
 	myTargetPrices := appuser.AppTargetIn{Username: appUserRequest.Username, TargetDiesel: appUserRequest.TargetDiesel, TargetE10: appUserRequest.TargetE10, TargetE5: appUserRequest.TargetE5}
-	result := appuser.StoreTargetPrices(myTargetPrices)
+	result := auController.appUserService.StoreTargetPrices(myTargetPrices)
 	httpResult := http.StatusOK
 	message := "Ok"
 	if result != appuser.Ok {
@@ -17,7 +17,6 @@ import (
 	"log"
 	"net/http"
 	"os"
-	gsclient "react-and-go/pkd/controller/client"
 	token "react-and-go/pkd/token"
 	"strconv"
 	"strings"
@@ -27,30 +26,58 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func Start(embeddedFiles fs.FS) {
+func Start(embeddedFiles fs.FS, auController *AuController, gsController *GsController, pcController *PcController, unController *UnController,
+	prController *PrController, idController *IdController, msgController *MsgController) {
 	apiBase := "/api"
 	router := gin.Default()
-	router.Use(gzip.Gzip(gzip.DefaultCompression))
-	router.POST(apiBase+"/appuser/signin", postSignin)
-	router.POST(apiBase+"/appuser/login", postLogin)
-	router.GET(apiBase+"/appuser/logout", token.CheckToken, getLogout)
-	router.GET(apiBase+"/appuser/location", token.CheckToken, getLocation)
-	router.GET(apiBase+"/appuser/refreshtoken", token.CheckToken, getRefreshToken)
-	router.POST(apiBase+"/appuser/locationradius", token.CheckToken, postUserLocationRadius)
-	router.POST(apiBase+"/appuser/targetprices", token.CheckToken, postTargetPrices)
-	router.GET(apiBase+"/config/updategs", token.CheckToken, gsclient.UpdateGasStations)
-	router.GET(apiBase+"/config/updatepc", token.CheckToken, getPostCodeCoordinates)
-	router.GET(apiBase+"/config/updatestatescounties", token.CheckToken, getStateCountyData)
-	router.GET(apiBase+"/config/recalcAvgs", token.CheckToken, getRecalcAvgs)
-	router.GET(apiBase+"/gasprice/:id", token.CheckToken, getGasPriceByGasStationId)
-	router.GET(apiBase+"/gasstation/:id", token.CheckToken, getGasStationById)
-	router.GET(apiBase+"/gasprice/avgs/:postcode", token.CheckToken, getAveragePrices)
-	router.POST(apiBase+"/gasstation/search/place", token.CheckToken, searchGasStationPlace)
-	router.POST(apiBase+"/gasstation/search/location", token.CheckToken, searchGasStationLocation)
-	router.GET(apiBase+"/usernotification/new/:useruuid", token.CheckToken, getNewUserNotifications)
-	router.GET(apiBase+"/usernotification/current/:useruuid", token.CheckToken, getCurrentUserNotifications)
-	router.GET(apiBase+"/postcode/countytimeslots/:postcode", token.CheckToken, getCountyDataByIdWithTimeSlots)
-	router.GET(apiBase+"/gasstation/countytimeslots/recalc", token.CheckToken, getRecalcTimeSlots)
+	//the event stream and the websocket have to be flushed per message
+	router.Use(gzip.Gzip(gzip.DefaultCompression, gzip.WithExcludedPaths([]string{apiBase + "/gasprice/stream", apiBase + "/usernotification/push"})))
+	router.POST(apiBase+"/appuser/signin", auController.postSignin)
+	router.POST(apiBase+"/appuser/login", auController.postLogin)
+	router.GET(apiBase+"/appuser/logout", token.CheckToken, auController.getLogout)
+	router.GET(apiBase+"/appuser/location", token.CheckToken, auController.getLocation)
+	router.GET(apiBase+"/appuser/refreshtoken", token.CheckToken, auController.getRefreshToken)
+	router.POST(apiBase+"/appuser/locationradius", token.CheckToken, auController.postUserLocationRadius)
+	router.POST(apiBase+"/appuser/targetprices", token.CheckToken, auController.postTargetPrices)
+	router.GET(apiBase+"/config/updategs", token.CheckToken, gsController.getUpdateGasStations)
+	router.POST(apiBase+"/config/importstations", token.CheckToken, gsController.postImportStations)
+	router.GET(apiBase+"/config/stationimports", token.CheckToken, gsController.getStationImportRuns)
+	router.GET(apiBase+"/config/stationimports/:id/report", token.CheckToken, gsController.getStationImportReport)
+	router.GET(apiBase+"/config/updatepc", token.CheckToken, auController.getPostCodeCoordinates)
+	router.GET(apiBase+"/config/updatestatescounties", token.CheckToken, auController.getStateCountyData)
+	router.GET(apiBase+"/config/recalcAvgs", token.CheckToken, gsController.getRecalcAvgs)
+	router.GET(apiBase+"/config/lifecyclechanges", token.CheckToken, gsController.getLifecycleChanges)
+	router.GET(apiBase+"/config/importprices", token.CheckToken, gsController.getImportPrices)
+	router.GET(apiBase+"/config/priceimports", token.CheckToken, gsController.getPriceImportFiles)
+	router.GET(apiBase+"/config/importdiffs", token.CheckToken, idController.getImportDiffs)
+	router.GET(apiBase+"/config/importdiffs/:id", token.CheckToken, idController.getImportDiff)
+	router.POST(apiBase+"/config/importdiffs/:id/apply", token.CheckToken, idController.postApplyImportDiff)
+	router.GET(apiBase+"/config/regions", token.CheckToken, prController.getPollingRegions)
+	router.POST(apiBase+"/config/regions", token.CheckToken, prController.postPollingRegion)
+	router.POST(apiBase+"/config/regions/:name/enable", token.CheckToken, prController.postEnablePollingRegion)
+	router.POST(apiBase+"/config/regions/:name/disable", token.CheckToken, prController.postDisablePollingRegion)
+	router.GET(apiBase+"/config/ingestion", token.CheckToken, msgController.getIngestionMetrics)
+	router.GET(apiBase+"/config/ingestion/ledger", token.CheckToken, msgController.getPriceMessages)
+	router.GET(apiBase+"/config/deadletters", token.CheckToken, msgController.getDeadLetters)
+	router.GET(apiBase+"/config/deadletters/:id", token.CheckToken, msgController.getDeadLetter)
+	router.POST(apiBase+"/config/deadletters/:id/reprocess", token.CheckToken, msgController.postReprocessDeadLetter)
+	router.POST(apiBase+"/config/deadletters/:id/discard", token.CheckToken, msgController.postDiscardDeadLetter)
+	router.GET(apiBase+"/gasprice/:id", token.CheckToken, gsController.getGasPriceByGasStationId)
+	router.GET(apiBase+"/gasprice/history/:id", token.CheckToken, gsController.getGasPriceHistoryByGasStationId)
+	router.GET(apiBase+"/gasprice/stream", token.CheckToken, gsController.streamPriceChanges)
+	router.GET(apiBase+"/gasprice/export", token.CheckToken, gsController.getPriceExport)
+	router.GET(apiBase+"/gasstation/:id", token.CheckToken, gsController.getGasStationById)
+	router.GET(apiBase+"/gasstation/changelog/:id", token.CheckToken, gsController.getGasStationChangesById)
+	router.GET(apiBase+"/gasprice/avgs/:postcode", token.CheckToken, gsController.getAveragePrices)
+	router.POST(apiBase+"/gasstation/search/place", token.CheckToken, gsController.searchGasStationPlace)
+	router.POST(apiBase+"/gasstation/search/location", token.CheckToken, gsController.searchGasStationLocation)
+	router.POST(apiBase+"/gasstation/search/best", token.CheckToken, gsController.searchBestGasStations)
+	router.POST(apiBase+"/gasstation/search/route", token.CheckToken, gsController.searchGasStationRoute)
+	router.GET(apiBase+"/usernotification/new/:useruuid", token.CheckToken, unController.getNewUserNotifications)
+	router.GET(apiBase+"/usernotification/current/:useruuid", token.CheckToken, unController.getCurrentUserNotifications)
+	router.GET(apiBase+"/usernotification/push/:useruuid", token.CheckTokenQueryParam, unController.getUserNotificationPush)
+	router.GET(apiBase+"/postcode/countytimeslots/:postcode", token.CheckToken, pcController.getCountyDataByIdWithTimeSlots)
+	router.GET(apiBase+"/gasstation/countytimeslots/recalc", token.CheckToken, gsController.getRecalcTimeSlots)
 
 	myPort := strings.TrimSpace(os.Getenv("PORT"))
 	portNum, err := strconv.ParseInt(myPort, 10, 0)
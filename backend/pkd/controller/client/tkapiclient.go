@@ -0,0 +1,336 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gsclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTkApiUrl     = "https://creativecommons.tankerkoenig.de/json"
+	maxPricesStationIds = 10
+	maxListRadiusKM     = 25.0
+	apiKeyCoolDown      = 10 * time.Minute
+)
+
+var errRetryable = errors.New("retryable")
+
+type TkApiConfig struct {
+	BaseUrl           string
+	ApiKeys           []string
+	RequestsPerMinute float64
+	Burst             int
+	MaxRetries        int
+	RetryBackoff      time.Duration
+	Timeout           time.Duration
+}
+
+// the keys are read from TK_API_KEYS(comma separated) or APIKEY1..APIKEY3
+func LoadTkApiConfig() TkApiConfig {
+	result := TkApiConfig{BaseUrl: defaultTkApiUrl, RequestsPerMinute: 6, Burst: 1, MaxRetries: 3, RetryBackoff: 2 * time.Second, Timeout: 5 * time.Second}
+	if myBaseUrl := strings.TrimSpace(os.Getenv("TK_API_URL")); len(myBaseUrl) > 0 {
+		result.BaseUrl = strings.TrimSuffix(myBaseUrl, "/")
+	}
+	for _, myKey := range strings.Split(os.Getenv("TK_API_KEYS"), ",") {
+		if len(strings.TrimSpace(myKey)) > 0 {
+			result.ApiKeys = append(result.ApiKeys, strings.TrimSpace(myKey))
+		}
+	}
+	for index := 1; len(result.ApiKeys) == 0 && index <= 3; index++ {
+		if myKey := strings.TrimSpace(os.Getenv(fmt.Sprintf("APIKEY%v", index))); len(myKey) > 0 {
+			result.ApiKeys = append(result.ApiKeys, myKey)
+		}
+	}
+	if myValue, err := strconv.ParseFloat(strings.TrimSpace(os.Getenv("TK_REQUESTS_PER_MINUTE")), 64); err == nil && myValue > 0 {
+		result.RequestsPerMinute = myValue
+	}
+	if myValue, err := strconv.Atoi(strings.TrimSpace(os.Getenv("TK_REQUEST_BURST"))); err == nil && myValue > 0 {
+		result.Burst = myValue
+	}
+	if myValue, err := strconv.Atoi(strings.TrimSpace(os.Getenv("TK_MAX_RETRIES"))); err == nil && myValue >= 0 {
+		result.MaxRetries = myValue
+	}
+	return result
+}
+
+// a price is a number or false if the fuel is not available
+type TkFuelPrice float64
+
+func (price *TkFuelPrice) UnmarshalJSON(data []byte) error {
+	myValue := strings.TrimSpace(string(data))
+	if myValue == "false" || myValue == "null" {
+		*price = 0
+		return nil
+	}
+	myPrice, err := strconv.ParseFloat(myValue, 64)
+	if err != nil {
+		return fmt.Errorf("invalid price: %v", myValue)
+	}
+	*price = TkFuelPrice(myPrice)
+	return nil
+}
+
+// the prices are stored in thousandths
+func (price TkFuelPrice) Thousandths() int {
+	return int(math.Round(float64(price) * 1000))
+}
+
+func (price TkFuelPrice) isValid() bool {
+	return price >= 0 && price < 10
+}
+
+type TkStation struct {
+	Id          string      `json:"id"`
+	Name        string      `json:"name"`
+	Brand       string      `json:"brand"`
+	Street      string      `json:"street"`
+	Place       string      `json:"place"`
+	Lat         float64     `json:"lat"`
+	Lng         float64     `json:"lng"`
+	Dist        float64     `json:"dist"`
+	Diesel      TkFuelPrice `json:"diesel"`
+	E5          TkFuelPrice `json:"e5"`
+	E10         TkFuelPrice `json:"e10"`
+	IsOpen      bool        `json:"isOpen"`
+	HouseNumber string      `json:"houseNumber"`
+	PostCode    int         `json:"postCode"`
+}
+
+type TkOpeningTime struct {
+	Text  string `json:"text"`
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+type TkStationDetail struct {
+	TkStation
+	OpeningTimes []TkOpeningTime `json:"openingTimes"`
+	Overrides    []string        `json:"overrides"`
+	WholeDay     bool            `json:"wholeDay"`
+}
+
+type TkPrice struct {
+	Status string      `json:"status"`
+	Diesel TkFuelPrice `json:"diesel"`
+	E5     TkFuelPrice `json:"e5"`
+	E10    TkFuelPrice `json:"e10"`
+}
+
+type tkResponse struct {
+	Ok      bool   `json:"ok"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+	License string `json:"license"`
+}
+
+type tkListResponse struct {
+	tkResponse
+	Stations []TkStation `json:"stations"`
+}
+
+type tkPricesResponse struct {
+	tkResponse
+	Prices map[string]TkPrice `json:"prices"`
+}
+
+type tkDetailResponse struct {
+	tkResponse
+	Station TkStationDetail `json:"station"`
+}
+
+type TkApiClient struct {
+	config     TkApiConfig
+	httpClient *http.Client
+	limiter    *tokenBucket
+	keyPool    *apiKeyPool
+}
+
+func NewTkApiClient(config TkApiConfig) *TkApiClient {
+	return &TkApiClient{config: config, httpClient: &http.Client{Timeout: config.Timeout}, limiter: newTokenBucket(config.RequestsPerMinute, config.Burst),
+		keyPool: newApiKeyPool(config.ApiKeys)}
+}
+
+// the stations in the radius(max 25 km) with all prices, invalid stations are dropped
+func (client *TkApiClient) List(ctx context.Context, latitude float64, longitude float64, radiusKM float64) ([]TkStation, error) {
+	if latitude < -90 || latitude > 90 || longitude < -180 || longitude > 180 || radiusKM <= 0 {
+		return nil, fmt.Errorf("invalid location: %v, %v radius: %v", latitude, longitude, radiusKM)
+	}
+	myParams := url.Values{}
+	myParams.Set("lat", strconv.FormatFloat(latitude, 'f', 6, 64))
+	myParams.Set("lng", strconv.FormatFloat(longitude, 'f', 6, 64))
+	myParams.Set("rad", strconv.FormatFloat(math.Min(radiusKM, maxListRadiusKM), 'f', 1, 64))
+	myParams.Set("sort", "dist")
+	myParams.Set("type", "all")
+	var myResponse tkListResponse
+	if err := client.get(ctx, "list.php", myParams, &myResponse, &myResponse.tkResponse); err != nil {
+		return nil, err
+	}
+	result := []TkStation{}
+	for _, myStation := range myResponse.Stations {
+		if len(strings.TrimSpace(myStation.Id)) == 0 || myStation.Lat < -90 || myStation.Lat > 90 || myStation.Lng < -180 || myStation.Lng > 180 ||
+			!myStation.Diesel.isValid() || !myStation.E5.isValid() || !myStation.E10.isValid() {
+			log.Printf("Invalid station in list response: %v\n", myStation.Id)
+			continue
+		}
+		result = append(result, myStation)
+	}
+	return result, nil
+}
+
+// requests the prices in batches of 10 station ids, invalid prices are dropped
+func (client *TkApiClient) Prices(ctx context.Context, stationIds []string) (map[string]TkPrice, error) {
+	result := make(map[string]TkPrice)
+	for _, chunk := range chunkIds(stationIds, maxPricesStationIds) {
+		myParams := url.Values{}
+		myParams.Set("ids", strings.Join(chunk, ","))
+		var myResponse tkPricesResponse
+		if err := client.get(ctx, "prices.php", myParams, &myResponse, &myResponse.tkResponse); err != nil {
+			return result, err
+		}
+		for myId, myPrice := range myResponse.Prices {
+			if (myPrice.Status != "open" && myPrice.Status != "closed" && myPrice.Status != "no prices") || !myPrice.Diesel.isValid() ||
+				!myPrice.E5.isValid() || !myPrice.E10.isValid() {
+				log.Printf("Invalid price in prices response: %v\n", myId)
+				continue
+			}
+			result[myId] = myPrice
+		}
+	}
+	return result, nil
+}
+
+func (client *TkApiClient) Detail(ctx context.Context, stationId string) (TkStationDetail, error) {
+	myParams := url.Values{}
+	myParams.Set("id", stationId)
+	var myResponse tkDetailResponse
+	if err := client.get(ctx, "detail.php", myParams, &myResponse, &myResponse.tkResponse); err != nil {
+		return TkStationDetail{}, err
+	}
+	if myResponse.Station.Id != stationId {
+		return TkStationDetail{}, fmt.Errorf("detail response for station: %v instead of: %v", myResponse.Station.Id, stationId)
+	}
+	return myResponse.Station, nil
+}
+
+// retries network errors, 429 and 5xx responses with exponential backoff, a rejected api key is replaced by the next key
+func (client *TkApiClient) get(ctx context.Context, path string, params url.Values, result any, response *tkResponse) error {
+	var lastErr error
+	for attempt := 0; attempt <= client.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			myBackoff := client.config.RetryBackoff * time.Duration(1<<(attempt-1))
+			myBackoff += time.Duration(rand.Int63n(int64(myBackoff)/2 + 1))
+			log.Printf("Retry %v of %v in %v: %v\n", attempt, path, myBackoff, lastErr)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(myBackoff):
+			}
+		}
+		if err := client.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		myKey, err := client.keyPool.nextKey()
+		if err != nil {
+			return err
+		}
+		lastErr = client.doGet(ctx, path, params, myKey, result, response)
+		if lastErr == nil {
+			return nil
+		}
+		if !errors.Is(lastErr, errRetryable) {
+			return lastErr
+		}
+	}
+	return lastErr
+}
+
+func (client *TkApiClient) doGet(ctx context.Context, path string, params url.Values, apikey string, result any, response *tkResponse) error {
+	myParams := url.Values{}
+	for myName, myValues := range params {
+		myParams[myName] = myValues
+	}
+	myParams.Set("apikey", apikey)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%v/%v?%v", client.config.BaseUrl, path, myParams.Encode()), nil)
+	if err != nil {
+		return err
+	}
+	httpResponse, err := client.httpClient.Do(request)
+	if err != nil {
+		//the error contains the url with the api key
+		var myUrlErr *url.Error
+		if errors.As(err, &myUrlErr) {
+			myUrlErr.URL = redactApiKey(myUrlErr.URL)
+		}
+		return fmt.Errorf("%w: request failed: %v", errRetryable, err)
+	}
+	defer httpResponse.Body.Close()
+	if httpResponse.StatusCode == http.StatusUnauthorized || httpResponse.StatusCode == http.StatusForbidden {
+		client.keyPool.disableKey(apikey, apiKeyCoolDown)
+		return fmt.Errorf("%w: api key rejected: %v", errRetryable, httpResponse.Status)
+	}
+	if httpResponse.StatusCode == http.StatusTooManyRequests || httpResponse.StatusCode >= 500 {
+		return fmt.Errorf("%w: response status: %v", errRetryable, httpResponse.Status)
+	}
+	if httpResponse.StatusCode >= 300 {
+		return fmt.Errorf("response status: %v", httpResponse.Status)
+	}
+	if err := json.NewDecoder(httpResponse.Body).Decode(result); err != nil {
+		return fmt.Errorf("%w: json decode failed: %v", errRetryable, err)
+	}
+	if !response.Ok {
+		if strings.Contains(strings.ToLower(response.Message), "apikey") || strings.Contains(strings.ToLower(response.Message), "api-key") {
+			client.keyPool.disableKey(apikey, apiKeyCoolDown)
+			return fmt.Errorf("%w: api key rejected: %v", errRetryable, response.Message)
+		}
+		return fmt.Errorf("request rejected: %v", response.Message)
+	}
+	return nil
+}
+
+// the api keys must not be logged
+func redactApiKey(rawUrl string) string {
+	myUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return "<invalid url>"
+	}
+	myParams := myUrl.Query()
+	if myParams.Has("apikey") {
+		myParams.Set("apikey", "REDACTED")
+		myUrl.RawQuery = myParams.Encode()
+	}
+	return myUrl.String()
+}
+
+func chunkIds(ids []string, chunkSize int) [][]string {
+	var result [][]string
+	for len(ids) > chunkSize {
+		result = append(result, ids[:chunkSize])
+		ids = ids[chunkSize:]
+	}
+	if len(ids) > 0 {
+		result = append(result, ids)
+	}
+	return result
+}
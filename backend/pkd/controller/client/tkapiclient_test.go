@@ -0,0 +1,91 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gsclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedactApiKey(t *testing.T) {
+	myTests := []struct {
+		name   string
+		rawUrl string
+		want   string
+	}{
+		{"with key", "https://example.org/json/list.php?apikey=secret&lat=52.5", "https://example.org/json/list.php?apikey=REDACTED&lat=52.5"},
+		{"without key", "https://example.org/json/list.php?lat=52.5", "https://example.org/json/list.php?lat=52.5"},
+		{"without query", "https://example.org/json/list.php", "https://example.org/json/list.php"},
+		{"invalid url", "://example.org?apikey=secret", "<invalid url>"},
+	}
+	for _, myTest := range myTests {
+		t.Run(myTest.name, func(t *testing.T) {
+			if got := redactApiKey(myTest.rawUrl); got != myTest.want {
+				t.Errorf("redactApiKey(%v) = %v, want %v", myTest.rawUrl, got, myTest.want)
+			}
+		})
+	}
+}
+
+func TestChunkIds(t *testing.T) {
+	myTests := []struct {
+		name      string
+		ids       []string
+		chunkSize int
+		want      []int
+	}{
+		{"empty", nil, 10, nil},
+		{"one chunk", []string{"a", "b"}, 10, []int{2}},
+		{"exact chunks", []string{"a", "b", "c", "d"}, 2, []int{2, 2}},
+		{"last chunk smaller", []string{"a", "b", "c"}, 2, []int{2, 1}},
+	}
+	for _, myTest := range myTests {
+		t.Run(myTest.name, func(t *testing.T) {
+			myChunks := chunkIds(myTest.ids, myTest.chunkSize)
+			if len(myChunks) != len(myTest.want) {
+				t.Fatalf("chunkIds() = %v chunks, want %v", len(myChunks), len(myTest.want))
+			}
+			for myIndex, myChunk := range myChunks {
+				if len(myChunk) != myTest.want[myIndex] {
+					t.Errorf("chunk %v has %v ids, want %v", myIndex, len(myChunk), myTest.want[myIndex])
+				}
+			}
+		})
+	}
+}
+
+func TestRequestErrorHidesApiKey(t *testing.T) {
+	myServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		myConn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			myConn.Close()
+		}
+	}))
+	defer myServer.Close()
+	myClient := NewTkApiClient(TkApiConfig{BaseUrl: myServer.URL, ApiKeys: []string{"secret-key"}, RequestsPerMinute: 6000, Burst: 1,
+		MaxRetries: 0, RetryBackoff: time.Millisecond, Timeout: time.Second})
+	_, err := myClient.Detail(context.Background(), "51d4b55e-a095-1aa0-e100-80009459e03a")
+	if err == nil {
+		t.Fatal("expected a request error")
+	}
+	if strings.Contains(err.Error(), "secret-key") {
+		t.Errorf("the error contains the api key: %v", err)
+	}
+	if !strings.Contains(err.Error(), "apikey=REDACTED") {
+		t.Errorf("the error does not contain the redacted url: %v", err)
+	}
+}
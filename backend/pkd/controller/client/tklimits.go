@@ -0,0 +1,92 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gsclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrNoApiKey = errors.New("no usable api key")
+
+// token bucket that refills continuously up to the burst size
+type tokenBucket struct {
+	mutex           sync.Mutex
+	capacity        float64
+	tokens          float64
+	refillPerSecond float64
+	lastRefill      time.Time
+}
+
+func newTokenBucket(requestsPerMinute float64, burst int) *tokenBucket {
+	return &tokenBucket{capacity: float64(burst), tokens: float64(burst), refillPerSecond: requestsPerMinute / 60.0, lastRefill: time.Now()}
+}
+
+func (bucket *tokenBucket) Wait(ctx context.Context) error {
+	for {
+		bucket.mutex.Lock()
+		myNow := time.Now()
+		bucket.tokens += myNow.Sub(bucket.lastRefill).Seconds() * bucket.refillPerSecond
+		if bucket.tokens > bucket.capacity {
+			bucket.tokens = bucket.capacity
+		}
+		bucket.lastRefill = myNow
+		if bucket.tokens >= 1 {
+			bucket.tokens -= 1
+			bucket.mutex.Unlock()
+			return nil
+		}
+		myWait := time.Duration((1 - bucket.tokens) / bucket.refillPerSecond * float64(time.Second))
+		bucket.mutex.Unlock()
+		myTimer := time.NewTimer(myWait)
+		select {
+		case <-ctx.Done():
+			myTimer.Stop()
+			return ctx.Err()
+		case <-myTimer.C:
+		}
+	}
+}
+
+// the keys are used round robin, a rejected key is skipped until its cool down is over
+type apiKeyPool struct {
+	mutex         sync.Mutex
+	keys          []string
+	disabledUntil map[string]time.Time
+	next          int
+}
+
+func newApiKeyPool(keys []string) *apiKeyPool {
+	return &apiKeyPool{keys: keys, disabledUntil: make(map[string]time.Time)}
+}
+
+func (pool *apiKeyPool) nextKey() (string, error) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	for index := 0; index < len(pool.keys); index++ {
+		myKey := pool.keys[pool.next]
+		pool.next = (pool.next + 1) % len(pool.keys)
+		if time.Now().After(pool.disabledUntil[myKey]) {
+			return myKey, nil
+		}
+	}
+	return "", ErrNoApiKey
+}
+
+func (pool *apiKeyPool) disableKey(key string, coolDown time.Duration) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	pool.disabledUntil[key] = time.Now().Add(coolDown)
+}
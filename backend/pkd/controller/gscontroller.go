@@ -13,37 +13,99 @@
 package controller
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	gsclient "react-and-go/pkd/controller/client"
 	gsbody "react-and-go/pkd/controller/gsmodel"
+	fileex "react-and-go/pkd/fileexport"
+	fileim "react-and-go/pkd/fileimport"
 	"react-and-go/pkd/gasstation"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"react-and-go/pkd/importdiff"
 	"react-and-go/pkd/postcode"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-func getGasPriceByGasStationId(c *gin.Context) {
+type GsController struct {
+	gasStationService *gasstation.GasStationService
+	postCodeService   *postcode.PostCodeService
+	gsClient          *gsclient.GsClient
+	priceImporter     *fileim.PriceImporter
+	priceExporter     *fileex.PriceExporter
+	stationImporter   *fileim.StationImporter
+}
+
+func NewGsController(gasStationService *gasstation.GasStationService, postCodeService *postcode.PostCodeService, gsClient *gsclient.GsClient,
+	priceImporter *fileim.PriceImporter, priceExporter *fileex.PriceExporter, stationImporter *fileim.StationImporter) *GsController {
+	return &GsController{gasStationService: gasStationService, postCodeService: postCodeService, gsClient: gsClient, priceImporter: priceImporter,
+		priceExporter: priceExporter, stationImporter: stationImporter}
+}
+
+func (gsController *GsController) getGasPriceByGasStationId(c *gin.Context) {
 	gasstationId := c.Params.ByName("id")
-	gsEntity := gasstation.FindPricesByStid(gasstationId)
+	gsEntity := gsController.gasStationService.FindPricesByStid(gasstationId)
 	c.JSON(http.StatusOK, gsEntity)
 }
 
-func getGasStationById(c *gin.Context) {
+func (gsController *GsController) getGasPriceHistoryByGasStationId(c *gin.Context) {
 	gasstationId := c.Params.ByName("id")
-	gsEntity := gasstation.FindById(gasstationId)
+	myTo := time.Now()
+	myFrom := myTo.AddDate(0, 0, -7)
+	var err error
+	if toStr := strings.TrimSpace(c.Query("to")); len(toStr) > 0 {
+		if myTo, err = time.Parse(time.RFC3339, toStr); err != nil {
+			c.JSON(http.StatusBadRequest, fmt.Sprintf("Invalid to: %v", toStr))
+			return
+		}
+		myFrom = myTo.AddDate(0, 0, -7)
+	}
+	if fromStr := strings.TrimSpace(c.Query("from")); len(fromStr) > 0 {
+		if myFrom, err = time.Parse(time.RFC3339, fromStr); err != nil {
+			c.JSON(http.StatusBadRequest, fmt.Sprintf("Invalid from: %v", fromStr))
+			return
+		}
+	}
+	myBucket, err := gasstation.ParseBucketSize(c.Query("bucket"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	priceHistory, err := gsController.gasStationService.FindPriceHistory(gasstationId, myFrom, myTo, myBucket)
+	if err != nil {
+		log.Printf("getGasPriceHistoryByGasStationId: %v", err.Error())
+		if errors.Is(err, gasstation.ErrInvalidPriceHistory) {
+			c.JSON(http.StatusBadRequest, err.Error())
+		} else {
+			c.JSON(http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	c.JSON(http.StatusOK, priceHistory)
+}
+
+func (gsController *GsController) getGasStationById(c *gin.Context) {
+	gasstationId := c.Params.ByName("id")
+	gsEntity := gsController.gasStationService.FindById(gasstationId)
 	c.JSON(http.StatusOK, gsEntity)
 }
 
-func searchGasStationPlace(c *gin.Context) {
+func (gsController *GsController) searchGasStationPlace(c *gin.Context) {
 	var searchPlaceBody gsbody.SearchPlaceBody
 	if err := c.Bind(&searchPlaceBody); err != nil {
 		log.Printf("searchGasStationPlace: %v", err.Error())
 	}
-	gsEntity := gasstation.FindBySearchPlace(searchPlaceBody)
+	gsEntity := gsController.gasStationService.FindBySearchPlace(searchPlaceBody)
 	c.JSON(http.StatusOK, gsEntity)
 }
 
-func searchGasStationLocation(c *gin.Context) {
+func (gsController *GsController) searchGasStationLocation(c *gin.Context) {
 	//jsonData, err := ioutil.ReadAll(c.Request.Body)
 	//fmt.Printf("Json: %v, Err: %v", string(jsonData), err)
 	var searchLocationBody gsbody.SearchLocation
@@ -51,22 +113,259 @@ func searchGasStationLocation(c *gin.Context) {
 		log.Printf("searchGasStationLocation: %v", err.Error())
 	}
 	//fmt.Printf("Lat: %v, Lng: %v\n", searchLocationBody.Latitude, searchLocationBody.Longitude)
-	gsEntity := gasstation.FindBySearchLocation(searchLocationBody)
+	gsEntity := gsController.gasStationService.FindBySearchLocation(searchLocationBody)
 	c.JSON(http.StatusOK, gsEntity)
 }
 
-func getRecalcAvgs(c *gin.Context) {
-	gasstation.ReCalcCountyStatePrices()
+func (gsController *GsController) searchBestGasStations(c *gin.Context) {
+	var searchBestBody gsbody.SearchBestBody
+	if err := c.Bind(&searchBestBody); err != nil {
+		log.Printf("searchBestGasStations: %v", err.Error())
+	}
+	rankedGasStations, err := gsController.gasStationService.FindBestStations(searchBestBody)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, rankedGasStations)
+}
+
+func (gsController *GsController) searchGasStationRoute(c *gin.Context) {
+	var searchRouteBody gsbody.SearchRouteBody
+	if err := c.Bind(&searchRouteBody); err != nil {
+		log.Printf("searchGasStationRoute: %v", err.Error())
+	}
+	routeGasStations, err := gsController.gasStationService.FindByRoute(searchRouteBody)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, routeGasStations)
+}
+
+func (gsController *GsController) getGasStationChangesById(c *gin.Context) {
+	gasstationId := c.Params.ByName("id")
+	gasStationChanges := gsController.gasStationService.FindChangesByStid(gasstationId)
+	c.JSON(http.StatusOK, gasStationChanges)
+}
+
+func (gsController *GsController) getLifecycleChanges(c *gin.Context) {
+	lifecycleChanges := gsController.gasStationService.FindLastImportLifecycleChanges()
+	c.JSON(http.StatusOK, lifecycleChanges)
+}
+
+func (gsController *GsController) getRecalcAvgs(c *gin.Context) {
+	gsController.gasStationService.ReCalcCountyStatePrices()
 	c.JSON(http.StatusOK, "Done.")
 }
 
-func getAveragePrices(c *gin.Context) {
+func (gsController *GsController) getImportPrices(c *gin.Context) {
+	var myDays [2]time.Time
+	for index, myParam := range []string{"from", "to"} {
+		if dayStr := strings.TrimSpace(c.Query(myParam)); len(dayStr) > 0 {
+			myDay, err := time.ParseInLocation(time.DateOnly, dayStr, time.Local)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, fmt.Sprintf("Invalid %v: %v", myParam, dayStr))
+				return
+			}
+			myDays[index] = myDay
+		}
+	}
+	if err := gsController.priceImporter.StartImportPrices(myDays[0], myDays[1]); err != nil {
+		c.JSON(http.StatusConflict, err.Error())
+		return
+	}
+	c.JSON(http.StatusAccepted, "Started.")
+}
+
+// the days from and to are included
+func (gsController *GsController) getPriceExport(c *gin.Context) {
+	myFormat, err := fileex.ParseExportFormat(c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	myFilter := gasstation.PriceExportFilter{Stids: parseCommaList(c.Query("stids")), PostCodes: parseCommaList(c.Query("postcodes")), County: c.Query("county")}
+	if myFilter.MinMax, err = parseMinMaxSquare(c); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	var myDays [2]time.Time
+	for index, myParam := range []string{"from", "to"} {
+		if myDays[index], err = time.ParseInLocation(time.DateOnly, strings.TrimSpace(c.Query(myParam)), time.Local); err != nil {
+			c.JSON(http.StatusBadRequest, fmt.Sprintf("Invalid %v: %v", myParam, c.Query(myParam)))
+			return
+		}
+	}
+	myFilter.From = myDays[0]
+	myFilter.To = myDays[1].AddDate(0, 0, 1)
+	if err := myFilter.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.Header("Content-Type", myFormat.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=prices-%v-%v.%v", myDays[0].Format(time.DateOnly), myDays[1].Format(time.DateOnly), myFormat))
+	c.Status(http.StatusOK)
+	//the status is sent, errors can only be logged
+	if rowNum, err := gsController.priceExporter.ExportPrices(c.Writer, myFormat, myFilter); err != nil {
+		log.Printf("Price export failed after %v rows: %v\n", rowNum, err)
+	}
+}
+
+func (gsController *GsController) getUpdateGasStations(c *gin.Context) {
+	if isDryRun(c) {
+		sendImportDiff(c, gsController.stationImporter.DiffStations)
+		return
+	}
+	gsController.sendStationImportRun(c, func() (gsmodel.StationImportRun, error) {
+		return gsController.stationImporter.ImportStations()
+	})
+}
+
+// the stations file is uploaded as multipart form field 'file'
+func (gsController *GsController) postImportStations(c *gin.Context) {
+	myFileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	myFile, err := myFileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	defer myFile.Close()
+	if isDryRun(c) {
+		sendImportDiff(c, func() (importdiff.ImportDiff, error) {
+			return gsController.stationImporter.DiffStationFile(myFileHeader.Filename, myFile)
+		})
+		return
+	}
+	gsController.sendStationImportRun(c, func() (gsmodel.StationImportRun, error) {
+		return gsController.stationImporter.ImportStationFile(myFileHeader.Filename, myFile)
+	})
+}
+
+func (gsController *GsController) sendStationImportRun(c *gin.Context, importFunc func() (gsmodel.StationImportRun, error)) {
+	myStationImportRun, err := importFunc()
+	if errors.Is(err, fileim.ErrStationImportRunning) {
+		c.JSON(http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		c.JSON(http.StatusBadRequest, myStationImportRun)
+		return
+	}
+	c.JSON(http.StatusOK, myStationImportRun)
+}
+
+func (gsController *GsController) getStationImportRuns(c *gin.Context) {
+	c.JSON(http.StatusOK, gsController.stationImporter.FindStationImportRuns())
+}
+
+func (gsController *GsController) getStationImportReport(c *gin.Context) {
+	myId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	myStationImportReport, found := gsController.stationImporter.FindStationImportReport(myId)
+	if !found {
+		c.JSON(http.StatusNotFound, fmt.Sprintf("Station import not found: %v", myId))
+		return
+	}
+	c.JSON(http.StatusOK, myStationImportReport)
+}
+
+func (gsController *GsController) getPriceImportFiles(c *gin.Context) {
+	priceImportFiles := gsController.gasStationService.FindPriceImportFiles()
+	c.JSON(http.StatusOK, priceImportFiles)
+}
+
+func (gsController *GsController) getAveragePrices(c *gin.Context) {
 	myPostcode := c.Params.ByName("postcode")
-	avgPrices := postcode.FindAvgsByPostcode(myPostcode)
+	avgPrices := gsController.postCodeService.FindAvgsByPostcode(myPostcode)
 	c.JSON(http.StatusOK, avgPrices)
 }
 
-func getRecalcTimeSlots(c *gin.Context) {
-	gasstation.CalcCountyTimeSlots()
+func (gsController *GsController) getRecalcTimeSlots(c *gin.Context) {
+	gsController.gasStationService.CalcCountyTimeSlots()
 	c.JSON(http.StatusOK, "Done.")
 }
+
+func (gsController *GsController) streamPriceChanges(c *gin.Context) {
+	var myFilter gasstation.PriceChangeFilter
+	var err error
+	if myFilter.MinMax, err = parseMinMaxSquare(c); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	myFilter.PostCodes = parseCommaList(c.Query("postcodes"))
+	if myFilter.MinMax == nil && len(myFilter.PostCodes) == 0 {
+		c.JSON(http.StatusBadRequest, "Bounding box or postcodes required")
+		return
+	}
+	for _, myFuelTypeStr := range strings.Split(c.Query("fueltype"), ",") {
+		if len(strings.TrimSpace(myFuelTypeStr)) == 0 {
+			continue
+		}
+		myFuelType, err := gasstation.ParseFuelType(myFuelTypeStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+		myFilter.FuelTypes = append(myFilter.FuelTypes, myFuelType)
+	}
+	subscription := gsController.gasStationService.SubscribePriceChanges(myFilter)
+	defer gsController.gasStationService.UnsubscribePriceChanges(subscription)
+	//keeps proxies from closing the idle connection
+	heartbeat := time.NewTicker(30 * time.Second)
+	defer heartbeat.Stop()
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Header("Content-Type", "text/event-stream")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case myPriceChangeEvent, ok := <-subscription.Events:
+			if !ok {
+				return false
+			}
+			c.SSEvent("pricechange", myPriceChangeEvent)
+			return true
+		case <-heartbeat.C:
+			c.SSEvent("heartbeat", time.Now())
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// returns nil without minLat parameter
+func parseMinMaxSquare(c *gin.Context) (*gasstation.MinMaxSquare, error) {
+	if len(strings.TrimSpace(c.Query("minLat"))) == 0 {
+		return nil, nil
+	}
+	myMinMax := gasstation.MinMaxSquare{}
+	var err error
+	for _, myParam := range []struct {
+		name  string
+		value *float64
+	}{{"minLat", &myMinMax.MinLat}, {"minLng", &myMinMax.MinLng}, {"maxLat", &myMinMax.MaxLat}, {"maxLng", &myMinMax.MaxLng}} {
+		if *myParam.value, err = strconv.ParseFloat(strings.TrimSpace(c.Query(myParam.name)), 64); err != nil {
+			return nil, fmt.Errorf("Invalid %v: %v", myParam.name, c.Query(myParam.name))
+		}
+	}
+	return &myMinMax, nil
+}
+
+func parseCommaList(listStr string) []string {
+	var result []string
+	for _, myValue := range strings.Split(listStr, ",") {
+		if len(strings.TrimSpace(myValue)) > 0 {
+			result = append(result, strings.TrimSpace(myValue))
+		}
+	}
+	return result
+}
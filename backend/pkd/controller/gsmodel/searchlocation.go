@@ -12,8 +12,12 @@
 */
 package gsbody
 
+import "time"
+
 type SearchLocation struct {
 	Longitude float64
 	Latitude  float64
 	Radius    float64
+	OpenNow   bool
+	OpenAt    *time.Time
 }
@@ -12,8 +12,12 @@
 */
 package gsbody
 
+import "time"
+
 type SearchPlaceBody struct {
 	StationName string
 	Place       string
 	PostCode    string
+	OpenNow     bool
+	OpenAt      *time.Time
 }
@@ -0,0 +1,75 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	fileim "react-and-go/pkd/fileimport"
+	"react-and-go/pkd/importdiff"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IdController struct {
+	diffStore *importdiff.DiffStore
+}
+
+func NewIdController(diffStore *importdiff.DiffStore) *IdController {
+	return &IdController{diffStore: diffStore}
+}
+
+func (idController *IdController) getImportDiffs(c *gin.Context) {
+	c.JSON(http.StatusOK, idController.diffStore.FindAll())
+}
+
+// the diff is downloaded as json file
+func (idController *IdController) getImportDiff(c *gin.Context) {
+	myImportDiff, found := idController.diffStore.Find(c.Param("id"))
+	if !found {
+		c.JSON(http.StatusNotFound, importdiff.ErrDiffNotFound.Error())
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"importdiff-%v.json\"", myImportDiff.ID))
+	c.JSON(http.StatusOK, myImportDiff)
+}
+
+func (idController *IdController) postApplyImportDiff(c *gin.Context) {
+	myImportDiff, err := idController.diffStore.Apply(c.Param("id"))
+	if errors.Is(err, importdiff.ErrDiffNotFound) {
+		c.JSON(http.StatusNotFound, err.Error())
+		return
+	} else if errors.Is(err, importdiff.ErrDiffOutdated) || errors.Is(err, fileim.ErrStationImportRunning) {
+		c.JSON(http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, myImportDiff)
+}
+
+// the dry run returns the diff instead of importing
+func sendImportDiff(c *gin.Context, diffFunc func() (importdiff.ImportDiff, error)) {
+	myImportDiff, err := diffFunc()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, myImportDiff)
+}
+
+func isDryRun(c *gin.Context) bool {
+	return c.Query("dryrun") == "true"
+}
@@ -0,0 +1,93 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"react-and-go/pkd/importdiff"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestIdRouter(diffStore *importdiff.DiffStore) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	myIdController := NewIdController(diffStore)
+	router := gin.New()
+	router.GET("/config/importdiffs", myIdController.getImportDiffs)
+	router.GET("/config/importdiffs/:id", myIdController.getImportDiff)
+	router.POST("/config/importdiffs/:id/apply", myIdController.postApplyImportDiff)
+	return router
+}
+
+func TestPostApplyImportDiff(t *testing.T) {
+	myDiffStore := importdiff.NewDiffStore()
+	myEntries := []importdiff.DiffEntry{{Key: "10115", Action: importdiff.ActionUpdate,
+		Changes: []importdiff.FieldChange{{Field: "Population", OldValue: "100", NewValue: "200"}}}}
+	myCurrentDiff := importdiff.NewImportDiff(importdiff.KindPostCodes, "test.csv", myEntries)
+	myApplyNum := 0
+	myApplyFunc := func(importDiff importdiff.ImportDiff) error {
+		if !importDiff.Matches(myCurrentDiff) {
+			return importdiff.ErrDiffOutdated
+		}
+		myApplyNum += 1
+		return nil
+	}
+	myImportDiff := myDiffStore.Add(importdiff.NewImportDiff(importdiff.KindPostCodes, "test.csv", myEntries), myApplyFunc)
+	myOutdatedDiff := myDiffStore.Add(importdiff.NewImportDiff(importdiff.KindPostCodes, "test.csv", []importdiff.DiffEntry{{Key: "10115",
+		Action: importdiff.ActionUpdate, Changes: []importdiff.FieldChange{{Field: "Population", OldValue: "100", NewValue: "300"}}}}), myApplyFunc)
+	myRouter := newTestIdRouter(myDiffStore)
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{"list", http.MethodGet, "/config/importdiffs", http.StatusOK},
+		{"download", http.MethodGet, "/config/importdiffs/" + myImportDiff.ID, http.StatusOK},
+		{"download unknown", http.MethodGet, "/config/importdiffs/unknown", http.StatusNotFound},
+		{"apply", http.MethodPost, "/config/importdiffs/" + myImportDiff.ID + "/apply", http.StatusOK},
+		{"apply twice", http.MethodPost, "/config/importdiffs/" + myImportDiff.ID + "/apply", http.StatusNotFound},
+		{"apply outdated", http.MethodPost, "/config/importdiffs/" + myOutdatedDiff.ID + "/apply", http.StatusConflict},
+		{"apply outdated again", http.MethodPost, "/config/importdiffs/" + myOutdatedDiff.ID + "/apply", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			myRecorder := httptest.NewRecorder()
+			myRouter.ServeHTTP(myRecorder, httptest.NewRequest(tt.method, tt.path, nil))
+			if myRecorder.Code != tt.wantStatus {
+				t.Errorf("%v %v status = %v want: %v body: %v", tt.method, tt.path, myRecorder.Code, tt.wantStatus, myRecorder.Body.String())
+			}
+		})
+	}
+	if myApplyNum != 1 {
+		t.Errorf("applied diffs = %v want: 1", myApplyNum)
+	}
+}
+
+func TestGetImportDiffs(t *testing.T) {
+	myDiffStore := importdiff.NewDiffStore()
+	myDiffStore.Add(importdiff.NewImportDiff(importdiff.KindStations, "stations.csv", []importdiff.DiffEntry{{Key: "stid1", Action: importdiff.ActionInsert},
+		{Key: "stid2", Action: importdiff.ActionRemove}}), func(importDiff importdiff.ImportDiff) error { return nil })
+	myRecorder := httptest.NewRecorder()
+	newTestIdRouter(myDiffStore).ServeHTTP(myRecorder, httptest.NewRequest(http.MethodGet, "/config/importdiffs", nil))
+	var myImportDiffs []importdiff.ImportDiff
+	if err := json.Unmarshal(myRecorder.Body.Bytes(), &myImportDiffs); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+	if len(myImportDiffs) != 1 || myImportDiffs[0].Inserts != 1 || myImportDiffs[0].Removes != 1 || myImportDiffs[0].Entries != nil {
+		t.Errorf("getImportDiffs() = %+v want: one summary without entries", myImportDiffs)
+	}
+}
@@ -0,0 +1,95 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"react-and-go/pkd/messaging"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MsgController struct {
+	msgClient *messaging.MsgClient
+}
+
+func NewMsgController(msgClient *messaging.MsgClient) *MsgController {
+	return &MsgController{msgClient: msgClient}
+}
+
+func (msgController *MsgController) getIngestionMetrics(c *gin.Context) {
+	c.JSON(http.StatusOK, msgController.msgClient.IngestionMetrics())
+}
+
+func (msgController *MsgController) getPriceMessages(c *gin.Context) {
+	c.JSON(http.StatusOK, msgController.msgClient.FindPriceMessages())
+}
+
+// the status parameter filters the dead letters(new, reprocessed, discarded)
+func (msgController *MsgController) getDeadLetters(c *gin.Context) {
+	c.JSON(http.StatusOK, msgController.msgClient.FindDeadLetters(strings.TrimSpace(c.Query("status"))))
+}
+
+func (msgController *MsgController) getDeadLetter(c *gin.Context) {
+	myId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	myDeadLetter, err := msgController.msgClient.FindDeadLetter(myId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, myDeadLetter)
+}
+
+func (msgController *MsgController) postReprocessDeadLetter(c *gin.Context) {
+	myId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	myPriceUpdateResult, err := msgController.msgClient.ReprocessDeadLetter(myId)
+	if !msgController.handleDeadLetterError(c, err) {
+		c.JSON(http.StatusOK, myPriceUpdateResult)
+	}
+}
+
+func (msgController *MsgController) postDiscardDeadLetter(c *gin.Context) {
+	myId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	myDeadLetter, err := msgController.msgClient.DiscardDeadLetter(myId)
+	if !msgController.handleDeadLetterError(c, err) {
+		c.JSON(http.StatusOK, myDeadLetter)
+	}
+}
+
+func (msgController *MsgController) handleDeadLetterError(c *gin.Context, err error) bool {
+	if errors.Is(err, messaging.ErrDeadLetterNotFound) {
+		c.JSON(http.StatusNotFound, err.Error())
+	} else if errors.Is(err, messaging.ErrDeadLetterClosed) || errors.Is(err, messaging.ErrDeadLetterNotApplied) {
+		c.JSON(http.StatusConflict, err.Error())
+	} else if errors.Is(err, messaging.ErrDeadLetterRejected) {
+		c.JSON(http.StatusUnprocessableEntity, err.Error())
+	} else if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+	}
+	return err != nil
+}
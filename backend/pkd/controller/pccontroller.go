@@ -19,8 +19,16 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func getCountyDataByIdWithTimeSlots(c *gin.Context) {
+type PcController struct {
+	postCodeService *postcode.PostCodeService
+}
+
+func NewPcController(postCodeService *postcode.PostCodeService) *PcController {
+	return &PcController{postCodeService: postCodeService}
+}
+
+func (pcController *PcController) getCountyDataByIdWithTimeSlots(c *gin.Context) {
 	myPostcode := c.Param("postcode")
-	myCountyData := postcode.FindCountyTimeSlotByPostcode(myPostcode)
+	myCountyData := pcController.postCodeService.FindCountyTimeSlotByPostcode(myPostcode)
 	c.JSON(http.StatusOK, myCountyData)
 }
@@ -0,0 +1,70 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"react-and-go/pkd/pollingregion"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PrController struct {
+	pollingRegionService *pollingregion.PollingRegionService
+}
+
+func NewPrController(pollingRegionService *pollingregion.PollingRegionService) *PrController {
+	return &PrController{pollingRegionService: pollingRegionService}
+}
+
+func (prController *PrController) getPollingRegions(c *gin.Context) {
+	c.JSON(http.StatusOK, prController.pollingRegionService.FindAll())
+}
+
+func (prController *PrController) postPollingRegion(c *gin.Context) {
+	var myRegionConfig pollingregion.RegionConfig
+	if err := c.Bind(&myRegionConfig); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	myPollingRegion, err := prController.pollingRegionService.AddRegion(myRegionConfig)
+	if errors.Is(err, pollingregion.ErrRegionExists) {
+		c.JSON(http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusCreated, myPollingRegion)
+}
+
+func (prController *PrController) postEnablePollingRegion(c *gin.Context) {
+	prController.setEnabled(c, true)
+}
+
+func (prController *PrController) postDisablePollingRegion(c *gin.Context) {
+	prController.setEnabled(c, false)
+}
+
+func (prController *PrController) setEnabled(c *gin.Context, enabled bool) {
+	myPollingRegion, err := prController.pollingRegionService.SetEnabled(c.Param("name"), enabled)
+	if errors.Is(err, pollingregion.ErrRegionNotFound) {
+		c.JSON(http.StatusNotFound, err.Error())
+		return
+	} else if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, myPollingRegion)
+}
@@ -13,23 +13,42 @@
 package controller
 
 import (
+	"log"
 	"net/http"
 	unbody "react-and-go/pkd/controller/unmodel"
 	notification "react-and-go/pkd/notification"
 	unmodel "react-and-go/pkd/notification/model"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 )
 
-func getNewUserNotifications(c *gin.Context) {
+const (
+	unWriteWait  = 10 * time.Second
+	unPongWait   = 60 * time.Second
+	unPingPeriod = 50 * time.Second
+)
+
+var unUpgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 4096}
+
+type UnController struct {
+	notificationService *notification.NotificationService
+}
+
+func NewUnController(notificationService *notification.NotificationService) *UnController {
+	return &UnController{notificationService: notificationService}
+}
+
+func (unController *UnController) getNewUserNotifications(c *gin.Context) {
 	userUuid := c.Param("useruuid")
-	myNotifications := notification.LoadNotifications(userUuid, true)
+	myNotifications := unController.notificationService.LoadNotifications(userUuid, true)
 	c.JSON(http.StatusOK, mapToUnResponses(myNotifications))
 }
 
-func getCurrentUserNotifications(c *gin.Context) {
+func (unController *UnController) getCurrentUserNotifications(c *gin.Context) {
 	userUuid := c.Param("useruuid")
-	myNotifications := notification.LoadNotifications(userUuid, false)
+	myNotifications := unController.notificationService.LoadNotifications(userUuid, false)
 	c.JSON(http.StatusOK, mapToUnResponses(myNotifications))
 }
 
@@ -37,9 +56,84 @@ func mapToUnResponses(myNotifications []unmodel.UserNotification) []unbody.UnRes
 	var unResponses []unbody.UnResponse
 	for _, myNotification := range myNotifications {
 		unResponse := unbody.UnResponse{
-			Timestamp: myNotification.Timestamp, UserUuid: myNotification.UserUuid, Title: myNotification.Title, Message: myNotification.Message, DataJson: myNotification.DataJson,
+			ID: myNotification.ID, Timestamp: myNotification.Timestamp, UserUuid: myNotification.UserUuid, Title: myNotification.Title, Message: myNotification.Message, DataJson: myNotification.DataJson,
 		}
 		unResponses = append(unResponses, unResponse)
 	}
 	return unResponses
 }
+
+func (unController *UnController) getUserNotificationPush(c *gin.Context) {
+	userUuid := c.Param("useruuid")
+	username, exists := c.Get("user")
+	if c.IsAborted() || !exists || !unController.notificationService.IsUserUuidOfUser(username.(string), userUuid) {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	wsConn, err := unUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("Websocket upgrade failed: %v\n", err)
+		return
+	}
+	defer wsConn.Close()
+	subscription := unController.notificationService.SubscribeNotifications(userUuid)
+	defer unController.notificationService.UnsubscribeNotifications(subscription)
+	readerDone := make(chan struct{})
+	go unController.readUserNotificationAcks(wsConn, userUuid, readerDone)
+	for _, myNotification := range unController.notificationService.FindUnacknowledgedNotifications(userUuid) {
+		if err := writeUnPushMessage(wsConn, myNotification); err != nil {
+			return
+		}
+	}
+	pingTicker := time.NewTicker(unPingPeriod)
+	defer pingTicker.Stop()
+	for {
+		select {
+		case myNotification, ok := <-subscription.Notifications:
+			if !ok {
+				return
+			}
+			if err := writeUnPushMessage(wsConn, myNotification); err != nil {
+				return
+			}
+		case <-pingTicker.C:
+			if err := wsConn.WriteControl(websocket.PingMessage, nil, time.Now().Add(unWriteWait)); err != nil {
+				return
+			}
+		case <-readerDone:
+			return
+		}
+	}
+}
+
+// the acknowledged notifications are marked as send, the others are pushed again on the next connect
+func (unController *UnController) readUserNotificationAcks(wsConn *websocket.Conn, userUuid string, readerDone chan struct{}) {
+	defer close(readerDone)
+	wsConn.SetReadLimit(4096)
+	wsConn.SetReadDeadline(time.Now().Add(unPongWait))
+	wsConn.SetPongHandler(func(string) error {
+		return wsConn.SetReadDeadline(time.Now().Add(unPongWait))
+	})
+	for {
+		var myPushMessage unbody.UnPushMessage
+		if err := wsConn.ReadJSON(&myPushMessage); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("Websocket read failed: %v\n", err)
+			}
+			return
+		}
+		if myPushMessage.Type == unbody.PushAck && len(myPushMessage.Ids) > 0 {
+			unController.notificationService.AcknowledgeNotifications(userUuid, myPushMessage.Ids)
+		}
+	}
+}
+
+func writeUnPushMessage(wsConn *websocket.Conn, myNotification unmodel.UserNotification) error {
+	myUnResponses := mapToUnResponses([]unmodel.UserNotification{myNotification})
+	wsConn.SetWriteDeadline(time.Now().Add(unWriteWait))
+	if err := wsConn.WriteJSON(unbody.UnPushMessage{Type: unbody.PushNotification, Notification: &myUnResponses[0]}); err != nil {
+		log.Printf("Websocket write failed: %v\n", err)
+		return err
+	}
+	return nil
+}
@@ -15,6 +15,7 @@ package unbody
 import "time"
 
 type UnResponse struct {
+	ID        int64
 	Timestamp time.Time
 	UserUuid  string
 	Title     string
@@ -13,161 +13,92 @@
 package cron
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	gsclient "react-and-go/pkd/controller/client"
+	fileim "react-and-go/pkd/fileimport"
 	"react-and-go/pkd/gasstation"
 	"react-and-go/pkd/messaging"
+	"react-and-go/pkd/pollingregion"
 	"strings"
 	"time"
 
 	"github.com/go-co-op/gocron"
 )
 
-type CircleCenter struct {
-	Latitude  float64
-	Longitude float64
+type CronJobs struct {
+	gasStationService    *gasstation.GasStationService
+	gsClient             *gsclient.GsClient
+	msgClient            *messaging.MsgClient
+	pollingRegionService *pollingregion.PollingRegionService
+	stationImporter      *fileim.StationImporter
 }
 
-var HamburgAndSH = [...]CircleCenter{
-	{
-		Latitude:  54.824158,
-		Longitude: 8.346131,
-	},
-	{
-		Latitude:  54.715297,
-		Longitude: 8.775641,
-	},
-	{
-		Latitude:  54.661861,
-		Longitude: 9.180214,
-	},
-	{
-		Latitude:  54.677340,
-		Longitude: 9.743868,
-	},
-	{
-		Latitude:  54.298884,
-		Longitude: 8.743990,
-	},
-	{
-		Latitude:  54.308298,
-		Longitude: 9.317139,
-	},
-	{
-		Latitude:  54.306721,
-		Longitude: 9.792173,
-	},
-	{
-		Latitude:  54.280894,
-		Longitude: 10.247840,
-	},
-	{
-		Latitude:  54.333907,
-		Longitude: 10.987011,
-	},
-	{
-		Latitude:  54.019711,
-		Longitude: 10.643870,
-	},
-	{
-		Latitude:  53.889138,
-		Longitude: 10.020025,
-	},
-	{
-		Latitude:  53.913517,
-		Longitude: 9.572239,
-	},
-	{
-		Latitude:  53.928135,
-		Longitude: 9.042212,
-	},
-	{
-		Latitude:  53.648308,
-		Longitude: 10.580193,
-	},
-	{
-		Latitude:  53.473590,
-		Longitude: 10.277897,
-	},
-	{
-		Latitude:  53.522599,
-		Longitude: 9.800100,
-	},
+func NewCronJobs(gasStationService *gasstation.GasStationService, gsClient *gsclient.GsClient, msgClient *messaging.MsgClient,
+	pollingRegionService *pollingregion.PollingRegionService, stationImporter *fileim.StationImporter) *CronJobs {
+	return &CronJobs{gasStationService: gasStationService, gsClient: gsClient, msgClient: msgClient, pollingRegionService: pollingRegionService,
+		stationImporter: stationImporter}
 }
 
-var requestCounter int64 = 0
-var apikeyIndex = 0
-
-func Start() {
-	/*
-		var apikeys [3]string
-		for index, _ := range apikeys {
-			apikeys[index] = os.Getenv(fmt.Sprintf("APIKEY%v", index+1))
-		}
-	*/
-
+func (cronJobs *CronJobs) Start() {
 	//gasstation.CalcCountyTimeSlots()
 
 	scheduler := gocron.NewScheduler(time.UTC)
-	scheduler.Every(1).Day().At("01:07").Do(func() {
-		gsclient.UpdateGasStations(nil)
-	})
+	scheduler.Every(1).Day().At("01:07").Do(cronJobs.stationImporter.ImportStations)
+
+	scheduler.Every(60).Seconds().Tag("messaging").Do(cronJobs.msgClient.ConnectionCheck)
+
+	scheduler.Every(1).Day().At("00:02").Tag("timeslices").Do(cronJobs.gasStationService.CalcCountyTimeSlots)
 
-	scheduler.Every(60).Seconds().Tag("messaging").Do(messaging.ConnectionCheck)
+	scheduler.Every(1).Day().At("02:05").Tag("averages").Do(cronJobs.gasStationService.ReCalcCountyStatePrices)
 
-	scheduler.Every(1).Day().At("00:02").Tag("timeslices").Do(gasstation.CalcCountyTimeSlots)
+	scheduler.Every(1).Day().At("03:08").Tag("cleanupOldPrices").Do(cronJobs.gasStationService.CleanupOldPrices)
 
-	scheduler.Every(1).Day().At("02:05").Tag("averages").Do(gasstation.ReCalcCountyStatePrices)
+	scheduler.Every(1).Day().At("03:38").Tag("pricePartitions").Do(cronJobs.gasStationService.CreateUpcomingPricePartitions)
 
-	scheduler.Every(1).Day().At("03:08").Tag("cleanupOldPrices").Do(gasstation.CleanupOldPrices)
+	scheduler.Every(1).Day().At("03:48").Tag("cleanupLedger").Do(cronJobs.msgClient.CleanupLedger)
+
+	//a region update takes minutes with the rate limit of the api, the regions have their own intervals
+	scheduler.Every(1).Minutes().SingletonMode().Tag("pricePolling").Do(cronJobs.updatePriceRegion)
 
 	msgFileStr := os.Getenv("MSG_MESSAGES")
 	if len(strings.TrimSpace(msgFileStr)) > 3 {
 		msgFiles := strings.Split(msgFileStr, ";")
-		scheduler.Every(60).Seconds().Tag("prices").Do(sendTestPriceMsgs, msgFiles)
+		scheduler.Every(60).Seconds().Tag("prices").Do(cronJobs.sendTestPriceMsgs, msgFiles)
 	}
 	scheduler.StartAsync()
 }
 
-func sendTestPriceMsgs(msgFiles []string) {
+func (cronJobs *CronJobs) sendTestPriceMsgs(msgFiles []string) {
 	for _, value := range msgFiles {
 		jsonFile, err := os.ReadFile(fmt.Sprintf("msg-examples/%v", value))
 		if err != nil {
 			log.Fatalf("file not found: %v", value)
 		}
-		messaging.SendMsg(string(jsonFile))
+		cronJobs.msgClient.SendMsg(string(jsonFile))
 		//log.Printf("Msg send: %v", string(jsonFile))
 		time.Sleep(10 * time.Second)
 	}
 }
 
-func updatePriceRegion(regionCircleCenters [16]CircleCenter, apikeys [3]string) {
-	for index, value := range HamburgAndSH {
-		//time.Sleep(6 * time.Second)
-		time.Sleep(15 * time.Second)
-		log.Printf("index: %v value: %v", index, value)
-		/*
-			err := gsclient.UpdateGsPrices(value.Latitude, value.Longitude, 25.0, apikeys[apikeyIndex])
-			if err != nil {
-				log.Printf("Region Canceled index: %v\n", index)
-				updateApiKeyIndex()
-				break
+// polls the prices with the Tankerkoenig api if MQTT is not connected, TK_POLLING=always/never overrides the fallback
+func (cronJobs *CronJobs) updatePriceRegion() {
+	pollingMode := strings.ToLower(strings.TrimSpace(os.Getenv("TK_POLLING")))
+	if pollingMode == "never" || (pollingMode != "always" && cronJobs.msgClient.IsConnected()) {
+		return
+	}
+	for _, myPollingRegion := range cronJobs.pollingRegionService.FindDueRegions(time.Now()) {
+		cronJobs.pollingRegionService.MarkPolled(myPollingRegion.Name, time.Now())
+		log.Printf("Price polling started for region %v with %v circles.\n", myPollingRegion.Name, len(myPollingRegion.PollingCircles))
+		for _, myPollingCircle := range myPollingRegion.PollingCircles {
+			if err := cronJobs.gsClient.UpdateGsPrices(myPollingCircle.Latitude, myPollingCircle.Longitude, myPollingCircle.RadiusKM); err != nil {
+				log.Printf("Region %v canceled circle: %v\n", myPollingRegion.Name, myPollingCircle.ID)
+				if errors.Is(err, gsclient.ErrNoApiKey) {
+					return
+				}
 			}
-		*/
-		requestCounter += 1
-		if requestCounter%45 == 0 {
-			updateApiKeyIndex()
 		}
-		log.Printf("Request %v, ApikeyIndex: %v\n", requestCounter, apikeyIndex)
-	}
-}
-
-func updateApiKeyIndex() {
-	if apikeyIndex < 2 {
-		apikeyIndex += 1
-	} else {
-		apikeyIndex = 0
 	}
 }
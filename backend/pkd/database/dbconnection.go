@@ -15,20 +15,38 @@ package database
 import (
 	"log"
 	"os"
+	"strings"
 
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 	"gorm.io/gorm/schema"
 )
 
+const (
+	Postgres = "postgres"
+	Sqlite   = "sqlite"
+)
+
 var DB *gorm.DB
 
 func ConnectToDB() {
 	var err error
 	dsn := os.Getenv("DB_PARAMS")
+	dbDriver := strings.ToLower(strings.TrimSpace(os.Getenv("DB_DRIVER")))
+
+	var dialector gorm.Dialector
+	switch dbDriver {
+	case "", Postgres:
+		dialector = postgres.Open(dsn)
+	case Sqlite:
+		dialector = sqlite.Open(createSqliteDsn(dsn))
+	default:
+		log.Fatalf("Unsupported database driver: %v", dbDriver)
+	}
 
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
+	DB, err = gorm.Open(dialector, &gorm.Config{
 		NamingStrategy: schema.NamingStrategy{
 			SingularTable: true,
 		},
@@ -46,3 +64,16 @@ func ConnectToDB() {
 
 	//log.Default().Printf("%v", DB.Migrator().CurrentDatabase())
 }
+
+// wait for locks and use the write ahead log, the price updates write concurrently
+func createSqliteDsn(dsn string) string {
+	myDsn := strings.TrimSpace(dsn)
+	if strings.Contains(myDsn, "_pragma=") {
+		return myDsn
+	}
+	separator := "?"
+	if strings.Contains(myDsn, "?") {
+		separator = "&"
+	}
+	return myDsn + separator + "_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)"
+}
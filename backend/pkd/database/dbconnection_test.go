@@ -0,0 +1,52 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package database
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateSqliteDsn(t *testing.T) {
+	tests := []struct {
+		name string
+		dsn  string
+		want string
+	}{
+		{"file", "/tmp/test.db", "/tmp/test.db?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)"},
+		{"file with params", " /tmp/test.db?mode=rwc ", "/tmp/test.db?mode=rwc&_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)"},
+		{"own pragmas", "/tmp/test.db?_pragma=foreign_keys(1)", "/tmp/test.db?_pragma=foreign_keys(1)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := createSqliteDsn(tt.dsn); got != tt.want {
+				t.Errorf("createSqliteDsn(%q) = %v want: %v", tt.dsn, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConnectToSqlite(t *testing.T) {
+	t.Setenv("DB_DRIVER", "SQLite")
+	t.Setenv("DB_PARAMS", filepath.Join(t.TempDir(), "test.db"))
+	ConnectToDB()
+	if myName := DB.Dialector.Name(); myName != Sqlite {
+		t.Fatalf("ConnectToDB() dialector: %v want: %v", myName, Sqlite)
+	}
+	if IsPartitioningEnabled() {
+		t.Errorf("IsPartitioningEnabled() = true want: false")
+	}
+	if IsPartitioned(DB, "gas_station_information_history") {
+		t.Errorf("IsPartitioned() = true want: false")
+	}
+}
@@ -17,7 +17,9 @@ import (
 	"react-and-go/pkd/appuser/aumodel"
 	database "react-and-go/pkd/database"
 	"react-and-go/pkd/gasstation/gsmodel"
+	"react-and-go/pkd/messaging/msgmodel"
 	unmodel "react-and-go/pkd/notification/model"
+	prmodel "react-and-go/pkd/pollingregion/prmodel"
 	pcmodel "react-and-go/pkd/postcode/pcmodel"
 )
 
@@ -25,7 +27,9 @@ func MigrateDB() {
 	if !database.DB.Migrator().HasTable(&gsmodel.GasStation{}) {
 		database.DB.AutoMigrate(&gsmodel.GasStation{})
 	}
-	if !database.DB.Migrator().HasTable(&gsmodel.GasPrice{}) {
+	if database.IsPartitioningEnabled() {
+		migratePricePartitions()
+	} else if !database.DB.Migrator().HasTable(&gsmodel.GasPrice{}) {
 		database.DB.AutoMigrate(&gsmodel.GasPrice{})
 	}
 	if !database.DB.Migrator().HasTable(&aumodel.AppUser{}) {
@@ -76,6 +80,54 @@ func MigrateDB() {
 	if !database.DB.Migrator().HasColumn(&aumodel.AppUser{}, "PostCode") {
 		database.DB.Migrator().AddColumn(&aumodel.AppUser{}, "PostCode")
 	}
+	if !database.DB.Migrator().HasColumn(&gsmodel.GasStation{}, "LifecycleStatus") {
+		database.DB.Migrator().AddColumn(&gsmodel.GasStation{}, "LifecycleStatus")
+	}
+	if !database.DB.Migrator().HasIndex(&gsmodel.GasStation{}, "idx_gs_lifecycle_status") {
+		database.DB.Migrator().CreateIndex(&gsmodel.GasStation{}, "idx_gs_lifecycle_status")
+	}
+	if !database.DB.Migrator().HasColumn(&gsmodel.GasStation{}, "ApplicableDays") {
+		database.DB.Migrator().AddColumn(&gsmodel.GasStation{}, "ApplicableDays")
+	}
+	if !database.DB.Migrator().HasTable(&gsmodel.LifecycleChange{}) {
+		database.DB.AutoMigrate(&gsmodel.LifecycleChange{})
+	}
+	if !database.DB.Migrator().HasTable(&gsmodel.GasStationChange{}) {
+		database.DB.AutoMigrate(&gsmodel.GasStationChange{})
+	}
+	if !database.DB.Migrator().HasTable(&gsmodel.GasPriceDaily{}) {
+		database.DB.AutoMigrate(&gsmodel.GasPriceDaily{})
+	}
+	if !database.DB.Migrator().HasTable(&gsmodel.PriceImportFile{}) {
+		database.DB.AutoMigrate(&gsmodel.PriceImportFile{})
+	}
+	if !database.DB.Migrator().HasTable(&gsmodel.StationImportRun{}) {
+		database.DB.AutoMigrate(&gsmodel.StationImportRun{})
+	}
+	if !database.DB.Migrator().HasColumn(&gsmodel.StationImportRun{}, "InvalidRows") {
+		database.DB.Migrator().AddColumn(&gsmodel.StationImportRun{}, "InvalidRows")
+	}
+	if !database.DB.Migrator().HasTable(&gsmodel.StationImportError{}) {
+		database.DB.AutoMigrate(&gsmodel.StationImportError{})
+	}
+	if !database.DB.Migrator().HasTable(&prmodel.PollingRegion{}) {
+		database.DB.AutoMigrate(&prmodel.PollingRegion{})
+	}
+	if !database.DB.Migrator().HasTable(&prmodel.PollingCircle{}) {
+		database.DB.AutoMigrate(&prmodel.PollingCircle{})
+	}
+	if !database.DB.Migrator().HasTable(&msgmodel.PriceMessage{}) {
+		database.DB.AutoMigrate(&msgmodel.PriceMessage{})
+	}
+	if !database.DB.Migrator().HasColumn(&msgmodel.PriceMessage{}, "Rejected") {
+		database.DB.Migrator().AddColumn(&msgmodel.PriceMessage{}, "Rejected")
+	}
+	if !database.DB.Migrator().HasColumn(&msgmodel.PriceMessage{}, "Superseded") {
+		database.DB.Migrator().AddColumn(&msgmodel.PriceMessage{}, "Superseded")
+	}
+	if !database.DB.Migrator().HasTable(&msgmodel.DeadLetter{}) {
+		database.DB.AutoMigrate(&msgmodel.DeadLetter{})
+	}
 
 	log.Printf("DB Migration Done.")
 }
@@ -0,0 +1,52 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package dbmigrate
+
+import (
+	"path/filepath"
+	"react-and-go/pkd/appuser/aumodel"
+	database "react-and-go/pkd/database"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"react-and-go/pkd/messaging/msgmodel"
+	"testing"
+)
+
+// the migration runs offline with sqlite, the postgres partitions are skipped
+func TestMigrateDBSqlite(t *testing.T) {
+	t.Setenv("DB_DRIVER", "sqlite")
+	t.Setenv("DB_PARAMS", filepath.Join(t.TempDir(), "test.db"))
+	database.ConnectToDB()
+	for run := 1; run <= 2; run++ {
+		MigrateDB()
+		tests := []struct {
+			name  string
+			model interface{}
+		}{
+			{"gas station", &gsmodel.GasStation{}},
+			{"gas price", &gsmodel.GasPrice{}},
+			{"gas price daily", &gsmodel.GasPriceDaily{}},
+			{"station import run", &gsmodel.StationImportRun{}},
+			{"app user", &aumodel.AppUser{}},
+			{"price message ledger", &msgmodel.PriceMessage{}},
+			{"dead letter", &msgmodel.DeadLetter{}},
+		}
+		for _, tt := range tests {
+			if !database.DB.Migrator().HasTable(tt.model) {
+				t.Errorf("MigrateDB() run %v table missing: %v", run, tt.name)
+			}
+		}
+		if !database.DB.Migrator().HasColumn(&gsmodel.GasStation{}, "ApplicableDays") {
+			t.Errorf("MigrateDB() run %v column missing: ApplicableDays", run)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package dbmigrate
+
+import (
+	"fmt"
+	"log"
+	database "react-and-go/pkd/database"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const PricePartitionMonthsAhead = 3
+
+// the primary key has to contain the partition key
+const createPartitionedPriceTable = `CREATE TABLE %v (
+	id bigint NOT NULL DEFAULT nextval('%v_id_seq'),
+	stid text,
+	e5 bigint,
+	e10 bigint,
+	diesel bigint,
+	date timestamptz NOT NULL,
+	changed bigint,
+	PRIMARY KEY (id, date)
+) PARTITION BY RANGE (date)`
+
+// an existing price table is converted only with DB_PRICE_PARTITIONS_CONVERT=true, the conversion copies all rows in one transaction
+func migratePricePartitions() {
+	myTableName := gsmodel.GasPrice{}.TableName()
+	if !database.DB.Migrator().HasTable(&gsmodel.GasPrice{}) {
+		if err := database.DB.Transaction(func(tx *gorm.DB) error {
+			return createPartitionedPrices(tx, myTableName)
+		}); err != nil {
+			log.Fatalf("Failed to create the partitioned price table: %v", err)
+		}
+	} else if !database.IsPartitioned(database.DB, myTableName) {
+		if !database.IsPartitionConversionEnabled() {
+			log.Printf("%v is not partitioned, set DB_PRICE_PARTITIONS_CONVERT=true to convert it into monthly partitions.", myTableName)
+			return
+		}
+		myStart := time.Now()
+		log.Printf("Converting %v into monthly partitions.", myTableName)
+		if err := database.DB.Transaction(func(tx *gorm.DB) error {
+			return convertToPartitionedPrices(tx, myTableName)
+		}); err != nil {
+			log.Fatalf("Failed to convert the price table into partitions: %v", err)
+		}
+		log.Printf("Converted %v into monthly partitions in %v. The old table is kept as %v.", myTableName, time.Since(myStart), unpartitionedTableName(myTableName))
+	}
+	if err := database.CreateDefaultPartition(database.DB, myTableName); err != nil {
+		log.Printf("Failed to create the default price partition: %v", err)
+	}
+	if err := CreateUpcomingPricePartitions(database.DB); err != nil {
+		log.Printf("Failed to create upcoming price partitions: %v", err)
+	}
+}
+
+// the old table and its indexes are kept to switch back until the table is dropped manually
+func unpartitionedTableName(tableName string) string {
+	return tableName + "_unpartitioned"
+}
+
+func CreateUpcomingPricePartitions(db *gorm.DB) error {
+	return database.CreateMonthlyPartitions(db, gsmodel.GasPrice{}.TableName(), time.Now(), time.Now().AddDate(0, PricePartitionMonthsAhead, 0))
+}
+
+func createPartitionedPrices(tx *gorm.DB, tableName string) error {
+	if err := tx.Exec(fmt.Sprintf("CREATE SEQUENCE IF NOT EXISTS %v_id_seq", tableName)).Error; err != nil {
+		return err
+	}
+	if err := tx.Exec(fmt.Sprintf(createPartitionedPriceTable, tableName, tableName)).Error; err != nil {
+		return err
+	}
+	if err := tx.Exec(fmt.Sprintf("ALTER SEQUENCE %v_id_seq OWNED BY %v.id", tableName, tableName)).Error; err != nil {
+		return err
+	}
+	if err := database.CreateDefaultPartition(tx, tableName); err != nil {
+		return err
+	}
+	if err := tx.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_stid ON %v (stid)", tableName)).Error; err != nil {
+		return err
+	}
+	return tx.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_date ON %v (date)", tableName)).Error
+}
+
+// the ids are kept, the sequence of the old table is moved to the partitioned table
+func convertToPartitionedPrices(tx *gorm.DB, tableName string) error {
+	myOldTableName := unpartitionedTableName(tableName)
+	if err := tx.Exec(fmt.Sprintf("ALTER TABLE %v RENAME TO %v", tableName, myOldTableName)).Error; err != nil {
+		return err
+	}
+	if err := tx.Exec(fmt.Sprintf("ALTER SEQUENCE IF EXISTS %v_id_seq OWNED BY NONE", tableName)).Error; err != nil {
+		return err
+	}
+	for _, myIndexName := range []string{"idx_stid", "idx_date"} {
+		if err := tx.Exec(fmt.Sprintf("ALTER INDEX IF EXISTS %v RENAME TO %v_unpartitioned", myIndexName, myIndexName)).Error; err != nil {
+			return err
+		}
+	}
+	if err := createPartitionedPrices(tx, tableName); err != nil {
+		return err
+	}
+	var myDateRange struct {
+		MinDate *time.Time
+		MaxDate *time.Time
+		RowNum  int64
+	}
+	tx.Raw(fmt.Sprintf("SELECT min(date) AS min_date, max(date) AS max_date, count(*) AS row_num FROM %v", myOldTableName)).Scan(&myDateRange)
+	if myDateRange.MinDate != nil && myDateRange.MaxDate != nil {
+		log.Printf("Creating the partitions from %v to %v.", myDateRange.MinDate.Format(time.DateOnly), myDateRange.MaxDate.Format(time.DateOnly))
+		if err := database.CreateMonthlyPartitions(tx, tableName, *myDateRange.MinDate, *myDateRange.MaxDate); err != nil {
+			return err
+		}
+	}
+	log.Printf("Copying %v rows into the partitions.", myDateRange.RowNum)
+	myCopyStart := time.Now()
+	myCopyResult := tx.Exec(fmt.Sprintf("INSERT INTO %v (id, stid, e5, e10, diesel, date, changed) SELECT id, stid, e5, e10, diesel, date, changed FROM %v WHERE date IS NOT NULL",
+		tableName, myOldTableName))
+	if myCopyResult.Error != nil {
+		return myCopyResult.Error
+	}
+	log.Printf("Copied %v rows in %v, rows without date are not copied.", myCopyResult.RowsAffected, time.Since(myCopyStart))
+	return tx.Exec(fmt.Sprintf("SELECT setval('%v_id_seq', (SELECT coalesce(max(id), 0) + 1 FROM %v), false)", tableName, tableName)).Error
+}
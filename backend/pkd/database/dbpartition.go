@@ -0,0 +1,151 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package database
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const partitionTimeFormat = "2006-01-02 15:04:05-07:00"
+
+type MonthlyPartition struct {
+	Name string
+	From time.Time
+	To   time.Time
+}
+
+// the monthly partitions are only supported by postgres and are switched on with DB_PRICE_PARTITIONS=true
+func IsPartitioningEnabled() bool {
+	return DB != nil && DB.Dialector.Name() == Postgres && isEnvTrue("DB_PRICE_PARTITIONS")
+}
+
+// an existing price table is only converted into partitions with DB_PRICE_PARTITIONS_CONVERT=true
+func IsPartitionConversionEnabled() bool {
+	return IsPartitioningEnabled() && isEnvTrue("DB_PRICE_PARTITIONS_CONVERT")
+}
+
+func isEnvTrue(name string) bool {
+	return strings.ToLower(strings.TrimSpace(os.Getenv(name))) == "true"
+}
+
+func DefaultPartitionName(tableName string) string {
+	return tableName + "_default"
+}
+
+// the rows outside of the monthly partitions like late or early timestamps are stored in the default partition
+func CreateDefaultPartition(db *gorm.DB, tableName string) error {
+	return db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %v PARTITION OF %v DEFAULT", DefaultPartitionName(tableName), tableName)).Error
+}
+
+func IsPartitioned(db *gorm.DB, tableName string) bool {
+	if db.Dialector.Name() != Postgres {
+		return false
+	}
+	var partitionedNum int64
+	db.Raw("SELECT count(*) FROM pg_partitioned_table pt JOIN pg_class c ON c.oid = pt.partrelid WHERE c.relname = ?", tableName).Scan(&partitionedNum)
+	return partitionedNum > 0
+}
+
+func MonthStart(myTime time.Time) time.Time {
+	myLocalTime := myTime.In(time.Local)
+	return time.Date(myLocalTime.Year(), myLocalTime.Month(), 1, 0, 0, 0, 0, time.Local)
+}
+
+// creates the missing monthly partitions for the months from 'from' to 'to' including both
+func CreateMonthlyPartitions(db *gorm.DB, tableName string, from time.Time, to time.Time) error {
+	for myMonth := MonthStart(from); !myMonth.After(MonthStart(to)); myMonth = myMonth.AddDate(0, 1, 0) {
+		myPartition := createMonthlyPartition(tableName, myMonth)
+		if db.Migrator().HasTable(myPartition.Name) {
+			continue
+		}
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			return attachMonthlyPartition(tx, tableName, myPartition)
+		}); err != nil {
+			return fmt.Errorf("failed to create partition %v: %v", myPartition.Name, err)
+		}
+	}
+	return nil
+}
+
+// postgres rejects a new partition while the default partition has rows of its range, the rows are moved into the new partition before it is attached
+func attachMonthlyPartition(tx *gorm.DB, tableName string, partition MonthlyPartition) error {
+	myFrom := partition.From.Format(partitionTimeFormat)
+	myTo := partition.To.Format(partitionTimeFormat)
+	if err := tx.Exec(fmt.Sprintf("CREATE TABLE %v (LIKE %v INCLUDING DEFAULTS INCLUDING CONSTRAINTS)", partition.Name, tableName)).Error; err != nil {
+		return err
+	}
+	if myDefaultName := DefaultPartitionName(tableName); tx.Migrator().HasTable(myDefaultName) {
+		myMoveResult := tx.Exec(fmt.Sprintf("INSERT INTO %v SELECT * FROM %v WHERE date >= '%v' AND date < '%v'", partition.Name, myDefaultName, myFrom, myTo))
+		if myMoveResult.Error != nil {
+			return myMoveResult.Error
+		}
+		if err := tx.Exec(fmt.Sprintf("DELETE FROM %v WHERE date >= '%v' AND date < '%v'", myDefaultName, myFrom, myTo)).Error; err != nil {
+			return err
+		}
+		if myMoveResult.RowsAffected > 0 {
+			log.Printf("Moved %v rows from %v into %v.\n", myMoveResult.RowsAffected, myDefaultName, partition.Name)
+		}
+	}
+	return tx.Exec(fmt.Sprintf("ALTER TABLE %v ATTACH PARTITION %v FOR VALUES FROM ('%v') TO ('%v')", tableName, partition.Name, myFrom, myTo)).Error
+}
+
+func FindMonthlyPartitions(db *gorm.DB, tableName string) []MonthlyPartition {
+	var partitionNames []string
+	db.Raw("SELECT c.relname FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid JOIN pg_class p ON p.oid = i.inhparent WHERE p.relname = ? ORDER BY c.relname",
+		tableName).Scan(&partitionNames)
+	var result []MonthlyPartition
+	for _, myPartitionName := range partitionNames {
+		if myPartitionName == DefaultPartitionName(tableName) {
+			continue
+		}
+		myMonth, err := time.ParseInLocation("2006_01", strings.TrimPrefix(myPartitionName, tableName+"_"), time.Local)
+		if err != nil {
+			log.Printf("Partition %v has no monthly name.\n", myPartitionName)
+			continue
+		}
+		result = append(result, createMonthlyPartition(tableName, myMonth))
+	}
+	return result
+}
+
+// drops the partitions that contain only rows before 'before', the rows of the month of 'before' are kept
+// the rows before 'before' in the default partition are deleted
+func DropMonthlyPartitionsBefore(db *gorm.DB, tableName string, before time.Time) (int, error) {
+	if myDefaultName := DefaultPartitionName(tableName); db.Migrator().HasTable(myDefaultName) {
+		if err := db.Exec(fmt.Sprintf("DELETE FROM %v WHERE date < ?", myDefaultName), before).Error; err != nil {
+			return 0, fmt.Errorf("failed to delete the rows of %v: %v", myDefaultName, err)
+		}
+	}
+	droppedNum := 0
+	for _, myPartition := range FindMonthlyPartitions(db, tableName) {
+		if myPartition.To.After(before) {
+			continue
+		}
+		if err := db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %v", myPartition.Name)).Error; err != nil {
+			return droppedNum, fmt.Errorf("failed to drop partition %v: %v", myPartition.Name, err)
+		}
+		droppedNum += 1
+	}
+	return droppedNum, nil
+}
+
+func createMonthlyPartition(tableName string, month time.Time) MonthlyPartition {
+	myMonth := MonthStart(month)
+	return MonthlyPartition{Name: fmt.Sprintf("%v_%04d_%02d", tableName, myMonth.Year(), myMonth.Month()), From: myMonth, To: myMonth.AddDate(0, 1, 0)}
+}
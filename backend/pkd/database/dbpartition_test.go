@@ -0,0 +1,64 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsEnvTrue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  bool
+	}{
+		{"unset", "", false},
+		{"true", "true", true},
+		{"upper case with blanks", " TRUE ", true},
+		{"false", "false", false},
+		{"other value", "yes", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DB_PARTITION_TEST", tt.value)
+			if got := isEnvTrue("DB_PARTITION_TEST"); got != tt.want {
+				t.Errorf("isEnvTrue() = %v want: %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateMonthlyPartition(t *testing.T) {
+	tests := []struct {
+		name     string
+		month    time.Time
+		wantName string
+		wantFrom time.Time
+		wantTo   time.Time
+	}{
+		{"month start", time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local), "prices_2024_03", time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local), time.Date(2024, 4, 1, 0, 0, 0, 0, time.Local)},
+		{"month end", time.Date(2024, 12, 31, 23, 59, 0, 0, time.Local), "prices_2024_12", time.Date(2024, 12, 1, 0, 0, 0, 0, time.Local), time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := createMonthlyPartition("prices", tt.month)
+			if got.Name != tt.wantName || !got.From.Equal(tt.wantFrom) || !got.To.Equal(tt.wantTo) {
+				t.Errorf("createMonthlyPartition() = %+v want: %v %v %v", got, tt.wantName, tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+	if got := DefaultPartitionName("prices"); got != "prices_default" {
+		t.Errorf("DefaultPartitionName() = %v want: prices_default", got)
+	}
+}
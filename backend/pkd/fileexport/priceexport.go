@@ -0,0 +1,156 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package fileexport
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"react-and-go/pkd/gasstation"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+type ExportFormat string
+
+const (
+	Csv     ExportFormat = "csv"
+	Parquet ExportFormat = "parquet"
+)
+
+const exportBatchSize = 1000
+
+// the columns of the Tankerkoenig price files, the csv export can be imported again
+type priceRow struct {
+	Date         time.Time `parquet:"date,timestamp(millisecond:utc)"`
+	StationUuid  string    `parquet:"station_uuid,dict"`
+	Diesel       float64   `parquet:"diesel"`
+	E5           float64   `parquet:"e5"`
+	E10          float64   `parquet:"e10"`
+	DieselChange int32     `parquet:"dieselchange"`
+	E5Change     int32     `parquet:"e5change"`
+	E10Change    int32     `parquet:"e10change"`
+}
+
+var csvHeader = []string{"date", "station_uuid", "diesel", "e5", "e10", "dieselchange", "e5change", "e10change"}
+
+func ParseExportFormat(formatStr string) (ExportFormat, error) {
+	switch ExportFormat(strings.ToLower(strings.TrimSpace(formatStr))) {
+	case Csv, "":
+		return Csv, nil
+	case Parquet:
+		return Parquet, nil
+	}
+	return "", fmt.Errorf("unsupported export format: %v", formatStr)
+}
+
+func (format ExportFormat) ContentType() string {
+	if format == Parquet {
+		return "application/vnd.apache.parquet"
+	}
+	return "text/csv"
+}
+
+type PriceExporter struct {
+	gasStationService *gasstation.GasStationService
+}
+
+func NewPriceExporter(gasStationService *gasstation.GasStationService) *PriceExporter {
+	return &PriceExporter{gasStationService: gasStationService}
+}
+
+// writes the prices batch by batch, the rows are never loaded at once
+func (exporter *PriceExporter) ExportPrices(writer io.Writer, format ExportFormat, filter gasstation.PriceExportFilter) (int, error) {
+	if format == Parquet {
+		return exporter.exportParquet(writer, filter)
+	}
+	return exporter.exportCsv(writer, filter)
+}
+
+func (exporter *PriceExporter) exportCsv(writer io.Writer, filter gasstation.PriceExportFilter) (int, error) {
+	csvWriter := csv.NewWriter(writer)
+	if err := csvWriter.Write(csvHeader); err != nil {
+		return 0, err
+	}
+	rowNum := 0
+	err := exporter.gasStationService.StreamPrices(filter, func(gasPrice gsmodel.GasPrice) error {
+		myPriceRow := createPriceRow(gasPrice)
+		if err := csvWriter.Write([]string{myPriceRow.Date.Format("2006-01-02 15:04:05-07"), myPriceRow.StationUuid, formatPrice(myPriceRow.Diesel),
+			formatPrice(myPriceRow.E5), formatPrice(myPriceRow.E10), strconv.Itoa(int(myPriceRow.DieselChange)), strconv.Itoa(int(myPriceRow.E5Change)),
+			strconv.Itoa(int(myPriceRow.E10Change))}); err != nil {
+			return err
+		}
+		rowNum += 1
+		if rowNum%exportBatchSize == 0 {
+			csvWriter.Flush()
+			return csvWriter.Error()
+		}
+		return nil
+	})
+	csvWriter.Flush()
+	if err == nil {
+		err = csvWriter.Error()
+	}
+	return rowNum, err
+}
+
+func (exporter *PriceExporter) exportParquet(writer io.Writer, filter gasstation.PriceExportFilter) (int, error) {
+	//the row groups are limited to keep the buffered rows small
+	parquetWriter := parquet.NewGenericWriter[priceRow](writer, parquet.Compression(&parquet.Snappy), parquet.MaxRowsPerRowGroup(100000))
+	rowNum := 0
+	priceRows := make([]priceRow, 0, exportBatchSize)
+	writeRows := func() error {
+		if len(priceRows) == 0 {
+			return nil
+		}
+		_, err := parquetWriter.Write(priceRows)
+		rowNum += len(priceRows)
+		priceRows = priceRows[:0]
+		return err
+	}
+	err := exporter.gasStationService.StreamPrices(filter, func(gasPrice gsmodel.GasPrice) error {
+		priceRows = append(priceRows, createPriceRow(gasPrice))
+		if len(priceRows) >= exportBatchSize {
+			return writeRows()
+		}
+		return nil
+	})
+	if err == nil {
+		err = writeRows()
+	}
+	if closeErr := parquetWriter.Close(); err == nil {
+		err = closeErr
+	}
+	return rowNum, err
+}
+
+func createPriceRow(gasPrice gsmodel.GasPrice) priceRow {
+	return priceRow{Date: gasPrice.Date, StationUuid: gasPrice.GasStationID, Diesel: float64(gasPrice.Diesel) / 1000, E5: float64(gasPrice.E5) / 1000,
+		E10: float64(gasPrice.E10) / 1000, DieselChange: changedFlag(gasPrice.Changed, 1), E5Change: changedFlag(gasPrice.Changed, 4),
+		E10Change: changedFlag(gasPrice.Changed, 16)}
+}
+
+func changedFlag(changed int, changedBit int) int32 {
+	if changed&changedBit > 0 {
+		return 1
+	}
+	return 0
+}
+
+func formatPrice(price float64) string {
+	return strconv.FormatFloat(price, 'f', 3, 64)
+}
@@ -0,0 +1,125 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package fileexport
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"react-and-go/pkd/gasstation"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"react-and-go/pkd/postcode"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func newTestPriceExporter(gasPrices []gsmodel.GasPrice) *PriceExporter {
+	myRepo := gasstation.NewGasStationMemRepo()
+	myRepo.SaveGasStations([]gsmodel.GasStation{{ID: "stid1", PostCode: "20095"}, {ID: "stid2", PostCode: "20097"}})
+	myRepo.SavePrices(gasPrices)
+	return NewPriceExporter(gasstation.NewGasStationService(myRepo, postcode.NewPostCodeMemRepo(), nil))
+}
+
+func createTestPrices(day time.Time) []gsmodel.GasPrice {
+	return []gsmodel.GasPrice{
+		{GasStationID: "stid1", E5: 1859, E10: 1799, Diesel: 1689, Date: day.Add(8 * time.Hour), Changed: 21},
+		{GasStationID: "stid1", E5: 1849, E10: 1799, Diesel: 1689, Date: day.Add(9 * time.Hour), Changed: 4},
+		{GasStationID: "stid2", E5: 1869, E10: 1809, Diesel: 1679, Date: day.Add(10 * time.Hour), Changed: 17},
+		{GasStationID: "stid2", E5: 1869, E10: 1809, Diesel: 1679, Date: day.AddDate(0, 0, 2), Changed: 1},
+	}
+}
+
+func TestExportCsv(t *testing.T) {
+	myDay := time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, -5)
+	myExporter := newTestPriceExporter(createTestPrices(myDay))
+	tests := []struct {
+		name     string
+		filter   gasstation.PriceExportFilter
+		wantRows [][]string
+		wantErr  error
+	}{
+		{"stations", gasstation.PriceExportFilter{Stids: []string{"stid1", "stid2"}, From: myDay, To: myDay.AddDate(0, 0, 1)}, [][]string{csvHeader,
+			{myDay.Add(8 * time.Hour).Format("2006-01-02 15:04:05-07"), "stid1", "1.689", "1.859", "1.799", "1", "1", "1"},
+			{myDay.Add(9 * time.Hour).Format("2006-01-02 15:04:05-07"), "stid1", "1.689", "1.849", "1.799", "0", "1", "0"},
+			{myDay.Add(10 * time.Hour).Format("2006-01-02 15:04:05-07"), "stid2", "1.679", "1.869", "1.809", "1", "0", "1"}}, nil},
+		{"postcode", gasstation.PriceExportFilter{PostCodes: []string{"20097"}, From: myDay.AddDate(0, 0, 1), To: myDay.AddDate(0, 0, 3)}, [][]string{csvHeader,
+			{myDay.AddDate(0, 0, 2).Format("2006-01-02 15:04:05-07"), "stid2", "1.679", "1.869", "1.809", "1", "0", "0"}}, nil},
+		{"no prices", gasstation.PriceExportFilter{Stids: []string{"stid1"}, From: myDay.AddDate(0, 0, 1), To: myDay.AddDate(0, 0, 2)}, [][]string{csvHeader}, nil},
+		{"before retention", gasstation.PriceExportFilter{Stids: []string{"stid1"}, From: myDay.AddDate(0, 0, -60), To: myDay}, [][]string{csvHeader}, gasstation.ErrExportBeforeRetention},
+		{"invalid filter", gasstation.PriceExportFilter{From: myDay, To: myDay.AddDate(0, 0, 1)}, [][]string{csvHeader}, gasstation.ErrInvalidExportFilter},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var myBuffer bytes.Buffer
+			rowNum, err := myExporter.ExportPrices(&myBuffer, Csv, tt.filter)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ExportPrices() error = %v want: %v", err, tt.wantErr)
+			}
+			if rowNum != len(tt.wantRows)-1 {
+				t.Errorf("ExportPrices() rows = %v want: %v", rowNum, len(tt.wantRows)-1)
+			}
+			myRows, err := csv.NewReader(&myBuffer).ReadAll()
+			if err != nil {
+				t.Fatalf("ReadAll() failed: %v", err)
+			}
+			if len(myRows) != len(tt.wantRows) {
+				t.Fatalf("ExportPrices() = %v want: %v", myRows, tt.wantRows)
+			}
+			for index := range myRows {
+				for column := range myRows[index] {
+					if myRows[index][column] != tt.wantRows[index][column] {
+						t.Errorf("ExportPrices() row %v = %v want: %v", index, myRows[index], tt.wantRows[index])
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestExportParquet(t *testing.T) {
+	myDay := time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, -5)
+	myPrices := createTestPrices(myDay)
+	//more rows than a batch
+	for index := 0; index < exportBatchSize+10; index++ {
+		myPrices = append(myPrices, gsmodel.GasPrice{GasStationID: "stid1", E5: 1800 + index%50, E10: 1750, Diesel: 1650,
+			Date: myDay.AddDate(0, 0, 1).Add(time.Duration(index) * time.Second), Changed: 4})
+	}
+	myExporter := newTestPriceExporter(myPrices)
+	var myBuffer bytes.Buffer
+	rowNum, err := myExporter.ExportPrices(&myBuffer, Parquet, gasstation.PriceExportFilter{Stids: []string{"stid1", "stid2"}, From: myDay, To: myDay.AddDate(0, 0, 3)})
+	if err != nil {
+		t.Fatalf("ExportPrices() failed: %v", err)
+	}
+	if rowNum != len(myPrices) {
+		t.Errorf("ExportPrices() rows = %v want: %v", rowNum, len(myPrices))
+	}
+	myRows, err := parquet.Read[priceRow](bytes.NewReader(myBuffer.Bytes()), int64(myBuffer.Len()))
+	if err != nil {
+		t.Fatalf("parquet.Read() failed: %v", err)
+	}
+	if len(myRows) != len(myPrices) {
+		t.Fatalf("parquet.Read() rows = %v want: %v", len(myRows), len(myPrices))
+	}
+	wantFirst := priceRow{Date: myDay.Add(8 * time.Hour), StationUuid: "stid1", Diesel: 1.689, E5: 1.859, E10: 1.799, DieselChange: 1, E5Change: 1, E10Change: 1}
+	if myRows[0].StationUuid != wantFirst.StationUuid || !myRows[0].Date.Equal(wantFirst.Date) || myRows[0].Diesel != wantFirst.Diesel ||
+		myRows[0].E5 != wantFirst.E5 || myRows[0].E10 != wantFirst.E10 || myRows[0].DieselChange != wantFirst.DieselChange ||
+		myRows[0].E5Change != wantFirst.E5Change || myRows[0].E10Change != wantFirst.E10Change {
+		t.Errorf("parquet.Read() first row = %+v want: %+v", myRows[0], wantFirst)
+	}
+	if myLast := myRows[len(myRows)-1]; myLast.StationUuid != "stid2" || myLast.DieselChange != 1 || myLast.E5Change != 0 {
+		t.Errorf("parquet.Read() last row = %+v", myLast)
+	}
+}
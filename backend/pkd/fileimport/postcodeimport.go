@@ -21,6 +21,7 @@ import (
 	"os"
 	"react-and-go/pkd/gasstation"
 	"react-and-go/pkd/gasstation/gsmodel"
+	"react-and-go/pkd/importdiff"
 	"react-and-go/pkd/postcode"
 	"react-and-go/pkd/postcode/pcmodel"
 	"strings"
@@ -48,14 +49,48 @@ type plzContainer struct {
 	Geometry   plzPolygon    `json:"geometry"`
 }
 
-func UpdatePostCodeCoordinates(fileName string) {
-	gzReader, file, err := createReader(fileName)
-	defer gzReader.Close()
-	defer file.Close()
+type PostCodeImporter struct {
+	postCodeService   *postcode.PostCodeService
+	gasStationService *gasstation.GasStationService
+	diffStore         *importdiff.DiffStore
+}
 
+func NewPostCodeImporter(postCodeService *postcode.PostCodeService, gasStationService *gasstation.GasStationService,
+	diffStore *importdiff.DiffStore) *PostCodeImporter {
+	return &PostCodeImporter{postCodeService: postCodeService, gasStationService: gasStationService, diffStore: diffStore}
+}
+
+func (importer *PostCodeImporter) UpdatePostCodeCoordinates(fileName string) {
+	myPostCodeData, err := readPostCodeData(fileName)
 	if err != nil {
 		return
 	}
+	importer.postCodeService.ImportPostCodeData(myPostCodeData)
+}
+
+// the diff is applied with the DiffStore
+func (importer *PostCodeImporter) DiffPostCodeCoordinates(fileName string) (importdiff.ImportDiff, error) {
+	myPostCodeData, err := readPostCodeData(fileName)
+	if err != nil {
+		return importdiff.ImportDiff{}, err
+	}
+	myImportDiff := importer.postCodeService.DiffPostCodeData(myPostCodeData, fileName)
+	return importer.diffStore.Add(myImportDiff, func(importDiff importdiff.ImportDiff) error {
+		if !importDiff.Matches(importer.postCodeService.DiffPostCodeData(myPostCodeData, fileName)) {
+			return importdiff.ErrDiffOutdated
+		}
+		importer.postCodeService.ImportPostCodeData(myPostCodeData)
+		return nil
+	}), nil
+}
+
+func readPostCodeData(fileName string) ([]postcode.PostCodeData, error) {
+	gzReader, file, err := createReader(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer gzReader.Close()
+	defer file.Close()
 
 	jsonDecoder := json.NewDecoder(gzReader)
 	plzContainerNumber := 0
@@ -72,14 +107,40 @@ func UpdatePostCodeCoordinates(fileName string) {
 	}
 	jsonDecoder.Token()
 	//log.Printf("Number of postcodes: %v\n", plzContainerNumber)
-	postcode.ImportPostCodeData(result)
+	return result, nil
 }
 
-func UpdateStatesAndCounties(fileName string) {
-	gzReader, file, err := createReader(fileName)
+func (importer *PostCodeImporter) UpdateStatesAndCounties(fileName string) {
+	plzToState, plzToCounty, plzs, err := readStatesAndCounties(fileName)
 	if err != nil {
 		return
 	}
+	importer.postCodeService.UpdateStatesCounties(plzToState, plzToCounty)
+	go importer.updateCountyStatePrices(plzs)
+}
+
+// the diff is applied with the DiffStore
+func (importer *PostCodeImporter) DiffStatesAndCounties(fileName string) (importdiff.ImportDiff, error) {
+	plzToState, plzToCounty, plzs, err := readStatesAndCounties(fileName)
+	if err != nil {
+		return importdiff.ImportDiff{}, err
+	}
+	myImportDiff := importer.postCodeService.DiffStatesCounties(plzToState, plzToCounty, fileName)
+	return importer.diffStore.Add(myImportDiff, func(importDiff importdiff.ImportDiff) error {
+		if !importDiff.Matches(importer.postCodeService.DiffStatesCounties(plzToState, plzToCounty, fileName)) {
+			return importdiff.ErrDiffOutdated
+		}
+		importer.postCodeService.UpdateStatesCounties(plzToState, plzToCounty)
+		go importer.updateCountyStatePrices(plzs)
+		return nil
+	}), nil
+}
+
+func readStatesAndCounties(fileName string) (map[string]string, map[string]string, []string, error) {
+	gzReader, file, err := createReader(fileName)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
 	defer gzReader.Close()
 	defer file.Close()
@@ -93,7 +154,7 @@ func UpdateStatesAndCounties(fileName string) {
 	for scanner.Scan() {
 		line := scanner.Text()
 		lineTokens := strings.Split(line, ",")
-		if lineId == 0 || len(lineTokens) < 5 {
+		if lineId == 0 || len(lineTokens) < 6 {
 			lineId += 1
 			continue
 		}
@@ -112,9 +173,7 @@ func UpdateStatesAndCounties(fileName string) {
 		}
 		lineId += 1
 	}
-
-	postcode.UpdateStatesCounties(plzToState, plzToCounty)
-	go updateCountyStatePrices(plzs)
+	return plzToState, plzToCounty, plzs, nil
 }
 
 func createReader(fileName string) (*gzip.Reader, *os.File, error) {
@@ -180,8 +239,8 @@ func calcPolygonArea(plzContainer *plzContainer) float64 {
 	return polygonArea
 }
 
-func updateCountyStatePrices(plzs []string) {
-	myGasStations := gasstation.FindByPostCodes(plzs)
+func (importer *PostCodeImporter) updateCountyStatePrices(plzs []string) {
+	myGasStations := importer.gasStationService.FindByPostCodes(plzs)
 	log.Printf("Gasstations: %v", len(myGasStations))
 	plzGasStation := make(map[string][]gsmodel.GasStation)
 	var gasStationStids []string
@@ -189,12 +248,12 @@ func updateCountyStatePrices(plzs []string) {
 		gasStationStids = append(gasStationStids, myGasStation.ID)
 		plzGasStation[myGasStation.PostCode] = append(plzGasStation[myGasStation.PostCode], myGasStation)
 	}
-	myGasPrices := gasstation.FindPricesByStids(&gasStationStids, 5, gasstation.Month, false)
+	myGasPrices := importer.gasStationService.FindPricesByStids(&gasStationStids, 5, gasstation.Month, false)
 	gasStationIdGasPrices := make(map[string][]gsmodel.GasPrice)
 	for _, myGasPrice := range myGasPrices {
 		gasStationIdGasPrices[myGasPrice.GasStationID] = append(gasStationIdGasPrices[myGasPrice.GasStationID], myGasPrice)
 	}
-	postCodeLocations := postcode.FindByPlzs(plzs)
+	postCodeLocations := importer.postCodeService.FindByPlzs(plzs)
 	var stateDatas []pcmodel.StateData
 	for _, myPostCodeLocation := range *postCodeLocations {
 		stateDatas = append(stateDatas, myPostCodeLocation.StateData)
@@ -0,0 +1,226 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package aufile
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"react-and-go/pkd/gasstation"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const priceFileTimeLayout = "2006-01-02 15:04:05-07"
+
+var ErrPriceImportRunning = errors.New("price import is running")
+
+type PriceImportResult struct {
+	Files        int
+	SkippedFiles int
+	Prices       int
+	InvalidRows  int
+	Unknown      int
+}
+
+type priceFile struct {
+	Path     string
+	FileName string
+	Day      time.Time
+}
+
+type PriceImporter struct {
+	gasStationService *gasstation.GasStationService
+	running           atomic.Bool
+}
+
+func NewPriceImporter(gasStationService *gasstation.GasStationService) *PriceImporter {
+	return &PriceImporter{gasStationService: gasStationService}
+}
+
+// imports the price files 'prices/YYYY/MM/YYYY-MM-DD-prices.csv' of PRICE_IMPORT_PATH from day 'from' to day 'to', zero times are open ends.
+// imported files are recorded and skipped, a failed import can be restarted.
+func (importer *PriceImporter) ImportPrices(from time.Time, to time.Time) (PriceImportResult, error) {
+	if !importer.running.CompareAndSwap(false, true) {
+		return PriceImportResult{}, ErrPriceImportRunning
+	}
+	defer importer.running.Store(false)
+	return importer.importPrices(from, to)
+}
+
+func (importer *PriceImporter) StartImportPrices(from time.Time, to time.Time) error {
+	if !importer.running.CompareAndSwap(false, true) {
+		return ErrPriceImportRunning
+	}
+	go func() {
+		defer importer.running.Store(false)
+		if _, err := importer.importPrices(from, to); err != nil {
+			log.Printf("ImportPrices failed: %v\n", err)
+		}
+	}()
+	return nil
+}
+
+func (importer *PriceImporter) importPrices(from time.Time, to time.Time) (PriceImportResult, error) {
+	result := PriceImportResult{}
+	myStart := time.Now()
+	priceFiles, err := findPriceFiles(strings.TrimSpace(os.Getenv("PRICE_IMPORT_PATH")), from, to)
+	if err != nil {
+		return result, err
+	}
+	importedFileNames := make(map[string]bool)
+	for _, myPriceImportFile := range importer.gasStationService.FindPriceImportFiles() {
+		importedFileNames[myPriceImportFile.FileName] = true
+	}
+	var importState *gasstation.PriceImportState
+	for _, myPriceFile := range priceFiles {
+		if importedFileNames[myPriceFile.FileName] {
+			result.SkippedFiles += 1
+			//the latest prices have to be reloaded after the skipped days
+			importState = nil
+			continue
+		}
+		if importState == nil {
+			importState = importer.gasStationService.CreatePriceImportState(myPriceFile.Day)
+		}
+		gasStationPrices, invalidRows, err := readPriceFile(myPriceFile.Path)
+		if err != nil {
+			return result, err
+		}
+		myPriceImportFile, err := importer.gasStationService.ImportPrices(myPriceFile.FileName, gasStationPrices, invalidRows, importState)
+		if err != nil {
+			return result, fmt.Errorf("failed to import file %v: %v", myPriceFile.FileName, err)
+		}
+		log.Printf("Price file %v imported, rows: %v prices: %v invalid: %v unknown: %v\n", myPriceImportFile.FileName, myPriceImportFile.Rows,
+			myPriceImportFile.Prices, myPriceImportFile.InvalidRows, myPriceImportFile.Unknown)
+		result.Files += 1
+		result.Prices += myPriceImportFile.Prices
+		result.InvalidRows += myPriceImportFile.InvalidRows
+		result.Unknown += myPriceImportFile.Unknown
+	}
+	if result.Prices > 0 {
+		importer.gasStationService.ReCalcCountyStatePrices()
+		importer.gasStationService.CalcCountyTimeSlots()
+	}
+	log.Printf("ImportPrices finished for %v files with %v prices in %v.\n", result.Files, result.Prices, time.Since(myStart))
+	return result, nil
+}
+
+// returns the price files ordered by day
+func findPriceFiles(basePath string, from time.Time, to time.Time) ([]priceFile, error) {
+	filePaths, err := filepath.Glob(filepath.Join(basePath, "prices", "*", "*", "*-prices.csv"))
+	if err != nil {
+		return nil, err
+	}
+	result := []priceFile{}
+	for _, myFilePath := range filePaths {
+		myDay, err := time.ParseInLocation(time.DateOnly, strings.TrimSuffix(filepath.Base(myFilePath), "-prices.csv"), time.Local)
+		if err != nil {
+			log.Printf("Price file %v has no date.\n", myFilePath)
+			continue
+		}
+		if (!from.IsZero() && myDay.Before(from)) || (!to.IsZero() && myDay.After(to)) {
+			continue
+		}
+		myFileName, err := filepath.Rel(basePath, myFilePath)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, priceFile{Path: myFilePath, FileName: filepath.ToSlash(myFileName), Day: myDay})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Day.Before(result[j].Day)
+	})
+	return result, nil
+}
+
+// the columns are found by the header, rows that can not be parsed are counted as invalid
+func readPriceFile(filePath string) ([]gasstation.GasStationPrices, int, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open file %v: %v", filePath, err)
+	}
+	defer file.Close()
+	csvReader := csv.NewReader(bufio.NewReader(file))
+	csvReader.FieldsPerRecord = -1
+	csvReader.ReuseRecord = true
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read header of %v: %v", filePath, err)
+	}
+	columnIndexes := make(map[string]int)
+	for index, myColumn := range header {
+		columnIndexes[strings.ToLower(strings.TrimSpace(myColumn))] = index
+	}
+	for _, myColumn := range []string{"date", "station_uuid", "diesel", "e5", "e10"} {
+		if _, ok := columnIndexes[myColumn]; !ok {
+			return nil, 0, fmt.Errorf("column %v missing in %v", myColumn, filePath)
+		}
+	}
+	result := []gasstation.GasStationPrices{}
+	invalidRows := 0
+	for {
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, invalidRows, fmt.Errorf("failed to read %v: %v", filePath, err)
+		}
+		myGasStationPrices, ok := createGasStationPrices(row, columnIndexes)
+		if !ok {
+			invalidRows += 1
+			continue
+		}
+		result = append(result, myGasStationPrices)
+	}
+	return result, invalidRows, nil
+}
+
+func createGasStationPrices(row []string, columnIndexes map[string]int) (gasstation.GasStationPrices, bool) {
+	for _, myIndex := range columnIndexes {
+		if myIndex >= len(row) {
+			return gasstation.GasStationPrices{}, false
+		}
+	}
+	myTimestamp, err := time.Parse(priceFileTimeLayout, strings.TrimSpace(row[columnIndexes["date"]]))
+	if err != nil {
+		return gasstation.GasStationPrices{}, false
+	}
+	myStid := strings.TrimSpace(row[columnIndexes["station_uuid"]])
+	myDiesel, err1 := parsePrice(row[columnIndexes["diesel"]])
+	myE5, err2 := parsePrice(row[columnIndexes["e5"]])
+	myE10, err3 := parsePrice(row[columnIndexes["e10"]])
+	if len(myStid) == 0 || err1 != nil || err2 != nil || err3 != nil {
+		return gasstation.GasStationPrices{}, false
+	}
+	return gasstation.GasStationPrices{GasStationID: myStid, E5: myE5, E10: myE10, Diesel: myDiesel, Timestamp: myTimestamp}, true
+}
+
+// the prices are stored in thousandths
+func parsePrice(priceStr string) (int, error) {
+	myPrice, err := strconv.ParseFloat(strings.TrimSpace(priceStr), 64)
+	if err != nil {
+		return 0, err
+	}
+	return int(math.Round(myPrice * 1000)), nil
+}
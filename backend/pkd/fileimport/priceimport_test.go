@@ -0,0 +1,176 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package aufile
+
+import (
+	"path/filepath"
+	"react-and-go/pkd/gasstation"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"react-and-go/pkd/postcode"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPriceHeader = "date,station_uuid,diesel,e5,e10,dieselchange,e5change,e10change"
+
+func writeTestPriceFile(t *testing.T, basePath string, day string, rows ...string) {
+	writeTestStationFile(t, filepath.Join(basePath, "prices", day[:4], day[5:7], day+"-prices.csv"), strings.Join(rows, "\n"))
+}
+
+func TestCreateGasStationPrices(t *testing.T) {
+	myColumnIndexes := map[string]int{"date": 0, "station_uuid": 1, "diesel": 2, "e5": 3, "e10": 4}
+	tests := []struct {
+		name   string
+		row    string
+		want   gasstation.GasStationPrices
+		wantOk bool
+	}{
+		{"valid", "2023-05-04 10:00:05+02,stid1,1.689,1.859,1.799", gasstation.GasStationPrices{GasStationID: "stid1", Diesel: 1689, E5: 1859, E10: 1799,
+			Timestamp: time.Date(2023, time.May, 4, 8, 0, 5, 0, time.UTC)}, true},
+		{"rounded to thousandths", "2023-05-04 10:00:05+02,stid1,1.6889,1.8591,0", gasstation.GasStationPrices{GasStationID: "stid1", Diesel: 1689, E5: 1859,
+			E10: 0, Timestamp: time.Date(2023, time.May, 4, 8, 0, 5, 0, time.UTC)}, true},
+		{"spaces", " 2023-01-02 03:04:05+01 , stid1 , 1.5 , 1.6 , 1.7 ", gasstation.GasStationPrices{GasStationID: "stid1", Diesel: 1500, E5: 1600, E10: 1700,
+			Timestamp: time.Date(2023, time.January, 2, 2, 4, 5, 0, time.UTC)}, true},
+		{"iso date", "2023-05-04T10:00:05Z,stid1,1.689,1.859,1.799", gasstation.GasStationPrices{}, false},
+		{"date without zone", "2023-05-04 10:00:05,stid1,1.689,1.859,1.799", gasstation.GasStationPrices{}, false},
+		{"missing stid", "2023-05-04 10:00:05+02,,1.689,1.859,1.799", gasstation.GasStationPrices{}, false},
+		{"invalid price", "2023-05-04 10:00:05+02,stid1,1.689,n/a,1.799", gasstation.GasStationPrices{}, false},
+		{"short row", "2023-05-04 10:00:05+02,stid1,1.689", gasstation.GasStationPrices{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := createGasStationPrices(strings.Split(tt.row, ","), myColumnIndexes)
+			if ok != tt.wantOk {
+				t.Fatalf("createGasStationPrices() ok = %v want: %v", ok, tt.wantOk)
+			}
+			if got.GasStationID != tt.want.GasStationID || got.Diesel != tt.want.Diesel || got.E5 != tt.want.E5 || got.E10 != tt.want.E10 ||
+				!got.Timestamp.Equal(tt.want.Timestamp) {
+				t.Errorf("createGasStationPrices() = %+v want: %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadPriceFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		wantPrices  int
+		wantInvalid int
+		wantErr     bool
+	}{
+		{"price file", testPriceHeader + "\n2023-05-04 10:00:05+02,stid1,1.689,1.859,1.799,1,1,1", 1, 0, false},
+		{"reordered header", " E5 ,Date,e10,station_uuid,diesel\n1.859,2023-05-04 10:00:05+02,1.799,stid1,1.689", 1, 0, false},
+		{"invalid rows", testPriceHeader + "\n2023-05-04 10:00:05+02,stid1,1.689,1.859,1.799,1,1,1\n2023-05-04,stid2,1.689,1.859,1.799,1,1,1\nstid3", 1, 2, false},
+		{"missing column", "date,station_uuid,diesel,e5\n2023-05-04 10:00:05+02,stid1,1.689,1.859", 0, 0, true},
+		{"empty file", "", 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			myFilePath := filepath.Join(t.TempDir(), "2023-05-04-prices.csv")
+			writeTestStationFile(t, myFilePath, tt.content)
+			myGasStationPrices, myInvalidRows, err := readPriceFile(myFilePath)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readPriceFile() error = %v wantErr: %v", err, tt.wantErr)
+			}
+			if len(myGasStationPrices) != tt.wantPrices || myInvalidRows != tt.wantInvalid {
+				t.Errorf("readPriceFile() = %v, %v want: %v, %v", len(myGasStationPrices), myInvalidRows, tt.wantPrices, tt.wantInvalid)
+			}
+		})
+	}
+	if _, _, err := readPriceFile(filepath.Join(t.TempDir(), "missing-prices.csv")); err == nil {
+		t.Errorf("readPriceFile() error = nil for a missing file")
+	}
+}
+
+func TestFindPriceFiles(t *testing.T) {
+	myDir := t.TempDir()
+	for _, myDay := range []string{"2023-05-02", "2023-04-30", "2023-05-01", "2023-05-03"} {
+		writeTestPriceFile(t, myDir, myDay, testPriceHeader)
+	}
+	writeTestStationFile(t, filepath.Join(myDir, "prices", "2023", "05", "latest-prices.csv"), testPriceHeader)
+	myDay := func(day string) time.Time {
+		result, _ := time.ParseInLocation(time.DateOnly, day, time.Local)
+		return result
+	}
+	tests := []struct {
+		name      string
+		from      time.Time
+		to        time.Time
+		wantFiles []string
+	}{
+		{"all files", time.Time{}, time.Time{}, []string{"prices/2023/04/2023-04-30-prices.csv", "prices/2023/05/2023-05-01-prices.csv",
+			"prices/2023/05/2023-05-02-prices.csv", "prices/2023/05/2023-05-03-prices.csv"}},
+		{"from", myDay("2023-05-02"), time.Time{}, []string{"prices/2023/05/2023-05-02-prices.csv", "prices/2023/05/2023-05-03-prices.csv"}},
+		{"to", time.Time{}, myDay("2023-04-30"), []string{"prices/2023/04/2023-04-30-prices.csv"}},
+		{"from and to", myDay("2023-05-01"), myDay("2023-05-02"), []string{"prices/2023/05/2023-05-01-prices.csv", "prices/2023/05/2023-05-02-prices.csv"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			myPriceFiles, err := findPriceFiles(myDir, tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("findPriceFiles() error = %v", err)
+			}
+			if len(myPriceFiles) != len(tt.wantFiles) {
+				t.Fatalf("findPriceFiles() = %+v want: %v", myPriceFiles, tt.wantFiles)
+			}
+			for index, myPriceFile := range myPriceFiles {
+				if myPriceFile.FileName != tt.wantFiles[index] {
+					t.Errorf("findPriceFiles() = %v want: %v", myPriceFile.FileName, tt.wantFiles[index])
+				}
+			}
+		})
+	}
+}
+
+// a failed import is resumed with the next file, the imported files are skipped
+func TestImportPricesResume(t *testing.T) {
+	myDir := t.TempDir()
+	t.Setenv("PRICE_IMPORT_PATH", myDir)
+	myRepo := gasstation.NewGasStationMemRepo()
+	myRepo.SaveGasStations([]gsmodel.GasStation{{ID: "stid1", StationName: "A", PostCode: "10115"}})
+	myImporter := NewPriceImporter(gasstation.NewGasStationService(myRepo, postcode.NewPostCodeMemRepo(), nil))
+	writeTestPriceFile(t, myDir, "2023-05-01", testPriceHeader, "2023-05-01 08:00:00+02,stid1,1.689,1.859,1.799,1,1,1",
+		"2023-05-01 09:00:00+02,stid1,1.689,1.849,1.799,0,1,0", "2023-05-01 10:00:00+02,stid1,1.689,1.849,1.799,0,0,0",
+		"2023-05-01 11:00:00+02,unknown,1.689,1.849,1.799,1,1,1", "2023-05-01,stid1,1.689,1.849,1.799,0,0,0")
+	writeTestPriceFile(t, myDir, "2023-05-02", "date,station_uuid", "2023-05-02 08:00:00+02,stid1")
+	myResult, err := myImporter.ImportPrices(time.Time{}, time.Time{})
+	if err == nil {
+		t.Fatalf("ImportPrices() error = nil want: the missing columns of the second file")
+	}
+	if myResult.Files != 1 || myResult.Prices != 2 || myResult.InvalidRows != 1 || myResult.Unknown != 1 {
+		t.Errorf("ImportPrices() = %+v want: 1 file, 2 prices, 1 invalid row, 1 unknown", myResult)
+	}
+	writeTestPriceFile(t, myDir, "2023-05-02", testPriceHeader, "2023-05-02 08:00:00+02,stid1,1.689,1.849,1.799,0,0,0",
+		"2023-05-02 09:00:00+02,stid1,1.679,1.849,1.799,1,0,0")
+	myResult, err = myImporter.ImportPrices(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("ImportPrices() error = %v", err)
+	}
+	//the unchanged first price of the day is compared with the last price of the imported day
+	if myResult.Files != 1 || myResult.SkippedFiles != 1 || myResult.Prices != 1 {
+		t.Errorf("ImportPrices() = %+v want: 1 file, 1 skipped file, 1 price", myResult)
+	}
+	myGasPrices := myRepo.FindPricesByStid("stid1")
+	if len(myGasPrices) != 3 || myGasPrices[0].Diesel != 1679 || myGasPrices[0].Changed != 1 || myGasPrices[2].Changed != 21 {
+		t.Errorf("ImportPrices() stored prices = %+v", myGasPrices)
+	}
+	if myPriceImportFiles := myRepo.FindPriceImportFiles(); len(myPriceImportFiles) != 2 {
+		t.Errorf("FindPriceImportFiles() = %+v want: 2 files", myPriceImportFiles)
+	}
+	myResult, err = myImporter.ImportPrices(time.Time{}, time.Time{})
+	if err != nil || myResult.Files != 0 || myResult.SkippedFiles != 2 {
+		t.Errorf("ImportPrices() = %+v, %v want: all files skipped", myResult, err)
+	}
+}
@@ -0,0 +1,256 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package aufile
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"react-and-go/pkd/gasstation"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"react-and-go/pkd/importdiff"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StationSourceUrl    = "url"
+	StationSourceDir    = "dir"
+	StationSourceUpload = "upload"
+)
+
+const maxStationFileSize = 200 * 1024 * 1024
+
+var ErrStationImportRunning = errors.New("station import is running")
+
+type StationImporter struct {
+	gasStationService *gasstation.GasStationService
+	diffStore         *importdiff.DiffStore
+	httpClient        *http.Client
+	running           atomic.Bool
+}
+
+func NewStationImporter(gasStationService *gasstation.GasStationService, diffStore *importdiff.DiffStore) *StationImporter {
+	return &StationImporter{gasStationService: gasStationService, diffStore: diffStore, httpClient: &http.Client{Timeout: 5 * time.Minute}}
+}
+
+// imports the stations file of STATION_IMPORT_SOURCE. 'url' downloads STATION_IMPORT_URL, 'dir' reads the latest
+// 'stations/YYYY/MM/YYYY-MM-DD-stations.csv' or '*-stations.csv' file of STATION_IMPORT_PATH.
+func (importer *StationImporter) ImportStations() (gsmodel.StationImportRun, error) {
+	mySource, myReadFunc, err := importer.configuredSource()
+	if err != nil {
+		return gsmodel.StationImportRun{}, err
+	}
+	return importer.importStations(mySource, myReadFunc)
+}
+
+func (importer *StationImporter) ImportStationFile(fileName string, reader io.Reader) (gsmodel.StationImportRun, error) {
+	return importer.importStations(StationSourceUpload, func() (string, []byte, error) {
+		myContent, err := readLimited(reader)
+		return fileName, myContent, err
+	})
+}
+
+// the dry run of ImportStations, the diff is applied with the DiffStore
+func (importer *StationImporter) DiffStations() (importdiff.ImportDiff, error) {
+	mySource, myReadFunc, err := importer.configuredSource()
+	if err != nil {
+		return importdiff.ImportDiff{}, err
+	}
+	return importer.diffStations(mySource, myReadFunc)
+}
+
+func (importer *StationImporter) DiffStationFile(fileName string, reader io.Reader) (importdiff.ImportDiff, error) {
+	return importer.diffStations(StationSourceUpload, func() (string, []byte, error) {
+		myContent, err := readLimited(reader)
+		return fileName, myContent, err
+	})
+}
+
+func (importer *StationImporter) configuredSource() (string, func() (string, []byte, error), error) {
+	mySource := strings.ToLower(strings.TrimSpace(os.Getenv("STATION_IMPORT_SOURCE")))
+	switch mySource {
+	case StationSourceDir:
+		return StationSourceDir, func() (string, []byte, error) {
+			return readLatestStationFile(strings.TrimSpace(os.Getenv("STATION_IMPORT_PATH")))
+		}, nil
+	case StationSourceUrl, "":
+		return StationSourceUrl, importer.downloadStationFile, nil
+	}
+	return mySource, nil, fmt.Errorf("unknown STATION_IMPORT_SOURCE: %v", mySource)
+}
+
+// the content of the file is kept for the apply, the import of the apply is recorded as a run of the source
+func (importer *StationImporter) diffStations(source string, readFunc func() (string, []byte, error)) (importdiff.ImportDiff, error) {
+	myLocation, myContent, err := readFunc()
+	if err != nil {
+		return importdiff.ImportDiff{}, err
+	}
+	myStationFile, err := readStationFile(myContent)
+	if err != nil {
+		return importdiff.ImportDiff{}, err
+	}
+	myImportDiff := importer.gasStationService.DiffGasStations(&myStationFile.GasStationImports, myStationFile.QuarantinedIds, myLocation)
+	return importer.diffStore.Add(myImportDiff, func(importDiff importdiff.ImportDiff) error {
+		if !importDiff.Matches(importer.gasStationService.DiffGasStations(&myStationFile.GasStationImports, myStationFile.QuarantinedIds, myLocation)) {
+			return importdiff.ErrDiffOutdated
+		}
+		_, err := importer.importStations(source, func() (string, []byte, error) {
+			return myLocation, myContent, nil
+		})
+		return err
+	}), nil
+}
+
+func (importer *StationImporter) FindStationImportRuns() []gsmodel.StationImportRun {
+	return importer.gasStationService.FindStationImportRuns(100)
+}
+
+// the import is skipped if the checksum is the checksum of the last imported file, every run is recorded
+func (importer *StationImporter) importStations(source string, readFunc func() (string, []byte, error)) (gsmodel.StationImportRun, error) {
+	if !importer.running.CompareAndSwap(false, true) {
+		return gsmodel.StationImportRun{}, ErrStationImportRunning
+	}
+	defer importer.running.Store(false)
+	result := gsmodel.StationImportRun{Source: source, StartTime: time.Now(), Status: gsmodel.ImportRunFailed}
+	importErrors, err := importer.importStationContent(&result, readFunc)
+	if err != nil {
+		result.Error = err.Error()
+		log.Printf("Station import from %v failed: %v\n", result.Location, err)
+	}
+	result.EndTime = time.Now()
+	importer.gasStationService.SaveStationImportRun(&result)
+	importer.gasStationService.SaveStationImportErrors(result.ID, importErrors)
+	log.Printf("Station import %v from %v: rows: %v invalid: %v stations: %v updated: %v new: %v\n", result.Status, result.Location, result.Rows,
+		result.InvalidRows, result.Stations, result.Updated, result.Created)
+	return result, err
+}
+
+func (importer *StationImporter) FindStationImportReport(stationImportRunId int64) (gasstation.StationImportReport, bool) {
+	return importer.gasStationService.FindStationImportReport(stationImportRunId)
+}
+
+// returns the quarantined rows
+func (importer *StationImporter) importStationContent(stationImportRun *gsmodel.StationImportRun,
+	readFunc func() (string, []byte, error)) ([]gsmodel.StationImportError, error) {
+	myLocation, myContent, err := readFunc()
+	stationImportRun.Location = myLocation
+	if err != nil {
+		return nil, err
+	}
+	myChecksum := sha256.Sum256(myContent)
+	stationImportRun.Checksum = hex.EncodeToString(myChecksum[:])
+	if myLastRun, found := importer.gasStationService.FindLastImportedStationRun(); found && myLastRun.Checksum == stationImportRun.Checksum {
+		stationImportRun.Status = gsmodel.ImportRunSkipped
+		return nil, nil
+	}
+	myStationFile, err := readStationFile(myContent)
+	stationImportRun.Rows = myStationFile.Rows
+	stationImportRun.InvalidRows = myStationFile.InvalidRows
+	if err != nil {
+		return myStationFile.ImportErrors, err
+	}
+	if len(myStationFile.GasStationImports) == 0 {
+		return myStationFile.ImportErrors, fmt.Errorf("the stations file has no valid stations")
+	}
+	myImportResult := importer.gasStationService.UpdateGasStations(&myStationFile.GasStationImports, myStationFile.QuarantinedIds)
+	stationImportRun.Stations = myImportResult.Stations
+	stationImportRun.Updated = myImportResult.Updated
+	stationImportRun.Created = myImportResult.Created
+	stationImportRun.LifecycleChanges = myImportResult.LifecycleChanges
+	stationImportRun.MasterDataChanges = myImportResult.MasterDataChanges
+	stationImportRun.Status = gsmodel.ImportRunImported
+	return myStationFile.ImportErrors, nil
+}
+
+// the placeholders {YYYY}, {MM} and {DD} of STATION_IMPORT_URL are replaced with the day of now minus STATION_IMPORT_DELAY_HOURS(default 30)
+func createStationUrl(urlTemplate string, now time.Time) string {
+	myDelayHours, err := strconv.Atoi(strings.TrimSpace(os.Getenv("STATION_IMPORT_DELAY_HOURS")))
+	if err != nil {
+		myDelayHours = 30
+	}
+	myDay := now.Add(time.Hour * time.Duration(-myDelayHours))
+	return strings.NewReplacer("{YYYY}", fmt.Sprintf("%04d", myDay.Year()), "{MM}", fmt.Sprintf("%02d", myDay.Month()),
+		"{DD}", fmt.Sprintf("%02d", myDay.Day())).Replace(urlTemplate)
+}
+
+func (importer *StationImporter) downloadStationFile() (string, []byte, error) {
+	myUrl := createStationUrl(strings.TrimSpace(os.Getenv("STATION_IMPORT_URL")), time.Now())
+	if len(myUrl) == 0 {
+		return myUrl, nil, fmt.Errorf("STATION_IMPORT_URL is not set")
+	}
+	myRequest, err := http.NewRequestWithContext(context.Background(), http.MethodGet, myUrl, nil)
+	if err != nil {
+		return myUrl, nil, err
+	}
+	response, err := importer.httpClient.Do(myRequest)
+	if err != nil {
+		return myUrl, nil, fmt.Errorf("request failed: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return myUrl, nil, fmt.Errorf("request failed with status: %v", response.Status)
+	}
+	myContent, err := readLimited(response.Body)
+	return myUrl, myContent, err
+}
+
+func readLatestStationFile(basePath string) (string, []byte, error) {
+	if len(basePath) == 0 {
+		return basePath, nil, fmt.Errorf("STATION_IMPORT_PATH is not set")
+	}
+	var filePaths []string
+	for _, myPattern := range []string{filepath.Join(basePath, "*-stations.csv"), filepath.Join(basePath, "stations", "*", "*", "*-stations.csv")} {
+		myFilePaths, err := filepath.Glob(myPattern)
+		if err != nil {
+			return basePath, nil, err
+		}
+		filePaths = append(filePaths, myFilePaths...)
+	}
+	if len(filePaths) == 0 {
+		return basePath, nil, fmt.Errorf("no stations file found in %v", basePath)
+	}
+	//the file names start with the day
+	sort.SliceStable(filePaths, func(i, j int) bool {
+		return filepath.Base(filePaths[i]) < filepath.Base(filePaths[j])
+	})
+	myFilePath := filePaths[len(filePaths)-1]
+	file, err := os.Open(myFilePath)
+	if err != nil {
+		return myFilePath, nil, fmt.Errorf("failed to open file %v: %v", myFilePath, err)
+	}
+	defer file.Close()
+	myContent, err := readLimited(file)
+	return myFilePath, myContent, err
+}
+
+func readLimited(reader io.Reader) ([]byte, error) {
+	myContent, err := io.ReadAll(io.LimitReader(reader, maxStationFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the stations file: %v", err)
+	}
+	if len(myContent) > maxStationFileSize {
+		return nil, fmt.Errorf("the stations file is larger than %v bytes", maxStationFileSize)
+	}
+	return myContent, nil
+}
@@ -0,0 +1,181 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package aufile
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"react-and-go/pkd/gasstation"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"react-and-go/pkd/importdiff"
+	"react-and-go/pkd/postcode"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestStationImporter() *StationImporter {
+	myService := gasstation.NewGasStationService(gasstation.NewGasStationMemRepo(), postcode.NewPostCodeMemRepo(), nil)
+	return NewStationImporter(myService, importdiff.NewDiffStore())
+}
+
+func createTestStationContent(stationNum int) string {
+	myRows := []string{testStationHeader}
+	for index := 1; index <= stationNum; index++ {
+		myRows = append(myRows, createTestStationRow(index))
+	}
+	return strings.Join(myRows, "\n")
+}
+
+func writeTestStationFile(t *testing.T, filePath string, content string) {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		t.Fatalf("MkdirAll() failed: %v", err)
+	}
+	if err := os.WriteFile(filePath, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+}
+
+func TestCreateStationUrl(t *testing.T) {
+	myNow := time.Date(2023, time.March, 2, 5, 0, 0, 0, time.Local)
+	tests := []struct {
+		name       string
+		delayHours string
+		want       string
+	}{
+		{"default delay", "", "https://host/stations/2023/02/2023-02-28-stations.csv"},
+		{"invalid delay", "one", "https://host/stations/2023/02/2023-02-28-stations.csv"},
+		{"no delay", "0", "https://host/stations/2023/03/2023-03-02-stations.csv"},
+		{"delay of a day", "24", "https://host/stations/2023/03/2023-03-01-stations.csv"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("STATION_IMPORT_DELAY_HOURS", tt.delayHours)
+			if got := createStationUrl("https://host/stations/{YYYY}/{MM}/{YYYY}-{MM}-{DD}-stations.csv", myNow); got != tt.want {
+				t.Errorf("createStationUrl() = %v want: %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadLatestStationFile(t *testing.T) {
+	myDir := t.TempDir()
+	writeTestStationFile(t, filepath.Join(myDir, "stations", "2023", "04", "2023-04-30-stations.csv"), "april")
+	writeTestStationFile(t, filepath.Join(myDir, "stations", "2023", "05", "2023-05-02-stations.csv"), "newest")
+	writeTestStationFile(t, filepath.Join(myDir, "2023-05-01-stations.csv"), "flat")
+	writeTestStationFile(t, filepath.Join(myDir, "2023-05-03-prices.csv"), "prices")
+	tests := []struct {
+		name        string
+		basePath    string
+		wantContent string
+		wantErr     bool
+	}{
+		{"newest file", myDir, "newest", false},
+		{"no path", "", "", true},
+		{"no stations file", t.TempDir(), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			myFilePath, myContent, err := readLatestStationFile(tt.basePath)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readLatestStationFile() error = %v wantErr: %v", err, tt.wantErr)
+			}
+			if string(myContent) != tt.wantContent {
+				t.Errorf("readLatestStationFile() = %v, %v want: %v", myFilePath, string(myContent), tt.wantContent)
+			}
+		})
+	}
+}
+
+func TestImportStationsFromUrl(t *testing.T) {
+	myContent := createTestStationContent(2)
+	myStatus := http.StatusOK
+	var myRequestPath string
+	myServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		myRequestPath = r.URL.Path
+		w.WriteHeader(myStatus)
+		w.Write([]byte(myContent))
+	}))
+	defer myServer.Close()
+	t.Setenv("STATION_IMPORT_SOURCE", StationSourceUrl)
+	t.Setenv("STATION_IMPORT_URL", myServer.URL+"/{YYYY}/{MM}/{YYYY}-{MM}-{DD}-stations.csv")
+	t.Setenv("STATION_IMPORT_DELAY_HOURS", "0")
+	myImporter := newTestStationImporter()
+	tests := []struct {
+		name        string
+		content     string
+		status      int
+		wantStatus  string
+		wantCreated int
+		wantErr     bool
+	}{
+		{"first import", createTestStationContent(2), http.StatusOK, gsmodel.ImportRunImported, 2, false},
+		{"same checksum", createTestStationContent(2), http.StatusOK, gsmodel.ImportRunSkipped, 0, false},
+		{"not found", createTestStationContent(3), http.StatusNotFound, gsmodel.ImportRunFailed, 0, true},
+		{"server error", createTestStationContent(3), http.StatusInternalServerError, gsmodel.ImportRunFailed, 0, true},
+		{"new station", createTestStationContent(3), http.StatusOK, gsmodel.ImportRunImported, 1, false},
+		{"no valid stations", testStationHeader + "\nstid1,Station 1,,,,,,52.5,13.4,,", http.StatusOK, gsmodel.ImportRunFailed, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			myContent = tt.content
+			myStatus = tt.status
+			myStationImportRun, err := myImporter.ImportStations()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ImportStations() error = %v wantErr: %v", err, tt.wantErr)
+			}
+			if myStationImportRun.Status != tt.wantStatus || myStationImportRun.Created != tt.wantCreated {
+				t.Errorf("ImportStations() = %+v want status: %v created: %v", myStationImportRun, tt.wantStatus, tt.wantCreated)
+			}
+			if myStationImportRun.ID == 0 || (err != nil && len(myStationImportRun.Error) == 0) {
+				t.Errorf("ImportStations() run not recorded: %+v", myStationImportRun)
+			}
+			if wantPath := time.Now().Format("/2006/01/2006-01-02-stations.csv"); myRequestPath != wantPath {
+				t.Errorf("ImportStations() request path = %v want: %v", myRequestPath, wantPath)
+			}
+		})
+	}
+	if myStationImportRuns := myImporter.FindStationImportRuns(); len(myStationImportRuns) != len(tests) {
+		t.Errorf("FindStationImportRuns() = %v want: %v runs", len(myStationImportRuns), len(tests))
+	}
+}
+
+func TestImportStationsFromDir(t *testing.T) {
+	myDir := t.TempDir()
+	t.Setenv("STATION_IMPORT_SOURCE", StationSourceDir)
+	t.Setenv("STATION_IMPORT_PATH", myDir)
+	myImporter := newTestStationImporter()
+	writeTestStationFile(t, filepath.Join(myDir, "2023-05-01-stations.csv"), createTestStationContent(1))
+	myStationImportRun, err := myImporter.ImportStations()
+	if err != nil || myStationImportRun.Status != gsmodel.ImportRunImported || myStationImportRun.Created != 1 {
+		t.Fatalf("ImportStations() = %+v, %v want: 1 created", myStationImportRun, err)
+	}
+	writeTestStationFile(t, filepath.Join(myDir, "stations", "2023", "05", "2023-05-02-stations.csv"), createTestStationContent(2))
+	myStationImportRun, err = myImporter.ImportStations()
+	if err != nil || myStationImportRun.Status != gsmodel.ImportRunImported || myStationImportRun.Created != 1 ||
+		filepath.Base(myStationImportRun.Location) != "2023-05-02-stations.csv" {
+		t.Errorf("ImportStations() = %+v, %v want: 1 created from the newest file", myStationImportRun, err)
+	}
+	if myStationImportRun, err = myImporter.ImportStations(); err != nil || myStationImportRun.Status != gsmodel.ImportRunSkipped {
+		t.Errorf("ImportStations() = %+v, %v want: skipped", myStationImportRun, err)
+	}
+}
+
+func TestImportStationsUnknownSource(t *testing.T) {
+	t.Setenv("STATION_IMPORT_SOURCE", "ftp")
+	if _, err := newTestStationImporter().ImportStations(); err == nil {
+		t.Errorf("ImportStations() error = nil want: unknown source")
+	}
+}
@@ -0,0 +1,164 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package aufile
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"react-and-go/pkd/gasstation"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const stationFileTimeLayout = "2006-01-02 15:04:05-07"
+
+// the quarantined rows above the limit are only counted
+const maxStationImportErrors = 1000
+
+var requiredStationColumns = []string{"uuid", "name", "latitude", "longitude"}
+var optionalStationColumns = []string{"brand", "street", "house_number", "post_code", "city", "first_active", "openingtimes_json"}
+
+var stationUuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+var postCodeRegex = regexp.MustCompile(`^[0-9]{5}$`)
+
+type stationFile struct {
+	GasStationImports []gasstation.GasStationImport
+	Rows              int
+	InvalidRows       int
+	ImportErrors      []gsmodel.StationImportError
+	//the quarantined stations must not be deactivated
+	QuarantinedIds map[string]bool
+}
+
+type stationRowError struct {
+	Field   string
+	Message string
+}
+
+// the columns are found by the header, the invalid rows are quarantined with the line and the reason
+func readStationFile(content []byte) (stationFile, error) {
+	result := stationFile{QuarantinedIds: make(map[string]bool)}
+	csvReader := csv.NewReader(bytes.NewReader(content))
+	csvReader.FieldsPerRecord = -1
+	header, err := csvReader.Read()
+	if err != nil {
+		return result, fmt.Errorf("failed to read the header of the stations file: %v", err)
+	}
+	columnIndexes := make(map[string]int)
+	for index, myColumn := range header {
+		columnIndexes[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(myColumn, "\ufeff")))] = index
+	}
+	for _, myColumn := range requiredStationColumns {
+		if _, ok := columnIndexes[myColumn]; !ok {
+			return result, fmt.Errorf("column %v missing in the stations file", myColumn)
+		}
+	}
+	stationUuids := make(map[string]bool)
+	for {
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		result.Rows += 1
+		var myParseError *csv.ParseError
+		if errors.As(err, &myParseError) {
+			result.addError(myParseError.Line, "", nil, stationRowError{Field: "row", Message: myParseError.Err.Error()})
+			continue
+		} else if err != nil {
+			return result, fmt.Errorf("failed to read the stations file: %v", err)
+		}
+		//the field positions are only set for a parsed row
+		myLine, _ := csvReader.FieldPos(0)
+		myGasStationImport, myRowError := createGasStationImport(row, header, columnIndexes)
+		if myRowError == nil && stationUuids[myGasStationImport.Uuid] {
+			myRowError = &stationRowError{Field: "uuid", Message: "duplicate uuid"}
+		}
+		if myRowError != nil {
+			result.addError(myLine, myGasStationImport.Uuid, row, *myRowError)
+			continue
+		}
+		stationUuids[myGasStationImport.Uuid] = true
+		result.GasStationImports = append(result.GasStationImports, myGasStationImport)
+	}
+	return result, nil
+}
+
+func (stationFile *stationFile) addError(line int, uuid string, row []string, rowError stationRowError) {
+	stationFile.InvalidRows += 1
+	if stationUuidRegex.MatchString(uuid) {
+		stationFile.QuarantinedIds[uuid] = true
+	}
+	if len(stationFile.ImportErrors) >= maxStationImportErrors {
+		return
+	}
+	stationFile.ImportErrors = append(stationFile.ImportErrors, gsmodel.StationImportError{Line: line, Uuid: uuid, Field: rowError.Field,
+		Message: rowError.Message, Row: strings.Join(row, ",")})
+}
+
+func createGasStationImport(row []string, header []string, columnIndexes map[string]int) (gasstation.GasStationImport, *stationRowError) {
+	myValues := make(map[string]string)
+	var myMissingColumn *string
+	for _, myColumn := range append(requiredStationColumns, optionalStationColumns...) {
+		myIndex, ok := columnIndexes[myColumn]
+		if !ok {
+			continue
+		}
+		if myIndex >= len(row) {
+			if myMissingColumn == nil {
+				myMissingColumn = &myColumn
+			}
+			continue
+		}
+		myValues[myColumn] = strings.TrimSpace(row[myIndex])
+	}
+	result := gasstation.GasStationImport{Uuid: myValues["uuid"], StationName: myValues["name"], Brand: myValues["brand"], Street: myValues["street"],
+		HouseNumber: myValues["house_number"], PostCode: myValues["post_code"], City: myValues["city"], OpeningTimesJson: myValues["openingtimes_json"]}
+	if myMissingColumn != nil {
+		return result, &stationRowError{Field: *myMissingColumn, Message: fmt.Sprintf("row has %v of %v fields", len(row), len(header))}
+	}
+	if !stationUuidRegex.MatchString(result.Uuid) {
+		return result, &stationRowError{Field: "uuid", Message: fmt.Sprintf("invalid uuid: '%v'", result.Uuid)}
+	}
+	if len(result.StationName) == 0 {
+		return result, &stationRowError{Field: "name", Message: "name is empty"}
+	}
+	var err error
+	if result.Latitude, err = strconv.ParseFloat(myValues["latitude"], 64); err != nil || result.Latitude < -90 || result.Latitude > 90 {
+		return result, &stationRowError{Field: "latitude", Message: fmt.Sprintf("invalid latitude: '%v'", myValues["latitude"])}
+	}
+	if result.Longitude, err = strconv.ParseFloat(myValues["longitude"], 64); err != nil || result.Longitude < -180 || result.Longitude > 180 {
+		return result, &stationRowError{Field: "longitude", Message: fmt.Sprintf("invalid longitude: '%v'", myValues["longitude"])}
+	}
+	if result.Latitude == 0 && result.Longitude == 0 {
+		return result, &stationRowError{Field: "latitude", Message: "coordinates are 0,0"}
+	}
+	if len(result.PostCode) > 0 && !postCodeRegex.MatchString(result.PostCode) {
+		return result, &stationRowError{Field: "post_code", Message: fmt.Sprintf("invalid post code: '%v'", result.PostCode)}
+	}
+	if len(myValues["first_active"]) > 0 {
+		if result.FirstActive, err = time.Parse(stationFileTimeLayout, myValues["first_active"]); err != nil {
+			return result, &stationRowError{Field: "first_active", Message: fmt.Sprintf("invalid timestamp: '%v'", myValues["first_active"])}
+		}
+	}
+	if len(result.OpeningTimesJson) > 0 && !json.Valid([]byte(result.OpeningTimesJson)) {
+		return result, &stationRowError{Field: "openingtimes_json", Message: "invalid json"}
+	}
+	return result, nil
+}
@@ -0,0 +1,126 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package aufile
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testStationHeader = "uuid,name,brand,street,house_number,post_code,city,latitude,longitude,first_active,openingtimes_json"
+
+func createTestStationUuid(index int) string {
+	return fmt.Sprintf("00060453-0001-4444-8888-%012d", index)
+}
+
+func createTestStationRow(index int) string {
+	return fmt.Sprintf("%v,Station %v,Aral,Hauptstr.,1,10115,Berlin,52.53,13.38,2023-05-04 10:00:00+02,{}", createTestStationUuid(index), index)
+}
+
+func TestReadStationFile(t *testing.T) {
+	tests := []struct {
+		name            string
+		content         string
+		wantStations    int
+		wantInvalidRows int
+		wantField       string
+		wantQuarantined bool
+		wantErr         bool
+	}{
+		{"valid", testStationHeader + "\n" + createTestStationRow(1) + "\n" + createTestStationRow(2), 2, 0, "", false, false},
+		{"reordered header", "latitude,longitude,name,uuid\n52.53,13.38,Station 1," + createTestStationUuid(1), 1, 0, "", false, false},
+		{"bom", "\ufeff" + testStationHeader + "\n" + createTestStationRow(1), 1, 0, "", false, false},
+		{"missing column", "uuid,name,latitude\n" + createTestStationUuid(1) + ",Station 1,52.53", 0, 0, "", false, true},
+		{"empty file", "", 0, 0, "", false, true},
+		{"short row", testStationHeader + "\n" + createTestStationUuid(1) + ",Station 1,Aral", 0, 1, "latitude", true, false},
+		{"invalid uuid", testStationHeader + "\n" + strings.Replace(createTestStationRow(1), createTestStationUuid(1), "stid1", 1), 0, 1, "uuid", false, false},
+		{"empty name", testStationHeader + "\n" + strings.Replace(createTestStationRow(1), "Station 1", "", 1), 0, 1, "name", true, false},
+		{"invalid latitude", testStationHeader + "\n" + strings.Replace(createTestStationRow(1), "52.53", "52.53x", 1), 0, 1, "latitude", true, false},
+		{"latitude out of range", testStationHeader + "\n" + strings.Replace(createTestStationRow(1), "52.53", "95.0", 1), 0, 1, "latitude", true, false},
+		{"invalid longitude", testStationHeader + "\n" + strings.Replace(createTestStationRow(1), "13.38", "east", 1), 0, 1, "longitude", true, false},
+		{"coordinates 0,0", testStationHeader + "\n" + strings.Replace(strings.Replace(createTestStationRow(1), "52.53", "0", 1), "13.38", "0.0", 1), 0, 1, "latitude", true, false},
+		{"invalid postcode", testStationHeader + "\n" + strings.Replace(createTestStationRow(1), "10115", "1011", 1), 0, 1, "post_code", true, false},
+		{"empty postcode", testStationHeader + "\n" + strings.Replace(createTestStationRow(1), "10115", "", 1), 1, 0, "", false, false},
+		{"first_active month and day swapped", testStationHeader + "\n" + strings.Replace(createTestStationRow(1), "2023-05-04", "2023-31-05", 1), 0, 1, "first_active", true, false},
+		{"first_active without zone", testStationHeader + "\n" + strings.Replace(createTestStationRow(1), "10:00:00+02", "10:00:00", 1), 0, 1, "first_active", true, false},
+		{"invalid json", testStationHeader + "\n" + strings.Replace(createTestStationRow(1), "{}", "{", 1), 0, 1, "openingtimes_json", true, false},
+		{"duplicate uuid", testStationHeader + "\n" + createTestStationRow(1) + "\n" + createTestStationRow(1), 1, 1, "uuid", true, false},
+		{"unclosed quote", testStationHeader + "\n" + createTestStationRow(1) + "\n\"" + createTestStationRow(2), 1, 1, "row", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			myStationFile, err := readStationFile([]byte(tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readStationFile() error = %v wantErr: %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(myStationFile.GasStationImports) != tt.wantStations || myStationFile.InvalidRows != tt.wantInvalidRows {
+				t.Errorf("readStationFile() stations = %v invalid rows = %v want: %v, %v errors: %+v", len(myStationFile.GasStationImports),
+					myStationFile.InvalidRows, tt.wantStations, tt.wantInvalidRows, myStationFile.ImportErrors)
+			}
+			if len(tt.wantField) > 0 && (len(myStationFile.ImportErrors) != 1 || myStationFile.ImportErrors[0].Field != tt.wantField) {
+				t.Errorf("readStationFile() errors = %+v want field: %v", myStationFile.ImportErrors, tt.wantField)
+			}
+			if myStationFile.QuarantinedIds[createTestStationUuid(1)] != tt.wantQuarantined {
+				t.Errorf("readStationFile() quarantined = %v want: %v", myStationFile.QuarantinedIds, tt.wantQuarantined)
+			}
+		})
+	}
+}
+
+func TestCreateGasStationImport(t *testing.T) {
+	myHeader := strings.Split(testStationHeader, ",")
+	myColumnIndexes := make(map[string]int)
+	for index, myColumn := range myHeader {
+		myColumnIndexes[myColumn] = index
+	}
+	myGasStationImport, myRowError := createGasStationImport(strings.Split(createTestStationRow(1), ","), myHeader, myColumnIndexes)
+	if myRowError != nil {
+		t.Fatalf("createGasStationImport() error = %+v", myRowError)
+	}
+	wantFirstActive := time.Date(2023, time.May, 4, 8, 0, 0, 0, time.UTC)
+	if myGasStationImport.Uuid != createTestStationUuid(1) || myGasStationImport.StationName != "Station 1" || myGasStationImport.Brand != "Aral" ||
+		myGasStationImport.PostCode != "10115" || myGasStationImport.City != "Berlin" || myGasStationImport.Latitude != 52.53 ||
+		myGasStationImport.Longitude != 13.38 || !myGasStationImport.FirstActive.Equal(wantFirstActive) || myGasStationImport.OpeningTimesJson != "{}" {
+		t.Errorf("createGasStationImport() = %+v want first active: %v", myGasStationImport, wantFirstActive)
+	}
+}
+
+// the rows above the error limit are counted and quarantined but not stored as errors
+func TestReadStationFileErrorLimit(t *testing.T) {
+	var myContent strings.Builder
+	myContent.WriteString(testStationHeader)
+	myInvalidRows := maxStationImportErrors + 5
+	for index := 1; index <= myInvalidRows; index++ {
+		myContent.WriteString("\n" + strings.Replace(createTestStationRow(index), "10115", "abc", 1))
+	}
+	myContent.WriteString("\n" + createTestStationRow(myInvalidRows+1))
+	myStationFile, err := readStationFile([]byte(myContent.String()))
+	if err != nil {
+		t.Fatalf("readStationFile() error = %v", err)
+	}
+	if myStationFile.Rows != myInvalidRows+1 || myStationFile.InvalidRows != myInvalidRows || len(myStationFile.GasStationImports) != 1 {
+		t.Errorf("readStationFile() rows = %v invalid rows = %v stations = %v", myStationFile.Rows, myStationFile.InvalidRows, len(myStationFile.GasStationImports))
+	}
+	if len(myStationFile.ImportErrors) != maxStationImportErrors || len(myStationFile.QuarantinedIds) != myInvalidRows {
+		t.Errorf("readStationFile() errors = %v quarantined = %v want: %v, %v", len(myStationFile.ImportErrors), len(myStationFile.QuarantinedIds),
+			maxStationImportErrors, myInvalidRows)
+	}
+	if myImportError := myStationFile.ImportErrors[0]; myImportError.Line != 2 || myImportError.Field != "post_code" || myImportError.Uuid != createTestStationUuid(1) {
+		t.Errorf("readStationFile() first error = %+v want line: 2 field: post_code", myImportError)
+	}
+}
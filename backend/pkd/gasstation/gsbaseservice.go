@@ -15,16 +15,14 @@ package gasstation
 import (
 	"fmt"
 	"log"
+	"math"
 	"os"
-	"react-and-go/pkd/database"
 	"react-and-go/pkd/gasstation/gsmodel"
-	"react-and-go/pkd/notification"
+	"react-and-go/pkd/postcode"
 	"react-and-go/pkd/postcode/pcmodel"
 	"strconv"
 	"strings"
 	"time"
-
-	"gorm.io/gorm"
 )
 
 //const earthRadius = 6371.0
@@ -36,7 +34,25 @@ const (
 	Month TimeFrame = iota
 )
 
-type minMaxSquare struct {
+type PriceNotifier interface {
+	SendNotifications(gasStationIDToGasPriceMapPtr *map[string]gsmodel.GasPrice, gasStations []gsmodel.GasStation)
+}
+
+type GasStationService struct {
+	gasStationRepo      GasStationRepo
+	postCodeRepo        postcode.PostCodeRepo
+	priceNotifier       PriceNotifier
+	priceChangeBroker   *priceChangeBroker
+	latestPriceCache    *latestPriceCache
+	pricePostProcessing *pricePostProcessing
+}
+
+func NewGasStationService(gasStationRepo GasStationRepo, postCodeRepo postcode.PostCodeRepo, priceNotifier PriceNotifier) *GasStationService {
+	return &GasStationService{gasStationRepo: gasStationRepo, postCodeRepo: postCodeRepo, priceNotifier: priceNotifier, priceChangeBroker: newPriceChangeBroker(),
+		latestPriceCache: newLatestPriceCache(), pricePostProcessing: newPricePostProcessing()}
+}
+
+type MinMaxSquare struct {
 	MinLat float64
 	MinLng float64
 	MaxLat float64
@@ -68,9 +84,8 @@ func resetDataMaps(stateDataMapRef *map[int]pcmodel.StateData, countyDataMap *ma
 	}
 }
 
-func createPostCodeMaps() (map[int]pcmodel.PostCodeLocation, map[int]pcmodel.StateData, map[int]pcmodel.CountyData) {
-	var postcodeLocations []pcmodel.PostCodeLocation
-	database.DB.Preload("StateData").Preload("CountyData").Find(&postcodeLocations)
+func (service *GasStationService) createPostCodeMaps() (map[int]pcmodel.PostCodeLocation, map[int]pcmodel.StateData, map[int]pcmodel.CountyData) {
+	postcodeLocations := service.postCodeRepo.FindAllPostCodeLocations(true)
 	postCodePostCodeLocationMap := make(map[int]pcmodel.PostCodeLocation)
 	idStateDataMap := make(map[int]pcmodel.StateData)
 	idCountyDataMap := make(map[int]pcmodel.CountyData)
@@ -82,8 +97,8 @@ func createPostCodeMaps() (map[int]pcmodel.PostCodeLocation, map[int]pcmodel.Sta
 	return postCodePostCodeLocationMap, idStateDataMap, idCountyDataMap
 }
 
-func createGasStationIdGasPriceMap(gasStations *[]gsmodel.GasStation, timeframe TimeFrame) map[string][]gsmodel.GasPrice {
-	gasPrices := findGasPricesByTimeframe(gasStations, timeframe)
+func (service *GasStationService) createGasStationIdGasPriceMap(gasStations *[]gsmodel.GasStation, timeframe TimeFrame) map[string][]gsmodel.GasPrice {
+	gasPrices := service.findGasPricesByTimeframe(gasStations, timeframe)
 	//log.Printf("gasPrices: %v", len(gasPrices))
 	gasStationIdGasPriceMap := make(map[string][]gsmodel.GasPrice)
 	for _, myGasPrice := range gasPrices {
@@ -98,8 +113,8 @@ func createGasStationIdGasPriceMap(gasStations *[]gsmodel.GasStation, timeframe
 	return gasStationIdGasPriceMap
 }
 
-func createGasStationIdGasPriceArrayMap(gasStations *[]gsmodel.GasStation, timeframe TimeFrame) map[string][]gsmodel.GasPrice {
-	gasPrices := findGasPricesByTimeframe(gasStations, timeframe)
+func (service *GasStationService) createGasStationIdGasPriceArrayMap(gasStations *[]gsmodel.GasStation, timeframe TimeFrame) map[string][]gsmodel.GasPrice {
+	gasPrices := service.findGasPricesByTimeframe(gasStations, timeframe)
 	//log.Printf("gasPrices: %v", len(gasPrices))
 	gasStationIdGasPriceMap := make(map[string][]gsmodel.GasPrice)
 	for _, myGasPrice := range gasPrices {
@@ -113,19 +128,18 @@ func createGasStationIdGasPriceArrayMap(gasStations *[]gsmodel.GasStation, timef
 	return gasStationIdGasPriceMap
 }
 
-func findGasPricesByTimeframe(gasStations *[]gsmodel.GasStation, timeframe TimeFrame) []gsmodel.GasPrice {
+func (service *GasStationService) findGasPricesByTimeframe(gasStations *[]gsmodel.GasStation, timeframe TimeFrame) []gsmodel.GasPrice {
 	var gasStationIds []string
 	for _, myGasStation := range *gasStations {
 		gasStationIds = append(gasStationIds, myGasStation.ID)
 	}
 	//log.Printf("gasStationIds: %v", len(gasStationIds))
-	gasPrices := FindPricesByStids(&gasStationIds, 0, timeframe, false)
+	gasPrices := service.FindPricesByStids(&gasStationIds, 0, timeframe, false)
 	return gasPrices
 }
 
-func createPostCodeGasStationsMap() map[string][]gsmodel.GasStation {
-	var gasStations []gsmodel.GasStation
-	database.DB.Find(&gasStations)
+func (service *GasStationService) createPostCodeGasStationsMap() map[string][]gsmodel.GasStation {
+	gasStations := service.gasStationRepo.FindAll(true)
 	postCodeGasStationsMap := make(map[string][]gsmodel.GasStation)
 	for _, myGasStation := range gasStations {
 		postCodeGasStationsMap[myGasStation.PostCode] = append(postCodeGasStationsMap[myGasStation.PostCode], myGasStation)
@@ -133,36 +147,18 @@ func createPostCodeGasStationsMap() map[string][]gsmodel.GasStation {
 	return postCodeGasStationsMap
 }
 
-func findPricesByStids(stids *[]string, resultLimit int, timeframe TimeFrame, onlyLastUpdate bool) []gsmodel.GasPrice {
-	var myGasPrices []gsmodel.GasPrice
+func (service *GasStationService) findPricesByStids(stids *[]string, resultLimit int, timeframe TimeFrame, onlyLastUpdate bool) []gsmodel.GasPrice {
 	var myTimeFrame time.Time
 	if timeframe == Day {
 		myTimeFrame = time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, -1).Round(time.Hour)
 	} else {
 		myTimeFrame = time.Now().Add(time.Hour * -720)
 	}
-	dateStr := fmt.Sprintf("%04d-%02d-%02d", myTimeFrame.Year(), myTimeFrame.Month(), myTimeFrame.Day())
-	//log.Printf("Cut off date: %v", dateStr)
-	chuncks := createInChunks(stids, true)
-	database.DB.Transaction(func(tx *gorm.DB) error {
-		for _, chunk := range chuncks {
-			var values []gsmodel.GasPrice
-			//log.Printf("Chunk: %v\n", chunk)
-			myQuery := tx.Where("stid IN ? and date >= date(?) ", chunk, dateStr).Order("date desc")
-			if resultLimit > 0 {
-				myQuery.Limit(resultLimit)
-			}
-			myQuery.Find(&values)
-			//log.Printf("%v", values)
-			var lastUpdates = values
-			if onlyLastUpdate {
-				lastUpdates = returnLastUpdatesGasStation(&values)
-			}
-			//log.Printf("%v", lastUpdates)
-			myGasPrices = append(myGasPrices, lastUpdates...)
-		}
-		return nil
-	})
+	myGasPrices := service.gasStationRepo.FindPricesByStids(*stids, myTimeFrame, resultLimit)
+	//log.Printf("%v", myGasPrices)
+	if onlyLastUpdate {
+		return returnLastUpdatesGasStation(&myGasPrices)
+	}
 	return myGasPrices
 }
 
@@ -193,6 +189,7 @@ func createNewGasStation(value GasStationImport) gsmodel.GasStation {
 	resultGs.Latitude = value.Latitude
 	resultGs.Longitude = value.Longitude
 	resultGs.OpenTs = 0
+	resultGs.ApplicableDays = gsmodel.ParseOpeningTimes(value.OpeningTimesJson, "").ApplicableDays()
 	resultGs.OtJson = value.OpeningTimesJson
 	resultGs.Place = value.City
 	resultGs.PostCode = value.PostCode
@@ -207,24 +204,76 @@ func createNewGasStation(value GasStationImport) gsmodel.GasStation {
 	return resultGs
 }
 
-func createPostCodePriceMap(gasStationIDToGasPriceMap *map[string]gsmodel.GasPrice) map[string]gsmodel.GasStation {
+// the state of the postcode selects the public holidays of the station
+func findPublicHolidayIdentifier(postCode string, postCodePostCodeLocationMap map[int]pcmodel.PostCodeLocation) string {
+	myPostCode, err := strconv.Atoi(strings.TrimSpace(postCode))
+	if err != nil {
+		return ""
+	}
+	return postCodePostCodeLocationMap[myPostCode].StateData.State
+}
+
+// compares the master data of the station with the import, updates the changed fields and increments the version
+func updateMasterData(gasStation *gsmodel.GasStation, importValue GasStationImport, importTime time.Time) []gsmodel.GasStationChange {
+	myVersion, err := strconv.Atoi(strings.TrimSpace(gasStation.Version))
+	if err != nil {
+		myVersion = 1
+	}
+	newVersion := strconv.Itoa(myVersion + 1)
+	var result []gsmodel.GasStationChange
+	compareField := func(field string, oldValue *string, newValue string) {
+		if strings.TrimSpace(*oldValue) != strings.TrimSpace(newValue) {
+			result = append(result, gsmodel.GasStationChange{GasStationID: gasStation.ID, Version: newVersion, VersionTime: importTime, Field: field,
+				OldValue: *oldValue, NewValue: newValue})
+			*oldValue = newValue
+		}
+	}
+	compareCoordinate := func(field string, oldValue *float64, newValue float64) {
+		//about 10cm
+		if math.Abs(*oldValue-newValue) > 0.000001 {
+			result = append(result, gsmodel.GasStationChange{GasStationID: gasStation.ID, Version: newVersion, VersionTime: importTime, Field: field,
+				OldValue: strconv.FormatFloat(*oldValue, 'f', -1, 64), NewValue: strconv.FormatFloat(newValue, 'f', -1, 64)})
+			*oldValue = newValue
+		}
+	}
+	compareField("StationName", &gasStation.StationName, importValue.StationName)
+	compareField("Brand", &gasStation.Brand, importValue.Brand)
+	compareField("Street", &gasStation.Street, importValue.Street)
+	compareField("HouseNumber", &gasStation.HouseNumber, importValue.HouseNumber)
+	compareField("PostCode", &gasStation.PostCode, importValue.PostCode)
+	compareField("Place", &gasStation.Place, importValue.City)
+	compareCoordinate("Latitude", &gasStation.Latitude, importValue.Latitude)
+	compareCoordinate("Longitude", &gasStation.Longitude, importValue.Longitude)
+	compareField("OtJson", &gasStation.OtJson, importValue.OpeningTimesJson)
+	if len(result) > 0 {
+		gasStation.Version = newVersion
+		gasStation.VersionTime = importTime
+	}
+	return result
+}
+
+func createLifecycleChange(gasStation *gsmodel.GasStation, newStatus string, importTime time.Time) gsmodel.LifecycleChange {
+	oldStatus := gasStation.LifecycleStatus
+	if len(strings.TrimSpace(oldStatus)) == 0 {
+		oldStatus = gsmodel.StatusActive
+	}
+	gasStation.LifecycleStatus = newStatus
+	return gsmodel.LifecycleChange{GasStationID: gasStation.ID, ImportTime: importTime, OldStatus: oldStatus, NewStatus: newStatus}
+}
+
+func (service *GasStationService) createPostCodePriceMap(gasStationIDToGasPriceMap *map[string]gsmodel.GasPrice) map[string]gsmodel.GasStation {
 	var gasStationIDs []string
 	for gasStationID := range *gasStationIDToGasPriceMap {
 		gasStationIDs = append(gasStationIDs, gasStationID)
 	}
-	gasStationIDChunks := createChunks(&gasStationIDs)
 	postcodeGasPriceMap := make(map[string]gsmodel.GasStation)
-	for _, gasStationIDChunk := range gasStationIDChunks {
-		var values []gsmodel.GasStation
-		database.DB.Where("ID IN ?", gasStationIDChunk).Find(&values)
-		for _, myGasStation := range values {
-			postcodeGasPriceMap[myGasStation.PostCode] = myGasStation
-		}
+	for _, myGasStation := range service.gasStationRepo.FindByIds(gasStationIDs, true) {
+		postcodeGasPriceMap[myGasStation.PostCode] = myGasStation
 	}
 	return postcodeGasPriceMap
 }
 
-func createPostcodePostcodeLocationMap(postcodeGasPriceMap *map[string]gsmodel.GasStation) map[int]pcmodel.PostCodeLocation {
+func (service *GasStationService) createPostcodePostcodeLocationMap(postcodeGasPriceMap *map[string]gsmodel.GasStation) map[int]pcmodel.PostCodeLocation {
 	var postcodes []string
 	for myPostcode := range *postcodeGasPriceMap {
 		if len(strings.TrimSpace(myPostcode)) == 0 {
@@ -235,9 +284,14 @@ func createPostcodePostcodeLocationMap(postcodeGasPriceMap *map[string]gsmodel.G
 	//var postcodeLocations []pcmodel.PostCodeLocation
 	postcodePostcodeLocationMap := make(map[int]pcmodel.PostCodeLocation)
 	postcodeChunks := createChunks(&postcodes)
-	for _, myPostcode := range postcodeChunks {
-		var values []pcmodel.PostCodeLocation
-		database.DB.Where("post_code IN ?", myPostcode).Preload("StateData").Preload("CountyData").Find(&values)
+	for _, myPostcodeChunk := range postcodeChunks {
+		var myPostcodeInts []int
+		for _, myPostcode := range myPostcodeChunk {
+			if myPostcodeInt, err := strconv.Atoi(strings.TrimSpace(myPostcode)); err == nil {
+				myPostcodeInts = append(myPostcodeInts, myPostcodeInt)
+			}
+		}
+		values := service.postCodeRepo.FindByPostCodes(myPostcodeInts)
 		//postcodeLocations = append(postcodeLocations, values...)
 		for _, myValue := range values {
 			postcodePostcodeLocationMap[int(myValue.PostCode)] = myValue
@@ -246,18 +300,18 @@ func createPostcodePostcodeLocationMap(postcodeGasPriceMap *map[string]gsmodel.G
 	return postcodePostcodeLocationMap
 }
 
-func createPostCodeGasStationMaps() (map[int]pcmodel.PostCodeLocation, map[int]pcmodel.StateData, map[int]pcmodel.CountyData, map[string][]gsmodel.GasStation) {
-	postCodePostCodeLocationMap, idStateDataMap, idCountyDataMap := createPostCodeMaps()
+func (service *GasStationService) createPostCodeGasStationMaps() (map[int]pcmodel.PostCodeLocation, map[int]pcmodel.StateData, map[int]pcmodel.CountyData, map[string][]gsmodel.GasStation) {
+	postCodePostCodeLocationMap, idStateDataMap, idCountyDataMap := service.createPostCodeMaps()
 	log.Printf("postCodePostCodeLocationMap: %v, idStateDataMap: %v, idCountyDataMap: %v",
 		len(postCodePostCodeLocationMap), len(idStateDataMap), len(idCountyDataMap))
-	postCodeGasStationsMap := createPostCodeGasStationsMap()
+	postCodeGasStationsMap := service.createPostCodeGasStationsMap()
 	log.Printf("postCodeGasStationsMap: %v", len(postCodeGasStationsMap))
 	return postCodePostCodeLocationMap, idStateDataMap, idCountyDataMap, postCodeGasStationsMap
 }
 
-func updateCountyStatePrices(gasStationIDToGasPriceMap *map[string]gsmodel.GasPrice) int {
-	postcodeGasPriceMap := createPostCodePriceMap(gasStationIDToGasPriceMap)
-	postcodePostcodeLocationMap := createPostcodePostcodeLocationMap(&postcodeGasPriceMap)
+func (service *GasStationService) updateCountyStatePrices(gasStationIDToGasPriceMap *map[string]gsmodel.GasPrice) (int, error) {
+	postcodeGasPriceMap := service.createPostCodePriceMap(gasStationIDToGasPriceMap)
+	postcodePostcodeLocationMap := service.createPostcodePostcodeLocationMap(&postcodeGasPriceMap)
 	modifiedStatesMap := make(map[int]pcmodel.StateData)
 	modifiedCountiesMap := make(map[int]pcmodel.CountyData)
 	//update avg prices
@@ -304,25 +358,32 @@ func updateCountyStatePrices(gasStationIDToGasPriceMap *map[string]gsmodel.GasPr
 			modifiedStatesMap[int(myPostcodeLocation.StateData.ID)] = myStateData
 		}
 	}
-	database.DB.Transaction(func(tx *gorm.DB) error {
+	err := service.postCodeRepo.Transaction(func(repo postcode.PostCodeRepo) error {
 		for _, myStateData := range modifiedStatesMap {
-			tx.Save(myStateData)
+			if err := repo.SaveStateData(&myStateData); err != nil {
+				return err
+			}
 		}
 		for _, myCountyData := range modifiedCountiesMap {
-			tx.Save(myCountyData)
+			if err := repo.SaveCountyData(&myCountyData); err != nil {
+				return err
+			}
 		}
 		return nil
 	})
-	return len(postcodePostcodeLocationMap)
+	if err != nil {
+		return 0, fmt.Errorf("county and state prices not updated: %w", err)
+	}
+	return len(postcodePostcodeLocationMap), nil
 }
 
-func sendNotifications(gasStationIDToGasPriceMap *map[string]gsmodel.GasPrice) {
+func (service *GasStationService) sendNotifications(gasStationIDToGasPriceMap *map[string]gsmodel.GasPrice) {
 	var gasStationIds []string
 	for key := range *gasStationIDToGasPriceMap {
 		gasStationIds = append(gasStationIds, key)
 	}
-	gasStations := findByIds(&gasStationIds)
-	notification.SendNotifications(gasStationIDToGasPriceMap, gasStations)
+	gasStations := service.gasStationRepo.FindByIds(gasStationIds, true)
+	service.priceNotifier.SendNotifications(gasStationIDToGasPriceMap, gasStations)
 }
 
 func createInChunks(ids *[]string, chunkedSelects bool) [][]string {
@@ -342,22 +403,8 @@ func createChunks(ids *[]string) [][]string {
 	return createInChunks(ids, cunckedSelects == "true")
 }
 
-func findByIds(ids *[]string) []gsmodel.GasStation {
-	var result []gsmodel.GasStation
-	chuncks := createChunks(ids)
-	database.DB.Transaction(func(tx *gorm.DB) error {
-		for _, chunk := range chuncks {
-			var values []gsmodel.GasStation
-			tx.Where("id in ?", chunk).Find(&values)
-			result = append(result, values...)
-		}
-		return nil
-	})
-	return result
-}
-
-func calcMinMaxSquare(longitude float64, latitude float64, radius float64) minMaxSquare {
-	minMax := minMaxSquare{MinLat: 1000.0, MinLng: 1000.0, MaxLat: 0.0, MaxLng: 0.0}
+func calcMinMaxSquare(longitude float64, latitude float64, radius float64) MinMaxSquare {
+	minMax := MinMaxSquare{MinLat: 1000.0, MinLng: 1000.0, MaxLat: 0.0, MaxLng: 0.0}
 	//fmt.Printf("StartLat: %v, StartLng: %v Radius: %v\n", searchLocation.Latitude, searchLocation.Longitude, searchLocation.Radius)
 	//max supported radius 20km and add 0.1 for floation point side effects
 	northLat, northLng := gsmodel.CalcLocation(latitude, longitude, radius, 0.0)
@@ -385,7 +432,7 @@ func chunkSlice[T any](mySlice []T, chunkSize int) (s [][]T) {
 	return result
 }
 
-func updateMinMaxSquare(newLat float64, newLng float64, minMax minMaxSquare) minMaxSquare {
+func updateMinMaxSquare(newLat float64, newLng float64, minMax MinMaxSquare) MinMaxSquare {
 	if newLat > minMax.MaxLat {
 		minMax.MaxLat = newLat
 	}
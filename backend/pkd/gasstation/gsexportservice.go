@@ -0,0 +1,86 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"errors"
+	"fmt"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"react-and-go/pkd/postcode"
+	"strings"
+	"time"
+)
+
+var ErrInvalidExportFilter = errors.New("stids, postcodes, county or bounding box and a time range are required")
+var ErrExportBeforeRetention = errors.New("the time range starts before the price retention, older prices are only kept as daily aggregates")
+
+type PriceExportFilter struct {
+	Stids     []string
+	PostCodes []string
+	County    string
+	MinMax    *MinMaxSquare
+	From      time.Time
+	To        time.Time
+}
+
+func (filter PriceExportFilter) Validate() error {
+	if (len(filter.Stids) == 0 && len(filter.PostCodes) == 0 && len(strings.TrimSpace(filter.County)) == 0 && filter.MinMax == nil) ||
+		filter.From.IsZero() || !filter.To.After(filter.From) {
+		return ErrInvalidExportFilter
+	}
+	if myRetentionStart := priceRetentionStart(time.Now()); filter.From.Before(myRetentionStart) {
+		return fmt.Errorf("%w, first day: %v", ErrExportBeforeRetention, myRetentionStart.Format(time.DateOnly))
+	}
+	return nil
+}
+
+// returns the stids of the union of the filter selections
+func (service *GasStationService) FindExportStids(filter PriceExportFilter) []string {
+	stidMap := make(map[string]bool)
+	for _, myStid := range filter.Stids {
+		stidMap[strings.TrimSpace(myStid)] = true
+	}
+	myPostCodes := append([]string{}, filter.PostCodes...)
+	if myCounty := strings.ToLower(strings.TrimSpace(filter.County)); len(myCounty) > 0 {
+		for _, myPostCodeLocation := range service.postCodeRepo.FindAllPostCodeLocations(true) {
+			if strings.ToLower(strings.TrimSpace(myPostCodeLocation.CountyData.County)) == myCounty {
+				myPostCodes = append(myPostCodes, postcode.FormatPostCode(myPostCodeLocation.PostCode))
+			}
+		}
+	}
+	if len(myPostCodes) > 0 {
+		for _, myGasStation := range service.gasStationRepo.FindByPostCodes(myPostCodes) {
+			stidMap[myGasStation.ID] = true
+		}
+	}
+	if filter.MinMax != nil {
+		for _, myGasStation := range service.gasStationRepo.FindInSquare(*filter.MinMax, 0) {
+			stidMap[myGasStation.ID] = true
+		}
+	}
+	result := []string{}
+	for myStid := range stidMap {
+		if len(myStid) > 0 {
+			result = append(result, myStid)
+		}
+	}
+	return result
+}
+
+// the prices are handed to the handler one by one ordered by station and date, the export stops with the first error of the handler
+func (service *GasStationService) StreamPrices(filter PriceExportFilter, handler func(gasPrice gsmodel.GasPrice) error) error {
+	if err := filter.Validate(); err != nil {
+		return err
+	}
+	return service.gasStationRepo.StreamPricesByStidsInRange(service.FindExportStids(filter), filter.From, filter.To, handler)
+}
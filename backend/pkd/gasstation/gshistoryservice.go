@@ -0,0 +1,235 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"errors"
+	"fmt"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"sort"
+	"strings"
+	"time"
+)
+
+type BucketSize string
+
+const (
+	QuarterHour BucketSize = "15m"
+	Hour        BucketSize = "hour"
+	DayBucket   BucketSize = "day"
+	Week        BucketSize = "week"
+)
+
+const maxHistoryBuckets = 5000
+
+var ErrInvalidPriceHistory = errors.New("invalid price history request")
+
+type PriceBucket struct {
+	Start time.Time
+	Open  int
+	High  int
+	Low   int
+	Close int
+	Mean  float64
+	Count int
+}
+
+// a raw price or the daily aggregate of a fuel type
+type priceSample struct {
+	Date  time.Time
+	Open  int
+	High  int
+	Low   int
+	Close int
+	Sum   float64
+	Count int
+}
+
+type PriceHistory struct {
+	GasStationID string
+	From         time.Time
+	To           time.Time
+	Bucket       BucketSize
+	E5           []PriceBucket
+	E10          []PriceBucket
+	Diesel       []PriceBucket
+}
+
+func ParseBucketSize(bucketStr string) (BucketSize, error) {
+	switch myBucket := BucketSize(strings.ToLower(strings.TrimSpace(bucketStr))); myBucket {
+	case QuarterHour, Hour, DayBucket, Week:
+		return myBucket, nil
+	case "":
+		return Hour, nil
+	default:
+		return "", fmt.Errorf("unsupported bucket size: %v", bucketStr)
+	}
+}
+
+func (service *GasStationService) FindPriceHistory(stid string, from time.Time, to time.Time, bucket BucketSize) (PriceHistory, error) {
+	result := PriceHistory{GasStationID: stid, From: from, To: to, Bucket: bucket, E5: []PriceBucket{}, E10: []PriceBucket{}, Diesel: []PriceBucket{}}
+	if !from.Before(to) {
+		return result, fmt.Errorf("%w: from: %v must be before to: %v", ErrInvalidPriceHistory, from, to)
+	}
+	if bucketNumber := int(to.Sub(from) / bucketDuration(bucket)); bucketNumber > maxHistoryBuckets {
+		return result, fmt.Errorf("%w: too many buckets: %v max: %v", ErrInvalidPriceHistory, bucketNumber, maxHistoryBuckets)
+	}
+	//the prices are read from the start of the first bucket
+	myStart := truncateToBucket(from, bucket)
+	//the days older than the retention period are only available as daily aggregates
+	myStartDay := time.Date(myStart.Year(), myStart.Month(), myStart.Day(), 0, 0, 0, 0, myStart.Location())
+	myGasPriceDailies, err := service.gasStationRepo.FindPriceDailiesByStidInRange(stid, myStartDay, to)
+	if err != nil {
+		return result, fmt.Errorf("find price dailies of %v: %w", stid, err)
+	}
+	myGasPrices, err := service.gasStationRepo.FindPricesByStidInRange(stid, myStart, to)
+	if err != nil {
+		return result, fmt.Errorf("find prices of %v: %w", stid, err)
+	}
+	//the prices in effect at the start of the first bucket
+	myLastGasPrice, lastPriceFound, err := service.gasStationRepo.FindLastPriceByStidBefore(stid, myStart)
+	if err != nil {
+		return result, fmt.Errorf("find last price of %v: %w", stid, err)
+	}
+	myLastGasPriceDaily, lastDailyFound, err := service.gasStationRepo.FindLastPriceDailyByStidBefore(stid, myStartDay)
+	if err != nil {
+		return result, fmt.Errorf("find last price daily of %v: %w", stid, err)
+	}
+	createFuelBuckets := func(fuelPrice func(gsmodel.GasPrice) int, fuelAggregate func(gsmodel.GasPriceDaily) gsmodel.FuelAggregate) []PriceBucket {
+		var myOpenPrice *int
+		if myAggregate := fuelAggregate(myLastGasPriceDaily); lastDailyFound && myAggregate.Count > 0 {
+			myOpenPrice = &myAggregate.Last
+		}
+		//the raw prices are younger than the daily aggregates
+		if myPrice := fuelPrice(myLastGasPrice); lastPriceFound && myPrice > 10 {
+			myOpenPrice = &myPrice
+		}
+		return createPriceBuckets(createPriceSamples(myGasPriceDailies, myGasPrices, fuelPrice, fuelAggregate), myOpenPrice, from, to, bucket)
+	}
+	result.E5 = createFuelBuckets(func(myGasPrice gsmodel.GasPrice) int { return myGasPrice.E5 },
+		func(myGasPriceDaily gsmodel.GasPriceDaily) gsmodel.FuelAggregate { return myGasPriceDaily.E5 })
+	result.E10 = createFuelBuckets(func(myGasPrice gsmodel.GasPrice) int { return myGasPrice.E10 },
+		func(myGasPriceDaily gsmodel.GasPriceDaily) gsmodel.FuelAggregate { return myGasPriceDaily.E10 })
+	result.Diesel = createFuelBuckets(func(myGasPrice gsmodel.GasPrice) int { return myGasPrice.Diesel },
+		func(myGasPriceDaily gsmodel.GasPriceDaily) gsmodel.FuelAggregate { return myGasPriceDaily.Diesel })
+	return result, nil
+}
+
+// the daily aggregates and the raw prices are merged in date order, prices below 10 mean that the fuel type is not available
+func createPriceSamples(gasPriceDailies []gsmodel.GasPriceDaily, gasPrices []gsmodel.GasPrice, fuelPrice func(gsmodel.GasPrice) int,
+	fuelAggregate func(gsmodel.GasPriceDaily) gsmodel.FuelAggregate) []priceSample {
+	result := []priceSample{}
+	dailyDays := make(map[string]bool)
+	for _, myGasPriceDaily := range gasPriceDailies {
+		dailyDays[myGasPriceDaily.Day.In(time.Local).Format(time.DateOnly)] = true
+		if myAggregate := fuelAggregate(myGasPriceDaily); myAggregate.Count > 0 {
+			result = append(result, priceSample{Date: myGasPriceDaily.Day, Open: myAggregate.First, High: myAggregate.Max, Low: myAggregate.Min,
+				Close: myAggregate.Last, Sum: myAggregate.Mean * float64(myAggregate.Count), Count: myAggregate.Count})
+		}
+	}
+	for _, myGasPrice := range gasPrices {
+		//the raw prices of a rolled up day are kept until their partition is dropped
+		if dailyDays[myGasPrice.Date.In(time.Local).Format(time.DateOnly)] {
+			continue
+		}
+		if myPrice := fuelPrice(myGasPrice); myPrice > 10 {
+			result = append(result, priceSample{Date: myGasPrice.Date, Open: myPrice, High: myPrice, Low: myPrice, Close: myPrice, Sum: float64(myPrice), Count: 1})
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Date.Before(result[j].Date)
+	})
+	return result
+}
+
+// the samples have to be sorted by date ascending, every bucket opens with the price in effect at its start
+// and the buckets without a price change carry the last price forward with a count of 0
+func createPriceBuckets(priceSamples []priceSample, openPrice *int, from time.Time, to time.Time, bucket BucketSize) []PriceBucket {
+	result := []PriceBucket{}
+	myLastPrice := openPrice
+	index := 0
+	for bucketStart := truncateToBucket(from, bucket); bucketStart.Before(to); bucketStart = nextBucketStart(bucketStart, bucket) {
+		bucketEnd := nextBucketStart(bucketStart, bucket)
+		var myBucket *PriceBucket
+		if myLastPrice != nil {
+			myBucket = &PriceBucket{Start: bucketStart, Open: *myLastPrice, High: *myLastPrice, Low: *myLastPrice, Close: *myLastPrice, Mean: float64(*myLastPrice)}
+		}
+		var sum float64 = 0
+		for ; index < len(priceSamples) && priceSamples[index].Date.Before(bucketEnd); index++ {
+			myPriceSample := priceSamples[index]
+			myClose := myPriceSample.Close
+			myLastPrice = &myClose
+			if myPriceSample.Date.Before(bucketStart) {
+				continue
+			}
+			if myBucket == nil {
+				myBucket = &PriceBucket{Start: bucketStart, Open: myPriceSample.Open, High: myPriceSample.High, Low: myPriceSample.Low}
+			}
+			if myPriceSample.High > myBucket.High {
+				myBucket.High = myPriceSample.High
+			}
+			if myPriceSample.Low < myBucket.Low {
+				myBucket.Low = myPriceSample.Low
+			}
+			myBucket.Close = myPriceSample.Close
+			myBucket.Count += myPriceSample.Count
+			sum += myPriceSample.Sum
+		}
+		if myBucket == nil {
+			continue
+		}
+		if myBucket.Count > 0 {
+			myBucket.Mean = sum / float64(myBucket.Count)
+		}
+		result = append(result, *myBucket)
+	}
+	return result
+}
+
+func truncateToBucket(myTime time.Time, bucket BucketSize) time.Time {
+	switch bucket {
+	case DayBucket:
+		return time.Date(myTime.Year(), myTime.Month(), myTime.Day(), 0, 0, 0, 0, myTime.Location())
+	case Week:
+		myDay := time.Date(myTime.Year(), myTime.Month(), myTime.Day(), 0, 0, 0, 0, myTime.Location())
+		//weeks start on monday
+		return myDay.AddDate(0, 0, -((int(myDay.Weekday()) + 6) % 7))
+	default:
+		return myTime.Truncate(bucketDuration(bucket))
+	}
+}
+
+// the day and week buckets follow the calendar over daylight saving time changes
+func nextBucketStart(bucketStart time.Time, bucket BucketSize) time.Time {
+	switch bucket {
+	case DayBucket:
+		return bucketStart.AddDate(0, 0, 1)
+	case Week:
+		return bucketStart.AddDate(0, 0, 7)
+	default:
+		return bucketStart.Add(bucketDuration(bucket))
+	}
+}
+
+func bucketDuration(bucket BucketSize) time.Duration {
+	switch bucket {
+	case QuarterHour:
+		return 15 * time.Minute
+	case DayBucket:
+		return 24 * time.Hour
+	case Week:
+		return 7 * 24 * time.Hour
+	default:
+		return time.Hour
+	}
+}
@@ -0,0 +1,152 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"react-and-go/pkd/gasstation/gsmodel"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseBucketSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    BucketSize
+		wantErr bool
+	}{
+		{"empty is hour", "", Hour, false},
+		{"quarter hour", "15m", QuarterHour, false},
+		{"upper case day", " DAY ", DayBucket, false},
+		{"week", "week", Week, false},
+		{"unsupported", "month", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseBucketSize(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseBucketSize(%q) error: %v wantErr: %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseBucketSize(%q) = %v want: %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncateToBucket(t *testing.T) {
+	myTime := time.Date(2024, 5, 16, 13, 47, 12, 0, time.UTC)
+	tests := []struct {
+		bucket BucketSize
+		want   time.Time
+	}{
+		{QuarterHour, time.Date(2024, 5, 16, 13, 45, 0, 0, time.UTC)},
+		{Hour, time.Date(2024, 5, 16, 13, 0, 0, 0, time.UTC)},
+		{DayBucket, time.Date(2024, 5, 16, 0, 0, 0, 0, time.UTC)},
+		{Week, time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.bucket), func(t *testing.T) {
+			if got := truncateToBucket(myTime, tt.bucket); !got.Equal(tt.want) {
+				t.Errorf("truncateToBucket(%v) = %v want: %v", tt.bucket, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreatePriceBuckets(t *testing.T) {
+	myFrom := time.Date(2024, 5, 16, 10, 0, 0, 0, time.UTC)
+	myTo := myFrom.Add(3 * time.Hour)
+	sample := func(minutes int, price int) priceSample {
+		return priceSample{Date: myFrom.Add(time.Duration(minutes) * time.Minute), Open: price, High: price, Low: price, Close: price, Sum: float64(price), Count: 1}
+	}
+	openPrice := 1800
+	tests := []struct {
+		name      string
+		samples   []priceSample
+		openPrice *int
+		want      []PriceBucket
+	}{
+		{"no prices", []priceSample{}, nil, []PriceBucket{}},
+		{"carried open price", []priceSample{}, &openPrice, []PriceBucket{
+			{Start: myFrom, Open: 1800, High: 1800, Low: 1800, Close: 1800, Mean: 1800},
+			{Start: myFrom.Add(time.Hour), Open: 1800, High: 1800, Low: 1800, Close: 1800, Mean: 1800},
+			{Start: myFrom.Add(2 * time.Hour), Open: 1800, High: 1800, Low: 1800, Close: 1800, Mean: 1800},
+		}},
+		{"open price and changes", []priceSample{sample(10, 1750), sample(40, 1850), sample(150, 1700)}, &openPrice, []PriceBucket{
+			{Start: myFrom, Open: 1800, High: 1850, Low: 1750, Close: 1850, Mean: 1800, Count: 2},
+			{Start: myFrom.Add(time.Hour), Open: 1850, High: 1850, Low: 1850, Close: 1850, Mean: 1850},
+			{Start: myFrom.Add(2 * time.Hour), Open: 1850, High: 1850, Low: 1700, Close: 1700, Mean: 1700, Count: 1},
+		}},
+		{"first price in the second bucket", []priceSample{sample(70, 1750), sample(80, 1760)}, nil, []PriceBucket{
+			{Start: myFrom.Add(time.Hour), Open: 1750, High: 1760, Low: 1750, Close: 1760, Mean: 1755, Count: 2},
+			{Start: myFrom.Add(2 * time.Hour), Open: 1760, High: 1760, Low: 1760, Close: 1760, Mean: 1760},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := createPriceBuckets(tt.samples, tt.openPrice, myFrom, myTo, Hour); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("createPriceBuckets() = %v want: %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindPriceHistory(t *testing.T) {
+	myFrom := time.Date(2024, 5, 16, 10, 0, 0, 0, time.UTC)
+	myRepo := NewGasStationMemRepo()
+	myRepo.SavePriceDailies([]gsmodel.GasPriceDaily{{GasStationID: "stid1", Day: time.Date(2024, 5, 14, 0, 0, 0, 0, time.UTC),
+		E5: gsmodel.FuelAggregate{First: 1900, Last: 1890, Min: 1880, Max: 1900, Mean: 1890, Count: 3}}})
+	myRepo.SavePrices([]gsmodel.GasPrice{
+		{GasStationID: "stid1", Date: myFrom.Add(-time.Hour), E5: 1800, E10: 1700, Diesel: 0},
+		{GasStationID: "stid1", Date: myFrom.Add(30 * time.Minute), E5: 1820, E10: 1700, Diesel: 1600},
+	})
+	myService := NewGasStationService(myRepo, nil, nil)
+	tests := []struct {
+		name    string
+		from    time.Time
+		to      time.Time
+		wantErr bool
+		wantE5  []int
+		wantE10 []int
+		wantDsl []int
+	}{
+		{"from after to", myFrom, myFrom, true, nil, nil, nil},
+		{"raw open price", myFrom, myFrom.Add(2 * time.Hour), false, []int{1800, 1820}, []int{1700, 1700}, []int{1600, 1600}},
+		{"daily open price", time.Date(2024, 5, 15, 22, 0, 0, 0, time.UTC), time.Date(2024, 5, 15, 23, 0, 0, 0, time.UTC), false, []int{1890}, []int{}, []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := myService.FindPriceHistory("stid1", tt.from, tt.to, Hour)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FindPriceHistory() error: %v wantErr: %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			for _, myFuel := range []struct {
+				buckets []PriceBucket
+				want    []int
+			}{{got.E5, tt.wantE5}, {got.E10, tt.wantE10}, {got.Diesel, tt.wantDsl}} {
+				myOpens := []int{}
+				for _, myBucket := range myFuel.buckets {
+					myOpens = append(myOpens, myBucket.Open)
+				}
+				if !reflect.DeepEqual(myOpens, myFuel.want) {
+					t.Errorf("FindPriceHistory() opens: %v want: %v", myOpens, myFuel.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"react-and-go/pkd/gasstation/gsmodel"
+	"sync"
+	"time"
+)
+
+// the latest price per station for the change detection of the price updates, missing stations are loaded from the db
+// the timestamps are the sequence of the updates per station including the updates without changes
+type latestPriceCache struct {
+	mutex      sync.Mutex
+	gasPrices  map[string]gsmodel.GasPrice
+	timestamps map[string]time.Time
+}
+
+func newLatestPriceCache() *latestPriceCache {
+	return &latestPriceCache{gasPrices: make(map[string]gsmodel.GasPrice), timestamps: make(map[string]time.Time)}
+}
+
+// the cached prices before 'since' count as missing like the prices outside of the db timeframe
+func (cache *latestPriceCache) find(stids []string, since time.Time) (map[string]gsmodel.GasPrice, []string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	result := make(map[string]gsmodel.GasPrice)
+	var missingStids []string
+	for _, myStid := range stids {
+		myGasPrice, found := cache.gasPrices[myStid]
+		if found && !myGasPrice.Date.Before(since) {
+			result[myStid] = myGasPrice
+			continue
+		}
+		delete(cache.gasPrices, myStid)
+		missingStids = append(missingStids, myStid)
+	}
+	return result, missingStids
+}
+
+// a loaded price does not replace a newer price of a concurrent update
+func (cache *latestPriceCache) load(gasPrices []gsmodel.GasPrice) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	for _, myGasPrice := range gasPrices {
+		if myCachedGasPrice, found := cache.gasPrices[myGasPrice.GasStationID]; !found || myCachedGasPrice.Date.Before(myGasPrice.Date) {
+			cache.gasPrices[myGasPrice.GasStationID] = myGasPrice
+		}
+		if myTimestamp, found := cache.timestamps[myGasPrice.GasStationID]; !found || myTimestamp.Before(myGasPrice.Date) {
+			cache.timestamps[myGasPrice.GasStationID] = myGasPrice.Date
+		}
+	}
+}
+
+// calculates the changes and stores the changed prices in one step, concurrent updates of a station can not both detect the same change
+// updates older than the last update of the station are stale, updates with the same timestamp are duplicates
+// the deltas are applied to the last price, updates with deltas and without a last price are rejected
+func (cache *latestPriceCache) update(stationPrices []GasStationPrices, lastGasPrices map[string]gsmodel.GasPrice,
+	calcChanges func(lastGasPrice gsmodel.GasPrice, found bool, stationPrices GasStationPrices) int) (map[string]gsmodel.GasPrice, PriceUpdateResult) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	result := make(map[string]gsmodel.GasPrice)
+	myPriceUpdateResult := PriceUpdateResult{Received: len(stationPrices)}
+	for _, myStationPrices := range stationPrices {
+		if myTimestamp, found := cache.timestamps[myStationPrices.GasStationID]; found && !myTimestamp.Before(myStationPrices.Timestamp) {
+			if myTimestamp.Equal(myStationPrices.Timestamp) {
+				myPriceUpdateResult.Duplicate += 1
+			} else {
+				myPriceUpdateResult.Stale += 1
+			}
+			continue
+		}
+		myLastGasPrice, found := cache.gasPrices[myStationPrices.GasStationID]
+		if !found {
+			myLastGasPrice, found = lastGasPrices[myStationPrices.GasStationID]
+		}
+		myStationPrices, ok := applyPriceDeltas(myLastGasPrice, found, myStationPrices)
+		if !ok {
+			myPriceUpdateResult.Rejected += 1
+			myPriceUpdateResult.RejectedStids = append(myPriceUpdateResult.RejectedStids, myStationPrices.GasStationID)
+			continue
+		}
+		cache.timestamps[myStationPrices.GasStationID] = myStationPrices.Timestamp
+		myChanges := calcChanges(myLastGasPrice, found, myStationPrices)
+		if myChanges == 0 {
+			myPriceUpdateResult.Unchanged += 1
+			continue
+		}
+		myGasPrice := gsmodel.GasPrice{GasStationID: myStationPrices.GasStationID, E5: myStationPrices.E5, E10: myStationPrices.E10,
+			Diesel: myStationPrices.Diesel, Date: myStationPrices.Timestamp, Changed: myChanges}
+		cache.gasPrices[myGasPrice.GasStationID] = myGasPrice
+		result[myGasPrice.GasStationID] = myGasPrice
+	}
+	myPriceUpdateResult.Updated = len(result)
+	return result, myPriceUpdateResult
+}
+
+// the removed stations are loaded again from the db with the next update, a redelivered update is accepted again
+func (cache *latestPriceCache) remove(stids []string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	for _, myStid := range stids {
+		delete(cache.gasPrices, myStid)
+		delete(cache.timestamps, myStid)
+	}
+}
@@ -0,0 +1,60 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gsmodel
+
+import "time"
+
+type FuelAggregate struct {
+	First int
+	Last  int
+	Min   int
+	Max   int
+	Mean  float64
+	Count int
+}
+
+// the prices of a station and a day, they replace the raw prices after the retention period
+type GasPriceDaily struct {
+	ID           int64         `gorm:"primaryKey"`
+	GasStationID string        `gorm:"column:stid;size:64;uniqueIndex:idx_gpd_stid_day"`
+	Day          time.Time     `gorm:"uniqueIndex:idx_gpd_stid_day;index:idx_gpd_day"`
+	E5           FuelAggregate `gorm:"embedded;embeddedPrefix:e5_"`
+	E10          FuelAggregate `gorm:"embedded;embeddedPrefix:e10_"`
+	Diesel       FuelAggregate `gorm:"embedded;embeddedPrefix:diesel_"`
+	Changes      int
+}
+
+func (GasPriceDaily) TableName() string {
+	return "gas_station_price_daily"
+}
+
+// adds a price in date order, prices below 10 mean that the fuel type is not available
+func (fuelAggregate *FuelAggregate) AddPrice(price int) {
+	if price <= 10 {
+		return
+	}
+	if fuelAggregate.Count == 0 {
+		fuelAggregate.First = price
+		fuelAggregate.Min = price
+		fuelAggregate.Max = price
+	}
+	if price < fuelAggregate.Min {
+		fuelAggregate.Min = price
+	}
+	if price > fuelAggregate.Max {
+		fuelAggregate.Max = price
+	}
+	fuelAggregate.Mean = (fuelAggregate.Mean*float64(fuelAggregate.Count) + float64(price)) / float64(fuelAggregate.Count+1)
+	fuelAggregate.Last = price
+	fuelAggregate.Count += 1
+}
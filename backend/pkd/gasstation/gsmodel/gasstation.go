@@ -36,9 +36,12 @@ type GasStation struct {
 	PriceChanged            time.Time
 	OpenTs                  int `gorm:"index:idx_open_ts"`
 	OtJson                  string
+	ApplicableDays          int
 	StationInImport         time.Time
 	FirstActive             time.Time
+	LifecycleStatus         string `gorm:"size:16;default:active;index:idx_gs_lifecycle_status"`
 	GasPrices               []GasPrice
+	OpeningTimes            WeeklySchedule `gorm:"-"`
 }
 
 type MyGasStation interface {
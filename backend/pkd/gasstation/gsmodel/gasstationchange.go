@@ -0,0 +1,29 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gsmodel
+
+import "time"
+
+type GasStationChange struct {
+	ID           int64  `gorm:"primaryKey"`
+	GasStationID string `gorm:"column:stid;index:idx_gsc_stid"`
+	Version      string
+	VersionTime  time.Time
+	Field        string `gorm:"size:32"`
+	OldValue     string
+	NewValue     string
+}
+
+func (GasStationChange) TableName() string {
+	return "gas_station_change"
+}
@@ -0,0 +1,274 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gsmodel
+
+import (
+	"encoding/json"
+	"log"
+	"slices"
+	"strings"
+	"time"
+	_ "time/tzdata"
+)
+
+// bit values of applicable_days in the Tankerkoenig opening times json
+const (
+	Monday        = 1
+	Tuesday       = 2
+	Wednesday     = 4
+	Thursday      = 8
+	Friday        = 16
+	Saturday      = 32
+	Sunday        = 64
+	PublicHoliday = 128
+)
+
+var weekdayBits = map[time.Weekday]int{time.Monday: Monday, time.Tuesday: Tuesday, time.Wednesday: Wednesday, time.Thursday: Thursday,
+	time.Friday: Friday, time.Saturday: Saturday, time.Sunday: Sunday}
+
+var stationLocation = loadStationLocation()
+
+type otJsonContainer struct {
+	OpeningTimes []otJsonRule `json:"openingTimes"`
+}
+
+type otJsonRule struct {
+	ApplicableDays int            `json:"applicable_days"`
+	Periods        []otJsonPeriod `json:"periods"`
+}
+
+type otJsonPeriod struct {
+	Startp string `json:"startp"`
+	Endp   string `json:"endp"`
+}
+
+type TimePeriod struct {
+	Start string
+	End   string
+}
+
+type DaySchedule struct {
+	Day     string
+	Periods []TimePeriod
+}
+
+type WeeklySchedule struct {
+	Known                   bool
+	Days                    []DaySchedule
+	PublicHolidays          []TimePeriod
+	applicableDays          int
+	dayPeriods              map[int][]TimePeriod
+	publicHolidayIdentifier string
+}
+
+// the public holiday identifier is the state of the station, an empty identifier selects only the nationwide holidays
+func ParseOpeningTimes(otJson string, publicHolidayIdentifier string) WeeklySchedule {
+	result := WeeklySchedule{Known: false, Days: []DaySchedule{}, PublicHolidays: []TimePeriod{}, dayPeriods: make(map[int][]TimePeriod),
+		publicHolidayIdentifier: publicHolidayIdentifier}
+	if len(strings.TrimSpace(otJson)) < 3 {
+		return result
+	}
+	var myOtJson otJsonContainer
+	if err := json.Unmarshal([]byte(otJson), &myOtJson); err != nil {
+		log.Printf("Opening times unmarshal failed: %v\n", err.Error())
+		return result
+	}
+	for _, myRule := range myOtJson.OpeningTimes {
+		for _, myPeriod := range myRule.Periods {
+			myPeriod.Startp = normalizeClockTime(myPeriod.Startp)
+			myPeriod.Endp = normalizeClockTime(myPeriod.Endp)
+			if !isValidClockTime(myPeriod.Startp) || !isValidClockTime(myPeriod.Endp) {
+				log.Printf("Invalid opening period: %v - %v\n", myPeriod.Startp, myPeriod.Endp)
+				continue
+			}
+			for dayBit := Monday; dayBit <= PublicHoliday; dayBit = dayBit << 1 {
+				if myRule.ApplicableDays&dayBit > 0 {
+					result.dayPeriods[dayBit] = append(result.dayPeriods[dayBit], TimePeriod{Start: myPeriod.Startp, End: myPeriod.Endp})
+					result.applicableDays |= dayBit
+				}
+			}
+		}
+	}
+	result.Known = result.applicableDays > 0
+	for _, myWeekday := range []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday} {
+		myPeriods := result.dayPeriods[weekdayBits[myWeekday]]
+		if myPeriods == nil {
+			myPeriods = []TimePeriod{}
+		}
+		result.Days = append(result.Days, DaySchedule{Day: myWeekday.String(), Periods: myPeriods})
+	}
+	if myPeriods, ok := result.dayPeriods[PublicHoliday]; ok {
+		result.PublicHolidays = myPeriods
+	}
+	return result
+}
+
+// returns the bits of the days with opening periods, 0 if the opening times are unknown
+func (weeklySchedule WeeklySchedule) ApplicableDays() int {
+	return weeklySchedule.applicableDays
+}
+
+// stations with unknown opening times are treated as open
+func (weeklySchedule WeeklySchedule) IsOpenAt(myTime time.Time) bool {
+	if !weeklySchedule.Known {
+		return true
+	}
+	localTime := myTime.In(stationLocation)
+	dayBit := weekdayBits[localTime.Weekday()]
+	if IsPublicHoliday(localTime, weeklySchedule.publicHolidayIdentifier) && weeklySchedule.applicableDays&PublicHoliday > 0 {
+		dayBit = PublicHoliday
+	}
+	clockTime := localTime.Format("15:04")
+	for _, myPeriod := range weeklySchedule.dayPeriods[dayBit] {
+		if isInPeriod(clockTime, myPeriod) {
+			return true
+		}
+	}
+	//periods of the previous day that end after midnight
+	previousTime := localTime.AddDate(0, 0, -1)
+	previousDayBit := weekdayBits[previousTime.Weekday()]
+	if IsPublicHoliday(previousTime, weeklySchedule.publicHolidayIdentifier) && weeklySchedule.applicableDays&PublicHoliday > 0 {
+		previousDayBit = PublicHoliday
+	}
+	for _, myPeriod := range weeklySchedule.dayPeriods[previousDayBit] {
+		if myPeriod.End < myPeriod.Start && clockTime < myPeriod.End {
+			return true
+		}
+	}
+	return false
+}
+
+// the holidays of a state, the month is 0 for the holidays relative to easter sunday
+type stateHoliday struct {
+	month        time.Month
+	day          int
+	easterOffset int
+	states       []string
+}
+
+// the states are named like in the postcode import, the holidays of single municipalities are not included
+var stateHolidays = []stateHoliday{
+	//Heilige Drei Koenige
+	{month: time.January, day: 6, states: []string{"Baden-Württemberg", "Bayern", "Sachsen-Anhalt"}},
+	//Internationaler Frauentag
+	{month: time.March, day: 8, states: []string{"Berlin", "Mecklenburg-Vorpommern"}},
+	//Ostersonntag and Pfingstsonntag
+	{easterOffset: 0, states: []string{"Brandenburg"}},
+	{easterOffset: 49, states: []string{"Brandenburg"}},
+	//Fronleichnam
+	{easterOffset: 60, states: []string{"Baden-Württemberg", "Bayern", "Hessen", "Nordrhein-Westfalen", "Rheinland-Pfalz", "Saarland"}},
+	//Mariae Himmelfahrt
+	{month: time.August, day: 15, states: []string{"Saarland"}},
+	//Weltkindertag
+	{month: time.September, day: 20, states: []string{"Thüringen"}},
+	//Reformationstag
+	{month: time.October, day: 31, states: []string{"Brandenburg", "Bremen", "Hamburg", "Mecklenburg-Vorpommern", "Niedersachsen", "Sachsen",
+		"Sachsen-Anhalt", "Schleswig-Holstein", "Thüringen"}},
+	//Allerheiligen
+	{month: time.November, day: 1, states: []string{"Baden-Württemberg", "Bayern", "Nordrhein-Westfalen", "Rheinland-Pfalz", "Saarland"}},
+}
+
+// the German nationwide public holidays and the holidays of the state in the public holiday identifier
+func IsPublicHoliday(myTime time.Time, publicHolidayIdentifier string) bool {
+	localTime := myTime.In(stationLocation)
+	year, month, day := localTime.Date()
+	switch {
+	case month == time.January && day == 1, month == time.May && day == 1, month == time.October && day == 3,
+		month == time.December && (day == 25 || day == 26):
+		return true
+	}
+	easterSunday := calcEasterSunday(year)
+	myDay := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	for _, offsetDays := range []int{-2, 1, 39, 50} {
+		if myDay.Equal(easterSunday.AddDate(0, 0, offsetDays)) {
+			return true
+		}
+	}
+	myState := strings.TrimSpace(publicHolidayIdentifier)
+	if len(myState) == 0 {
+		return false
+	}
+	for _, myHoliday := range stateHolidays {
+		if !slices.Contains(myHoliday.states, myState) {
+			continue
+		}
+		if (myHoliday.month == 0 && myDay.Equal(easterSunday.AddDate(0, 0, myHoliday.easterOffset))) ||
+			(myHoliday.month == month && myHoliday.day == day) {
+			return true
+		}
+	}
+	//Buss- und Bettag is the wednesday before the 23rd of november
+	if myState == "Sachsen" && month == time.November && localTime.Weekday() == time.Wednesday && day >= 16 && day <= 22 {
+		return true
+	}
+	return false
+}
+
+// Gauss/Meeus algorithm for the gregorian calendar
+func calcEasterSunday(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := ((h + l - 7*m + 114) % 31) + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+func isInPeriod(clockTime string, myPeriod TimePeriod) bool {
+	//24:00 and 00:00 as end mean open until midnight
+	if myPeriod.End == "24:00" || (myPeriod.End == "00:00" && myPeriod.Start != "00:00") {
+		return clockTime >= myPeriod.Start
+	}
+	if myPeriod.Start == myPeriod.End {
+		return true
+	}
+	if myPeriod.End < myPeriod.Start {
+		return clockTime >= myPeriod.Start
+	}
+	return clockTime >= myPeriod.Start && clockTime < myPeriod.End
+}
+
+// the clock times can contain seconds
+func normalizeClockTime(clockTime string) string {
+	myClockTime := strings.TrimSpace(clockTime)
+	if len(myClockTime) == 8 {
+		return myClockTime[:5]
+	}
+	return myClockTime
+}
+
+func isValidClockTime(clockTime string) bool {
+	if clockTime == "24:00" {
+		return true
+	}
+	_, err := time.Parse("15:04", clockTime)
+	return err == nil
+}
+
+func loadStationLocation() *time.Location {
+	myLocation, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		log.Printf("Failed to load location Europe/Berlin: %v\n", err.Error())
+		return time.Local
+	}
+	return myLocation
+}
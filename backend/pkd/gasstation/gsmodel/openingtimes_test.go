@@ -0,0 +1,131 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gsmodel
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCalcEasterSunday(t *testing.T) {
+	tests := []struct {
+		year int
+		want time.Time
+	}{
+		{2019, time.Date(2019, time.April, 21, 0, 0, 0, 0, time.UTC)},
+		{2024, time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC)},
+		{2025, time.Date(2025, time.April, 20, 0, 0, 0, 0, time.UTC)},
+		{2038, time.Date(2038, time.April, 25, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := calcEasterSunday(tt.year); !got.Equal(tt.want) {
+			t.Errorf("calcEasterSunday(%v) = %v want: %v", tt.year, got, tt.want)
+		}
+	}
+}
+
+func TestIsPublicHoliday(t *testing.T) {
+	berlinDay := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 12, 0, 0, 0, stationLocation)
+	}
+	tests := []struct {
+		name  string
+		day   time.Time
+		state string
+		want  bool
+	}{
+		{"new year", berlinDay(2024, time.January, 1), "", true},
+		{"good friday", berlinDay(2024, time.March, 29), "", true},
+		{"whit monday", berlinDay(2024, time.May, 20), "Bayern", true},
+		{"working day", berlinDay(2024, time.May, 21), "Bayern", false},
+		{"corpus christi without state", berlinDay(2024, time.May, 30), "", false},
+		{"corpus christi in bavaria", berlinDay(2024, time.May, 30), "Bayern", true},
+		{"corpus christi in saxony", berlinDay(2024, time.May, 30), "Sachsen", false},
+		{"reformation day in saxony", berlinDay(2024, time.October, 31), "Sachsen", true},
+		{"reformation day in bavaria", berlinDay(2024, time.October, 31), "Bayern", false},
+		{"repentance day in saxony", berlinDay(2024, time.November, 20), "Sachsen", true},
+		{"repentance day in berlin", berlinDay(2024, time.November, 20), "Berlin", false},
+		{"womens day in berlin", berlinDay(2024, time.March, 8), "Berlin", true},
+		{"late evening utc", time.Date(2024, time.December, 24, 23, 30, 0, 0, time.UTC), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsPublicHoliday(tt.day, tt.state); got != tt.want {
+				t.Errorf("IsPublicHoliday(%v, %q) = %v want: %v", tt.day, tt.state, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOpeningTimes(t *testing.T) {
+	tests := []struct {
+		name         string
+		otJson       string
+		wantKnown    bool
+		wantDays     int
+		wantMonday   int
+		wantHolidays int
+	}{
+		{"empty", "", false, 0, 0, 0},
+		{"invalid json", "{openingTimes", false, 0, 0, 0},
+		{"weekdays", `{"openingTimes":[{"applicable_days":31,"periods":[{"startp":"06:00","endp":"22:00"}]}]}`, true, 31, 1, 0},
+		{"seconds and holidays", `{"openingTimes":[{"applicable_days":129,"periods":[{"startp":"06:00:00","endp":"12:00:00"},{"startp":"14:00","endp":"20:00"}]}]}`,
+			true, 129, 2, 2},
+		{"invalid period", `{"openingTimes":[{"applicable_days":1,"periods":[{"startp":"6 Uhr","endp":"22:00"}]}]}`, false, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseOpeningTimes(tt.otJson, "")
+			if got.Known != tt.wantKnown || got.ApplicableDays() != tt.wantDays || len(got.PublicHolidays) != tt.wantHolidays {
+				t.Fatalf("ParseOpeningTimes() known: %v days: %v holidays: %v want: %v %v %v", got.Known, got.ApplicableDays(), len(got.PublicHolidays),
+					tt.wantKnown, tt.wantDays, tt.wantHolidays)
+			}
+			if len(got.Days) > 0 && (len(got.Days) != 7 || len(got.Days[0].Periods) != tt.wantMonday) {
+				t.Errorf("ParseOpeningTimes() days: %v want monday periods: %v", got.Days, tt.wantMonday)
+			}
+		})
+	}
+}
+
+func TestIsOpenAt(t *testing.T) {
+	weekdays := `{"openingTimes":[{"applicable_days":31,"periods":[{"startp":"06:00","endp":"22:00"}]},` +
+		`{"applicable_days":128,"periods":[{"startp":"10:00","endp":"12:00"}]}]}`
+	night := `{"openingTimes":[{"applicable_days":16,"periods":[{"startp":"22:00","endp":"02:00"}]}]}`
+	berlinTime := func(month time.Month, day int, hour int) time.Time {
+		return time.Date(2024, month, day, hour, 30, 0, 0, stationLocation)
+	}
+	tests := []struct {
+		name   string
+		otJson string
+		state  string
+		time   time.Time
+		want   bool
+	}{
+		{"unknown is open", "", "", berlinTime(time.May, 21, 3), true},
+		{"weekday open", weekdays, "", berlinTime(time.May, 21, 8), true},
+		{"weekday closed", weekdays, "", berlinTime(time.May, 21, 22), false},
+		{"sunday closed", weekdays, "", berlinTime(time.May, 19, 11), false},
+		{"corpus christi without state", weekdays, "", berlinTime(time.May, 30, 14), true},
+		{"corpus christi holiday hours", weekdays, "Bayern", berlinTime(time.May, 30, 14), false},
+		{"corpus christi holiday open", weekdays, "Bayern", berlinTime(time.May, 30, 11), true},
+		{"after midnight", night, "", berlinTime(time.May, 18, 1), true},
+		{"night closed", night, "", berlinTime(time.May, 18, 3), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseOpeningTimes(tt.otJson, tt.state).IsOpenAt(tt.time); got != tt.want {
+				t.Errorf("IsOpenAt(%v) = %v want: %v", tt.time, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gsmodel
+
+import "time"
+
+const (
+	ImportRunImported = "imported"
+	ImportRunSkipped  = "skipped"
+	ImportRunFailed   = "failed"
+)
+
+type StationImportRun struct {
+	ID                int64  `gorm:"primaryKey"`
+	Source            string `gorm:"size:16"`
+	Location          string
+	Checksum          string    `gorm:"size:64;index:idx_sir_checksum"`
+	StartTime         time.Time `gorm:"index:idx_sir_start_time"`
+	EndTime           time.Time
+	Status            string `gorm:"size:16"`
+	Rows              int
+	InvalidRows       int
+	Stations          int
+	Updated           int
+	Created           int
+	LifecycleChanges  int
+	MasterDataChanges int
+	Error             string
+}
+
+func (StationImportRun) TableName() string {
+	return "gas_station_import_run"
+}
+
+// a quarantined row of a station import
+type StationImportError struct {
+	ID                 int64 `gorm:"primaryKey"`
+	StationImportRunID int64 `gorm:"index:idx_sie_station_import_run_id"`
+	Line               int
+	Uuid               string
+	Field              string
+	Message            string
+	Row                string
+}
+
+func (StationImportError) TableName() string {
+	return "gas_station_import_error"
+}
@@ -0,0 +1,59 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"react-and-go/pkd/gasstation/gsmodel"
+	"react-and-go/pkd/workpool"
+)
+
+// the stages after a price update run on bounded pools, a full queue slows down the price updates
+type pricePostProcessing struct {
+	publishPool      *workpool.WorkPool
+	notificationPool *workpool.WorkPool
+	countyStatePool  *workpool.WorkPool
+}
+
+// the county and state averages are updated by reading, adjusting and saving them, a second worker would overwrite the updates of the first
+const countyStateWorkers = 1
+
+func newPricePostProcessing() *pricePostProcessing {
+	myWorkers := workpool.EnvInt("PRICE_POSTPROCESS_WORKERS", 2)
+	myQueueSize := workpool.EnvInt("PRICE_POSTPROCESS_QUEUE_SIZE", 100)
+	return &pricePostProcessing{publishPool: workpool.NewWorkPool("publishPriceChanges", myWorkers, myQueueSize),
+		notificationPool: workpool.NewWorkPool("sendNotifications", myWorkers, myQueueSize),
+		countyStatePool:  workpool.NewWorkPool("updateCountyStatePrices", countyStateWorkers, myQueueSize)}
+}
+
+func (service *GasStationService) postProcessPrices(gasPriceUpdateMap map[string]gsmodel.GasPrice) {
+	if len(gasPriceUpdateMap) == 0 {
+		return
+	}
+	service.pricePostProcessing.publishPool.Submit(func() error {
+		service.publishPriceChanges(&gasPriceUpdateMap)
+		return nil
+	})
+	service.pricePostProcessing.notificationPool.Submit(func() error {
+		service.sendNotifications(&gasPriceUpdateMap)
+		return nil
+	})
+	service.pricePostProcessing.countyStatePool.Submit(func() error {
+		_, err := service.updateCountyStatePrices(&gasPriceUpdateMap)
+		return err
+	})
+}
+
+func (service *GasStationService) PostProcessingMetrics() []workpool.WorkPoolMetrics {
+	return []workpool.WorkPoolMetrics{service.pricePostProcessing.publishPool.Metrics(), service.pricePostProcessing.notificationPool.Metrics(),
+		service.pricePostProcessing.countyStatePool.Metrics()}
+}
@@ -0,0 +1,96 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"react-and-go/pkd/gasstation/gsmodel"
+	"sort"
+	"time"
+)
+
+type PriceImportState struct {
+	latestGasPrices map[string]gsmodel.GasPrice
+	gasStationIds   map[string]bool
+}
+
+// the state has to be created for the day of the first imported file, the files have to be imported in date order
+func (service *GasStationService) CreatePriceImportState(before time.Time) *PriceImportState {
+	result := &PriceImportState{latestGasPrices: make(map[string]gsmodel.GasPrice), gasStationIds: make(map[string]bool)}
+	for _, myGasStation := range service.gasStationRepo.FindAll(false) {
+		result.gasStationIds[myGasStation.ID] = true
+	}
+	for _, myGasPrice := range service.gasStationRepo.FindLatestPricesBefore(before) {
+		result.latestGasPrices[myGasPrice.GasStationID] = myGasPrice
+	}
+	return result
+}
+
+func (service *GasStationService) FindPriceImportFiles() []gsmodel.PriceImportFile {
+	return service.gasStationRepo.FindPriceImportFiles()
+}
+
+// stores the changed prices like UpdatePrice without the age check and the notifications, the file record is saved in the same transaction
+func (service *GasStationService) ImportPrices(fileName string, gasStationPrices []GasStationPrices, invalidRows int, state *PriceImportState) (gsmodel.PriceImportFile, error) {
+	sort.SliceStable(gasStationPrices, func(i, j int) bool {
+		return gasStationPrices[i].Timestamp.Before(gasStationPrices[j].Timestamp)
+	})
+	result := gsmodel.PriceImportFile{FileName: fileName, ImportTime: time.Now(), Rows: len(gasStationPrices) + invalidRows, InvalidRows: invalidRows}
+	//the state is updated after the commit
+	updatedGasPrices := make(map[string]gsmodel.GasPrice)
+	var gasPrices []gsmodel.GasPrice
+	for _, myGasStationPrices := range gasStationPrices {
+		if !state.gasStationIds[myGasStationPrices.GasStationID] {
+			result.Unknown += 1
+			continue
+		}
+		myLastGasPrice, found := updatedGasPrices[myGasStationPrices.GasStationID]
+		if !found {
+			myLastGasPrice, found = state.latestGasPrices[myGasStationPrices.GasStationID]
+		}
+		myChanges := calcPriceChanges(myLastGasPrice, myGasStationPrices)
+		if !found && myChanges > 0 {
+			myChanges = 21
+		}
+		if myChanges == 0 {
+			continue
+		}
+		myGasPrice := gsmodel.GasPrice{GasStationID: myGasStationPrices.GasStationID, E5: myGasStationPrices.E5, E10: myGasStationPrices.E10,
+			Diesel: myGasStationPrices.Diesel, Date: myGasStationPrices.Timestamp, Changed: myChanges}
+		gasPrices = append(gasPrices, myGasPrice)
+		updatedGasPrices[myGasPrice.GasStationID] = myGasPrice
+	}
+	result.Prices = len(gasPrices)
+	err := service.gasStationRepo.Transaction(func(repo GasStationRepo) error {
+		if len(gasPrices) > 0 {
+			if err := repo.CreatePricePartitions(gasPrices[0].Date, gasPrices[len(gasPrices)-1].Date); err != nil {
+				return err
+			}
+			if err := repo.CreatePrices(gasPrices); err != nil {
+				return err
+			}
+		}
+		repo.SavePriceImportFile(result)
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	var myStids []string
+	for myStid, myGasPrice := range updatedGasPrices {
+		state.latestGasPrices[myStid] = myGasPrice
+		myStids = append(myStids, myStid)
+	}
+	//the imported prices can be newer than the cached prices of the price updates
+	service.latestPriceCache.remove(myStids)
+	return result, nil
+}
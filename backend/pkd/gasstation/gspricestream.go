@@ -0,0 +1,156 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"log"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const priceChangeBufferSize = 100
+
+type PriceChangeEvent struct {
+	GasStationID string
+	StationName  string
+	Brand        string
+	PostCode     string
+	Latitude     float64
+	Longitude    float64
+	E5           int
+	E10          int
+	Diesel       int
+	Date         time.Time
+	Changed      int
+}
+
+type PriceChangeFilter struct {
+	MinMax    *MinMaxSquare
+	PostCodes []string
+	FuelTypes []FuelType
+}
+
+type PriceChangeSubscription struct {
+	Events  chan PriceChangeEvent
+	filter  PriceChangeFilter
+	dropped atomic.Int64
+}
+
+type priceChangeBroker struct {
+	mutex         sync.RWMutex
+	subscriptions map[*PriceChangeSubscription]bool
+}
+
+func newPriceChangeBroker() *priceChangeBroker {
+	return &priceChangeBroker{subscriptions: make(map[*PriceChangeSubscription]bool)}
+}
+
+func (service *GasStationService) SubscribePriceChanges(filter PriceChangeFilter) *PriceChangeSubscription {
+	subscription := &PriceChangeSubscription{Events: make(chan PriceChangeEvent, priceChangeBufferSize), filter: filter}
+	service.priceChangeBroker.mutex.Lock()
+	defer service.priceChangeBroker.mutex.Unlock()
+	service.priceChangeBroker.subscriptions[subscription] = true
+	log.Printf("Price change subscriptions: %v\n", len(service.priceChangeBroker.subscriptions))
+	return subscription
+}
+
+func (service *GasStationService) UnsubscribePriceChanges(subscription *PriceChangeSubscription) {
+	service.priceChangeBroker.mutex.Lock()
+	defer service.priceChangeBroker.mutex.Unlock()
+	if _, ok := service.priceChangeBroker.subscriptions[subscription]; ok {
+		delete(service.priceChangeBroker.subscriptions, subscription)
+		close(subscription.Events)
+		if subscription.dropped.Load() > 0 {
+			log.Printf("Price change subscription dropped events: %v\n", subscription.dropped.Load())
+		}
+	}
+}
+
+func (service *GasStationService) publishPriceChanges(gasStationIDToGasPriceMap *map[string]gsmodel.GasPrice) {
+	service.priceChangeBroker.mutex.RLock()
+	subscriptionNum := len(service.priceChangeBroker.subscriptions)
+	service.priceChangeBroker.mutex.RUnlock()
+	if subscriptionNum == 0 || len(*gasStationIDToGasPriceMap) == 0 {
+		return
+	}
+	var gasStationIds []string
+	for key := range *gasStationIDToGasPriceMap {
+		gasStationIds = append(gasStationIds, key)
+	}
+	var priceChangeEvents []PriceChangeEvent
+	for _, myGasStation := range service.gasStationRepo.FindByIds(gasStationIds, true) {
+		myGasPrice := (*gasStationIDToGasPriceMap)[myGasStation.ID]
+		priceChangeEvents = append(priceChangeEvents, PriceChangeEvent{GasStationID: myGasStation.ID, StationName: myGasStation.StationName, Brand: myGasStation.Brand,
+			PostCode: myGasStation.PostCode, Latitude: myGasStation.Latitude, Longitude: myGasStation.Longitude, E5: myGasPrice.E5, E10: myGasPrice.E10,
+			Diesel: myGasPrice.Diesel, Date: myGasPrice.Date, Changed: myGasPrice.Changed})
+	}
+	service.priceChangeBroker.mutex.RLock()
+	defer service.priceChangeBroker.mutex.RUnlock()
+	for subscription := range service.priceChangeBroker.subscriptions {
+		for _, myPriceChangeEvent := range priceChangeEvents {
+			if !subscription.filter.matches(myPriceChangeEvent) {
+				continue
+			}
+			//a slow client must not block the price updates
+			select {
+			case subscription.Events <- myPriceChangeEvent:
+			default:
+				subscription.dropped.Add(1)
+			}
+		}
+	}
+}
+
+func (filter PriceChangeFilter) matches(priceChangeEvent PriceChangeEvent) bool {
+	if filter.MinMax != nil && (priceChangeEvent.Latitude < filter.MinMax.MinLat || priceChangeEvent.Latitude > filter.MinMax.MaxLat ||
+		priceChangeEvent.Longitude < filter.MinMax.MinLng || priceChangeEvent.Longitude > filter.MinMax.MaxLng) {
+		return false
+	}
+	if len(filter.PostCodes) > 0 {
+		postCodeFound := false
+		for _, myPostCode := range filter.PostCodes {
+			if strings.TrimSpace(myPostCode) == strings.TrimSpace(priceChangeEvent.PostCode) {
+				postCodeFound = true
+				break
+			}
+		}
+		if !postCodeFound {
+			return false
+		}
+	}
+	if len(filter.FuelTypes) == 0 {
+		return true
+	}
+	for _, myFuelType := range filter.FuelTypes {
+		if priceChangeEvent.Changed&fuelTypeChangedBit(myFuelType) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// the bits of the GasPrice.Changed field
+func fuelTypeChangedBit(fuelType FuelType) int {
+	switch fuelType {
+	case Diesel:
+		return 1
+	case E5:
+		return 4
+	case E10:
+		return 16
+	}
+	return 0
+}
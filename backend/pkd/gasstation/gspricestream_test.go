@@ -0,0 +1,122 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"react-and-go/pkd/gasstation/gsmodel"
+	"testing"
+	"time"
+)
+
+func TestPriceChangeFilterMatches(t *testing.T) {
+	myEvent := PriceChangeEvent{GasStationID: "stid1", PostCode: "20095", Latitude: 53.55, Longitude: 10.0, Changed: 4}
+	tests := []struct {
+		name   string
+		filter PriceChangeFilter
+		want   bool
+	}{
+		{"no filter", PriceChangeFilter{}, true},
+		{"in the box", PriceChangeFilter{MinMax: &MinMaxSquare{MinLat: 53.5, MinLng: 9.9, MaxLat: 53.6, MaxLng: 10.1}}, true},
+		{"on the box border", PriceChangeFilter{MinMax: &MinMaxSquare{MinLat: 53.55, MinLng: 10.0, MaxLat: 53.6, MaxLng: 10.1}}, true},
+		{"north of the box", PriceChangeFilter{MinMax: &MinMaxSquare{MinLat: 53.4, MinLng: 9.9, MaxLat: 53.5, MaxLng: 10.1}}, false},
+		{"east of the box", PriceChangeFilter{MinMax: &MinMaxSquare{MinLat: 53.5, MinLng: 9.8, MaxLat: 53.6, MaxLng: 9.9}}, false},
+		{"postcode", PriceChangeFilter{PostCodes: []string{"10115", " 20095 "}}, true},
+		{"other postcode", PriceChangeFilter{PostCodes: []string{"10115"}}, false},
+		{"changed fuel", PriceChangeFilter{FuelTypes: []FuelType{E5}}, true},
+		{"one of the fuels changed", PriceChangeFilter{FuelTypes: []FuelType{Diesel, E5}}, true},
+		{"unchanged fuels", PriceChangeFilter{FuelTypes: []FuelType{Diesel, E10}}, false},
+		{"unknown fuel", PriceChangeFilter{FuelTypes: []FuelType{"lpg"}}, false},
+		{"box and other postcode", PriceChangeFilter{MinMax: &MinMaxSquare{MinLat: 53.5, MinLng: 9.9, MaxLat: 53.6, MaxLng: 10.1}, PostCodes: []string{"10115"}}, false},
+		{"postcode and unchanged fuel", PriceChangeFilter{PostCodes: []string{"20095"}, FuelTypes: []FuelType{E10}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.matches(myEvent); got != tt.want {
+				t.Errorf("matches() = %v want: %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFuelTypeChangedBit(t *testing.T) {
+	myGasPrice := gsmodel.GasPrice{Changed: 1 | 4 | 16}
+	for _, myFuelType := range []FuelType{Diesel, E5, E10} {
+		if myChangedBit := fuelTypeChangedBit(myFuelType); myChangedBit == 0 || myGasPrice.Changed&myChangedBit == 0 {
+			t.Errorf("fuelTypeChangedBit(%v) = %v", myFuelType, myChangedBit)
+		}
+	}
+}
+
+func TestPublishPriceChanges(t *testing.T) {
+	myService, myRepo := newTestGasStationService()
+	myRepo.SaveGasStations([]gsmodel.GasStation{{ID: "stid1", StationName: "A", PostCode: "20095", Latitude: 53.55, Longitude: 10.0},
+		{ID: "stid2", StationName: "B", PostCode: "10115", Latitude: 52.53, Longitude: 13.38},
+		{ID: "stid3", StationName: "C", PostCode: "20095", Latitude: 53.56, Longitude: 10.01, LifecycleStatus: gsmodel.StatusInactive}})
+	mySubscription := myService.SubscribePriceChanges(PriceChangeFilter{PostCodes: []string{"20095"}})
+	myOtherSubscription := myService.SubscribePriceChanges(PriceChangeFilter{FuelTypes: []FuelType{Diesel}})
+	myDate := time.Now().Truncate(time.Second)
+	myService.publishPriceChanges(&map[string]gsmodel.GasPrice{
+		"stid1": {GasStationID: "stid1", E5: 1800, Date: myDate, Changed: 4},
+		"stid2": {GasStationID: "stid2", Diesel: 1700, Date: myDate, Changed: 1},
+		"stid3": {GasStationID: "stid3", E5: 1790, Date: myDate, Changed: 4},
+	})
+	if len(mySubscription.Events) != 1 || len(myOtherSubscription.Events) != 1 {
+		t.Fatalf("publishPriceChanges() events = %v, %v want: 1, 1", len(mySubscription.Events), len(myOtherSubscription.Events))
+	}
+	if myEvent := <-mySubscription.Events; myEvent.GasStationID != "stid1" || myEvent.StationName != "A" || myEvent.E5 != 1800 || !myEvent.Date.Equal(myDate) {
+		t.Errorf("publishPriceChanges() event = %+v want: the e5 change of stid1", myEvent)
+	}
+	if myEvent := <-myOtherSubscription.Events; myEvent.GasStationID != "stid2" || myEvent.Diesel != 1700 {
+		t.Errorf("publishPriceChanges() event = %+v want: the diesel change of stid2", myEvent)
+	}
+	myService.UnsubscribePriceChanges(mySubscription)
+	myService.UnsubscribePriceChanges(mySubscription)
+	if _, ok := <-mySubscription.Events; ok {
+		t.Errorf("UnsubscribePriceChanges() events are not closed")
+	}
+	myService.UnsubscribePriceChanges(myOtherSubscription)
+}
+
+// a subscription that is not read drops the events above the buffer size
+func TestPublishPriceChangesFullBuffer(t *testing.T) {
+	myService, myRepo := newTestGasStationService()
+	myRepo.SaveGasStations([]gsmodel.GasStation{{ID: "stid1", StationName: "A", PostCode: "20095", Latitude: 53.55, Longitude: 10.0}})
+	mySubscription := myService.SubscribePriceChanges(PriceChangeFilter{})
+	myReadSubscription := myService.SubscribePriceChanges(PriceChangeFilter{})
+	myExtraEvents := 5
+	myDone := make(chan bool)
+	go func() {
+		for index := 0; index < priceChangeBufferSize+myExtraEvents; index++ {
+			myService.publishPriceChanges(&map[string]gsmodel.GasPrice{"stid1": {GasStationID: "stid1", E5: 1700 + index, Changed: 4}})
+			<-myReadSubscription.Events
+		}
+		close(myDone)
+	}()
+	select {
+	case <-myDone:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("publishPriceChanges() blocked on a full buffer")
+	}
+	if len(mySubscription.Events) != priceChangeBufferSize || mySubscription.dropped.Load() != int64(myExtraEvents) {
+		t.Errorf("publishPriceChanges() buffered = %v dropped = %v want: %v, %v", len(mySubscription.Events), mySubscription.dropped.Load(),
+			priceChangeBufferSize, myExtraEvents)
+	}
+	if myReadSubscription.dropped.Load() != 0 {
+		t.Errorf("publishPriceChanges() dropped = %v for the read subscription", myReadSubscription.dropped.Load())
+	}
+	if myEvent := <-mySubscription.Events; myEvent.E5 != 1700 {
+		t.Errorf("publishPriceChanges() first event = %+v want: the oldest event", myEvent)
+	}
+	myService.UnsubscribePriceChanges(mySubscription)
+	myService.UnsubscribePriceChanges(myReadSubscription)
+}
@@ -0,0 +1,997 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"log"
+	"maps"
+	gsbody "react-and-go/pkd/controller/gsmodel"
+	"react-and-go/pkd/database"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"react-and-go/pkd/memrepo"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type GasStationRepo interface {
+	FindById(id string) gsmodel.GasStation
+	FindByIds(ids []string, onlyActive bool) []gsmodel.GasStation
+	FindAll(onlyActive bool) []gsmodel.GasStation
+	CountActive() int64
+	FindByPostCodes(postCodes []string) []gsmodel.GasStation
+	FindBySearchPlace(searchPlace gsbody.SearchPlaceBody) []gsmodel.GasStation
+	FindInSquare(minMax MinMaxSquare, pricesLimit int) []gsmodel.GasStation
+	SaveGasStations(gasStations []gsmodel.GasStation)
+	UpdateStationInImport(ids []string, importTime time.Time)
+	SaveLifecycleChanges(lifecycleChanges []gsmodel.LifecycleChange)
+	FindLastImportLifecycleChanges() []gsmodel.LifecycleChange
+	SaveGasStationChanges(gasStationChanges []gsmodel.GasStationChange)
+	FindChangesByStid(stid string) []gsmodel.GasStationChange
+	FindPricesByStids(stids []string, since time.Time, resultLimit int) []gsmodel.GasPrice
+	FindPricesByStid(stid string) []gsmodel.GasPrice
+	FindPricesByStidInRange(stid string, from time.Time, to time.Time) ([]gsmodel.GasPrice, error)
+	FindLastPriceByStidBefore(stid string, before time.Time) (gsmodel.GasPrice, bool, error)
+	StreamPricesByStidsInRange(stids []string, from time.Time, to time.Time, handler func(gasPrice gsmodel.GasPrice) error) error
+	SavePrices(gasPrices []gsmodel.GasPrice)
+	DeletePricesBefore(before time.Time)
+	CreatePricePartitions(from time.Time, to time.Time) error
+	FindOldestPrice() (gsmodel.GasPrice, bool)
+	FindPricesInRange(from time.Time, to time.Time) []gsmodel.GasPrice
+	SavePriceDailies(gasPriceDailies []gsmodel.GasPriceDaily)
+	FindPriceDailiesByStidInRange(stid string, from time.Time, to time.Time) ([]gsmodel.GasPriceDaily, error)
+	FindLastPriceDailyByStidBefore(stid string, before time.Time) (gsmodel.GasPriceDaily, bool, error)
+	FindLatestPricesBefore(before time.Time) []gsmodel.GasPrice
+	FindLatestPricesByStids(stids []string, since time.Time) []gsmodel.GasPrice
+	CreatePrices(gasPrices []gsmodel.GasPrice) error
+	SavePriceImportFile(priceImportFile gsmodel.PriceImportFile)
+	FindPriceImportFiles() []gsmodel.PriceImportFile
+	SaveStationImportRun(stationImportRun *gsmodel.StationImportRun)
+	FindStationImportRuns(limit int) []gsmodel.StationImportRun
+	FindLastImportedStationRun() (gsmodel.StationImportRun, bool)
+	FindStationImportRunById(id int64) (gsmodel.StationImportRun, bool)
+	SaveStationImportErrors(stationImportErrors []gsmodel.StationImportError)
+	FindStationImportErrors(stationImportRunId int64) []gsmodel.StationImportError
+	Transaction(txFunc func(repo GasStationRepo) error) error
+}
+
+type gasStationDbRepo struct {
+	db *gorm.DB
+}
+
+func NewGasStationDbRepo(db *gorm.DB) GasStationRepo {
+	return &gasStationDbRepo{db: db}
+}
+
+// excludes the stations that are missing in the station import
+func activeGasStations(db *gorm.DB) *gorm.DB {
+	return db.Where("lifecycle_status IS NULL or lifecycle_status <> ?", gsmodel.StatusInactive)
+}
+
+func (repo *gasStationDbRepo) FindById(id string) gsmodel.GasStation {
+	var myGasStation gsmodel.GasStation
+	repo.db.Where("id = ?", id).Preload("GasPrices", func(db *gorm.DB) *gorm.DB {
+		return db.Order("date DESC").Limit(20)
+	}).First(&myGasStation)
+	return myGasStation
+}
+
+func (repo *gasStationDbRepo) FindByIds(ids []string, onlyActive bool) []gsmodel.GasStation {
+	var result []gsmodel.GasStation
+	chuncks := createChunks(&ids)
+	repo.db.Transaction(func(tx *gorm.DB) error {
+		for _, chunk := range chuncks {
+			var values []gsmodel.GasStation
+			myQuery := tx.Where("id in ?", chunk)
+			if onlyActive {
+				myQuery = myQuery.Scopes(activeGasStations)
+			}
+			myQuery.Find(&values)
+			result = append(result, values...)
+		}
+		return nil
+	})
+	return result
+}
+
+func (repo *gasStationDbRepo) FindAll(onlyActive bool) []gsmodel.GasStation {
+	var gasStations []gsmodel.GasStation
+	if onlyActive {
+		repo.db.Scopes(activeGasStations).Find(&gasStations)
+	} else {
+		repo.db.Find(&gasStations)
+	}
+	return gasStations
+}
+
+func (repo *gasStationDbRepo) CountActive() int64 {
+	var result int64
+	repo.db.Model(&gsmodel.GasStation{}).Scopes(activeGasStations).Count(&result)
+	return result
+}
+
+func (repo *gasStationDbRepo) FindByPostCodes(postCodes []string) []gsmodel.GasStation {
+	chunks := createChunks(&postCodes)
+	var gasStations []gsmodel.GasStation
+	repo.db.Transaction(func(tx *gorm.DB) error {
+		for _, chunk := range chunks {
+			var myGasStations []gsmodel.GasStation
+			tx.Where("post_code IN ?", chunk).Scopes(activeGasStations).Find(&myGasStations)
+			gasStations = append(gasStations, myGasStations...)
+		}
+		return nil
+	})
+	return gasStations
+}
+
+func (repo *gasStationDbRepo) FindBySearchPlace(searchPlace gsbody.SearchPlaceBody) []gsmodel.GasStation {
+	var gasStations []gsmodel.GasStation
+	var query = repo.db.Scopes(activeGasStations)
+	if len(strings.TrimSpace(searchPlace.Place)) >= 2 {
+		query = query.Where("name LIKE ?", "%"+strings.TrimSpace(searchPlace.Place)+"%")
+	}
+	if len(strings.TrimSpace(searchPlace.PostCode)) >= 4 {
+		query = query.Where("post_code LIKE ?", "%"+strings.TrimSpace(searchPlace.PostCode)+"%")
+	}
+	if len(strings.TrimSpace(searchPlace.StationName)) >= 2 {
+		query = query.Where("name LIKE ?", "%"+strings.TrimSpace(searchPlace.StationName)+"%")
+	}
+	query.Preload("GasPrices", func(db *gorm.DB) *gorm.DB {
+		return db.Order("date DESC").Limit(50)
+	}).Find(&gasStations)
+	return gasStations
+}
+
+// returns the active stations in the square, the prices are only loaded if the limit is positive
+func (repo *gasStationDbRepo) FindInSquare(minMax MinMaxSquare, pricesLimit int) []gsmodel.GasStation {
+	var gasStations []gsmodel.GasStation
+	myQuery := repo.db.Where("lat >= ? and lat <= ? and lng >= ? and lng <= ?", minMax.MinLat, minMax.MaxLat, minMax.MinLng, minMax.MaxLng).Scopes(activeGasStations)
+	if pricesLimit > 0 {
+		myQuery = myQuery.Preload("GasPrices", func(db *gorm.DB) *gorm.DB {
+			return db.Order("date DESC").Limit(pricesLimit)
+		})
+	}
+	myQuery.Find(&gasStations)
+	return gasStations
+}
+
+func (repo *gasStationDbRepo) SaveGasStations(gasStations []gsmodel.GasStation) {
+	if len(gasStations) > 0 {
+		repo.db.Save(&gasStations)
+	}
+}
+
+func (repo *gasStationDbRepo) UpdateStationInImport(ids []string, importTime time.Time) {
+	if len(ids) > 0 {
+		repo.db.Model(&gsmodel.GasStation{}).Where("id IN ?", ids).Update("station_in_import", importTime)
+	}
+}
+
+func (repo *gasStationDbRepo) SaveLifecycleChanges(lifecycleChanges []gsmodel.LifecycleChange) {
+	for _, myLifecycleChange := range lifecycleChanges {
+		repo.db.Save(&myLifecycleChange)
+	}
+}
+
+// every import sets station_in_import of the imported stations, an import without lifecycle changes returns no changes
+func (repo *gasStationDbRepo) FindLastImportLifecycleChanges() []gsmodel.LifecycleChange {
+	lifecycleChanges := []gsmodel.LifecycleChange{}
+	var lastImportedStation gsmodel.GasStation
+	if result := repo.db.Order("station_in_import desc").Limit(1).Find(&lastImportedStation); result.Error != nil || result.RowsAffected == 0 {
+		return lifecycleChanges
+	}
+	repo.db.Where("import_time = ?", lastImportedStation.StationInImport).Order("stid").Find(&lifecycleChanges)
+	return lifecycleChanges
+}
+
+func (repo *gasStationDbRepo) SaveGasStationChanges(gasStationChanges []gsmodel.GasStationChange) {
+	for _, myGasStationChange := range gasStationChanges {
+		repo.db.Save(&myGasStationChange)
+	}
+}
+
+func (repo *gasStationDbRepo) FindChangesByStid(stid string) []gsmodel.GasStationChange {
+	gasStationChanges := []gsmodel.GasStationChange{}
+	repo.db.Where("stid = ?", stid).Order("version_time desc").Order("field").Find(&gasStationChanges)
+	return gasStationChanges
+}
+
+// returns the prices since the day of the since time ordered by date descending, the limit is applied per chunk
+func (repo *gasStationDbRepo) FindPricesByStids(stids []string, since time.Time, resultLimit int) []gsmodel.GasPrice {
+	var myGasPrices []gsmodel.GasPrice
+	//a timestamp parameter lets postgres prune the monthly partitions
+	sinceDay := time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, since.Location())
+	chuncks := createInChunks(&stids, true)
+	repo.db.Transaction(func(tx *gorm.DB) error {
+		for _, chunk := range chuncks {
+			var values []gsmodel.GasPrice
+			//log.Printf("Chunk: %v\n", chunk)
+			myQuery := tx.Where("stid IN ? and date >= ?", chunk, sinceDay).Order("date desc")
+			if resultLimit > 0 {
+				myQuery = myQuery.Limit(resultLimit)
+			}
+			myQuery.Find(&values)
+			myGasPrices = append(myGasPrices, values...)
+		}
+		return nil
+	})
+	return myGasPrices
+}
+
+func (repo *gasStationDbRepo) FindPricesByStid(stid string) []gsmodel.GasPrice {
+	var myGasPrice []gsmodel.GasPrice
+	repo.db.Where("stid = ?", stid).Order("date desc").Find(&myGasPrice)
+	return myGasPrice
+}
+
+// returns the prices ordered by date ascending
+func (repo *gasStationDbRepo) FindPricesByStidInRange(stid string, from time.Time, to time.Time) ([]gsmodel.GasPrice, error) {
+	var myGasPrices []gsmodel.GasPrice
+	err := repo.db.Where("stid = ? and date >= ? and date < ?", stid, from, to).Order("date asc").Find(&myGasPrices).Error
+	return myGasPrices, err
+}
+
+// the price that is in effect at the 'before' time
+func (repo *gasStationDbRepo) FindLastPriceByStidBefore(stid string, before time.Time) (gsmodel.GasPrice, bool, error) {
+	var myGasPrices []gsmodel.GasPrice
+	err := repo.db.Where("stid = ? and date < ?", stid, before).Order("date desc").Limit(1).Find(&myGasPrices).Error
+	if err != nil || len(myGasPrices) == 0 {
+		return gsmodel.GasPrice{}, false, err
+	}
+	return myGasPrices[0], true, nil
+}
+
+// reads the rows one by one ordered by station and date
+func (repo *gasStationDbRepo) StreamPricesByStidsInRange(stids []string, from time.Time, to time.Time, handler func(gasPrice gsmodel.GasPrice) error) error {
+	myStids := append([]string{}, stids...)
+	sort.Strings(myStids)
+	for _, chunk := range createInChunks(&myStids, true) {
+		rows, err := repo.db.Model(&gsmodel.GasPrice{}).Where("stid IN ? and date >= ? and date < ?", chunk, from, to).Order("stid asc").Order("date asc").Rows()
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var myGasPrice gsmodel.GasPrice
+			if err = repo.db.ScanRows(rows, &myGasPrice); err == nil {
+				err = handler(myGasPrice)
+			}
+			if err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (repo *gasStationDbRepo) SavePrices(gasPrices []gsmodel.GasPrice) {
+	for _, value := range gasPrices {
+		repo.db.Save(&value)
+	}
+}
+
+// a partitioned table drops the monthly partitions that end before the 'before' time, the rows of the month of 'before' are kept
+func (repo *gasStationDbRepo) DeletePricesBefore(before time.Time) {
+	myTableName := gsmodel.GasPrice{}.TableName()
+	if !database.IsPartitioned(repo.db, myTableName) {
+		repo.db.Where("date < ?", before).Delete(&gsmodel.GasPrice{})
+		return
+	}
+	droppedNum, err := database.DropMonthlyPartitionsBefore(repo.db, myTableName, before)
+	if err != nil {
+		log.Printf("DeletePricesBefore failed: %v\n", err)
+	}
+	log.Printf("DeletePricesBefore dropped %v partitions.\n", droppedNum)
+}
+
+func (repo *gasStationDbRepo) CreatePricePartitions(from time.Time, to time.Time) error {
+	myTableName := gsmodel.GasPrice{}.TableName()
+	if !database.IsPartitioned(repo.db, myTableName) {
+		return nil
+	}
+	return database.CreateMonthlyPartitions(repo.db, myTableName, from, to)
+}
+
+func (repo *gasStationDbRepo) FindOldestPrice() (gsmodel.GasPrice, bool) {
+	var myGasPrices []gsmodel.GasPrice
+	repo.db.Order("date asc").Limit(1).Find(&myGasPrices)
+	if len(myGasPrices) == 0 {
+		return gsmodel.GasPrice{}, false
+	}
+	return myGasPrices[0], true
+}
+
+// returns the prices ordered by station and date ascending
+func (repo *gasStationDbRepo) FindPricesInRange(from time.Time, to time.Time) []gsmodel.GasPrice {
+	var myGasPrices []gsmodel.GasPrice
+	repo.db.Where("date >= ? and date < ?", from, to).Order("stid asc").Order("date asc").Find(&myGasPrices)
+	return myGasPrices
+}
+
+// a rerun for the same station and day replaces the aggregate
+func (repo *gasStationDbRepo) SavePriceDailies(gasPriceDailies []gsmodel.GasPriceDaily) {
+	if len(gasPriceDailies) > 0 {
+		repo.db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "stid"}, {Name: "day"}}, UpdateAll: true}).CreateInBatches(&gasPriceDailies, 500)
+	}
+}
+
+// returns the daily aggregates ordered by day ascending
+func (repo *gasStationDbRepo) FindPriceDailiesByStidInRange(stid string, from time.Time, to time.Time) ([]gsmodel.GasPriceDaily, error) {
+	var myGasPriceDailies []gsmodel.GasPriceDaily
+	err := repo.db.Where("stid = ? and day >= ? and day < ?", stid, from, to).Order("day asc").Find(&myGasPriceDailies).Error
+	return myGasPriceDailies, err
+}
+
+func (repo *gasStationDbRepo) FindLastPriceDailyByStidBefore(stid string, before time.Time) (gsmodel.GasPriceDaily, bool, error) {
+	var myGasPriceDailies []gsmodel.GasPriceDaily
+	err := repo.db.Where("stid = ? and day < ?", stid, before).Order("day desc").Limit(1).Find(&myGasPriceDailies).Error
+	if err != nil || len(myGasPriceDailies) == 0 {
+		return gsmodel.GasPriceDaily{}, false, err
+	}
+	return myGasPriceDailies[0], true, nil
+}
+
+// returns the latest price per station before the 'before' time
+func (repo *gasStationDbRepo) FindLatestPricesBefore(before time.Time) []gsmodel.GasPrice {
+	var myGasPrices []gsmodel.GasPrice
+	myLatestDates := repo.db.Model(&gsmodel.GasPrice{}).Select("stid, max(date) as max_date").Where("date < ?", before).Group("stid")
+	repo.db.Joins("JOIN (?) latest ON latest.stid = gas_station_information_history.stid and latest.max_date = gas_station_information_history.date", myLatestDates).
+		Find(&myGasPrices)
+	return myGasPrices
+}
+
+// the latest price of each station since the 'since' day, the timestamp parameter lets postgres prune the monthly partitions
+func (repo *gasStationDbRepo) FindLatestPricesByStids(stids []string, since time.Time) []gsmodel.GasPrice {
+	var myGasPrices []gsmodel.GasPrice
+	sinceDay := time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, since.Location())
+	for _, chunk := range createInChunks(&stids, true) {
+		var values []gsmodel.GasPrice
+		myLatestDates := repo.db.Model(&gsmodel.GasPrice{}).Select("stid, max(date) as max_date").Where("stid IN ? and date >= ?", chunk, sinceDay).Group("stid")
+		repo.db.Joins("JOIN (?) latest ON latest.stid = gas_station_information_history.stid and latest.max_date = gas_station_information_history.date", myLatestDates).
+			Where("gas_station_information_history.date >= ?", sinceDay).Find(&values)
+		myGasPrices = append(myGasPrices, values...)
+	}
+	return myGasPrices
+}
+
+// inserts the new prices with multi row inserts
+func (repo *gasStationDbRepo) CreatePrices(gasPrices []gsmodel.GasPrice) error {
+	if len(gasPrices) == 0 {
+		return nil
+	}
+	return repo.db.CreateInBatches(&gasPrices, 1000).Error
+}
+
+func (repo *gasStationDbRepo) SavePriceImportFile(priceImportFile gsmodel.PriceImportFile) {
+	repo.db.Save(&priceImportFile)
+}
+
+func (repo *gasStationDbRepo) FindPriceImportFiles() []gsmodel.PriceImportFile {
+	priceImportFiles := []gsmodel.PriceImportFile{}
+	repo.db.Order("file_name").Find(&priceImportFiles)
+	return priceImportFiles
+}
+
+func (repo *gasStationDbRepo) SaveStationImportRun(stationImportRun *gsmodel.StationImportRun) {
+	repo.db.Save(stationImportRun)
+}
+
+// the latest runs first
+func (repo *gasStationDbRepo) FindStationImportRuns(limit int) []gsmodel.StationImportRun {
+	stationImportRuns := []gsmodel.StationImportRun{}
+	repo.db.Order("start_time desc").Limit(limit).Find(&stationImportRuns)
+	return stationImportRuns
+}
+
+func (repo *gasStationDbRepo) FindLastImportedStationRun() (gsmodel.StationImportRun, bool) {
+	var stationImportRuns []gsmodel.StationImportRun
+	repo.db.Where("status = ?", gsmodel.ImportRunImported).Order("start_time desc").Limit(1).Find(&stationImportRuns)
+	if len(stationImportRuns) == 0 {
+		return gsmodel.StationImportRun{}, false
+	}
+	return stationImportRuns[0], true
+}
+
+func (repo *gasStationDbRepo) FindStationImportRunById(id int64) (gsmodel.StationImportRun, bool) {
+	var stationImportRuns []gsmodel.StationImportRun
+	repo.db.Where("id = ?", id).Limit(1).Find(&stationImportRuns)
+	if len(stationImportRuns) == 0 {
+		return gsmodel.StationImportRun{}, false
+	}
+	return stationImportRuns[0], true
+}
+
+func (repo *gasStationDbRepo) SaveStationImportErrors(stationImportErrors []gsmodel.StationImportError) {
+	if len(stationImportErrors) == 0 {
+		return
+	}
+	repo.db.CreateInBatches(&stationImportErrors, 500)
+}
+
+func (repo *gasStationDbRepo) FindStationImportErrors(stationImportRunId int64) []gsmodel.StationImportError {
+	stationImportErrors := []gsmodel.StationImportError{}
+	repo.db.Where("station_import_run_id = ?", stationImportRunId).Order("line").Find(&stationImportErrors)
+	return stationImportErrors
+}
+
+func (repo *gasStationDbRepo) Transaction(txFunc func(repo GasStationRepo) error) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		return txFunc(&gasStationDbRepo{db: tx})
+	})
+}
+
+type gasStationMemRepo struct {
+	mutex             *sync.RWMutex
+	gasStations       map[string]gsmodel.GasStation
+	gasPrices         map[string][]gsmodel.GasPrice
+	gasPriceDailies   map[string]map[time.Time]gsmodel.GasPriceDaily
+	lifecycleChanges  *[]gsmodel.LifecycleChange
+	gasStationChanges *[]gsmodel.GasStationChange
+	priceImportFiles  map[string]gsmodel.PriceImportFile
+	stationImportRuns *[]gsmodel.StationImportRun
+	stationImportErrs map[int64][]gsmodel.StationImportError
+	ids               *memrepo.IdSequence[int64]
+	transactor        *memrepo.Transactor
+}
+
+func NewGasStationMemRepo() GasStationRepo {
+	return &gasStationMemRepo{mutex: &sync.RWMutex{}, gasStations: make(map[string]gsmodel.GasStation), gasPrices: make(map[string][]gsmodel.GasPrice),
+		gasPriceDailies:  make(map[string]map[time.Time]gsmodel.GasPriceDaily),
+		lifecycleChanges: &[]gsmodel.LifecycleChange{}, gasStationChanges: &[]gsmodel.GasStationChange{},
+		priceImportFiles: make(map[string]gsmodel.PriceImportFile), stationImportRuns: &[]gsmodel.StationImportRun{},
+		stationImportErrs: make(map[int64][]gsmodel.StationImportError), ids: memrepo.NewIdSequence[int64](), transactor: memrepo.NewTransactor()}
+}
+
+func (repo *gasStationMemRepo) FindById(id string) gsmodel.GasStation {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	myGasStation := repo.gasStations[id]
+	myGasStation.GasPrices = repo.latestPrices(id, 20)
+	return myGasStation
+}
+
+func (repo *gasStationMemRepo) FindByIds(ids []string, onlyActive bool) []gsmodel.GasStation {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	result := []gsmodel.GasStation{}
+	for _, myId := range ids {
+		if myGasStation, ok := repo.gasStations[myId]; ok && (!onlyActive || myGasStation.LifecycleStatus != gsmodel.StatusInactive) {
+			result = append(result, myGasStation)
+		}
+	}
+	return result
+}
+
+func (repo *gasStationMemRepo) FindAll(onlyActive bool) []gsmodel.GasStation {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	return repo.filterGasStations(func(myGasStation gsmodel.GasStation) bool {
+		return !onlyActive || myGasStation.LifecycleStatus != gsmodel.StatusInactive
+	})
+}
+
+func (repo *gasStationMemRepo) CountActive() int64 {
+	return int64(len(repo.FindAll(true)))
+}
+
+func (repo *gasStationMemRepo) FindByPostCodes(postCodes []string) []gsmodel.GasStation {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	postCodeMap := make(map[string]bool)
+	for _, myPostCode := range postCodes {
+		postCodeMap[myPostCode] = true
+	}
+	return repo.filterGasStations(func(myGasStation gsmodel.GasStation) bool {
+		return postCodeMap[myGasStation.PostCode] && myGasStation.LifecycleStatus != gsmodel.StatusInactive
+	})
+}
+
+func (repo *gasStationMemRepo) FindBySearchPlace(searchPlace gsbody.SearchPlaceBody) []gsmodel.GasStation {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	result := repo.filterGasStations(func(myGasStation gsmodel.GasStation) bool {
+		if myGasStation.LifecycleStatus == gsmodel.StatusInactive {
+			return false
+		}
+		if len(strings.TrimSpace(searchPlace.Place)) >= 2 && !strings.Contains(myGasStation.StationName, strings.TrimSpace(searchPlace.Place)) {
+			return false
+		}
+		if len(strings.TrimSpace(searchPlace.PostCode)) >= 4 && !strings.Contains(myGasStation.PostCode, strings.TrimSpace(searchPlace.PostCode)) {
+			return false
+		}
+		if len(strings.TrimSpace(searchPlace.StationName)) >= 2 && !strings.Contains(myGasStation.StationName, strings.TrimSpace(searchPlace.StationName)) {
+			return false
+		}
+		return true
+	})
+	for index := range result {
+		result[index].GasPrices = repo.latestPrices(result[index].ID, 50)
+	}
+	return result
+}
+
+func (repo *gasStationMemRepo) FindInSquare(minMax MinMaxSquare, pricesLimit int) []gsmodel.GasStation {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	result := repo.filterGasStations(func(myGasStation gsmodel.GasStation) bool {
+		return myGasStation.Latitude >= minMax.MinLat && myGasStation.Latitude <= minMax.MaxLat && myGasStation.Longitude >= minMax.MinLng &&
+			myGasStation.Longitude <= minMax.MaxLng && myGasStation.LifecycleStatus != gsmodel.StatusInactive
+	})
+	if pricesLimit > 0 {
+		for index := range result {
+			result[index].GasPrices = repo.latestPrices(result[index].ID, pricesLimit)
+		}
+	}
+	return result
+}
+
+func (repo *gasStationMemRepo) SaveGasStations(gasStations []gsmodel.GasStation) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	for _, myGasStation := range gasStations {
+		if len(myGasStation.LifecycleStatus) == 0 {
+			myGasStation.LifecycleStatus = gsmodel.StatusActive
+		}
+		myGasStation.GasPrices = nil
+		repo.gasStations[myGasStation.ID] = myGasStation
+	}
+}
+
+func (repo *gasStationMemRepo) UpdateStationInImport(ids []string, importTime time.Time) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	for _, myId := range ids {
+		if myGasStation, ok := repo.gasStations[myId]; ok {
+			myGasStation.StationInImport = importTime
+			repo.gasStations[myId] = myGasStation
+		}
+	}
+}
+
+func (repo *gasStationMemRepo) SaveLifecycleChanges(lifecycleChanges []gsmodel.LifecycleChange) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	for _, myLifecycleChange := range lifecycleChanges {
+		myLifecycleChange.ID = repo.ids.Next()
+		*repo.lifecycleChanges = append(*repo.lifecycleChanges, myLifecycleChange)
+	}
+}
+
+func (repo *gasStationMemRepo) FindLastImportLifecycleChanges() []gsmodel.LifecycleChange {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	result := []gsmodel.LifecycleChange{}
+	var lastImportTime time.Time
+	for _, myGasStation := range repo.gasStations {
+		if myGasStation.StationInImport.After(lastImportTime) {
+			lastImportTime = myGasStation.StationInImport
+		}
+	}
+	if lastImportTime.IsZero() {
+		return result
+	}
+	for _, myLifecycleChange := range *repo.lifecycleChanges {
+		if myLifecycleChange.ImportTime.Equal(lastImportTime) {
+			result = append(result, myLifecycleChange)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].GasStationID < result[j].GasStationID
+	})
+	return result
+}
+
+func (repo *gasStationMemRepo) SaveGasStationChanges(gasStationChanges []gsmodel.GasStationChange) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	for _, myGasStationChange := range gasStationChanges {
+		myGasStationChange.ID = repo.ids.Next()
+		*repo.gasStationChanges = append(*repo.gasStationChanges, myGasStationChange)
+	}
+}
+
+func (repo *gasStationMemRepo) FindChangesByStid(stid string) []gsmodel.GasStationChange {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	result := []gsmodel.GasStationChange{}
+	for _, myGasStationChange := range *repo.gasStationChanges {
+		if myGasStationChange.GasStationID == stid {
+			result = append(result, myGasStationChange)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].VersionTime.Equal(result[j].VersionTime) {
+			return result[i].Field < result[j].Field
+		}
+		return result[i].VersionTime.After(result[j].VersionTime)
+	})
+	return result
+}
+
+func (repo *gasStationMemRepo) FindPricesByStids(stids []string, since time.Time, resultLimit int) []gsmodel.GasPrice {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	sinceDay := time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, since.Location())
+	result := []gsmodel.GasPrice{}
+	for _, myStid := range stids {
+		for _, myGasPrice := range repo.gasPrices[myStid] {
+			if !myGasPrice.Date.Before(sinceDay) {
+				result = append(result, myGasPrice)
+			}
+		}
+	}
+	sortPricesDesc(result)
+	if resultLimit > 0 && len(result) > resultLimit {
+		result = result[:resultLimit]
+	}
+	return result
+}
+
+func (repo *gasStationMemRepo) FindPricesByStid(stid string) []gsmodel.GasPrice {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	return repo.latestPrices(stid, 0)
+}
+
+func (repo *gasStationMemRepo) FindPricesByStidInRange(stid string, from time.Time, to time.Time) ([]gsmodel.GasPrice, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	result := []gsmodel.GasPrice{}
+	for _, myGasPrice := range repo.gasPrices[stid] {
+		if !myGasPrice.Date.Before(from) && myGasPrice.Date.Before(to) {
+			result = append(result, myGasPrice)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Date.Before(result[j].Date)
+	})
+	return result, nil
+}
+
+func (repo *gasStationMemRepo) FindLastPriceByStidBefore(stid string, before time.Time) (gsmodel.GasPrice, bool, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	var result *gsmodel.GasPrice
+	for index, myGasPrice := range repo.gasPrices[stid] {
+		if myGasPrice.Date.Before(before) && (result == nil || myGasPrice.Date.After(result.Date)) {
+			result = &repo.gasPrices[stid][index]
+		}
+	}
+	if result == nil {
+		return gsmodel.GasPrice{}, false, nil
+	}
+	return *result, true, nil
+}
+
+func (repo *gasStationMemRepo) StreamPricesByStidsInRange(stids []string, from time.Time, to time.Time, handler func(gasPrice gsmodel.GasPrice) error) error {
+	myStids := append([]string{}, stids...)
+	sort.Strings(myStids)
+	for _, myStid := range myStids {
+		myGasPrices, _ := repo.FindPricesByStidInRange(myStid, from, to)
+		for _, myGasPrice := range myGasPrices {
+			if err := handler(myGasPrice); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (repo *gasStationMemRepo) SavePrices(gasPrices []gsmodel.GasPrice) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	for _, myGasPrice := range gasPrices {
+		if myGasPrice.ID == 0 {
+			myGasPrice.ID = repo.ids.Next()
+		}
+		repo.gasPrices[myGasPrice.GasStationID] = append(repo.gasPrices[myGasPrice.GasStationID], myGasPrice)
+	}
+}
+
+func (repo *gasStationMemRepo) DeletePricesBefore(before time.Time) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	for myStid, myGasPrices := range repo.gasPrices {
+		var keptGasPrices []gsmodel.GasPrice
+		for _, myGasPrice := range myGasPrices {
+			if !myGasPrice.Date.Before(before) {
+				keptGasPrices = append(keptGasPrices, myGasPrice)
+			}
+		}
+		repo.gasPrices[myStid] = keptGasPrices
+	}
+}
+
+func (repo *gasStationMemRepo) CreatePricePartitions(from time.Time, to time.Time) error {
+	return nil
+}
+
+func (repo *gasStationMemRepo) FindOldestPrice() (gsmodel.GasPrice, bool) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	var result gsmodel.GasPrice
+	found := false
+	for _, myGasPrices := range repo.gasPrices {
+		for _, myGasPrice := range myGasPrices {
+			if !found || myGasPrice.Date.Before(result.Date) {
+				result = myGasPrice
+				found = true
+			}
+		}
+	}
+	return result, found
+}
+
+func (repo *gasStationMemRepo) FindPricesInRange(from time.Time, to time.Time) []gsmodel.GasPrice {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	result := []gsmodel.GasPrice{}
+	for _, myGasPrices := range repo.gasPrices {
+		for _, myGasPrice := range myGasPrices {
+			if !myGasPrice.Date.Before(from) && myGasPrice.Date.Before(to) {
+				result = append(result, myGasPrice)
+			}
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].GasStationID == result[j].GasStationID {
+			return result[i].Date.Before(result[j].Date)
+		}
+		return result[i].GasStationID < result[j].GasStationID
+	})
+	return result
+}
+
+func (repo *gasStationMemRepo) SavePriceDailies(gasPriceDailies []gsmodel.GasPriceDaily) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	for _, myGasPriceDaily := range gasPriceDailies {
+		if _, ok := repo.gasPriceDailies[myGasPriceDaily.GasStationID]; !ok {
+			repo.gasPriceDailies[myGasPriceDaily.GasStationID] = make(map[time.Time]gsmodel.GasPriceDaily)
+		}
+		if myOldGasPriceDaily, ok := repo.gasPriceDailies[myGasPriceDaily.GasStationID][myGasPriceDaily.Day]; ok {
+			myGasPriceDaily.ID = myOldGasPriceDaily.ID
+		} else {
+			myGasPriceDaily.ID = repo.ids.Next()
+		}
+		repo.gasPriceDailies[myGasPriceDaily.GasStationID][myGasPriceDaily.Day] = myGasPriceDaily
+	}
+}
+
+func (repo *gasStationMemRepo) FindPriceDailiesByStidInRange(stid string, from time.Time, to time.Time) ([]gsmodel.GasPriceDaily, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	result := []gsmodel.GasPriceDaily{}
+	for _, myGasPriceDaily := range repo.gasPriceDailies[stid] {
+		if !myGasPriceDaily.Day.Before(from) && myGasPriceDaily.Day.Before(to) {
+			result = append(result, myGasPriceDaily)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Day.Before(result[j].Day)
+	})
+	return result, nil
+}
+
+func (repo *gasStationMemRepo) FindLastPriceDailyByStidBefore(stid string, before time.Time) (gsmodel.GasPriceDaily, bool, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	result := gsmodel.GasPriceDaily{}
+	found := false
+	for _, myGasPriceDaily := range repo.gasPriceDailies[stid] {
+		if myGasPriceDaily.Day.Before(before) && (!found || myGasPriceDaily.Day.After(result.Day)) {
+			result = myGasPriceDaily
+			found = true
+		}
+	}
+	return result, found, nil
+}
+
+func (repo *gasStationMemRepo) FindLatestPricesBefore(before time.Time) []gsmodel.GasPrice {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	result := []gsmodel.GasPrice{}
+	for _, myGasPrices := range repo.gasPrices {
+		var myLatestGasPrice *gsmodel.GasPrice
+		for index := range myGasPrices {
+			if myGasPrices[index].Date.Before(before) && (myLatestGasPrice == nil || myGasPrices[index].Date.After(myLatestGasPrice.Date)) {
+				myLatestGasPrice = &myGasPrices[index]
+			}
+		}
+		if myLatestGasPrice != nil {
+			result = append(result, *myLatestGasPrice)
+		}
+	}
+	return result
+}
+
+func (repo *gasStationMemRepo) FindLatestPricesByStids(stids []string, since time.Time) []gsmodel.GasPrice {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	sinceDay := time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, since.Location())
+	result := []gsmodel.GasPrice{}
+	for _, myStid := range stids {
+		var myLatestGasPrice *gsmodel.GasPrice
+		myGasPrices := repo.gasPrices[myStid]
+		for index := range myGasPrices {
+			if !myGasPrices[index].Date.Before(sinceDay) && (myLatestGasPrice == nil || myGasPrices[index].Date.After(myLatestGasPrice.Date)) {
+				myLatestGasPrice = &myGasPrices[index]
+			}
+		}
+		if myLatestGasPrice != nil {
+			result = append(result, *myLatestGasPrice)
+		}
+	}
+	return result
+}
+
+func (repo *gasStationMemRepo) CreatePrices(gasPrices []gsmodel.GasPrice) error {
+	repo.SavePrices(gasPrices)
+	return nil
+}
+
+func (repo *gasStationMemRepo) SavePriceImportFile(priceImportFile gsmodel.PriceImportFile) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	if myOldPriceImportFile, ok := repo.priceImportFiles[priceImportFile.FileName]; ok {
+		priceImportFile.ID = myOldPriceImportFile.ID
+	} else if priceImportFile.ID == 0 {
+		priceImportFile.ID = repo.ids.Next()
+	}
+	repo.priceImportFiles[priceImportFile.FileName] = priceImportFile
+}
+
+func (repo *gasStationMemRepo) FindPriceImportFiles() []gsmodel.PriceImportFile {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	result := []gsmodel.PriceImportFile{}
+	for _, myPriceImportFile := range repo.priceImportFiles {
+		result = append(result, myPriceImportFile)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].FileName < result[j].FileName
+	})
+	return result
+}
+
+func (repo *gasStationMemRepo) SaveStationImportRun(stationImportRun *gsmodel.StationImportRun) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	for index, myStationImportRun := range *repo.stationImportRuns {
+		if stationImportRun.ID != 0 && myStationImportRun.ID == stationImportRun.ID {
+			(*repo.stationImportRuns)[index] = *stationImportRun
+			return
+		}
+	}
+	if stationImportRun.ID == 0 {
+		stationImportRun.ID = repo.ids.Next()
+	}
+	*repo.stationImportRuns = append(*repo.stationImportRuns, *stationImportRun)
+}
+
+func (repo *gasStationMemRepo) FindStationImportRuns(limit int) []gsmodel.StationImportRun {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	result := append([]gsmodel.StationImportRun{}, *repo.stationImportRuns...)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].StartTime.After(result[j].StartTime)
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+func (repo *gasStationMemRepo) FindLastImportedStationRun() (gsmodel.StationImportRun, bool) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	result, found := gsmodel.StationImportRun{}, false
+	for _, myStationImportRun := range *repo.stationImportRuns {
+		if myStationImportRun.Status == gsmodel.ImportRunImported && (!found || myStationImportRun.StartTime.After(result.StartTime)) {
+			result, found = myStationImportRun, true
+		}
+	}
+	return result, found
+}
+
+func (repo *gasStationMemRepo) FindStationImportRunById(id int64) (gsmodel.StationImportRun, bool) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	for _, myStationImportRun := range *repo.stationImportRuns {
+		if myStationImportRun.ID == id {
+			return myStationImportRun, true
+		}
+	}
+	return gsmodel.StationImportRun{}, false
+}
+
+func (repo *gasStationMemRepo) SaveStationImportErrors(stationImportErrors []gsmodel.StationImportError) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	for _, myStationImportError := range stationImportErrors {
+		myStationImportError.ID = repo.ids.Next()
+		repo.stationImportErrs[myStationImportError.StationImportRunID] = append(repo.stationImportErrs[myStationImportError.StationImportRunID],
+			myStationImportError)
+	}
+}
+
+func (repo *gasStationMemRepo) FindStationImportErrors(stationImportRunId int64) []gsmodel.StationImportError {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	result := append([]gsmodel.StationImportError{}, repo.stationImportErrs[stationImportRunId]...)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Line < result[j].Line
+	})
+	return result
+}
+
+func (repo *gasStationMemRepo) Transaction(txFunc func(repo GasStationRepo) error) error {
+	return repo.transactor.Run(repo.mutex, func() func() {
+		myGasStations := maps.Clone(repo.gasStations)
+		myGasPrices := memrepo.CloneMapOfSlices(repo.gasPrices)
+		myGasPriceDailies := memrepo.CloneMapOfMaps(repo.gasPriceDailies)
+		myLifecycleChanges := slices.Clone(*repo.lifecycleChanges)
+		myGasStationChanges := slices.Clone(*repo.gasStationChanges)
+		myPriceImportFiles := maps.Clone(repo.priceImportFiles)
+		myStationImportRuns := slices.Clone(*repo.stationImportRuns)
+		myStationImportErrs := memrepo.CloneMapOfSlices(repo.stationImportErrs)
+		return func() {
+			repo.gasStations = myGasStations
+			repo.gasPrices = myGasPrices
+			repo.gasPriceDailies = myGasPriceDailies
+			*repo.lifecycleChanges = myLifecycleChanges
+			*repo.gasStationChanges = myGasStationChanges
+			repo.priceImportFiles = myPriceImportFiles
+			*repo.stationImportRuns = myStationImportRuns
+			repo.stationImportErrs = myStationImportErrs
+		}
+	}, func() error { return txFunc(repo) })
+}
+
+func (repo *gasStationMemRepo) filterGasStations(filter func(gsmodel.GasStation) bool) []gsmodel.GasStation {
+	result := []gsmodel.GasStation{}
+	for _, myGasStation := range repo.gasStations {
+		if filter(myGasStation) {
+			result = append(result, myGasStation)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+func (repo *gasStationMemRepo) latestPrices(stid string, limit int) []gsmodel.GasPrice {
+	result := append([]gsmodel.GasPrice{}, repo.gasPrices[stid]...)
+	sortPricesDesc(result)
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+func sortPricesDesc(gasPrices []gsmodel.GasPrice) {
+	sort.SliceStable(gasPrices, func(i, j int) bool {
+		return gasPrices[i].Date.After(gasPrices[j].Date)
+	})
+}
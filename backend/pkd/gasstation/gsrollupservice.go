@@ -0,0 +1,82 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"log"
+	"react-and-go/pkd/database/dbmigrate"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"time"
+)
+
+const priceRetentionHours = 1080
+
+// rolls the prices older than the retention period up into daily aggregates and deletes them afterwards
+func (service *GasStationService) CleanupOldPrices() {
+	log.Printf("CleanupOldPrices started.")
+	myStart := time.Now()
+	cutOffDay := priceRetentionStart(myStart)
+	dayNum := 0
+	dailyNum := 0
+	oldestGasPrice, found := service.gasStationRepo.FindOldestPrice()
+	if !found || !oldestGasPrice.Date.Before(cutOffDay) {
+		log.Printf("CleanupOldPrices found no prices to roll up.")
+		return
+	}
+	myDate := oldestGasPrice.Date.In(time.Local)
+	//the days of a partition that is not dropped yet are rolled up again, the daily aggregates are replaced
+	for myDay := time.Date(myDate.Year(), myDate.Month(), myDate.Day(), 0, 0, 0, 0, time.Local); myDay.Before(cutOffDay); myDay = myDay.AddDate(0, 0, 1) {
+		err := service.gasStationRepo.Transaction(func(repo GasStationRepo) error {
+			gasPriceDailies := createGasPriceDailies(myDay, repo.FindPricesInRange(myDay, myDay.AddDate(0, 0, 1)))
+			repo.SavePriceDailies(gasPriceDailies)
+			dailyNum += len(gasPriceDailies)
+			return nil
+		})
+		if err != nil {
+			log.Printf("CleanupOldPrices failed for day: %v, %v", myDay, err)
+			return
+		}
+		dayNum += 1
+	}
+	service.gasStationRepo.DeletePricesBefore(cutOffDay)
+	myDuration := time.Since(myStart)
+	log.Printf("CleanupOldPrices finished for %v days with %v daily aggregates in %v.", dayNum, dailyNum, myDuration)
+}
+
+// the prices before the first day of the retention period are only kept as daily aggregates
+func priceRetentionStart(now time.Time) time.Time {
+	myTimeFrame := now.Add(time.Hour * -priceRetentionHours)
+	return time.Date(myTimeFrame.Year(), myTimeFrame.Month(), myTimeFrame.Day(), 0, 0, 0, 0, time.Local)
+}
+
+func (service *GasStationService) CreateUpcomingPricePartitions() {
+	if err := service.gasStationRepo.CreatePricePartitions(time.Now(), time.Now().AddDate(0, dbmigrate.PricePartitionMonthsAhead, 0)); err != nil {
+		log.Printf("CreateUpcomingPricePartitions failed: %v", err)
+	}
+}
+
+// the prices have to be ordered by station and date
+func createGasPriceDailies(day time.Time, gasPrices []gsmodel.GasPrice) []gsmodel.GasPriceDaily {
+	var result []gsmodel.GasPriceDaily
+	for _, myGasPrice := range gasPrices {
+		if len(result) == 0 || result[len(result)-1].GasStationID != myGasPrice.GasStationID {
+			result = append(result, gsmodel.GasPriceDaily{GasStationID: myGasPrice.GasStationID, Day: day})
+		}
+		myGasPriceDaily := &result[len(result)-1]
+		myGasPriceDaily.E5.AddPrice(myGasPrice.E5)
+		myGasPriceDaily.E10.AddPrice(myGasPrice.E10)
+		myGasPriceDaily.Diesel.AddPrice(myGasPrice.Diesel)
+		myGasPriceDaily.Changes += 1
+	}
+	return result
+}
@@ -0,0 +1,134 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"errors"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"react-and-go/pkd/postcode"
+	"testing"
+	"time"
+)
+
+// records the order of the rollup calls, a txErr fails the transactions
+type rollupTestRepo struct {
+	GasStationRepo
+	calls *[]string
+	txErr error
+}
+
+func (repo rollupTestRepo) SavePriceDailies(gasPriceDailies []gsmodel.GasPriceDaily) {
+	*repo.calls = append(*repo.calls, "save")
+	repo.GasStationRepo.SavePriceDailies(gasPriceDailies)
+}
+
+func (repo rollupTestRepo) DeletePricesBefore(before time.Time) {
+	*repo.calls = append(*repo.calls, "delete")
+	repo.GasStationRepo.DeletePricesBefore(before)
+}
+
+func (repo rollupTestRepo) Transaction(txFunc func(repo GasStationRepo) error) error {
+	if repo.txErr != nil {
+		return repo.txErr
+	}
+	return repo.GasStationRepo.Transaction(func(txRepo GasStationRepo) error {
+		return txFunc(rollupTestRepo{GasStationRepo: txRepo, calls: repo.calls})
+	})
+}
+
+func newTestRollupService(txErr error) (*GasStationService, GasStationRepo, *[]string, time.Time) {
+	myRepo := NewGasStationMemRepo()
+	myCalls := []string{}
+	myNow := time.Now()
+	myDay := time.Date(myNow.Year(), myNow.Month(), myNow.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, -50)
+	//the prices are saved out of date order
+	myRepo.SavePrices([]gsmodel.GasPrice{
+		{GasStationID: "stid1", E5: 1850, E10: 0, Diesel: 1700, Date: myDay.Add(12 * time.Hour)},
+		{GasStationID: "stid1", E5: 1800, E10: 0, Diesel: 1690, Date: myDay.Add(8 * time.Hour)},
+		{GasStationID: "stid1", E5: 1820, E10: 0, Diesel: 1660, Date: myDay.Add(18 * time.Hour)},
+		{GasStationID: "stid1", E5: 1750, E10: 0, Diesel: 1710, Date: myDay.Add(10 * time.Hour)},
+		{GasStationID: "stid2", E5: 1900, E10: 1850, Diesel: 1750, Date: myDay.Add(9 * time.Hour)},
+		{GasStationID: "stid1", E5: 1790, E10: 0, Diesel: 1680, Date: myDay.AddDate(0, 0, 1).Add(7 * time.Hour)},
+		{GasStationID: "stid1", E5: 1780, E10: 0, Diesel: 1670, Date: myNow.Add(-24 * time.Hour)},
+	})
+	myTestRepo := rollupTestRepo{GasStationRepo: myRepo, calls: &myCalls, txErr: txErr}
+	return NewGasStationService(myTestRepo, postcode.NewPostCodeMemRepo(), nil), myRepo, &myCalls, myDay
+}
+
+func TestCleanupOldPrices(t *testing.T) {
+	myService, myRepo, myCalls, myDay := newTestRollupService(nil)
+	myService.CleanupOldPrices()
+	myGasPriceDailies, _ := myRepo.FindPriceDailiesByStidInRange("stid1", myDay, myDay.AddDate(0, 0, 2))
+	if len(myGasPriceDailies) != 2 {
+		t.Fatalf("CleanupOldPrices() dailies = %v want: 2", len(myGasPriceDailies))
+	}
+	tests := []struct {
+		name          string
+		fuelAggregate gsmodel.FuelAggregate
+		want          gsmodel.FuelAggregate
+	}{
+		{"e5 first day", myGasPriceDailies[0].E5, gsmodel.FuelAggregate{First: 1800, Last: 1820, Min: 1750, Max: 1850, Mean: 1805, Count: 4}},
+		{"diesel first day", myGasPriceDailies[0].Diesel, gsmodel.FuelAggregate{First: 1690, Last: 1660, Min: 1660, Max: 1710, Mean: 1690, Count: 4}},
+		{"e10 not sold", myGasPriceDailies[0].E10, gsmodel.FuelAggregate{}},
+		{"e5 second day", myGasPriceDailies[1].E5, gsmodel.FuelAggregate{First: 1790, Last: 1790, Min: 1790, Max: 1790, Mean: 1790, Count: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.fuelAggregate != tt.want {
+				t.Errorf("CleanupOldPrices() aggregate = %+v want: %+v", tt.fuelAggregate, tt.want)
+			}
+		})
+	}
+	if myGasPriceDailies[0].Changes != 4 || !myGasPriceDailies[0].Day.Equal(myDay) {
+		t.Errorf("CleanupOldPrices() daily = %+v want: 4 changes on %v", myGasPriceDailies[0], myDay)
+	}
+	if myOtherDailies, _ := myRepo.FindPriceDailiesByStidInRange("stid2", myDay, myDay.AddDate(0, 0, 1)); len(myOtherDailies) != 1 || myOtherDailies[0].E10.Max != 1850 {
+		t.Errorf("CleanupOldPrices() stid2 dailies = %+v", myOtherDailies)
+	}
+	if myGasPrices := myRepo.FindPricesByStid("stid1"); len(myGasPrices) != 1 || myGasPrices[0].E5 != 1780 {
+		t.Errorf("CleanupOldPrices() kept prices = %+v want: the price of yesterday", myGasPrices)
+	}
+	//the dailies of every day are saved before the raw prices are deleted
+	if myLast := len(*myCalls) - 1; myLast < 1 || (*myCalls)[myLast] != "delete" || (*myCalls)[myLast-1] != "save" {
+		t.Errorf("CleanupOldPrices() calls = %v want: the saves before the delete", *myCalls)
+	}
+	for _, myCall := range (*myCalls)[:len(*myCalls)-1] {
+		if myCall != "save" {
+			t.Errorf("CleanupOldPrices() calls = %v want: one delete at the end", *myCalls)
+		}
+	}
+}
+
+func TestCleanupOldPricesFailedRollup(t *testing.T) {
+	myService, myRepo, myCalls, _ := newTestRollupService(errors.New("rollup failed"))
+	myService.CleanupOldPrices()
+	if len(*myCalls) != 0 {
+		t.Errorf("CleanupOldPrices() calls = %v want: no delete after a failed rollup", *myCalls)
+	}
+	if myGasPrices := myRepo.FindPricesByStid("stid1"); len(myGasPrices) != 6 {
+		t.Errorf("CleanupOldPrices() kept prices = %v want: 6", len(myGasPrices))
+	}
+}
+
+func TestCleanupOldPricesAgain(t *testing.T) {
+	myService, myRepo, myCalls, myDay := newTestRollupService(nil)
+	myService.CleanupOldPrices()
+	*myCalls = []string{}
+	myService.CleanupOldPrices()
+	if len(*myCalls) != 0 {
+		t.Errorf("CleanupOldPrices() calls = %v want: nothing to roll up", *myCalls)
+	}
+	if myGasPriceDailies, _ := myRepo.FindPriceDailiesByStidInRange("stid1", myDay, myDay.AddDate(0, 0, 2)); len(myGasPriceDailies) != 2 {
+		t.Errorf("CleanupOldPrices() dailies = %v want: 2", len(myGasPriceDailies))
+	}
+}
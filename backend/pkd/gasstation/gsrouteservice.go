@@ -0,0 +1,239 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"fmt"
+	"math"
+	gsbody "react-and-go/pkd/controller/gsmodel"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"sort"
+	"strings"
+)
+
+const maxCorridorKm = 10.0
+const maxRoutePoints = 10000
+
+// the route is split in sections of this length to query the stations with small bounding boxes
+const routeSectionKm = 25.0
+
+// the number of segments in a block of the route index
+const routeBlockSegments = 64
+
+type RouteGasStation struct {
+	GasStation         gsmodel.GasStation
+	DistanceToRoute    float64
+	DistanceAlongRoute float64
+}
+
+type routeProjection struct {
+	distanceToRoute    float64
+	distanceAlongRoute float64
+}
+
+func (service *GasStationService) FindByRoute(searchRoute gsbody.SearchRouteBody) ([]RouteGasStation, error) {
+	result := []RouteGasStation{}
+	routePoints, err := createRoutePoints(searchRoute)
+	if err != nil {
+		return result, err
+	}
+	myCorridorKm := searchRoute.CorridorKm
+	if myCorridorKm <= 0.0 {
+		return result, fmt.Errorf("corridor width must be positive: %v", searchRoute.CorridorKm)
+	}
+	if myCorridorKm > maxCorridorKm {
+		myCorridorKm = maxCorridorKm
+	}
+	gasStationMap := make(map[string]gsmodel.GasStation)
+	for _, routeSection := range createRouteSections(routePoints) {
+		minMax := calcRouteMinMaxSquare(routeSection, myCorridorKm)
+		for _, myGasStation := range service.gasStationRepo.FindInSquare(minMax, 0) {
+			gasStationMap[myGasStation.ID] = myGasStation
+		}
+	}
+	var gasStations []gsmodel.GasStation
+	for _, myGasStation := range gasStationMap {
+		gasStations = append(gasStations, myGasStation)
+	}
+	var gasStationIds []string
+	myRouteIndex := createRouteIndex(routePoints, myCorridorKm)
+	for _, myGasStation := range filterOpenGasStations(gasStations, searchRoute.OpenNow, searchRoute.OpenAt) {
+		myProjection := myRouteIndex.project(myGasStation.Latitude, myGasStation.Longitude)
+		if myProjection.distanceToRoute <= myCorridorKm {
+			result = append(result, RouteGasStation{GasStation: myGasStation, DistanceToRoute: myProjection.distanceToRoute, DistanceAlongRoute: myProjection.distanceAlongRoute})
+			gasStationIds = append(gasStationIds, myGasStation.ID)
+		}
+	}
+	if len(gasStationIds) > 0 {
+		gasStationIdGasPriceMap := service.findLatestPriceMap(gasStationIds)
+		for index := range result {
+			if myGasPrice, found := gasStationIdGasPriceMap[result[index].GasStation.ID]; found {
+				result[index].GasStation.GasPrices = []gsmodel.GasPrice{myGasPrice}
+			}
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].DistanceAlongRoute < result[j].DistanceAlongRoute
+	})
+	return result, nil
+}
+
+func createRoutePoints(searchRoute gsbody.SearchRouteBody) ([]gsbody.RoutePoint, error) {
+	routePoints := searchRoute.Route
+	if searchRoute.LineString != nil {
+		if !strings.EqualFold(searchRoute.LineString.Type, "LineString") {
+			return nil, fmt.Errorf("unsupported geometry type: %v", searchRoute.LineString.Type)
+		}
+		routePoints = []gsbody.RoutePoint{}
+		for _, myCoordinate := range searchRoute.LineString.Coordinates {
+			if len(myCoordinate) < 2 {
+				return nil, fmt.Errorf("invalid coordinate: %v", myCoordinate)
+			}
+			//GeoJson coordinates are longitude, latitude
+			routePoints = append(routePoints, gsbody.RoutePoint{Longitude: myCoordinate[0], Latitude: myCoordinate[1]})
+		}
+	}
+	if len(routePoints) < 2 {
+		return nil, fmt.Errorf("route needs at least 2 points: %v", len(routePoints))
+	}
+	if len(routePoints) > maxRoutePoints {
+		return nil, fmt.Errorf("route has too many points: %v max: %v", len(routePoints), maxRoutePoints)
+	}
+	for _, myRoutePoint := range routePoints {
+		if math.Abs(myRoutePoint.Latitude) > 90.0 || math.Abs(myRoutePoint.Longitude) > 180.0 {
+			return nil, fmt.Errorf("invalid route point: %v", myRoutePoint)
+		}
+	}
+	return routePoints, nil
+}
+
+func createRouteSections(routePoints []gsbody.RoutePoint) [][]gsbody.RoutePoint {
+	var result [][]gsbody.RoutePoint
+	mySection := []gsbody.RoutePoint{routePoints[0]}
+	sectionKm := 0.0
+	for index := 1; index < len(routePoints); index++ {
+		//long segments are split to keep the bounding boxes small
+		segmentKm := calcDistance(routePoints[index-1], routePoints[index])
+		steps := int(math.Ceil(segmentKm / routeSectionKm))
+		for step := 1; step <= steps; step++ {
+			fraction := float64(step) / float64(steps)
+			myRoutePoint := gsbody.RoutePoint{Latitude: routePoints[index-1].Latitude + fraction*(routePoints[index].Latitude-routePoints[index-1].Latitude),
+				Longitude: routePoints[index-1].Longitude + fraction*(routePoints[index].Longitude-routePoints[index-1].Longitude)}
+			mySection = append(mySection, myRoutePoint)
+			sectionKm += segmentKm / float64(steps)
+			if sectionKm >= routeSectionKm {
+				result = append(result, mySection)
+				mySection = []gsbody.RoutePoint{myRoutePoint}
+				sectionKm = 0.0
+			}
+		}
+	}
+	if len(mySection) > 1 {
+		result = append(result, mySection)
+	}
+	return result
+}
+
+func calcRouteMinMaxSquare(routeSection []gsbody.RoutePoint, corridorKm float64) MinMaxSquare {
+	minMax := MinMaxSquare{MinLat: 1000.0, MinLng: 1000.0, MaxLat: -1000.0, MaxLng: -1000.0}
+	for _, myRoutePoint := range routeSection {
+		pointMinMax := calcMinMaxSquare(myRoutePoint.Longitude, myRoutePoint.Latitude, corridorKm)
+		minMax = updateMinMaxSquare(pointMinMax.MinLat, pointMinMax.MinLng, minMax)
+		minMax = updateMinMaxSquare(pointMinMax.MaxLat, pointMinMax.MaxLng, minMax)
+	}
+	return minMax
+}
+
+// the segments of a block are skipped together if the location is outside of the bounding box of the block
+type routeIndex struct {
+	routePoints []gsbody.RoutePoint
+	blocks      []routeBlock
+}
+
+type routeBlock struct {
+	firstPoint int
+	lastPoint  int
+	startKm    float64
+	minMax     MinMaxSquare
+}
+
+// the bounding boxes include the corridor, the nearest segment of a location in the corridor is in a matching block
+func createRouteIndex(routePoints []gsbody.RoutePoint, corridorKm float64) routeIndex {
+	result := routeIndex{routePoints: routePoints}
+	routeKm := 0.0
+	for firstPoint := 0; firstPoint < len(routePoints)-1; firstPoint += routeBlockSegments {
+		lastPoint := min(firstPoint+routeBlockSegments, len(routePoints)-1)
+		result.blocks = append(result.blocks, routeBlock{firstPoint: firstPoint, lastPoint: lastPoint, startKm: routeKm,
+			minMax: calcRouteMinMaxSquare(routePoints[firstPoint:lastPoint+1], corridorKm+0.1)})
+		for index := firstPoint + 1; index <= lastPoint; index++ {
+			_, _, segmentKm := projectOnSegment(routePoints[index-1], routePoints[index], routePoints[index].Latitude, routePoints[index].Longitude)
+			routeKm += segmentKm
+		}
+	}
+	return result
+}
+
+// projects the location on the nearest segment of the blocks that contain the location
+func (index routeIndex) project(latitude float64, longitude float64) routeProjection {
+	result := routeProjection{distanceToRoute: math.MaxFloat64}
+	for _, myBlock := range index.blocks {
+		if latitude < myBlock.minMax.MinLat || latitude > myBlock.minMax.MaxLat || longitude < myBlock.minMax.MinLng || longitude > myBlock.minMax.MaxLng {
+			continue
+		}
+		myProjection := projectOnRoute(index.routePoints[myBlock.firstPoint:myBlock.lastPoint+1], latitude, longitude)
+		if myProjection.distanceToRoute < result.distanceToRoute {
+			result.distanceToRoute = myProjection.distanceToRoute
+			result.distanceAlongRoute = myBlock.startKm + myProjection.distanceAlongRoute
+		}
+	}
+	return result
+}
+
+// projects the location on the nearest route segment with an equirectangular approximation
+func projectOnRoute(routePoints []gsbody.RoutePoint, latitude float64, longitude float64) routeProjection {
+	result := routeProjection{distanceToRoute: math.MaxFloat64}
+	routeKm := 0.0
+	for index := 1; index < len(routePoints); index++ {
+		distance, fraction, segmentKm := projectOnSegment(routePoints[index-1], routePoints[index], latitude, longitude)
+		if distance < result.distanceToRoute {
+			result.distanceToRoute = distance
+			result.distanceAlongRoute = routeKm + fraction*segmentKm
+		}
+		routeKm += segmentKm
+	}
+	return result
+}
+
+// returns the distance to the segment, the fraction of the segment up to the projected location and the length of the segment
+func projectOnSegment(startPoint gsbody.RoutePoint, endPoint gsbody.RoutePoint, latitude float64, longitude float64) (float64, float64, float64) {
+	kmPerDegLat := math.Pi * 6371.0 / 180.0
+	kmPerDegLng := kmPerDegLat * math.Cos((startPoint.Latitude+endPoint.Latitude)/2*math.Pi/180.0)
+	segmentX := (endPoint.Longitude - startPoint.Longitude) * kmPerDegLng
+	segmentY := (endPoint.Latitude - startPoint.Latitude) * kmPerDegLat
+	pointX := (longitude - startPoint.Longitude) * kmPerDegLng
+	pointY := (latitude - startPoint.Latitude) * kmPerDegLat
+	segmentKm := math.Sqrt(segmentX*segmentX + segmentY*segmentY)
+	fraction := 0.0
+	if segmentKm > 0.0 {
+		fraction = math.Max(0.0, math.Min(1.0, (pointX*segmentX+pointY*segmentY)/(segmentKm*segmentKm)))
+	}
+	deltaX := pointX - fraction*segmentX
+	deltaY := pointY - fraction*segmentY
+	return math.Sqrt(deltaX*deltaX + deltaY*deltaY), fraction, segmentKm
+}
+
+func calcDistance(startPoint gsbody.RoutePoint, endPoint gsbody.RoutePoint) float64 {
+	myGasStation := gsmodel.GasStation{Latitude: endPoint.Latitude, Longitude: endPoint.Longitude}
+	distance, _ := myGasStation.CalcDistanceBearing(startPoint.Latitude, startPoint.Longitude)
+	return distance
+}
@@ -0,0 +1,181 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"math"
+	gsbody "react-and-go/pkd/controller/gsmodel"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"testing"
+)
+
+func TestCreateRoutePoints(t *testing.T) {
+	tests := []struct {
+		name        string
+		searchRoute gsbody.SearchRouteBody
+		want        []gsbody.RoutePoint
+		wantErr     bool
+	}{
+		{"route", gsbody.SearchRouteBody{Route: []gsbody.RoutePoint{{Longitude: 13.0, Latitude: 52.0}, {Longitude: 13.1, Latitude: 52.0}}},
+			[]gsbody.RoutePoint{{Longitude: 13.0, Latitude: 52.0}, {Longitude: 13.1, Latitude: 52.0}}, false},
+		{"line string", gsbody.SearchRouteBody{LineString: &gsbody.GeoJsonLineString{Type: "LineString", Coordinates: [][]float64{{13.0, 52.0}, {13.1, 52.0}}}},
+			[]gsbody.RoutePoint{{Longitude: 13.0, Latitude: 52.0}, {Longitude: 13.1, Latitude: 52.0}}, false},
+		{"other geometry", gsbody.SearchRouteBody{LineString: &gsbody.GeoJsonLineString{Type: "Point", Coordinates: [][]float64{{13.0, 52.0}, {13.1, 52.0}}}}, nil, true},
+		{"invalid coordinate", gsbody.SearchRouteBody{LineString: &gsbody.GeoJsonLineString{Type: "LineString", Coordinates: [][]float64{{13.0}, {13.1, 52.0}}}}, nil, true},
+		{"one point", gsbody.SearchRouteBody{Route: []gsbody.RoutePoint{{Longitude: 13.0, Latitude: 52.0}}}, nil, true},
+		{"invalid latitude", gsbody.SearchRouteBody{Route: []gsbody.RoutePoint{{Longitude: 13.0, Latitude: 92.0}, {Longitude: 13.1, Latitude: 52.0}}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := createRoutePoints(tt.searchRoute)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("createRoutePoints() error = %v wantErr: %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("createRoutePoints() = %v want: %v", got, tt.want)
+			}
+			for index := range got {
+				if got[index] != tt.want[index] {
+					t.Errorf("createRoutePoints() = %v want: %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateRouteSections(t *testing.T) {
+	tests := []struct {
+		name         string
+		routePoints  []gsbody.RoutePoint
+		wantSections int
+	}{
+		{"short route", []gsbody.RoutePoint{{Longitude: 13.0, Latitude: 52.0}, {Longitude: 13.1, Latitude: 52.0}}, 1},
+		{"long segment", []gsbody.RoutePoint{{Longitude: 13.0, Latitude: 52.0}, {Longitude: 13.0, Latitude: 52.54}}, 2},
+		{"long route", []gsbody.RoutePoint{{Longitude: 13.0, Latitude: 52.0}, {Longitude: 13.0, Latitude: 52.9}}, 3},
+		{"many short segments", []gsbody.RoutePoint{{Longitude: 13.0, Latitude: 52.0}, {Longitude: 13.0, Latitude: 52.2}, {Longitude: 13.0, Latitude: 52.4}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := createRouteSections(tt.routePoints)
+			if len(got) != tt.wantSections {
+				t.Fatalf("createRouteSections() = %v sections want: %v", len(got), tt.wantSections)
+			}
+			//the sections are connected and cover the route
+			if got[0][0] != tt.routePoints[0] || got[len(got)-1][len(got[len(got)-1])-1] != tt.routePoints[len(tt.routePoints)-1] {
+				t.Errorf("createRouteSections() = %v does not cover the route: %v", got, tt.routePoints)
+			}
+			for index := 1; index < len(got); index++ {
+				if got[index][0] != got[index-1][len(got[index-1])-1] {
+					t.Errorf("section %v does not start at the end of section %v", index, index-1)
+				}
+			}
+		})
+	}
+}
+
+func TestProjectOnRoute(t *testing.T) {
+	myRoutePoints := []gsbody.RoutePoint{{Longitude: 13.0, Latitude: 52.0}, {Longitude: 13.1, Latitude: 52.0}, {Longitude: 13.1, Latitude: 52.1}}
+	tests := []struct {
+		name           string
+		latitude       float64
+		longitude      float64
+		wantToRoute    float64
+		wantAlongRoute float64
+	}{
+		{"route start", 52.0, 13.0, 0.0, 0.0},
+		{"beside the first segment", 52.01, 13.05, 1.112, 3.423},
+		{"before the route start", 52.0, 12.9, 6.846, 0.0},
+		{"beside the second segment", 52.05, 13.11, 0.684, 12.405},
+		{"after the route end", 52.2, 13.1, 11.119, 17.965},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := projectOnRoute(myRoutePoints, tt.latitude, tt.longitude)
+			if math.Abs(got.distanceToRoute-tt.wantToRoute) > 0.01 || math.Abs(got.distanceAlongRoute-tt.wantAlongRoute) > 0.01 {
+				t.Errorf("projectOnRoute() = %+v want: %v %v", got, tt.wantToRoute, tt.wantAlongRoute)
+			}
+		})
+	}
+}
+
+func TestRouteIndex(t *testing.T) {
+	//a zigzag route of 500 points with 8 blocks
+	var myRoutePoints []gsbody.RoutePoint
+	for index := 0; index < 500; index++ {
+		myRoutePoints = append(myRoutePoints, gsbody.RoutePoint{Longitude: 13.0 + float64(index)*0.005, Latitude: 52.0 + float64(index%2)*0.003})
+	}
+	myCorridorKm := 2.0
+	myRouteIndex := createRouteIndex(myRoutePoints, myCorridorKm)
+	if len(myRouteIndex.blocks) != 8 {
+		t.Fatalf("createRouteIndex() = %v blocks want: 8", len(myRouteIndex.blocks))
+	}
+	tests := []struct {
+		name      string
+		latitude  float64
+		longitude float64
+	}{
+		{"route start", 52.0, 13.0},
+		{"block border", 52.01, 13.0 + 64*0.005},
+		{"corridor edge", 52.0 + 0.017, 13.7},
+		{"route end", 52.003, 13.0 + 499*0.005},
+		{"after the route end", 52.0, 15.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := projectOnRoute(myRoutePoints, tt.latitude, tt.longitude)
+			got := myRouteIndex.project(tt.latitude, tt.longitude)
+			if want.distanceToRoute > myCorridorKm {
+				if got.distanceToRoute <= myCorridorKm {
+					t.Errorf("project() = %+v want a location outside of the corridor", got)
+				}
+				return
+			}
+			if math.Abs(got.distanceToRoute-want.distanceToRoute) > 0.0001 || math.Abs(got.distanceAlongRoute-want.distanceAlongRoute) > 0.0001 {
+				t.Errorf("project() = %+v want: %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestFindByRoute(t *testing.T) {
+	myService, myRepo := newTestGasStationService()
+	myRepo.SaveGasStations([]gsmodel.GasStation{{ID: "end", Latitude: 52.001, Longitude: 13.09}, {ID: "start", Latitude: 51.999, Longitude: 13.01},
+		{ID: "far", Latitude: 52.2, Longitude: 13.05}, {ID: "inactive", Latitude: 52.0, Longitude: 13.05, LifecycleStatus: gsmodel.StatusInactive}})
+	myRoute := []gsbody.RoutePoint{{Longitude: 13.0, Latitude: 52.0}, {Longitude: 13.1, Latitude: 52.0}}
+	tests := []struct {
+		name       string
+		corridorKm float64
+		want       []string
+		wantErr    bool
+	}{
+		{"corridor", 1.0, []string{"start", "end"}, false},
+		{"corridor above the max", 100.0, []string{"start", "end"}, false},
+		{"no corridor", 0.0, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := myService.FindByRoute(gsbody.SearchRouteBody{Route: myRoute, CorridorKm: tt.corridorKm})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FindByRoute() error = %v wantErr: %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("FindByRoute() = %v want: %v", got, tt.want)
+			}
+			for index := range got {
+				if got[index].GasStation.ID != tt.want[index] {
+					t.Errorf("FindByRoute()[%v] = %v want: %v", index, got[index].GasStation.ID, tt.want[index])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"fmt"
+	gsbody "react-and-go/pkd/controller/gsmodel"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"sort"
+	"strings"
+	"time"
+)
+
+type FuelType string
+
+const (
+	E5     FuelType = "e5"
+	E10    FuelType = "e10"
+	Diesel FuelType = "diesel"
+)
+
+type SortOrder string
+
+const (
+	SortByPrice    SortOrder = "price"
+	SortByDistance SortOrder = "distance"
+	SortByScore    SortOrder = "score"
+)
+
+type RankedGasStation struct {
+	GasStation gsmodel.GasStation
+	FuelType   FuelType
+	Price      int
+	PriceDate  time.Time
+	PriceAge   int64
+	Distance   float64
+	Bearing    float64
+	Score      float64
+}
+
+func ParseFuelType(fuelTypeStr string) (FuelType, error) {
+	switch myFuelType := FuelType(strings.ToLower(strings.TrimSpace(fuelTypeStr))); myFuelType {
+	case E5, E10, Diesel:
+		return myFuelType, nil
+	default:
+		return "", fmt.Errorf("unsupported fuel type: %v", fuelTypeStr)
+	}
+}
+
+func ParseSortOrder(sortOrderStr string) (SortOrder, error) {
+	switch mySortOrder := SortOrder(strings.ToLower(strings.TrimSpace(sortOrderStr))); mySortOrder {
+	case SortByPrice, SortByDistance, SortByScore:
+		return mySortOrder, nil
+	case "":
+		return SortByPrice, nil
+	default:
+		return "", fmt.Errorf("unsupported sort order: %v", sortOrderStr)
+	}
+}
+
+func FuelPrice(gasPrice gsmodel.GasPrice, fuelType FuelType) int {
+	switch fuelType {
+	case E5:
+		return gasPrice.E5
+	case E10:
+		return gasPrice.E10
+	default:
+		return gasPrice.Diesel
+	}
+}
+
+func (service *GasStationService) FindBestStations(searchBest gsbody.SearchBestBody) ([]RankedGasStation, error) {
+	result := []RankedGasStation{}
+	myFuelType, err := ParseFuelType(searchBest.FuelType)
+	if err != nil {
+		return result, err
+	}
+	mySortOrder, err := ParseSortOrder(searchBest.SortBy)
+	if err != nil {
+		return result, err
+	}
+	myRadius := searchBest.Radius + 0.1
+	if myRadius > 20.0 {
+		myRadius = 20.1
+	}
+	gasStations := filterOpenGasStations(service.findStationsInCircle(searchBest.Latitude, searchBest.Longitude, myRadius), searchBest.OpenNow, searchBest.OpenAt)
+	var gasStationIds []string
+	for _, myGasStation := range gasStations {
+		gasStationIds = append(gasStationIds, myGasStation.ID)
+	}
+	gasStationIdGasPriceMap := service.findLatestPriceMap(gasStationIds)
+	for _, myGasStation := range gasStations {
+		myGasPrice, found := gasStationIdGasPriceMap[myGasStation.ID]
+		//prices below 10 mean that the fuel type is not available
+		if !found || FuelPrice(myGasPrice, myFuelType) <= 10 {
+			continue
+		}
+		distance, bearing := myGasStation.CalcDistanceBearing(searchBest.Latitude, searchBest.Longitude)
+		result = append(result, RankedGasStation{GasStation: myGasStation, FuelType: myFuelType, Price: FuelPrice(myGasPrice, myFuelType),
+			PriceDate: myGasPrice.Date, PriceAge: int64(time.Since(myGasPrice.Date).Seconds()), Distance: distance, Bearing: bearing})
+	}
+	calcScores(result, myRadius)
+	sortRankedGasStations(result, mySortOrder)
+	if searchBest.Limit > 0 && len(result) > searchBest.Limit {
+		result = result[:searchBest.Limit]
+	}
+	return result, nil
+}
+
+// the score is the normalized price plus the normalized distance, lower is better
+func calcScores(rankedGasStations []RankedGasStation, radius float64) {
+	if len(rankedGasStations) == 0 {
+		return
+	}
+	minPrice, maxPrice := rankedGasStations[0].Price, rankedGasStations[0].Price
+	for _, myRankedGasStation := range rankedGasStations {
+		if myRankedGasStation.Price < minPrice {
+			minPrice = myRankedGasStation.Price
+		}
+		if myRankedGasStation.Price > maxPrice {
+			maxPrice = myRankedGasStation.Price
+		}
+	}
+	for index := range rankedGasStations {
+		priceScore := 0.0
+		if maxPrice > minPrice {
+			priceScore = float64(rankedGasStations[index].Price-minPrice) / float64(maxPrice-minPrice)
+		}
+		rankedGasStations[index].Score = priceScore + rankedGasStations[index].Distance/radius
+	}
+}
+
+func sortRankedGasStations(rankedGasStations []RankedGasStation, sortOrder SortOrder) {
+	sort.SliceStable(rankedGasStations, func(i, j int) bool {
+		switch sortOrder {
+		case SortByDistance:
+			return rankedGasStations[i].Distance < rankedGasStations[j].Distance
+		case SortByScore:
+			return rankedGasStations[i].Score < rankedGasStations[j].Score
+		default:
+			if rankedGasStations[i].Price == rankedGasStations[j].Price {
+				return rankedGasStations[i].Distance < rankedGasStations[j].Distance
+			}
+			return rankedGasStations[i].Price < rankedGasStations[j].Price
+		}
+	})
+}
+
+// the latest price of the stations in the last 30 days, the latest price cache is used before the db
+func (service *GasStationService) findLatestPriceMap(stids []string) map[string]gsmodel.GasPrice {
+	mySince := time.Now().Add(time.Hour * -720)
+	result, missingStids := service.latestPriceCache.find(stids, mySince)
+	if len(missingStids) > 0 {
+		for _, myGasPrice := range service.gasStationRepo.FindLatestPricesByStids(missingStids, mySince) {
+			result[myGasPrice.GasStationID] = myGasPrice
+		}
+	}
+	return result
+}
+
+func (service *GasStationService) findStationsInCircle(latitude float64, longitude float64, radius float64) []gsmodel.GasStation {
+	minMax := calcMinMaxSquare(longitude, latitude, radius)
+	gasStations := service.gasStationRepo.FindInSquare(minMax, 0)
+	filteredGasStations := []gsmodel.GasStation{}
+	for _, myGasStation := range gasStations {
+		distance, _ := myGasStation.CalcDistanceBearing(latitude, longitude)
+		if distance < radius {
+			filteredGasStations = append(filteredGasStations, myGasStation)
+		}
+	}
+	return filteredGasStations
+}
+
+func addOpeningTimes(gasStations []gsmodel.GasStation) {
+	for index := range gasStations {
+		gasStations[index].OpeningTimes = gsmodel.ParseOpeningTimes(gasStations[index].OtJson, gasStations[index].PublicHolidayIdentifier)
+	}
+}
+
+// adds the opening times and removes the closed stations if openNow or openAt is set
+func filterOpenGasStations(gasStations []gsmodel.GasStation, openNow bool, openAt *time.Time) []gsmodel.GasStation {
+	addOpeningTimes(gasStations)
+	if !openNow && openAt == nil {
+		return gasStations
+	}
+	myOpenAt := time.Now()
+	if openAt != nil {
+		myOpenAt = *openAt
+	}
+	result := []gsmodel.GasStation{}
+	for _, myGasStation := range gasStations {
+		if myGasStation.OpeningTimes.IsOpenAt(myOpenAt) {
+			result = append(result, myGasStation)
+		}
+	}
+	return result
+}
@@ -0,0 +1,120 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"math"
+	gsbody "react-and-go/pkd/controller/gsmodel"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"react-and-go/pkd/postcode"
+	"testing"
+	"time"
+)
+
+func createRankedGasStation(id string, price int, distance float64) RankedGasStation {
+	return RankedGasStation{GasStation: gsmodel.GasStation{ID: id}, Price: price, Distance: distance}
+}
+
+func TestCalcScores(t *testing.T) {
+	tests := []struct {
+		name              string
+		rankedGasStations []RankedGasStation
+		radius            float64
+		wantScores        []float64
+	}{
+		{"no stations", nil, 10.0, nil},
+		{"same prices", []RankedGasStation{createRankedGasStation("a", 1800, 2.0), createRankedGasStation("b", 1800, 5.0)}, 10.0, []float64{0.2, 0.5}},
+		{"price against distance", []RankedGasStation{createRankedGasStation("cheap", 1700, 10.0), createRankedGasStation("near", 1800, 0.0),
+			createRankedGasStation("between", 1750, 2.0)}, 10.0, []float64{1.0, 1.0, 0.7}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calcScores(tt.rankedGasStations, tt.radius)
+			for index, myRankedGasStation := range tt.rankedGasStations {
+				if math.Abs(myRankedGasStation.Score-tt.wantScores[index]) > 0.0001 {
+					t.Errorf("calcScores() %v = %v want: %v", myRankedGasStation.GasStation.ID, myRankedGasStation.Score, tt.wantScores[index])
+				}
+			}
+		})
+	}
+}
+
+func TestSortRankedGasStations(t *testing.T) {
+	myRankedGasStations := func() []RankedGasStation {
+		result := []RankedGasStation{createRankedGasStation("a", 1800, 1.0), createRankedGasStation("b", 1700, 4.0),
+			createRankedGasStation("c", 1700, 2.0), createRankedGasStation("d", 1750, 3.0)}
+		result[0].Score, result[1].Score, result[2].Score, result[3].Score = 0.5, 0.4, 0.5, 0.9
+		return result
+	}
+	tests := []struct {
+		name      string
+		sortOrder SortOrder
+		want      []string
+	}{
+		{"price with the distance for ties", SortByPrice, []string{"c", "b", "d", "a"}},
+		{"distance", SortByDistance, []string{"a", "c", "d", "b"}},
+		{"score keeps the order of ties", SortByScore, []string{"b", "a", "c", "d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := myRankedGasStations()
+			sortRankedGasStations(got, tt.sortOrder)
+			for index := range got {
+				if got[index].GasStation.ID != tt.want[index] {
+					t.Fatalf("sortRankedGasStations()[%v] = %v want: %v", index, got[index].GasStation.ID, tt.want[index])
+				}
+			}
+		})
+	}
+}
+
+func TestFindBestStations(t *testing.T) {
+	myService, myRepo := newTestGasStationService()
+	myRepo.SaveGasStations([]gsmodel.GasStation{{ID: "near", Latitude: 52.001, Longitude: 13.0}, {ID: "cheap", Latitude: 52.02, Longitude: 13.0},
+		{ID: "noDiesel", Latitude: 52.0, Longitude: 13.001}, {ID: "noPrice", Latitude: 52.0, Longitude: 13.002}, {ID: "far", Latitude: 52.5, Longitude: 13.0}})
+	myTime := time.Now().Add(-time.Hour)
+	myService.UpdatePrice(&[]GasStationPrices{{GasStationID: "near", E5: 1850, E10: 1750, Diesel: 1650, Timestamp: myTime},
+		{GasStationID: "cheap", E5: 1800, E10: 1700, Diesel: 1600, Timestamp: myTime}, {GasStationID: "noDiesel", E5: 1790, E10: 1690, Diesel: 0, Timestamp: myTime},
+		{GasStationID: "far", E5: 1700, E10: 1600, Diesel: 1500, Timestamp: myTime}})
+	tests := []struct {
+		name       string
+		searchBest gsbody.SearchBestBody
+		want       []string
+		wantErr    bool
+	}{
+		{"diesel by price", gsbody.SearchBestBody{Latitude: 52.0, Longitude: 13.0, Radius: 5.0, FuelType: "diesel"}, []string{"cheap", "near"}, false},
+		{"e5 by distance", gsbody.SearchBestBody{Latitude: 52.0, Longitude: 13.0, Radius: 5.0, FuelType: "E5", SortBy: "distance"}, []string{"noDiesel", "near", "cheap"}, false},
+		{"limit", gsbody.SearchBestBody{Latitude: 52.0, Longitude: 13.0, Radius: 5.0, FuelType: "e10", Limit: 1}, []string{"noDiesel"}, false},
+		{"unknown fuel", gsbody.SearchBestBody{Latitude: 52.0, Longitude: 13.0, Radius: 5.0, FuelType: "lpg"}, nil, true},
+	}
+	//a new service has no cached prices and reads the latest prices from the repo
+	myRepoService := NewGasStationService(myRepo, postcode.NewPostCodeMemRepo(), &testPriceNotifier{notified: make(chan []string, 100)})
+	for _, tt := range tests {
+		for _, myTestService := range []*GasStationService{myService, myRepoService} {
+			t.Run(tt.name, func(t *testing.T) {
+				got, err := myTestService.FindBestStations(tt.searchBest)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("FindBestStations() error = %v wantErr: %v", err, tt.wantErr)
+				}
+				if len(got) != len(tt.want) {
+					t.Fatalf("FindBestStations() = %v stations want: %v", len(got), tt.want)
+				}
+				for index := range got {
+					if got[index].GasStation.ID != tt.want[index] {
+						t.Errorf("FindBestStations()[%v] = %v want: %v", index, got[index].GasStation.ID, tt.want[index])
+					}
+				}
+			})
+		}
+	}
+}
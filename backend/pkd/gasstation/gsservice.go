@@ -0,0 +1,646 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"fmt"
+	"log"
+	gsbody "react-and-go/pkd/controller/gsmodel"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"react-and-go/pkd/importdiff"
+	"react-and-go/pkd/postcode"
+	"react-and-go/pkd/postcode/pcmodel"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// a delta is applied to the last known price of the fuel, the price of a fuel with a delta is ignored
+type GasStationPrices struct {
+	GasStationID string `gorm:"column:stid"`
+	E5           int
+	E10          int
+	Diesel       int
+	Timestamp    time.Time
+	E5Delta      *int `gorm:"-"`
+	E10Delta     *int `gorm:"-"`
+	DieselDelta  *int `gorm:"-"`
+	//the fuel has no price and no delta in a message with deltas
+	E5KeepLast     bool `gorm:"-"`
+	E10KeepLast    bool `gorm:"-"`
+	DieselKeepLast bool `gorm:"-"`
+}
+
+// the stale, duplicate, superseded and rejected updates are dropped, the unchanged updates have the prices of the last update
+// a superseded update is replaced by a newer update of the same station in the same batch
+type PriceUpdateResult struct {
+	Received      int
+	Updated       int
+	Unchanged     int
+	Stale         int
+	Duplicate     int
+	Superseded    int
+	Rejected      int
+	RejectedStids []string `json:",omitempty"`
+}
+
+type GasStationImport struct {
+	Uuid             string
+	StationName      string
+	Brand            string
+	Street           string
+	HouseNumber      string
+	PostCode         string
+	City             string
+	Latitude         float64
+	Longitude        float64
+	FirstActive      time.Time
+	OpeningTimesJson string
+}
+
+type GasStationImportResult struct {
+	Stations          int
+	Updated           int
+	Created           int
+	LifecycleChanges  int
+	MasterDataChanges int
+}
+
+// the changes of a station import, the stations are only written by applyGasStationUpdate
+type gasStationUpdatePlan struct {
+	importTime        time.Time
+	stations          int
+	changedStations   []gsmodel.GasStation
+	importedIds       []string
+	newGasStations    []gsmodel.GasStation
+	lifecycleChanges  []gsmodel.LifecycleChange
+	gasStationChanges []gsmodel.GasStationChange
+	diffEntries       []importdiff.DiffEntry
+}
+
+// the stations of quarantinedIds had invalid rows in the import and keep their status
+func (service *GasStationService) UpdateGasStations(gasStations *[]GasStationImport, quarantinedIds map[string]bool) GasStationImportResult {
+	myUpdatePlan := service.planGasStationUpdate(gasStations, quarantinedIds)
+	if myUpdatePlan.stations == 0 {
+		log.Printf("GasStation import is empty, skipping update.\n")
+		return GasStationImportResult{}
+	}
+	return service.applyGasStationUpdate(myUpdatePlan)
+}
+
+// reports the changes of the import without writing them, removed stations are deactivated
+func (service *GasStationService) DiffGasStations(gasStations *[]GasStationImport, quarantinedIds map[string]bool, source string) importdiff.ImportDiff {
+	return importdiff.NewImportDiff(importdiff.KindStations, source, service.planGasStationUpdate(gasStations, quarantinedIds).diffEntries)
+}
+
+func (service *GasStationService) planGasStationUpdate(gasStations *[]GasStationImport, quarantinedIds map[string]bool) gasStationUpdatePlan {
+	gasStationImportMap := make(map[string]GasStationImport)
+	for _, value := range *gasStations {
+		gasStationImportMap[value.Uuid] = value
+	}
+	fmt.Printf("GasStations found: %v\n", len(gasStationImportMap))
+	result := gasStationUpdatePlan{importTime: time.Now(), stations: len(gasStationImportMap)}
+	if len(gasStationImportMap) == 0 {
+		return result
+	}
+	activeGasStationsNum := service.gasStationRepo.CountActive()
+	//a partial import must not deactivate the missing stations
+	deactivationAllowed := int64(len(gasStationImportMap)) >= activeGasStationsNum/2
+	if !deactivationAllowed {
+		log.Printf("GasStation import has %v stations of %v active stations, skipping deactivation.\n", len(gasStationImportMap), activeGasStationsNum)
+	}
+	postCodePostCodeLocationMap, _, _ := service.createPostCodeMaps()
+	for _, myGasStation := range service.gasStationRepo.FindAll(false) {
+		importValue, found := gasStationImportMap[myGasStation.ID]
+		if !found {
+			if deactivationAllowed && !quarantinedIds[myGasStation.ID] && myGasStation.LifecycleStatus != gsmodel.StatusInactive {
+				myLifecycleChange := createLifecycleChange(&myGasStation, gsmodel.StatusInactive, result.importTime)
+				result.lifecycleChanges = append(result.lifecycleChanges, myLifecycleChange)
+				result.changedStations = append(result.changedStations, myGasStation)
+				result.diffEntries = append(result.diffEntries, importdiff.DiffEntry{Key: myGasStation.ID, Action: importdiff.ActionRemove,
+					Changes: []importdiff.FieldChange{{Field: "LifecycleStatus", OldValue: myLifecycleChange.OldStatus, NewValue: myLifecycleChange.NewStatus}}})
+			}
+			continue
+		}
+		result.importedIds = append(result.importedIds, myGasStation.ID)
+		var myFieldChanges []importdiff.FieldChange
+		if myGasStation.LifecycleStatus == gsmodel.StatusInactive {
+			myLifecycleChange := createLifecycleChange(&myGasStation, gsmodel.StatusReappeared, result.importTime)
+			result.lifecycleChanges = append(result.lifecycleChanges, myLifecycleChange)
+			myFieldChanges = append(myFieldChanges, importdiff.FieldChange{Field: "LifecycleStatus", OldValue: myLifecycleChange.OldStatus,
+				NewValue: myLifecycleChange.NewStatus})
+		}
+		if myChanges := updateMasterData(&myGasStation, importValue, result.importTime); len(myChanges) > 0 {
+			result.gasStationChanges = append(result.gasStationChanges, myChanges...)
+			for _, myChange := range myChanges {
+				myFieldChanges = append(myFieldChanges, importdiff.FieldChange{Field: myChange.Field, OldValue: myChange.OldValue, NewValue: myChange.NewValue})
+			}
+		}
+		myApplicableDays := gsmodel.ParseOpeningTimes(myGasStation.OtJson, "").ApplicableDays()
+		myPublicHolidayIdentifier := findPublicHolidayIdentifier(myGasStation.PostCode, postCodePostCodeLocationMap)
+		if myGasStation.ApplicableDays != myApplicableDays {
+			myFieldChanges = append(myFieldChanges, importdiff.FieldChange{Field: "ApplicableDays", OldValue: strconv.Itoa(myGasStation.ApplicableDays),
+				NewValue: strconv.Itoa(myApplicableDays)})
+			myGasStation.ApplicableDays = myApplicableDays
+		}
+		if myGasStation.PublicHolidayIdentifier != myPublicHolidayIdentifier {
+			myFieldChanges = append(myFieldChanges, importdiff.FieldChange{Field: "PublicHolidayIdentifier", OldValue: myGasStation.PublicHolidayIdentifier,
+				NewValue: myPublicHolidayIdentifier})
+			myGasStation.PublicHolidayIdentifier = myPublicHolidayIdentifier
+		}
+		if len(myFieldChanges) > 0 {
+			myGasStation.StationInImport = result.importTime
+			result.changedStations = append(result.changedStations, myGasStation)
+			result.diffEntries = append(result.diffEntries, importdiff.DiffEntry{Key: myGasStation.ID, Action: importdiff.ActionUpdate, Changes: myFieldChanges})
+		}
+		delete(gasStationImportMap, myGasStation.ID)
+	}
+	for _, value := range gasStationImportMap {
+		resultGs := createNewGasStation(value)
+		resultGs.PublicHolidayIdentifier = findPublicHolidayIdentifier(resultGs.PostCode, postCodePostCodeLocationMap)
+		resultGs.StationInImport = result.importTime
+		result.newGasStations = append(result.newGasStations, resultGs)
+		result.diffEntries = append(result.diffEntries, importdiff.DiffEntry{Key: resultGs.ID, Action: importdiff.ActionInsert,
+			Changes: []importdiff.FieldChange{{Field: "StationName", NewValue: resultGs.StationName}, {Field: "PostCode", NewValue: resultGs.PostCode},
+				{Field: "Place", NewValue: resultGs.Place}}})
+	}
+	sort.SliceStable(result.diffEntries, func(i, j int) bool {
+		return result.diffEntries[i].Key < result.diffEntries[j].Key
+	})
+	return result
+}
+
+func (service *GasStationService) applyGasStationUpdate(updatePlan gasStationUpdatePlan) GasStationImportResult {
+	result := GasStationImportResult{Stations: updatePlan.stations}
+	service.gasStationRepo.Transaction(func(repo GasStationRepo) error {
+		for _, batch := range chunkSlice(updatePlan.changedStations, 1000) {
+			repo.SaveGasStations(batch)
+		}
+		for _, batch := range chunkSlice(updatePlan.importedIds, 1000) {
+			repo.UpdateStationInImport(batch, updatePlan.importTime)
+		}
+		fmt.Printf("GasStations updated: %v\n", len(updatePlan.changedStations))
+		repo.SaveGasStations(updatePlan.newGasStations)
+		fmt.Printf("GasStations new: %v\n", len(updatePlan.newGasStations))
+		repo.SaveLifecycleChanges(updatePlan.lifecycleChanges)
+		fmt.Printf("GasStation lifecycle changes: %v\n", len(updatePlan.lifecycleChanges))
+		repo.SaveGasStationChanges(updatePlan.gasStationChanges)
+		fmt.Printf("GasStation master data changes: %v\n", len(updatePlan.gasStationChanges))
+		result.Updated = len(updatePlan.changedStations)
+		result.Created = len(updatePlan.newGasStations)
+		result.LifecycleChanges = len(updatePlan.lifecycleChanges)
+		result.MasterDataChanges = len(updatePlan.gasStationChanges)
+		return nil
+	})
+	return result
+}
+
+func (service *GasStationService) FindChangesByStid(stid string) []gsmodel.GasStationChange {
+	return service.gasStationRepo.FindChangesByStid(stid)
+}
+
+func (service *GasStationService) FindLastImportLifecycleChanges() []gsmodel.LifecycleChange {
+	return service.gasStationRepo.FindLastImportLifecycleChanges()
+}
+
+// the updates are sequenced per station by their timestamp, in a batch the latest update of a station is used
+// an update with the timestamp of the used update is a duplicate, an older update is superseded
+func (service *GasStationService) UpdatePrice(gasStationPrices *[]GasStationPrices) PriceUpdateResult {
+	stationPricesMap := make(map[string]GasStationPrices)
+	myBatchDuplicate := 0
+	myBatchSuperseded := 0
+	for _, value := range *gasStationPrices {
+		myStationPrices, found := stationPricesMap[value.GasStationID]
+		switch {
+		case !found:
+		case value.Timestamp.Equal(myStationPrices.Timestamp):
+			myBatchDuplicate += 1
+		default:
+			myBatchSuperseded += 1
+		}
+		if !found || !value.Timestamp.Before(myStationPrices.Timestamp) {
+			stationPricesMap[value.GasStationID] = value
+		}
+	}
+	var stationPricesKeys []string
+	var stationPrices []GasStationPrices
+	for key, value := range stationPricesMap {
+		stationPricesKeys = append(stationPricesKeys, key)
+		stationPrices = append(stationPrices, value)
+	}
+	mySince := time.Now().Add(time.Hour * -720)
+	lastGasPrices, missingStids := service.latestPriceCache.find(stationPricesKeys, mySince)
+	var stationPricesDb []gsmodel.GasPrice
+	if len(missingStids) > 0 {
+		stationPricesDb = service.gasStationRepo.FindLatestPricesByStids(missingStids, mySince)
+		service.latestPriceCache.load(stationPricesDb)
+	}
+	log.Printf("StationPricesKeys: %v StationPricesCached: %v StationPricesDb: %v", len(stationPricesKeys), len(lastGasPrices), len(stationPricesDb))
+	for _, value := range stationPricesDb {
+		lastGasPrices[value.GasStationID] = value
+	}
+	knownStids := service.findStidsWithoutPrice(stationPricesKeys, lastGasPrices)
+	gasPriceUpdateMap, result := service.latestPriceCache.update(stationPrices, lastGasPrices, func(lastGasPrice gsmodel.GasPrice, found bool, stationPrices GasStationPrices) int {
+		// validation checks
+		if stationPrices.Timestamp.Before(time.Now().Add(time.Hour * -720)) {
+			return 0
+		}
+		if !found {
+			if knownStids[stationPrices.GasStationID] {
+				log.Printf("GasStation with first price: %v\n", stationPrices.GasStationID)
+				return 21
+			}
+			return 0
+		}
+		return calcPriceChanges(lastGasPrice, stationPrices)
+	})
+	result.Duplicate += myBatchDuplicate
+	result.Superseded = myBatchSuperseded
+	result.Received = len(*gasStationPrices)
+	if err := service.handleMaps(gasPriceUpdateMap); err != nil {
+		result.Updated = 0
+	}
+	if result.Stale > 0 || result.Duplicate > 0 || result.Superseded > 0 || result.Rejected > 0 {
+		log.Printf("Priceupdates dropped stale: %v duplicate: %v superseded: %v rejected: %v\n", result.Stale, result.Duplicate, result.Superseded, result.Rejected)
+	}
+	return result
+}
+
+// the stations without a last price get a first price if they exist
+func (service *GasStationService) findStidsWithoutPrice(stids []string, lastGasPrices map[string]gsmodel.GasPrice) map[string]bool {
+	result := make(map[string]bool)
+	var stationIds []string
+	for _, myStid := range stids {
+		if _, found := lastGasPrices[myStid]; !found {
+			stationIds = append(stationIds, myStid)
+		}
+	}
+	if len(stationIds) == 0 {
+		return result
+	}
+	for _, gasStation := range service.gasStationRepo.FindByIds(stationIds, false) {
+		result[gasStation.ID] = true
+	}
+	//create new gas stations
+	if len(stationIds) > len(result) {
+		log.Default().Printf("New GasStations: %v\n", len(stationIds)-len(result))
+	}
+	return result
+}
+
+func (gasStationPrices GasStationPrices) needsLastPrice() bool {
+	return gasStationPrices.E5Delta != nil || gasStationPrices.E10Delta != nil || gasStationPrices.DieselDelta != nil ||
+		gasStationPrices.E5KeepLast || gasStationPrices.E10KeepLast || gasStationPrices.DieselKeepLast
+}
+
+// the deltas and the kept prices need a last price of the station, a delta needs a last price of the fuel and is rejected if the price is below 1
+// a kept price of 0 is a fuel that is not sold
+func applyPriceDeltas(lastGasPrice gsmodel.GasPrice, found bool, gasStationPrices GasStationPrices) (GasStationPrices, bool) {
+	if !gasStationPrices.needsLastPrice() {
+		return gasStationPrices, true
+	}
+	if !found {
+		return gasStationPrices, false
+	}
+	result := gasStationPrices
+	var ok bool
+	if result.E5, ok = applyPriceDelta(lastGasPrice.E5, gasStationPrices.E5, gasStationPrices.E5Delta, gasStationPrices.E5KeepLast); !ok {
+		return gasStationPrices, false
+	}
+	if result.E10, ok = applyPriceDelta(lastGasPrice.E10, gasStationPrices.E10, gasStationPrices.E10Delta, gasStationPrices.E10KeepLast); !ok {
+		return gasStationPrices, false
+	}
+	if result.Diesel, ok = applyPriceDelta(lastGasPrice.Diesel, gasStationPrices.Diesel, gasStationPrices.DieselDelta, gasStationPrices.DieselKeepLast); !ok {
+		return gasStationPrices, false
+	}
+	result.E5Delta, result.E10Delta, result.DieselDelta = nil, nil, nil
+	result.E5KeepLast, result.E10KeepLast, result.DieselKeepLast = false, false, false
+	return result, true
+}
+
+func applyPriceDelta(lastPrice int, price int, delta *int, keepLast bool) (int, bool) {
+	if keepLast {
+		return lastPrice, true
+	}
+	if delta == nil {
+		return price, true
+	}
+	if lastPrice <= 0 || lastPrice+*delta <= 0 {
+		return price, false
+	}
+	return lastPrice + *delta, true
+}
+
+// returns the changed bits of the new prices compared to the last price, invalid prices have no changes
+func calcPriceChanges(lastGasPrice gsmodel.GasPrice, gasStationPrices GasStationPrices) int {
+	if gasStationPrices.Diesel < 0 || gasStationPrices.E10 < 0 || gasStationPrices.E5 < 0 {
+		return 0
+	}
+	var myChanges = 0
+	if gasStationPrices.Diesel != lastGasPrice.Diesel {
+		myChanges = myChanges + 1
+	}
+	if gasStationPrices.E10 != lastGasPrice.E10 {
+		myChanges = myChanges + 16
+	}
+	if gasStationPrices.E5 != lastGasPrice.E5 {
+		myChanges = myChanges + 4
+	}
+	return myChanges
+}
+
+// the prices are inserted with multi row inserts, the cached prices of a failed insert are loaded again from the db
+func (service *GasStationService) handleMaps(gasPriceUpdateMap map[string]gsmodel.GasPrice) error {
+	var gasPrices []gsmodel.GasPrice
+	for _, value := range gasPriceUpdateMap {
+		gasPrices = append(gasPrices, value)
+	}
+	err := service.gasStationRepo.Transaction(func(repo GasStationRepo) error {
+		return repo.CreatePrices(gasPrices)
+	})
+	if err != nil {
+		log.Printf("Prices update failed: %v\n", err)
+		var stids []string
+		for key := range gasPriceUpdateMap {
+			stids = append(stids, key)
+		}
+		service.latestPriceCache.remove(stids)
+		return err
+	}
+	log.Printf("Prices updated: %v\n", len(gasPriceUpdateMap))
+	service.postProcessPrices(gasPriceUpdateMap)
+	return nil
+}
+
+func (service *GasStationService) ReCalcCountyStatePrices() {
+	log.Printf("recalcCountyStatePrices started.")
+	myStart := time.Now()
+	postCodePostCodeLocationMap, idStateDataMap, idCountyDataMap, postCodeGasStationsMap := service.createPostCodeGasStationMaps()
+	//gasStationIdGasPriceMap := createGasStationIdGasPriceMap(&postCodeGasStationsMap, Month)
+	resetDataMaps(&idStateDataMap, &idCountyDataMap)
+	//sum up prices and count stations
+	for _, myPostCodeLocation := range postCodePostCodeLocationMap {
+		myPostCode := postcode.FormatPostCode(myPostCodeLocation.PostCode)
+		gasStations := postCodeGasStationsMap[myPostCode]
+		//log.Printf("gasStationIdGasPriceMap: %v", len(gasStations))
+		for _, myGasStationPrices := range service.createGasStationIdGasPriceMap(&gasStations, Month) {
+			for _, myGasPrice := range myGasStationPrices {
+				if myGasPrice.E5 < 10 && myGasPrice.E10 < 10 && myGasPrice.Diesel < 10 {
+					continue
+				}
+				//log.Printf("%v", myGasPrice)
+				myStateData := idStateDataMap[int(myPostCodeLocation.StateData.ID)]
+				myCountyData := idCountyDataMap[int(myPostCodeLocation.CountyData.ID)]
+				myStateData.GasStationNum += 1
+				myCountyData.GasStationNum += 1
+				if myGasPrice.E5 > 10 {
+					myCountyData.GsNumE5 += 1
+					myStateData.GsNumE5 += 1
+					myStateData.AvgE5 += float64(myGasPrice.E5)
+					myCountyData.AvgE5 += float64(myGasPrice.E5)
+					/*
+						if myCountyData.ID == 51 {
+							gasStations := postCodeGasStationsMap[myPostCode]
+							log.Printf("GsNumE5: %v, AvgE5: %v, E5: %v, Id: %v, GasStations: %v", myCountyData.GsNumE5, myCountyData.AvgE5, myGasPrice.E5, myCountyData.ID, len(gasStations))
+						}
+					*/
+				}
+				if myGasPrice.E10 > 10 {
+					myCountyData.GsNumE10 += 1
+					myStateData.GsNumE10 += 1
+					myStateData.AvgE10 += float64(myGasPrice.E10)
+					myCountyData.AvgE10 += float64(myGasPrice.E10)
+				}
+				if myGasPrice.Diesel > 10 {
+					myCountyData.GsNumDiesel += 1
+					myStateData.GsNumDiesel += 1
+					myStateData.AvgDiesel += float64(myGasPrice.Diesel)
+					myCountyData.AvgDiesel += float64(myGasPrice.Diesel)
+				}
+				idStateDataMap[int(myPostCodeLocation.StateData.ID)] = myStateData
+				idCountyDataMap[int(myPostCodeLocation.CountyData.ID)] = myCountyData
+			}
+		}
+	}
+	//log.Printf("e5Count: %v, e10Count: %v, dieselCount: %v", e5Count, e10Count, dieselCount)
+	log.Printf("sums for postCodePostCodeLocationMap: %v", len(postCodeGasStationsMap))
+	//divide by station count and save
+	service.postCodeRepo.Transaction(func(repo postcode.PostCodeRepo) error {
+		for _, myStateData := range idStateDataMap {
+			if myStateData.GasStationNum > 0 {
+				myStateData.AvgDiesel /= float64(myStateData.GsNumDiesel)
+				myStateData.AvgE10 /= float64(myStateData.GsNumE10)
+				myStateData.AvgE5 /= float64(myStateData.GsNumE5)
+				repo.SaveStateData(&myStateData)
+			}
+		}
+		for _, myCountyData := range idCountyDataMap {
+			if myCountyData.GasStationNum > 0 {
+				myCountyData.AvgDiesel /= float64(myCountyData.GsNumDiesel)
+				myCountyData.AvgE10 /= float64(myCountyData.GsNumE10)
+				myCountyData.AvgE5 /= float64(myCountyData.GsNumE5)
+				repo.SaveCountyData(&myCountyData)
+			}
+		}
+		return nil
+	})
+	myDuration := time.Since(myStart)
+	log.Printf("recalcCountyStatePrices finished for %v states and %v counties in %v.", len(idStateDataMap), len(idCountyDataMap), myDuration)
+}
+
+func (service *GasStationService) createCodeTimeSliceBuckets(postCodePostCodeLocationMap map[int]pcmodel.PostCodeLocation, postCodeGasStationsMap map[string][]gsmodel.GasStation) map[string]map[time.Time][]gsmodel.GasPrice {
+	//gasStationIdGasPriceMap := createGasStationIdGasPriceArrayMap(&postCodeGasStationsMap, Day)
+	postCodeTimeSliceBuckets := make(map[string]map[time.Time][]gsmodel.GasPrice)
+	for _, myPostCodeLocation := range postCodePostCodeLocationMap {
+		myPostCode := postcode.FormatPostCode(myPostCodeLocation.PostCode)
+		gasStations := postCodeGasStationsMap[myPostCode]
+		gasStationIdGasPriceMap := service.createGasStationIdGasPriceArrayMap(&gasStations, Day)
+		for _, myGasStation := range postCodeGasStationsMap[myPostCode] {
+			yesterday := time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, -1).Round(time.Hour)
+			//yesterday := time.Now().AddDate(0, 0, -1).Round(time.Hour)
+			//15 min slices
+			timeSlices := make([]time.Time, 0)
+			//15 min buckets
+			timeSliceBuckets := make(map[time.Time][]gsmodel.GasPrice)
+			for myTimeSlice := yesterday; myTimeSlice.Before(time.Now()); myTimeSlice = myTimeSlice.Add(time.Minute * 15).Round(time.Minute) {
+				timeSlices = append(timeSlices, myTimeSlice)
+				timeSliceBuckets[myTimeSlice] = make([]gsmodel.GasPrice, 0)
+			}
+			for _, myStationPrice := range gasStationIdGasPriceMap[myGasStation.ID] {
+				for i := 0; i < len(timeSlices)-1; i++ {
+					if timeSlices[i].Before(myStationPrice.Date) && timeSlices[i+1].After(myStationPrice.Date) {
+						timeSliceBuckets[timeSlices[i]] = append(timeSliceBuckets[timeSlices[i]], myStationPrice)
+						break
+					} else if i == len(timeSlices)-1 && timeSlices[i+1].Before(myStationPrice.Date) {
+						timeSliceBuckets[timeSlices[i+1]] = append(timeSliceBuckets[timeSlices[i+1]], myStationPrice)
+						break
+					}
+				}
+			}
+			for _, myTimeSlice := range timeSlices {
+				if myBuckets, ok := postCodeTimeSliceBuckets[myPostCode]; ok {
+					myBuckets[myTimeSlice] = append(myBuckets[myTimeSlice], timeSliceBuckets[myTimeSlice]...)
+				} else {
+					postCodeTimeSliceBuckets[myPostCode] = timeSliceBuckets
+				}
+			}
+		}
+	}
+	return postCodeTimeSliceBuckets
+}
+
+func createTimeSliceBuckets(myCountyData pcmodel.CountyData, postCodePostCodeLocationMap map[int]pcmodel.PostCodeLocation, postCodeTimeSliceBuckets map[string]map[time.Time][]gsmodel.GasPrice) map[time.Time][]gsmodel.GasPrice {
+	timeSliceBuckets := make(map[time.Time][]gsmodel.GasPrice)
+	var myPostCodeLocations []pcmodel.PostCodeLocation
+	for _, myPostCodeLocation := range postCodePostCodeLocationMap {
+		if myPostCodeLocation.CountyDataID == myCountyData.ID && myCountyData.County != "Kreisfrei" {
+			myPostCodeLocations = append(myPostCodeLocations, myPostCodeLocation)
+		}
+	}
+	log.Printf("myPostCodeLocations: %v postcode: %v\n", len(myPostCodeLocations), myCountyData.County)
+	for _, myPcLocation := range myPostCodeLocations {
+		//log.Printf("postCodeLocations: %v\n", len(myPcLocation.CountyData.PostCodeLocations))
+		myPostCode := postcode.FormatPostCode(myPcLocation.PostCode)
+		if myBuckets, ok := postCodeTimeSliceBuckets[myPostCode]; ok {
+			for mySlice, myNewPrices := range myBuckets {
+				if myOldPrices, ok := timeSliceBuckets[mySlice]; ok {
+					timeSliceBuckets[mySlice] = append(myOldPrices, myNewPrices...)
+				} else {
+					timeSliceBuckets[mySlice] = myNewPrices
+				}
+			}
+		}
+	}
+	return timeSliceBuckets
+}
+
+func createTimeSlicesPriceSums(myCountyData pcmodel.CountyData, timeSliceBuckets map[time.Time][]gsmodel.GasPrice) map[time.Time]pcmodel.CountyTimeSlot {
+	timeSlicesPriceSums := make(map[time.Time]pcmodel.CountyTimeSlot)
+	for mySlice, myPrices := range timeSliceBuckets {
+		var e5Sum, e10Sum, dieselSum, e5Num, e10Num, dieselNum, gsNum int64 = 0, 0, 0, 0, 0, 0, 0
+		for _, myPrice := range myPrices {
+			if myPrice.E5 > 10 {
+				e5Num += 1
+				e5Sum += int64(myPrice.E5)
+			}
+			if myPrice.E10 > 10 {
+				e10Sum += int64(myPrice.E10)
+				e10Num += 1
+			}
+			if myPrice.Diesel > 10 {
+				dieselSum += int64(myPrice.Diesel)
+				dieselNum += 1
+			}
+			if myPrice.E5 > 10 || myPrice.E10 > 10 || myPrice.Diesel > 10 {
+				gsNum += 1
+			}
+		}
+		if gsNum > 0 {
+			myCountyTimeSlot := pcmodel.CountyTimeSlot{
+				StartDate:    mySlice,
+				CountyDataID: myCountyData.ID,
+				CountyData:   myCountyData,
+				GsAvgValues: pcmodel.GsAvgValues{
+					GasStationNum: int(gsNum),
+					GsNumDiesel:   int(dieselNum),
+					GsNumE10:      int(e10Num),
+					GsNumE5:       int(e5Num),
+					AvgE5:         divZeroCheck(e5Sum, e5Num),
+					AvgE10:        divZeroCheck(e10Sum, e10Num),
+					AvgDiesel:     divZeroCheck(dieselSum, dieselNum),
+				},
+			}
+			timeSlicesPriceSums[mySlice] = myCountyTimeSlot
+		}
+	}
+	return timeSlicesPriceSums
+}
+
+func divZeroCheck(number int64, divisor int64) float64 {
+	if divisor == 0 {
+		return 0
+	}
+	return float64(number / divisor)
+}
+
+func (service *GasStationService) CalcCountyTimeSlots() {
+	log.Printf("calcCountyTimeSlots started.")
+	myStart := time.Now()
+	postCodePostCodeLocationMap, _, idCountyDataMap, postCodeGasStationsMap := service.createPostCodeGasStationMaps()
+
+	postCodeTimeSliceBuckets := service.createCodeTimeSliceBuckets(postCodePostCodeLocationMap, postCodeGasStationsMap)
+	//log.Printf("postCodeTimeSliceBuckets: %v\n", len(postCodeGasStationsMap))
+	service.postCodeRepo.DeleteAllCountyTimeSlots()
+	//log.Printf("idCountyDataMap: %v\n", len(idCountyDataMap))
+	//calc average changes in 10 min slots
+	for _, myCountyData := range idCountyDataMap {
+		//10 min buckets
+		timeSliceBuckets := createTimeSliceBuckets(myCountyData, postCodePostCodeLocationMap, postCodeTimeSliceBuckets)
+		//log.Printf("createTimeSliceBuckets: %v\n", len(timeSliceBuckets))
+		timeSlicesPriceSums := createTimeSlicesPriceSums(myCountyData, timeSliceBuckets)
+		service.postCodeRepo.Transaction(func(repo postcode.PostCodeRepo) error {
+			for _, myCountyTimeSlots := range timeSlicesPriceSums {
+				repo.SaveCountyTimeSlot(&myCountyTimeSlots)
+			}
+			return nil
+		})
+	}
+	//store changes in countytimeslots
+	myDuration := time.Since(myStart)
+	log.Printf("calcCountyTimeSlots finished for %v counties in %v.", len(idCountyDataMap), myDuration)
+}
+
+func (service *GasStationService) FindById(id string) gsmodel.GasStation {
+	myGasStation := service.gasStationRepo.FindById(id)
+	myGasStation.OpeningTimes = gsmodel.ParseOpeningTimes(myGasStation.OtJson, myGasStation.PublicHolidayIdentifier)
+	return myGasStation
+}
+
+func (service *GasStationService) FindPricesByStids(stids *[]string, resultLimit int, timeframe TimeFrame, onlyLastUpdate bool) []gsmodel.GasPrice {
+	myGasPrice := service.findPricesByStids(stids, resultLimit, timeframe, onlyLastUpdate)
+	return myGasPrice
+}
+
+func (service *GasStationService) FindByPostCodes(postcodes []string) []gsmodel.GasStation {
+	return service.gasStationRepo.FindByPostCodes(postcodes)
+}
+
+func (service *GasStationService) FindPricesByStid(stid string) []gsmodel.GasPrice {
+	return service.gasStationRepo.FindPricesByStid(stid)
+}
+
+func (service *GasStationService) FindBySearchPlace(searchPlace gsbody.SearchPlaceBody) []gsmodel.GasStation {
+	gasStations := service.gasStationRepo.FindBySearchPlace(searchPlace)
+	return filterOpenGasStations(gasStations, searchPlace.OpenNow, searchPlace.OpenAt)
+}
+
+func (service *GasStationService) FindBySearchLocation(searchLocation gsbody.SearchLocation) []gsmodel.GasStation {
+	myRadius := searchLocation.Radius + 0.1
+	if myRadius > 20.0 {
+		myRadius = 20.1
+	}
+	minMax := calcMinMaxSquare(searchLocation.Longitude, searchLocation.Latitude, myRadius)
+	//fmt.Printf("WestLat: %v, WestLng: %v\n", westLat, westLng)
+	//fmt.Printf("MinLat: %v, MinLng: %v, MaxLat: %v, MaxLng: %v\n", minMax.MinLat, minMax.MinLng, minMax.MaxLat, minMax.MaxLng)
+	gasStations := service.gasStationRepo.FindInSquare(minMax, 100)
+	//filter for stations in circle
+	filteredGasStations := []gsmodel.GasStation{}
+	for _, myGasStation := range gasStations {
+		distance, bearing := myGasStation.CalcDistanceBearing(searchLocation.Latitude, searchLocation.Longitude)
+		//fmt.Printf("Distance: %v, Bearing: %v\n", distance, bearing)
+		if distance < myRadius && bearing > -1.0 {
+			filteredGasStations = append(filteredGasStations, myGasStation)
+		}
+	}
+	return filterOpenGasStations(filteredGasStations, searchLocation.OpenNow, searchLocation.OpenAt)
+}
@@ -0,0 +1,352 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import (
+	"errors"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"react-and-go/pkd/postcode"
+	"react-and-go/pkd/postcode/pcmodel"
+	"testing"
+	"time"
+)
+
+// records the stations of the notifications
+type testPriceNotifier struct {
+	notified chan []string
+}
+
+func (notifier *testPriceNotifier) SendNotifications(gasStationIDToGasPriceMapPtr *map[string]gsmodel.GasPrice, gasStations []gsmodel.GasStation) {
+	myStids := []string{}
+	for _, myGasStation := range gasStations {
+		myStids = append(myStids, myGasStation.ID)
+	}
+	notifier.notified <- myStids
+}
+
+func newTestGasStationService() (*GasStationService, GasStationRepo) {
+	myRepo := NewGasStationMemRepo()
+	return NewGasStationService(myRepo, postcode.NewPostCodeMemRepo(), &testPriceNotifier{notified: make(chan []string, 100)}), myRepo
+}
+
+func TestFindLastImportLifecycleChanges(t *testing.T) {
+	myService, _ := newTestGasStationService()
+	stationA := GasStationImport{Uuid: "stidA", StationName: "A", PostCode: "10115"}
+	stationB := GasStationImport{Uuid: "stidB", StationName: "B", PostCode: "10115"}
+	tests := []struct {
+		name        string
+		imports     []GasStationImport
+		wantChanges map[string]string
+	}{
+		{"first import", []GasStationImport{stationA, stationB}, map[string]string{}},
+		{"station removed", []GasStationImport{stationA}, map[string]string{"stidB": gsmodel.StatusInactive}},
+		{"import without changes", []GasStationImport{stationA}, map[string]string{}},
+		{"station reappeared", []GasStationImport{stationA, stationB}, map[string]string{"stidB": gsmodel.StatusReappeared}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			myImports := append([]GasStationImport{}, tt.imports...)
+			myService.UpdateGasStations(&myImports, map[string]bool{})
+			myChanges := myService.FindLastImportLifecycleChanges()
+			if len(myChanges) != len(tt.wantChanges) {
+				t.Fatalf("FindLastImportLifecycleChanges() = %v want: %v", myChanges, tt.wantChanges)
+			}
+			for _, myChange := range myChanges {
+				if tt.wantChanges[myChange.GasStationID] != myChange.NewStatus {
+					t.Errorf("FindLastImportLifecycleChanges() %v status: %v want: %v", myChange.GasStationID, myChange.NewStatus, tt.wantChanges[myChange.GasStationID])
+				}
+			}
+		})
+	}
+}
+
+func TestUpdatePrice(t *testing.T) {
+	myService, myRepo := newTestGasStationService()
+	myRepo.SaveGasStations([]gsmodel.GasStation{{ID: "stid1", StationName: "A", PostCode: "10115"}})
+	myTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	prices := func(minutes int, e5 int) GasStationPrices {
+		return GasStationPrices{GasStationID: "stid1", E5: e5, E10: 1700, Diesel: 1600, Timestamp: myTime.Add(time.Duration(minutes) * time.Minute)}
+	}
+	tests := []struct {
+		name        string
+		prices      []GasStationPrices
+		want        PriceUpdateResult
+		wantChanged []int
+	}{
+		{"first price", []GasStationPrices{prices(0, 1800)}, PriceUpdateResult{Received: 1, Updated: 1}, []int{21}},
+		{"unknown station", []GasStationPrices{{GasStationID: "unknown", E5: 1800, Timestamp: myTime}}, PriceUpdateResult{Received: 1, Unchanged: 1}, []int{21}},
+		{"duplicate", []GasStationPrices{prices(0, 1800)}, PriceUpdateResult{Received: 1, Duplicate: 1}, []int{21}},
+		{"stale", []GasStationPrices{prices(-1, 1790)}, PriceUpdateResult{Received: 1, Stale: 1}, []int{21}},
+		{"unchanged", []GasStationPrices{prices(1, 1800)}, PriceUpdateResult{Received: 1, Unchanged: 1}, []int{21}},
+		{"e5 changed", []GasStationPrices{prices(2, 1790)}, PriceUpdateResult{Received: 1, Updated: 1}, []int{4, 21}},
+		{"latest of a batch", []GasStationPrices{prices(4, 1770), prices(3, 1780)}, PriceUpdateResult{Received: 2, Updated: 1, Superseded: 1}, []int{4, 4, 21}},
+		{"duplicate in a batch", []GasStationPrices{prices(5, 1760), prices(5, 1760)}, PriceUpdateResult{Received: 2, Updated: 1, Duplicate: 1}, []int{4, 4, 4, 21}},
+		{"superseded and duplicate", []GasStationPrices{prices(6, 1750), prices(5, 1760), prices(6, 1750)}, PriceUpdateResult{Received: 3, Updated: 1, Duplicate: 1, Superseded: 1}, []int{4, 4, 4, 4, 21}},
+		{"too old", []GasStationPrices{prices(-60*24*31, 1700)}, PriceUpdateResult{Received: 1, Stale: 1}, []int{4, 4, 4, 4, 21}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			myPrices := append([]GasStationPrices{}, tt.prices...)
+			if got := myService.UpdatePrice(&myPrices); got.Received != tt.want.Received || got.Updated != tt.want.Updated ||
+				got.Unchanged != tt.want.Unchanged || got.Stale != tt.want.Stale || got.Duplicate != tt.want.Duplicate || got.Superseded != tt.want.Superseded || got.Rejected != tt.want.Rejected {
+				t.Errorf("UpdatePrice() = %+v want: %+v", got, tt.want)
+			}
+			myChanged := []int{}
+			for _, myGasPrice := range myRepo.FindPricesByStid("stid1") {
+				myChanged = append(myChanged, myGasPrice.Changed)
+			}
+			if len(myChanged) != len(tt.wantChanged) {
+				t.Fatalf("UpdatePrice() stored changes: %v want: %v", myChanged, tt.wantChanged)
+			}
+			for index := range myChanged {
+				if myChanged[index] != tt.wantChanged[index] {
+					t.Errorf("UpdatePrice() stored changes: %v want: %v", myChanged, tt.wantChanged)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdatePriceNotifications(t *testing.T) {
+	myService, myRepo := newTestGasStationService()
+	myRepo.SaveGasStations([]gsmodel.GasStation{{ID: "stid1", StationName: "A", PostCode: "10115"}})
+	myPrices := []GasStationPrices{{GasStationID: "stid1", E5: 1800, E10: 1700, Diesel: 1600, Timestamp: time.Now()}}
+	myService.UpdatePrice(&myPrices)
+	select {
+	case myStids := <-myService.priceNotifier.(*testPriceNotifier).notified:
+		if len(myStids) != 1 || myStids[0] != "stid1" {
+			t.Errorf("SendNotifications() stations: %v want: [stid1]", myStids)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("SendNotifications() was not called")
+	}
+}
+
+func TestGasStationMemRepoTransaction(t *testing.T) {
+	errTest := errors.New("test")
+	tests := []struct {
+		name       string
+		txErr      error
+		wantPrices int
+	}{
+		{"commit", nil, 2},
+		{"rollback", errTest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			myRepo := NewGasStationMemRepo()
+			err := myRepo.Transaction(func(repo GasStationRepo) error {
+				repo.SaveGasStations([]gsmodel.GasStation{{ID: "stid1"}})
+				if err := repo.CreatePrices([]gsmodel.GasPrice{{GasStationID: "stid1", E5: 1800, Date: time.Now()}}); err != nil {
+					return err
+				}
+				repo.SavePrices([]gsmodel.GasPrice{{GasStationID: "stid1", E5: 1790, Date: time.Now()}})
+				return tt.txErr
+			})
+			if !errors.Is(err, tt.txErr) {
+				t.Fatalf("Transaction() error: %v want: %v", err, tt.txErr)
+			}
+			if got := len(myRepo.FindPricesByStid("stid1")); got != tt.wantPrices {
+				t.Errorf("Transaction() prices: %v want: %v", got, tt.wantPrices)
+			}
+			if got := len(myRepo.FindByIds([]string{"stid1"}, false)); got != tt.wantPrices/2 {
+				t.Errorf("Transaction() stations: %v want: %v", got, tt.wantPrices/2)
+			}
+		})
+	}
+}
+
+func TestReCalcCountyStatePrices(t *testing.T) {
+	myPostCodeRepo := postcode.NewPostCodeMemRepo()
+	myPostCodeRepo.SavePostCodeLocation(&pcmodel.PostCodeLocation{PostCode: 10115, StateData: pcmodel.StateData{State: "Berlin"},
+		CountyData: pcmodel.CountyData{County: "Berlin"}})
+	myPostCodeRepo.SavePostCodeLocation(&pcmodel.PostCodeLocation{PostCode: 20095, StateData: pcmodel.StateData{State: "Hamburg"},
+		CountyData: pcmodel.CountyData{County: "Hamburg"}})
+	myRepo := NewGasStationMemRepo()
+	myService := NewGasStationService(myRepo, myPostCodeRepo, &testPriceNotifier{notified: make(chan []string, 100)})
+	myRepo.SaveGasStations([]gsmodel.GasStation{{ID: "stid1", PostCode: "10115"}, {ID: "stid2", PostCode: "10115"}, {ID: "stid3", PostCode: "20095"}})
+	//the averages use the prices before today
+	myYesterday := time.Now().AddDate(0, 0, -1)
+	myRepo.SavePrices([]gsmodel.GasPrice{{GasStationID: "stid1", E5: 1800, E10: 1700, Diesel: 1600, Date: myYesterday},
+		{GasStationID: "stid2", E5: 1900, E10: 0, Diesel: 1700, Date: myYesterday},
+		{GasStationID: "stid3", E5: 2000, E10: 1900, Diesel: 1800, Date: myYesterday},
+		{GasStationID: "stid3", E5: 1000, E10: 1000, Diesel: 1000, Date: time.Now()}})
+	myService.ReCalcCountyStatePrices()
+	tests := []struct {
+		state        string
+		wantNum      int
+		wantAvgE5    float64
+		wantAvgE10   float64
+		wantAvgDsl   float64
+		wantGsNumE10 int
+	}{
+		{"Berlin", 2, 1850, 1700, 1650, 1},
+		{"Hamburg", 1, 2000, 1900, 1800, 1},
+	}
+	_, myStateDatas, _ := myService.createPostCodeMaps()
+	for _, tt := range tests {
+		t.Run(tt.state, func(t *testing.T) {
+			for _, myStateData := range myStateDatas {
+				if myStateData.State != tt.state {
+					continue
+				}
+				if myStateData.GasStationNum != tt.wantNum || myStateData.AvgE5 != tt.wantAvgE5 || myStateData.AvgE10 != tt.wantAvgE10 ||
+					myStateData.AvgDiesel != tt.wantAvgDsl || myStateData.GsNumE10 != tt.wantGsNumE10 {
+					t.Errorf("ReCalcCountyStatePrices() %v: %+v", tt.state, myStateData.GsAvgValues)
+				}
+				return
+			}
+			t.Errorf("ReCalcCountyStatePrices() state missing: %v", tt.state)
+		})
+	}
+}
+
+func TestApplyPriceDeltas(t *testing.T) {
+	myLastGasPrice := gsmodel.GasPrice{GasStationID: "stid1", E5: 1800, E10: 1700, Diesel: 1600}
+	delta := func(value int) *int {
+		return &value
+	}
+	tests := []struct {
+		name      string
+		found     bool
+		lastPrice gsmodel.GasPrice
+		prices    GasStationPrices
+		want      GasStationPrices
+		wantOk    bool
+	}{
+		{"absolute prices", false, gsmodel.GasPrice{}, GasStationPrices{E5: 1810, E10: 1710, Diesel: 1610}, GasStationPrices{E5: 1810, E10: 1710, Diesel: 1610}, true},
+		{"deltas", true, myLastGasPrice, GasStationPrices{E5Delta: delta(-10), E10Delta: delta(20), DieselDelta: delta(0)}, GasStationPrices{E5: 1790, E10: 1720, Diesel: 1600}, true},
+		{"mixed", true, myLastGasPrice, GasStationPrices{E5: 1850, E10Delta: delta(5), DieselKeepLast: true}, GasStationPrices{E5: 1850, E10: 1705, Diesel: 1600}, true},
+		{"keep last prices", true, myLastGasPrice, GasStationPrices{E5Delta: delta(1), E10KeepLast: true, DieselKeepLast: true}, GasStationPrices{E5: 1801, E10: 1700, Diesel: 1600}, true},
+		{"no last price", false, gsmodel.GasPrice{}, GasStationPrices{E5Delta: delta(-10)}, GasStationPrices{E5Delta: delta(-10)}, false},
+		{"no last price of the fuel", true, gsmodel.GasPrice{E5: 1800}, GasStationPrices{E5Delta: delta(-10), DieselDelta: delta(5)}, GasStationPrices{E5Delta: delta(-10), DieselDelta: delta(5)}, false},
+		{"station without one fuel", true, gsmodel.GasPrice{E5: 1800, Diesel: 1600}, GasStationPrices{DieselDelta: delta(-10), E5KeepLast: true, E10KeepLast: true},
+			GasStationPrices{E5: 1800, E10: 0, Diesel: 1590}, true},
+		{"kept prices without last price", false, gsmodel.GasPrice{}, GasStationPrices{DieselDelta: delta(-10), E5KeepLast: true}, GasStationPrices{DieselDelta: delta(-10), E5KeepLast: true}, false},
+		{"price below 1", true, myLastGasPrice, GasStationPrices{E5Delta: delta(-1800)}, GasStationPrices{E5Delta: delta(-1800)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := applyPriceDeltas(tt.lastPrice, tt.found, tt.prices)
+			if ok != tt.wantOk || got.E5 != tt.want.E5 || got.E10 != tt.want.E10 || got.Diesel != tt.want.Diesel {
+				t.Errorf("applyPriceDeltas() = %+v, %v want: %+v, %v", got, ok, tt.want, tt.wantOk)
+			}
+			if ok && got.needsLastPrice() {
+				t.Errorf("applyPriceDeltas() = %+v the deltas are not cleared", got)
+			}
+		})
+	}
+}
+
+func TestUpdateMasterData(t *testing.T) {
+	myImportTime := time.Now().Truncate(time.Second)
+	myVersionTime := myImportTime.Add(-24 * time.Hour)
+	myImport := GasStationImport{Uuid: "stid1", StationName: "A", Brand: "Aral", Street: "Hauptstr.", HouseNumber: "1", PostCode: "10115", City: "Berlin",
+		Latitude: 52.53, Longitude: 13.38, OpeningTimesJson: "{}"}
+	myGasStation := func() gsmodel.GasStation {
+		return gsmodel.GasStation{ID: "stid1", StationName: "A", Brand: "Aral", Street: "Hauptstr.", HouseNumber: "1", PostCode: "10115", Place: "Berlin",
+			Latitude: 52.53, Longitude: 13.38, OtJson: "{}", Version: "3", VersionTime: myVersionTime}
+	}
+	tests := []struct {
+		name        string
+		gasStation  func() gsmodel.GasStation
+		importValue func() GasStationImport
+		wantChanges []gsmodel.GasStationChange
+		wantVersion string
+	}{
+		{"unchanged", myGasStation, func() GasStationImport { return myImport }, nil, "3"},
+		{"whitespace only", myGasStation, func() GasStationImport {
+			myValue := myImport
+			myValue.StationName = " A "
+			return myValue
+		}, nil, "3"},
+		{"coordinate below 10cm", myGasStation, func() GasStationImport {
+			myValue := myImport
+			myValue.Latitude += 0.0000005
+			return myValue
+		}, nil, "3"},
+		{"brand changed", myGasStation, func() GasStationImport {
+			myValue := myImport
+			myValue.Brand = "Shell"
+			return myValue
+		}, []gsmodel.GasStationChange{{Field: "Brand", OldValue: "Aral", NewValue: "Shell"}}, "4"},
+		{"place and longitude changed", myGasStation, func() GasStationImport {
+			myValue := myImport
+			myValue.City = "Hamburg"
+			myValue.Longitude = 10.0
+			return myValue
+		}, []gsmodel.GasStationChange{{Field: "Place", OldValue: "Berlin", NewValue: "Hamburg"}, {Field: "Longitude", OldValue: "13.38", NewValue: "10"}}, "4"},
+		{"invalid version", func() gsmodel.GasStation {
+			myValue := myGasStation()
+			myValue.Version = ""
+			return myValue
+		}, func() GasStationImport {
+			myValue := myImport
+			myValue.OpeningTimesJson = `{"openingTimes":[]}`
+			return myValue
+		}, []gsmodel.GasStationChange{{Field: "OtJson", OldValue: "{}", NewValue: `{"openingTimes":[]}`}}, "2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			myValue := tt.gasStation()
+			myOldVersionTime := myValue.VersionTime
+			myChanges := updateMasterData(&myValue, tt.importValue(), myImportTime)
+			if len(myChanges) != len(tt.wantChanges) {
+				t.Fatalf("updateMasterData() = %+v want: %+v", myChanges, tt.wantChanges)
+			}
+			for index, myChange := range myChanges {
+				if myChange.Field != tt.wantChanges[index].Field || myChange.OldValue != tt.wantChanges[index].OldValue ||
+					myChange.NewValue != tt.wantChanges[index].NewValue || myChange.GasStationID != "stid1" || myChange.Version != tt.wantVersion ||
+					!myChange.VersionTime.Equal(myImportTime) {
+					t.Errorf("updateMasterData() change = %+v want: %+v version: %v", myChange, tt.wantChanges[index], tt.wantVersion)
+				}
+			}
+			wantVersionTime := myOldVersionTime
+			if len(tt.wantChanges) > 0 {
+				wantVersionTime = myImportTime
+			}
+			if myValue.Version != tt.wantVersion || !myValue.VersionTime.Equal(wantVersionTime) {
+				t.Errorf("updateMasterData() version = %v, %v want: %v, %v", myValue.Version, myValue.VersionTime, tt.wantVersion, wantVersionTime)
+			}
+		})
+	}
+}
+
+// an import stores the GasStationChange rows and bumps the version only for changed master data
+func TestUpdateGasStationsMasterData(t *testing.T) {
+	myService, myRepo := newTestGasStationService()
+	myImport := GasStationImport{Uuid: "stid1", StationName: "A", Brand: "Aral", PostCode: "10115", City: "Berlin", Latitude: 52.53, Longitude: 13.38}
+	myService.UpdateGasStations(&[]GasStationImport{myImport}, nil)
+	myVersion := myRepo.FindById("stid1").Version
+	myResult := myService.UpdateGasStations(&[]GasStationImport{myImport}, nil)
+	if myResult.MasterDataChanges != 0 || myRepo.FindById("stid1").Version != myVersion || len(myService.FindChangesByStid("stid1")) != 0 {
+		t.Errorf("UpdateGasStations() unchanged import = %+v version: %v want: %v", myResult, myRepo.FindById("stid1").Version, myVersion)
+	}
+	myImport.StationName = "B"
+	myImport.Street = "Hauptstr."
+	myResult = myService.UpdateGasStations(&[]GasStationImport{myImport}, nil)
+	myGasStation := myRepo.FindById("stid1")
+	myChanges := myService.FindChangesByStid("stid1")
+	if myResult.MasterDataChanges != 2 || len(myChanges) != 2 || myGasStation.StationName != "B" || myGasStation.Version == myVersion {
+		t.Fatalf("UpdateGasStations() = %+v changes: %+v station: %+v", myResult, myChanges, myGasStation)
+	}
+	for _, myChange := range myChanges {
+		if myChange.Version != myGasStation.Version || !myChange.VersionTime.Equal(myGasStation.VersionTime) {
+			t.Errorf("UpdateGasStations() change = %+v want version: %v, %v", myChange, myGasStation.Version, myGasStation.VersionTime)
+		}
+	}
+	myService.UpdateGasStations(&[]GasStationImport{myImport}, nil)
+	if myRepo.FindById("stid1").Version != myGasStation.Version || len(myService.FindChangesByStid("stid1")) != 2 {
+		t.Errorf("UpdateGasStations() unchanged import bumped the version: %v want: %v", myRepo.FindById("stid1").Version, myGasStation.Version)
+	}
+}
@@ -0,0 +1,54 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package gasstation
+
+import "react-and-go/pkd/gasstation/gsmodel"
+
+type StationImportReport struct {
+	StationImportRun gsmodel.StationImportRun
+	//the number of quarantined rows per field
+	FieldErrors  map[string]int
+	ImportErrors []gsmodel.StationImportError
+}
+
+func (service *GasStationService) SaveStationImportRun(stationImportRun *gsmodel.StationImportRun) {
+	service.gasStationRepo.SaveStationImportRun(stationImportRun)
+}
+
+func (service *GasStationService) SaveStationImportErrors(stationImportRunId int64, stationImportErrors []gsmodel.StationImportError) {
+	for index := range stationImportErrors {
+		stationImportErrors[index].StationImportRunID = stationImportRunId
+	}
+	service.gasStationRepo.SaveStationImportErrors(stationImportErrors)
+}
+
+func (service *GasStationService) FindStationImportRuns(limit int) []gsmodel.StationImportRun {
+	return service.gasStationRepo.FindStationImportRuns(limit)
+}
+
+func (service *GasStationService) FindLastImportedStationRun() (gsmodel.StationImportRun, bool) {
+	return service.gasStationRepo.FindLastImportedStationRun()
+}
+
+func (service *GasStationService) FindStationImportReport(stationImportRunId int64) (StationImportReport, bool) {
+	myStationImportRun, found := service.gasStationRepo.FindStationImportRunById(stationImportRunId)
+	if !found {
+		return StationImportReport{}, false
+	}
+	result := StationImportReport{StationImportRun: myStationImportRun, FieldErrors: make(map[string]int),
+		ImportErrors: service.gasStationRepo.FindStationImportErrors(stationImportRunId)}
+	for _, myStationImportError := range result.ImportErrors {
+		result.FieldErrors[myStationImportError.Field] += 1
+	}
+	return result, true
+}
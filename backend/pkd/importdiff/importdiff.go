@@ -0,0 +1,170 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package importdiff
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ActionInsert = "insert"
+	ActionUpdate = "update"
+	ActionRemove = "remove"
+)
+
+const (
+	KindStations       = "stations"
+	KindPostCodes      = "postcodes"
+	KindStatesCounties = "statescounties"
+)
+
+// the pending diffs are kept in memory and are lost on restart
+const diffTtl = 2 * time.Hour
+
+var ErrDiffNotFound = errors.New("import diff not found")
+var ErrDiffOutdated = errors.New("the data changed since the dry run, a new dry run is needed")
+
+type FieldChange struct {
+	Field    string
+	OldValue string
+	NewValue string
+}
+
+type DiffEntry struct {
+	Key     string
+	Action  string
+	Changes []FieldChange
+}
+
+type ImportDiff struct {
+	ID      string
+	Kind    string
+	Source  string
+	Created time.Time
+	Inserts int
+	Updates int
+	Removes int
+	Entries []DiffEntry `json:",omitempty"`
+}
+
+func NewImportDiff(kind string, source string, entries []DiffEntry) ImportDiff {
+	result := ImportDiff{Kind: kind, Source: source, Created: time.Now(), Entries: entries}
+	for _, myDiffEntry := range entries {
+		switch myDiffEntry.Action {
+		case ActionInsert:
+			result.Inserts += 1
+		case ActionUpdate:
+			result.Updates += 1
+		case ActionRemove:
+			result.Removes += 1
+		}
+	}
+	return result
+}
+
+// a recomputed diff with other entries or other field values means that the data changed since the dry run
+func (importDiff ImportDiff) Matches(otherDiff ImportDiff) bool {
+	if importDiff.Inserts != otherDiff.Inserts || importDiff.Updates != otherDiff.Updates || importDiff.Removes != otherDiff.Removes ||
+		len(importDiff.Entries) != len(otherDiff.Entries) {
+		return false
+	}
+	for index, myDiffEntry := range importDiff.Entries {
+		if myDiffEntry.Key != otherDiff.Entries[index].Key || myDiffEntry.Action != otherDiff.Entries[index].Action ||
+			len(myDiffEntry.Changes) != len(otherDiff.Entries[index].Changes) {
+			return false
+		}
+		for changeIndex, myFieldChange := range myDiffEntry.Changes {
+			if myFieldChange != otherDiff.Entries[index].Changes[changeIndex] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (importDiff ImportDiff) Summary() ImportDiff {
+	importDiff.Entries = nil
+	return importDiff
+}
+
+type pendingDiff struct {
+	importDiff ImportDiff
+	applyFunc  func(importDiff ImportDiff) error
+}
+
+type DiffStore struct {
+	mutex        sync.Mutex
+	pendingDiffs map[string]pendingDiff
+}
+
+func NewDiffStore() *DiffStore {
+	return &DiffStore{pendingDiffs: make(map[string]pendingDiff)}
+}
+
+// the applyFunc gets the stored diff to check it against the current data
+func (store *DiffStore) Add(importDiff ImportDiff, applyFunc func(importDiff ImportDiff) error) ImportDiff {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.removeExpired()
+	importDiff.ID = uuid.NewString()
+	store.pendingDiffs[importDiff.ID] = pendingDiff{importDiff: importDiff, applyFunc: applyFunc}
+	return importDiff
+}
+
+func (store *DiffStore) Find(id string) (ImportDiff, bool) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.removeExpired()
+	myPendingDiff, ok := store.pendingDiffs[id]
+	return myPendingDiff.importDiff, ok
+}
+
+func (store *DiffStore) FindAll() []ImportDiff {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.removeExpired()
+	result := []ImportDiff{}
+	for _, myPendingDiff := range store.pendingDiffs {
+		result = append(result, myPendingDiff.importDiff.Summary())
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Created.After(result[j].Created)
+	})
+	return result
+}
+
+// a diff can be applied once, an outdated diff is removed
+func (store *DiffStore) Apply(id string) (ImportDiff, error) {
+	store.mutex.Lock()
+	store.removeExpired()
+	myPendingDiff, ok := store.pendingDiffs[id]
+	delete(store.pendingDiffs, id)
+	store.mutex.Unlock()
+	if !ok {
+		return ImportDiff{}, ErrDiffNotFound
+	}
+	return myPendingDiff.importDiff.Summary(), myPendingDiff.applyFunc(myPendingDiff.importDiff)
+}
+
+func (store *DiffStore) removeExpired() {
+	for key, myPendingDiff := range store.pendingDiffs {
+		if time.Since(myPendingDiff.importDiff.Created) > diffTtl {
+			delete(store.pendingDiffs, key)
+		}
+	}
+}
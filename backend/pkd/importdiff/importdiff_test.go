@@ -0,0 +1,124 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package importdiff
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func createTestDiff(population string) ImportDiff {
+	return NewImportDiff(KindPostCodes, "test.csv", []DiffEntry{
+		{Key: "10115", Action: ActionUpdate, Changes: []FieldChange{{Field: "Population", OldValue: "100", NewValue: population}}},
+		{Key: "20095", Action: ActionInsert, Changes: []FieldChange{{Field: "Label", NewValue: "Hamburg"}}},
+	})
+}
+
+func TestNewImportDiff(t *testing.T) {
+	myImportDiff := NewImportDiff(KindStations, "test.csv", []DiffEntry{{Key: "a", Action: ActionInsert}, {Key: "b", Action: ActionUpdate},
+		{Key: "c", Action: ActionUpdate}, {Key: "d", Action: ActionRemove}})
+	if myImportDiff.Inserts != 1 || myImportDiff.Updates != 2 || myImportDiff.Removes != 1 {
+		t.Errorf("NewImportDiff() = %+v want: 1 insert, 2 updates, 1 remove", myImportDiff)
+	}
+	if mySummary := myImportDiff.Summary(); mySummary.Entries != nil || len(myImportDiff.Entries) != 4 {
+		t.Errorf("Summary() entries = %v original entries: %v", mySummary.Entries, len(myImportDiff.Entries))
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name      string
+		otherDiff ImportDiff
+		want      bool
+	}{
+		{"same diff", createTestDiff("200"), true},
+		{"other new value", createTestDiff("300"), false},
+		{"other old value", NewImportDiff(KindPostCodes, "test.csv", []DiffEntry{
+			{Key: "10115", Action: ActionUpdate, Changes: []FieldChange{{Field: "Population", OldValue: "150", NewValue: "200"}}},
+			{Key: "20095", Action: ActionInsert, Changes: []FieldChange{{Field: "Label", NewValue: "Hamburg"}}}}), false},
+		{"other field", NewImportDiff(KindPostCodes, "test.csv", []DiffEntry{
+			{Key: "10115", Action: ActionUpdate, Changes: []FieldChange{{Field: "Label", OldValue: "100", NewValue: "200"}}},
+			{Key: "20095", Action: ActionInsert, Changes: []FieldChange{{Field: "Label", NewValue: "Hamburg"}}}}), false},
+		{"other key", NewImportDiff(KindPostCodes, "test.csv", []DiffEntry{
+			{Key: "10117", Action: ActionUpdate, Changes: []FieldChange{{Field: "Population", OldValue: "100", NewValue: "200"}}},
+			{Key: "20095", Action: ActionInsert, Changes: []FieldChange{{Field: "Label", NewValue: "Hamburg"}}}}), false},
+		{"missing entry", NewImportDiff(KindPostCodes, "test.csv", []DiffEntry{
+			{Key: "10115", Action: ActionUpdate, Changes: []FieldChange{{Field: "Population", OldValue: "100", NewValue: "200"}}}}), false},
+		{"other action", NewImportDiff(KindPostCodes, "test.csv", []DiffEntry{
+			{Key: "10115", Action: ActionInsert, Changes: []FieldChange{{Field: "Population", OldValue: "100", NewValue: "200"}}},
+			{Key: "20095", Action: ActionInsert, Changes: []FieldChange{{Field: "Label", NewValue: "Hamburg"}}}}), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := createTestDiff("200").Matches(tt.otherDiff); got != tt.want {
+				t.Errorf("Matches() = %v want: %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffStoreApply(t *testing.T) {
+	myStore := NewDiffStore()
+	myApplied := []string{}
+	myCurrentDiff := createTestDiff("200")
+	myApplyFunc := func(importDiff ImportDiff) error {
+		if !importDiff.Matches(myCurrentDiff) {
+			return ErrDiffOutdated
+		}
+		myApplied = append(myApplied, importDiff.ID)
+		return nil
+	}
+	myImportDiff := myStore.Add(createTestDiff("200"), myApplyFunc)
+	myOutdatedDiff := myStore.Add(createTestDiff("300"), myApplyFunc)
+	if myFoundDiff, found := myStore.Find(myImportDiff.ID); !found || len(myFoundDiff.Entries) != 2 {
+		t.Fatalf("Find() = %+v, %v want: the diff with its entries", myFoundDiff, found)
+	}
+	if myImportDiffs := myStore.FindAll(); len(myImportDiffs) != 2 || myImportDiffs[0].Entries != nil {
+		t.Errorf("FindAll() = %+v want: 2 summaries", myImportDiffs)
+	}
+	tests := []struct {
+		name    string
+		id      string
+		wantErr error
+	}{
+		{"apply", myImportDiff.ID, nil},
+		{"apply twice", myImportDiff.ID, ErrDiffNotFound},
+		{"outdated", myOutdatedDiff.ID, ErrDiffOutdated},
+		{"outdated removed", myOutdatedDiff.ID, ErrDiffNotFound},
+		{"unknown", "unknown", ErrDiffNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := myStore.Apply(tt.id); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Apply() error = %v want: %v", err, tt.wantErr)
+			}
+		})
+	}
+	if len(myApplied) != 1 || myApplied[0] != myImportDiff.ID {
+		t.Errorf("applied diffs = %v want: %v", myApplied, myImportDiff.ID)
+	}
+}
+
+func TestDiffStoreExpired(t *testing.T) {
+	myStore := NewDiffStore()
+	myImportDiff := myStore.Add(createTestDiff("200"), func(importDiff ImportDiff) error { return nil })
+	myStore.mutex.Lock()
+	myPendingDiff := myStore.pendingDiffs[myImportDiff.ID]
+	myPendingDiff.importDiff.Created = time.Now().Add(-diffTtl - time.Minute)
+	myStore.pendingDiffs[myImportDiff.ID] = myPendingDiff
+	myStore.mutex.Unlock()
+	if _, found := myStore.Find(myImportDiff.ID); found {
+		t.Errorf("Find() found an expired diff")
+	}
+}
@@ -0,0 +1,83 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package memrepo
+
+import (
+	"maps"
+	"slices"
+	"sync"
+)
+
+// the ids of the in-memory repositories start at 1 like the database sequences and are not reused after a rollback
+type IdSequence[T int64 | uint] struct {
+	nextId T
+}
+
+func NewIdSequence[T int64 | uint]() *IdSequence[T] {
+	return &IdSequence[T]{nextId: 1}
+}
+
+// the caller holds the write lock of the repository
+func (sequence *IdSequence[T]) Next() T {
+	result := sequence.nextId
+	sequence.nextId += 1
+	return result
+}
+
+// the transactions of an in-memory repository run one at a time, a failed transaction restores the data of its start
+type Transactor struct {
+	mutex *sync.Mutex
+}
+
+func NewTransactor() *Transactor {
+	return &Transactor{mutex: &sync.Mutex{}}
+}
+
+// snapshot is called with the read lock of the data and returns the function that restores the snapshot with the write lock,
+// the writes outside of transactions are not serialized with the transactions
+func (transactor *Transactor) Run(dataMutex *sync.RWMutex, snapshot func() func(), txFunc func() error) (err error) {
+	transactor.mutex.Lock()
+	defer transactor.mutex.Unlock()
+	dataMutex.RLock()
+	restore := snapshot()
+	dataMutex.RUnlock()
+	rollback := true
+	defer func() {
+		if rollback {
+			dataMutex.Lock()
+			restore()
+			dataMutex.Unlock()
+		}
+	}()
+	err = txFunc()
+	rollback = err != nil
+	return err
+}
+
+// copies the map and the slices of its values
+func CloneMapOfSlices[K comparable, V any](myMap map[K][]V) map[K][]V {
+	result := make(map[K][]V, len(myMap))
+	for key, value := range myMap {
+		result[key] = slices.Clone(value)
+	}
+	return result
+}
+
+// copies the map and the maps of its values
+func CloneMapOfMaps[K comparable, K2 comparable, V any](myMap map[K]map[K2]V) map[K]map[K2]V {
+	result := make(map[K]map[K2]V, len(myMap))
+	for key, value := range myMap {
+		result[key] = maps.Clone(value)
+	}
+	return result
+}
@@ -0,0 +1,97 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package memrepo
+
+import (
+	"errors"
+	"maps"
+	"sync"
+	"testing"
+)
+
+func TestIdSequence(t *testing.T) {
+	mySequence := NewIdSequence[uint]()
+	for _, want := range []uint{1, 2, 3} {
+		if got := mySequence.Next(); got != want {
+			t.Errorf("Next() = %v want: %v", got, want)
+		}
+	}
+}
+
+func TestTransactorRun(t *testing.T) {
+	errTest := errors.New("test")
+	tests := []struct {
+		name      string
+		txFunc    func(data map[string]int) error
+		wantPanic bool
+		want      map[string]int
+	}{
+		{"commit", func(data map[string]int) error {
+			data["b"] = 2
+			return nil
+		}, false, map[string]int{"a": 1, "b": 2}},
+		{"rollback on error", func(data map[string]int) error {
+			data["b"] = 2
+			delete(data, "a")
+			return errTest
+		}, false, map[string]int{"a": 1}},
+		{"rollback on panic", func(data map[string]int) error {
+			data["b"] = 2
+			panic("test")
+		}, true, map[string]int{"a": 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			myMutex := &sync.RWMutex{}
+			myData := map[string]int{"a": 1}
+			myTransactor := NewTransactor()
+			func() {
+				defer func() {
+					if myPanic := recover(); (myPanic != nil) != tt.wantPanic {
+						t.Errorf("Run() panic: %v wantPanic: %v", myPanic, tt.wantPanic)
+					}
+				}()
+				myTransactor.Run(myMutex, func() func() {
+					myDataSnapshot := maps.Clone(myData)
+					return func() { myData = myDataSnapshot }
+				}, func() error {
+					return tt.txFunc(myData)
+				})
+			}()
+			if len(myData) != len(tt.want) {
+				t.Fatalf("Run() data: %v want: %v", myData, tt.want)
+			}
+			for key, value := range tt.want {
+				if myData[key] != value {
+					t.Errorf("Run() data: %v want: %v", myData, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCloneMapOfSlices(t *testing.T) {
+	myMap := map[string][]int{"a": {1, 2}}
+	myClone := CloneMapOfSlices(myMap)
+	myMap["a"][0] = 3
+	myMap["b"] = []int{4}
+	if len(myClone) != 1 || myClone["a"][0] != 1 {
+		t.Errorf("CloneMapOfSlices() = %v shares data with %v", myClone, myMap)
+	}
+	myNested := map[string]map[int]int{"a": {1: 1}}
+	myNestedClone := CloneMapOfMaps(myNested)
+	myNested["a"][1] = 2
+	if myNestedClone["a"][1] != 1 {
+		t.Errorf("CloneMapOfMaps() = %v shares data with %v", myNestedClone, myNested)
+	}
+}
@@ -0,0 +1,144 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package messaging
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+type TopicConfig struct {
+	Topic string
+	Qos   byte
+}
+
+type MsgConfig struct {
+	ServerUrl       string
+	ClientId        string
+	User            string
+	Password        string
+	Topics          []TopicConfig
+	SharedGroup     string
+	CleanSession    bool
+	StorePath       string
+	TlsCaFile       string
+	TlsCertFile     string
+	TlsKeyFile      string
+	DeadLetterTopic string
+}
+
+// the topics are read from MSG_TOPICS("topic:qos" separated by ';', wildcards are supported) or MSG_GAS_PRICE_TOPIC with qos 1
+func LoadMsgConfig() MsgConfig {
+	result := MsgConfig{ServerUrl: os.Getenv("MSG_PARAMS"), ClientId: os.Getenv("MSG_CLIENT_ID"), User: os.Getenv("MSG_SERVER_USER"), Password: os.Getenv("MSG_SERVER_PWD"),
+		SharedGroup: strings.TrimSpace(os.Getenv("MSG_SHARED_GROUP")), CleanSession: strings.ToLower(strings.TrimSpace(os.Getenv("MSG_CLEAN_SESSION"))) != "false",
+		StorePath: strings.TrimSpace(os.Getenv("MSG_STORE_PATH")), TlsCaFile: strings.TrimSpace(os.Getenv("MSG_TLS_CA_FILE")),
+		TlsCertFile: strings.TrimSpace(os.Getenv("MSG_TLS_CERT_FILE")), TlsKeyFile: strings.TrimSpace(os.Getenv("MSG_TLS_KEY_FILE")),
+		DeadLetterTopic: strings.TrimSpace(os.Getenv("MSG_DEAD_LETTER_TOPIC"))}
+	result.Topics = parseTopics(os.Getenv("MSG_TOPICS"))
+	if myTopic := strings.TrimSpace(os.Getenv("MSG_GAS_PRICE_TOPIC")); len(result.Topics) == 0 && len(myTopic) > 0 {
+		result.Topics = []TopicConfig{{Topic: myTopic, Qos: 1}}
+	}
+	return result
+}
+
+// a topic without a valid qos gets qos 1
+func parseTopics(topicsStr string) []TopicConfig {
+	var result []TopicConfig
+	for _, myTopicStr := range strings.Split(topicsStr, ";") {
+		myTopicStr = strings.TrimSpace(myTopicStr)
+		if len(myTopicStr) == 0 {
+			continue
+		}
+		myTopicConfig := TopicConfig{Topic: myTopicStr, Qos: 1}
+		if index := strings.LastIndex(myTopicStr, ":"); index > 0 {
+			if myQos, err := strconv.Atoi(strings.TrimSpace(myTopicStr[index+1:])); err == nil && myQos >= 0 && myQos <= 2 {
+				myTopicConfig = TopicConfig{Topic: strings.TrimSpace(myTopicStr[:index]), Qos: byte(myQos)}
+			}
+		}
+		result = append(result, myTopicConfig)
+	}
+	return result
+}
+
+// the shared subscriptions use the '$share/group/topic' filter, the broker distributes the messages between the clients of the group
+func (msgConfig MsgConfig) topicFilter(topicConfig TopicConfig) string {
+	if len(msgConfig.SharedGroup) == 0 || strings.HasPrefix(topicConfig.Topic, "$share/") {
+		return topicConfig.Topic
+	}
+	return fmt.Sprintf("$share/%v/%v", msgConfig.SharedGroup, topicConfig.Topic)
+}
+
+// a persistent session keeps the subscriptions and the queued messages of the client id in the broker, the client store keeps the unacknowledged messages
+// the messages are acknowledged by the handler after they are processed, the broker redelivers the unprocessed messages of a persistent session
+func (msgConfig MsgConfig) createClientOptions() (*mqtt.ClientOptions, error) {
+	options := mqtt.NewClientOptions()
+	options.AddBroker(msgConfig.ServerUrl)
+	options.SetClientID(msgConfig.ClientId)
+	options.SetUsername(msgConfig.User)
+	options.SetPassword(msgConfig.Password)
+	options.SetCleanSession(msgConfig.CleanSession)
+	options.SetAutoAckDisabled(true)
+	//the paho client speaks MQTT 3.1.1, the shared subscriptions are a broker extension and the sessions have no expiry interval
+	options.SetProtocolVersion(4)
+	if len(msgConfig.SharedGroup) > 0 {
+		if strings.ContainsAny(msgConfig.SharedGroup, "/+#") {
+			return nil, fmt.Errorf("invalid shared group: %v", msgConfig.SharedGroup)
+		}
+		log.Printf("Shared group %v uses '$share/' topic filters with MQTT 3.1.1, the broker has to support shared subscriptions for MQTT 3.1.1 clients.\n", msgConfig.SharedGroup)
+	}
+	if !msgConfig.CleanSession {
+		options.SetResumeSubs(true)
+		if len(msgConfig.StorePath) > 0 {
+			options.SetStore(mqtt.NewFileStore(msgConfig.StorePath))
+		}
+	}
+	if len(msgConfig.TlsCaFile) > 0 || len(msgConfig.TlsCertFile) > 0 {
+		myTlsConfig, err := msgConfig.createTlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		options.SetTLSConfig(myTlsConfig)
+	}
+	return options, nil
+}
+
+// the client certificate is used for mutual tls, the ca file replaces the system cert pool
+func (msgConfig MsgConfig) createTlsConfig() (*tls.Config, error) {
+	result := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(msgConfig.TlsCaFile) > 0 {
+		myCaCert, err := os.ReadFile(msgConfig.TlsCaFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the ca file: %v", err)
+		}
+		myCertPool := x509.NewCertPool()
+		if !myCertPool.AppendCertsFromPEM(myCaCert) {
+			return nil, fmt.Errorf("no certificates found in the ca file: %v", msgConfig.TlsCaFile)
+		}
+		result.RootCAs = myCertPool
+	}
+	if len(msgConfig.TlsCertFile) > 0 || len(msgConfig.TlsKeyFile) > 0 {
+		myCertificate, err := tls.LoadX509KeyPair(msgConfig.TlsCertFile, msgConfig.TlsKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the client certificate: %v", err)
+		}
+		result.Certificates = []tls.Certificate{myCertificate}
+	}
+	return result, nil
+}
@@ -0,0 +1,95 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package messaging
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTopics(t *testing.T) {
+	tests := []struct {
+		name      string
+		topicsStr string
+		want      []TopicConfig
+	}{
+		{"empty", "", nil},
+		{"default qos", "prices", []TopicConfig{{Topic: "prices", Qos: 1}}},
+		{"with qos", "prices:0; updates/+:2", []TopicConfig{{Topic: "prices", Qos: 0}, {Topic: "updates/+", Qos: 2}}},
+		{"invalid qos", "prices:3", []TopicConfig{{Topic: "prices:3", Qos: 1}}},
+		{"no number", "prices:abc", []TopicConfig{{Topic: "prices:abc", Qos: 1}}},
+		{"empty entries", " ; prices/# ;", []TopicConfig{{Topic: "prices/#", Qos: 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseTopics(tt.topicsStr); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTopics(%v) = %v want: %v", tt.topicsStr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTopicFilter(t *testing.T) {
+	tests := []struct {
+		name        string
+		sharedGroup string
+		topic       string
+		want        string
+	}{
+		{"no shared group", "", "prices", "prices"},
+		{"shared group", "backend", "prices/+", "$share/backend/prices/+"},
+		{"shared topic", "backend", "$share/other/prices", "$share/other/prices"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			myMsgConfig := MsgConfig{SharedGroup: tt.sharedGroup}
+			if got := myMsgConfig.topicFilter(TopicConfig{Topic: tt.topic, Qos: 1}); got != tt.want {
+				t.Errorf("topicFilter() = %v want: %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateClientOptions(t *testing.T) {
+	tests := []struct {
+		name         string
+		cleanSession bool
+		sharedGroup  string
+		wantErr      bool
+	}{
+		{"clean session", true, "", false},
+		{"persistent session", false, "", false},
+		{"shared group", true, "backend", false},
+		{"invalid shared group", true, "back/end", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			myOptions, err := MsgConfig{ServerUrl: "tcp://localhost:1883", ClientId: "test", CleanSession: tt.cleanSession, SharedGroup: tt.sharedGroup}.createClientOptions()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("createClientOptions() error = %v wantErr: %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !myOptions.AutoAckDisabled {
+				t.Errorf("AutoAckDisabled = false, the messages must be acknowledged after they are processed")
+			}
+			if myOptions.ProtocolVersion != 4 {
+				t.Errorf("ProtocolVersion = %v want: 4(MQTT 3.1.1)", myOptions.ProtocolVersion)
+			}
+			if myOptions.CleanSession != tt.cleanSession || myOptions.ResumeSubs == tt.cleanSession {
+				t.Errorf("CleanSession = %v ResumeSubs = %v", myOptions.CleanSession, myOptions.ResumeSubs)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package messaging
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"react-and-go/pkd/gasstation"
+	"react-and-go/pkd/messaging/msgmodel"
+	"time"
+)
+
+const deadLettersLimit = 500
+
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+var ErrDeadLetterClosed = errors.New("dead letter is already reprocessed or discarded")
+var ErrDeadLetterRejected = errors.New("dead letter is rejected again")
+var ErrDeadLetterNotApplied = errors.New("dead letter is not applied")
+
+func createDeadLetter(stid string, payload []byte, reason string) msgmodel.DeadLetter {
+	myNow := time.Now()
+	return msgmodel.DeadLetter{Stid: stid, Payload: string(payload), Reason: reason, Status: msgmodel.DeadLetterNew, Created: myNow, Updated: myNow}
+}
+
+// the entry is stored as a message with the entry only to reprocess it like a message
+func createEntryDeadLetter(stid string, entry json.RawMessage, reason string) msgmodel.DeadLetter {
+	myPayload, err := json.Marshal(map[string]json.RawMessage{stid: entry})
+	if err != nil {
+		myPayload = entry
+	}
+	return createDeadLetter(stid, myPayload, reason)
+}
+
+// the dead letters are published to MSG_DEAD_LETTER_TOPIC if it is set
+func (msgClient *MsgClient) storeDeadLetters(deadLetters []msgmodel.DeadLetter, topicName string, messageId uint16) {
+	for _, myDeadLetter := range deadLetters {
+		myDeadLetter.Topic = topicName
+		myDeadLetter.MessageId = int(messageId)
+		if err := msgClient.msgRepo.SaveDeadLetter(&myDeadLetter); err != nil {
+			log.Printf("Dead letter save failed: %v\n", err)
+		}
+		msgClient.dropped.deadLetters.Add(1)
+		msgClient.publishDeadLetter(myDeadLetter)
+	}
+	if len(deadLetters) > 0 {
+		log.Printf("Dead letters stored: %v topic: %v\n", len(deadLetters), topicName)
+	}
+}
+
+func (msgClient *MsgClient) publishDeadLetter(deadLetter msgmodel.DeadLetter) {
+	if len(msgClient.msgConfig.DeadLetterTopic) == 0 || !msgClient.IsConnected() {
+		return
+	}
+	myDeadLetterJson, err := json.Marshal(deadLetter)
+	if err != nil {
+		log.Printf("Dead letter marshal failed: %v\n", err)
+		return
+	}
+	token := msgClient.client.Publish(msgClient.msgConfig.DeadLetterTopic, 1, false, myDeadLetterJson)
+	if token.WaitTimeout(5*time.Second) && token.Error() != nil {
+		log.Printf("Dead letter publish failed: %v\n", token.Error())
+	}
+}
+
+func (msgClient *MsgClient) FindDeadLetters(status string) []msgmodel.DeadLetter {
+	return msgClient.msgRepo.FindDeadLetters(status, deadLettersLimit)
+}
+
+func (msgClient *MsgClient) FindDeadLetter(id int64) (msgmodel.DeadLetter, error) {
+	myDeadLetter, found := msgClient.msgRepo.FindDeadLetterById(id)
+	if !found {
+		return myDeadLetter, ErrDeadLetterNotFound
+	}
+	return myDeadLetter, nil
+}
+
+// the payload bypasses the ledger, a dead letter that is rejected again or is not applied stays new with the new reason
+// only a dead letter with updated prices is reprocessed, a stale, duplicate or unchanged update is not applied
+func (msgClient *MsgClient) ReprocessDeadLetter(id int64) (gasstation.PriceUpdateResult, error) {
+	myDeadLetter, err := msgClient.findNewDeadLetter(id)
+	if err != nil {
+		return gasstation.PriceUpdateResult{}, err
+	}
+	result, deadLetters := msgClient.processPriceUpdate([]byte(myDeadLetter.Payload))
+	myDeadLetter.Attempts += 1
+	myDeadLetter.Updated = time.Now()
+	var myErr error
+	if len(deadLetters) > 0 {
+		myDeadLetter.Reason = deadLetters[0].Reason
+		myErr = ErrDeadLetterRejected
+	} else if result.Updated == 0 {
+		myDeadLetter.Reason = fmt.Sprintf("not applied, stale: %v duplicate: %v superseded: %v unchanged: %v", result.Stale, result.Duplicate, result.Superseded, result.Unchanged)
+		myErr = ErrDeadLetterNotApplied
+	} else {
+		myDeadLetter.Status = msgmodel.DeadLetterReprocessed
+	}
+	if err := msgClient.msgRepo.SaveDeadLetter(&myDeadLetter); err != nil {
+		return result, err
+	}
+	if myErr != nil {
+		return result, fmt.Errorf("%w: %v", myErr, myDeadLetter.Reason)
+	}
+	return result, nil
+}
+
+func (msgClient *MsgClient) DiscardDeadLetter(id int64) (msgmodel.DeadLetter, error) {
+	myDeadLetter, err := msgClient.findNewDeadLetter(id)
+	if err != nil {
+		return myDeadLetter, err
+	}
+	myDeadLetter.Status = msgmodel.DeadLetterDiscarded
+	myDeadLetter.Updated = time.Now()
+	return myDeadLetter, msgClient.msgRepo.SaveDeadLetter(&myDeadLetter)
+}
+
+func (msgClient *MsgClient) findNewDeadLetter(id int64) (msgmodel.DeadLetter, error) {
+	myDeadLetter, err := msgClient.FindDeadLetter(id)
+	if err != nil {
+		return myDeadLetter, err
+	}
+	if myDeadLetter.Status != msgmodel.DeadLetterNew {
+		return myDeadLetter, ErrDeadLetterClosed
+	}
+	return myDeadLetter, nil
+}
@@ -0,0 +1,134 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package messaging
+
+import (
+	"errors"
+	"fmt"
+	"react-and-go/pkd/messaging/msgmodel"
+	"strings"
+	"testing"
+	"time"
+)
+
+// the entry with an invalid price is quarantined, a reprocess can not apply it and it stays new until it is discarded
+func TestInvalidPriceDeadLetter(t *testing.T) {
+	myMsgClient, myGasStationRepo := newTestMsgClient(t)
+	myTime := time.Now().Add(-time.Hour).Unix()
+	myPayload := []byte(fmt.Sprintf(`{"stid1":{"Useconds":%v,"E5":"1.85e1","E10":"1.759","Diesel":"1.659"},"stid2":{"Useconds":%v,"E5":"1.859","E10":"1.759","Diesel":"1.659"}}`,
+		myTime, myTime))
+	if got := myMsgClient.HandlePriceUpdate(&myPayload, "prices", 1); got.Received != 1 || got.Updated != 1 {
+		t.Errorf("HandlePriceUpdate() = %+v want: Received 1 Updated 1", got)
+	}
+	if myGasPrices := myGasStationRepo.FindPricesByStid("stid1"); len(myGasPrices) != 0 {
+		t.Errorf("prices = %+v want: no prices of the quarantined entry", myGasPrices)
+	}
+	myDeadLetters := myMsgClient.FindDeadLetters(msgmodel.DeadLetterNew)
+	if len(myDeadLetters) != 1 || myDeadLetters[0].Stid != "stid1" || !strings.Contains(myDeadLetters[0].Reason, "invalid price") {
+		t.Fatalf("dead letters = %+v want: one dead letter of stid1 with the invalid price", myDeadLetters)
+	}
+	tests := []struct {
+		name       string
+		reprocess  bool
+		wantErr    error
+		wantStatus string
+		wantTries  int
+	}{
+		{"reprocess", true, ErrDeadLetterRejected, msgmodel.DeadLetterNew, 1},
+		{"reprocess again", true, ErrDeadLetterRejected, msgmodel.DeadLetterNew, 2},
+		{"discard", false, nil, msgmodel.DeadLetterDiscarded, 2},
+		{"reprocess discarded", true, ErrDeadLetterClosed, msgmodel.DeadLetterDiscarded, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.reprocess {
+				_, err = myMsgClient.ReprocessDeadLetter(myDeadLetters[0].ID)
+			} else {
+				_, err = myMsgClient.DiscardDeadLetter(myDeadLetters[0].ID)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v want: %v", err, tt.wantErr)
+			}
+			myDeadLetter, _ := myMsgClient.FindDeadLetter(myDeadLetters[0].ID)
+			if myDeadLetter.Status != tt.wantStatus || myDeadLetter.Attempts != tt.wantTries {
+				t.Errorf("dead letter = %+v want: status %v attempts %v", myDeadLetter, tt.wantStatus, tt.wantTries)
+			}
+			if myGasPrices := myGasStationRepo.FindPricesByStid("stid1"); len(myGasPrices) != 0 {
+				t.Errorf("prices = %+v want: no prices of the quarantined entry", myGasPrices)
+			}
+		})
+	}
+}
+
+// a dead letter that is stale after a newer price is not applied and stays new
+func TestReprocessStaleDeadLetter(t *testing.T) {
+	myTime := time.Now().Add(-time.Hour)
+	myDeltaPayload := []byte(fmt.Sprintf(`{"stid2":{"Useconds":%v,"E5_delta":-0.01}}`, myTime.Unix()))
+	myNewerPayload := []byte(fmt.Sprintf(`{"stid2":{"Useconds":%v,"E5":"1.859","E10":"1.759","Diesel":"1.659"}}`, myTime.Add(time.Minute).Unix()))
+	myMsgClient, _ := newTestMsgClient(t)
+	myMsgClient.HandlePriceUpdate(&myDeltaPayload, "prices", 1)
+	myMsgClient.HandlePriceUpdate(&myNewerPayload, "prices", 2)
+	myDeadLetters := myMsgClient.FindDeadLetters(msgmodel.DeadLetterNew)
+	if len(myDeadLetters) != 1 {
+		t.Fatalf("dead letters = %+v want: one dead letter", myDeadLetters)
+	}
+	got, err := myMsgClient.ReprocessDeadLetter(myDeadLetters[0].ID)
+	if !errors.Is(err, ErrDeadLetterNotApplied) || got.Stale != 1 {
+		t.Errorf("ReprocessDeadLetter() = %+v, %v want: Stale 1, %v", got, err, ErrDeadLetterNotApplied)
+	}
+	myDeadLetter, _ := myMsgClient.FindDeadLetter(myDeadLetters[0].ID)
+	if myDeadLetter.Status != msgmodel.DeadLetterNew || !strings.Contains(myDeadLetter.Reason, "stale: 1") {
+		t.Errorf("dead letter = %+v want: status new with the stale reason", myDeadLetter)
+	}
+}
+
+// the reprocessed dead letter has the result of the original message after the missing last price
+func TestReprocessDeadLetter(t *testing.T) {
+	myTime := time.Now().Add(-time.Hour)
+	myBasePayload := []byte(fmt.Sprintf(`{"stid2":{"Useconds":%v,"E5":"1.859","E10":"1.759","Diesel":"1.659"}}`, myTime.Unix()))
+	myDeltaPayload := []byte(fmt.Sprintf(`{"stid1":{"Useconds":%v,"E5":"1.869","E10":"1.769","Diesel":"1.669"},"stid2":{"Useconds":%v,"E5_delta":-0.01}}`,
+		myTime.Add(time.Minute).Unix(), myTime.Add(time.Minute).Unix()))
+	myDeadLetterClient, myDeadLetterRepo := newTestMsgClient(t)
+	if got := myDeadLetterClient.HandlePriceUpdate(&myDeltaPayload, "prices", 1); got.Updated != 1 || got.Rejected != 1 {
+		t.Fatalf("HandlePriceUpdate() = %+v want: Updated 1 Rejected 1", got)
+	}
+	myDeadLetters := myDeadLetterClient.FindDeadLetters(msgmodel.DeadLetterNew)
+	if len(myDeadLetters) != 1 || myDeadLetters[0].Stid != "stid2" {
+		t.Fatalf("dead letters = %+v want: one dead letter of stid2", myDeadLetters)
+	}
+	myDeadLetterClient.HandlePriceUpdate(&myBasePayload, "prices", 2)
+	if got, err := myDeadLetterClient.ReprocessDeadLetter(myDeadLetters[0].ID); err != nil || got.Updated != 1 {
+		t.Fatalf("ReprocessDeadLetter() = %+v, %v want: Updated 1", got, err)
+	}
+	myOriginalClient, myOriginalRepo := newTestMsgClient(t)
+	myOriginalClient.HandlePriceUpdate(&myBasePayload, "prices", 2)
+	if got := myOriginalClient.HandlePriceUpdate(&myDeltaPayload, "prices", 1); got.Updated != 2 || got.Rejected != 0 {
+		t.Fatalf("HandlePriceUpdate() = %+v want: Updated 2", got)
+	}
+	for _, myStid := range []string{"stid1", "stid2"} {
+		myReprocessedPrices := myDeadLetterRepo.FindPricesByStid(myStid)
+		myOriginalPrices := myOriginalRepo.FindPricesByStid(myStid)
+		if len(myReprocessedPrices) != len(myOriginalPrices) || len(myOriginalPrices) == 0 {
+			t.Fatalf("%v prices = %+v want: %+v", myStid, myReprocessedPrices, myOriginalPrices)
+		}
+		myReprocessed, myOriginal := myReprocessedPrices[0], myOriginalPrices[0]
+		if myReprocessed.E5 != myOriginal.E5 || myReprocessed.E10 != myOriginal.E10 || myReprocessed.Diesel != myOriginal.Diesel ||
+			!myReprocessed.Date.Equal(myOriginal.Date) || myReprocessed.Changed != myOriginal.Changed {
+			t.Errorf("%v price = %+v want: %+v", myStid, myReprocessed, myOriginal)
+		}
+	}
+	if _, err := myDeadLetterClient.ReprocessDeadLetter(myDeadLetters[0].ID); !errors.Is(err, ErrDeadLetterClosed) {
+		t.Errorf("ReprocessDeadLetter() error = %v want: %v", err, ErrDeadLetterClosed)
+	}
+}
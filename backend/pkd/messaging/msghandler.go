@@ -14,11 +14,15 @@ package messaging
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"math/rand"
 	"os"
 	"react-and-go/pkd/gasstation"
+	"react-and-go/pkd/messaging/msgmodel"
+	"react-and-go/pkd/workpool"
 	"strings"
 	"sync"
 	"time"
@@ -26,39 +30,79 @@ import (
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
 
+// the prices and the deltas are in euro, a missing delta is nil
 type PriceUpdates struct {
 	Useconds     int64
 	Diesel       json.Number
 	E5           json.Number
 	E10          json.Number
-	Diesel_delta float64
-	E5_delta     float64
-	E10_delta    float64
+	Diesel_delta *float64
+	E5_delta     *float64
+	E10_delta    *float64
 }
 
-var updatePricesMutex sync.Mutex
+type MsgClient struct {
+	gasStationService *gasstation.GasStationService
+	client            mqtt.Client
+	ingestionPool     *workpool.WorkPool
+	msgConfig         MsgConfig
+	msgRepo           MsgRepo
+	ledgerMutex       sync.Mutex
+	dropped           droppedCounters
+}
 
-var client mqtt.Client
+type IngestionMetrics struct {
+	Queue          workpool.WorkPoolMetrics
+	PostProcessing []workpool.WorkPoolMetrics
+	Dropped        DroppedUpdates
+}
 
 var randSource = rand.NewSource(time.Now().UnixNano())
 
-var gasPriceMsgHandler mqtt.MessageHandler = func(client mqtt.Client, msg mqtt.Message) {
+// more than one worker does not keep the order of the messages
+func NewMsgClient(gasStationService *gasstation.GasStationService, msgRepo MsgRepo) *MsgClient {
+	myIngestionPool := workpool.NewWorkPool("priceIngestion", workpool.EnvInt("MSG_WORKERS", 1), workpool.EnvInt("MSG_QUEUE_SIZE", 100))
+	return &MsgClient{gasStationService: gasStationService, ingestionPool: myIngestionPool, msgConfig: LoadMsgConfig(), msgRepo: msgRepo}
+}
+
+// a full queue blocks the callback and the broker holds back the next messages, the message is acknowledged after it is processed
+// the dead letters are ignored if a wildcard topic matches the dead letter topic
+func (msgClient *MsgClient) gasPriceMsgHandler(client mqtt.Client, msg mqtt.Message) {
+	if len(msgClient.msgConfig.DeadLetterTopic) > 0 && msg.Topic() == msgClient.msgConfig.DeadLetterTopic {
+		msg.Ack()
+		return
+	}
 	//fmt.Printf("Message: %s received on topic: %s size: %d\n", msg.Payload(), msg.Topic(), len(msg.Payload()))
-	startTime := time.Now()
-	fmt.Printf("Message received on topic: %s size: %d\n", msg.Topic(), len(msg.Payload()))
-	ptr := msg.Payload()
-	HandlePriceUpdate(&ptr, msg.Topic())
-	fmt.Printf("Message processed in: %v on topic: %s size: %d\n", time.Since(startTime), msg.Topic(), len(msg.Payload()))
+	fmt.Printf("Message received on topic: %s size: %d duplicate: %v\n", msg.Topic(), len(msg.Payload()), msg.Duplicate())
+	myPayload := msg.Payload()
+	myTopic := msg.Topic()
+	myMessageId := msg.MessageID()
+	msgClient.ingestionPool.Submit(func() error {
+		//a failed message is acknowledged too, the ledger and the dead letters keep the failures
+		defer msg.Ack()
+		startTime := time.Now()
+		var err error
+		if isTestMode() {
+			msgClient.HandlePriceUpdate(&myPayload, myTopic, myMessageId)
+		} else {
+			err = msgClient.handlePriceMessage(myPayload, myTopic, myMessageId)
+		}
+		fmt.Printf("Message processed in: %v on topic: %s size: %d\n", time.Since(startTime), myTopic, len(myPayload))
+		return err
+	})
+}
+
+func (msgClient *MsgClient) IngestionMetrics() IngestionMetrics {
+	return IngestionMetrics{Queue: msgClient.ingestionPool.Metrics(), PostProcessing: msgClient.gasStationService.PostProcessingMetrics(), Dropped: msgClient.DroppedUpdates()}
 }
 
 var messagePubHandler mqtt.MessageHandler = func(client mqtt.Client, msg mqtt.Message) {
 	fmt.Printf("Message %s received on topic %s\n", msg.Payload(), msg.Topic())
 }
 
-var connectHandler mqtt.OnConnectHandler = func(client mqtt.Client) {
+func (msgClient *MsgClient) connectHandler(client mqtt.Client) {
 	fmt.Println("Connected")
-	msgGasPriceTopic := os.Getenv("MSG_GAS_PRICE_TOPIC")
-	subscribeToTopic(msgGasPriceTopic)
+	msgClient.subscribeToTopics()
 }
 
 var connectionLostHandler mqtt.ConnectionLostHandler = func(client mqtt.Client, err error) {
@@ -66,63 +110,86 @@ var connectionLostHandler mqtt.ConnectionLostHandler = func(client mqtt.Client,
 	client.Disconnect(0)
 }
 
-func Start() {
-	msgServerUrl := os.Getenv("MSG_PARAMS")
-	msgClientId := os.Getenv("MSG_CLIENT_ID")
-	msgServerUser := os.Getenv("MSG_SERVER_USER")
-	msgServerPwd := os.Getenv("MSG_SERVER_PWD")
-	options := mqtt.NewClientOptions()
-	options.AddBroker(msgServerUrl)
-	options.SetClientID(msgClientId)
-	options.SetUsername(msgServerUser)
-	options.SetPassword(msgServerPwd)
+// the topics are subscribed by the connect handler after every connect
+func (msgClient *MsgClient) Start() {
+	options, err := msgClient.msgConfig.createClientOptions()
+	if err != nil {
+		log.Printf("Invalid messaging config: %v\n", err)
+		return
+	}
 	options.SetDefaultPublishHandler(messagePubHandler)
-	options.OnConnect = connectHandler
+	options.OnConnect = msgClient.connectHandler
 	options.OnConnectionLost = connectionLostHandler
 
-	client = mqtt.NewClient(options)
-	token := client.Connect()
+	msgClient.client = mqtt.NewClient(options)
+	//the queued messages of a persistent session can arrive before the subscriptions
+	for _, myTopicConfig := range msgClient.msgConfig.Topics {
+		msgClient.client.AddRoute(msgClient.msgConfig.topicFilter(myTopicConfig), msgClient.gasPriceMsgHandler)
+	}
+	token := msgClient.client.Connect()
 	if token.Wait() && token.Error() != nil {
 		log.Printf("Connection failed: %v\n", token.Error())
 	} else {
-		log.Printf("Connected to: %v id: %v\n", msgServerUrl, msgClientId)
+		log.Printf("Connected to: %v id: %v clean session: %v\n", msgClient.msgConfig.ServerUrl, msgClient.msgConfig.ClientId, msgClient.msgConfig.CleanSession)
 	}
-
-	msgGasPriceTopic := os.Getenv("MSG_GAS_PRICE_TOPIC")
-	subscribeToTopic(msgGasPriceTopic)
 }
 
-func Stop() {
-	client.Disconnect(1000)
+// the queued messages are processed before Stop returns
+func (msgClient *MsgClient) Stop() {
+	if msgClient.client != nil {
+		msgClient.client.Disconnect(1000)
+	}
+	msgClient.ingestionPool.Stop()
 }
 
-func SendMsg(msg string) {
+func (msgClient *MsgClient) SendMsg(msg string) {
+	if msgClient.client == nil {
+		return
+	}
 	msgGasPriceTopic := os.Getenv("MSG_GAS_PRICE_TOPIC")
-	client.Publish(msgGasPriceTopic, 0, false, msg)
+	msgClient.client.Publish(msgGasPriceTopic, 0, false, msg)
 }
 
-func ConnectionCheck() {
-	if !client.IsConnected() || !client.IsConnectionOpen() {
-		log.Printf("Trying to reconnect. IsConnected: %v IsConnectionOpen: %v\n", client.IsConnected(), client.IsConnectionOpen())
-		client.Disconnect(0)
-		Start()
+func (msgClient *MsgClient) IsConnected() bool {
+	return msgClient.client != nil && msgClient.client.IsConnectionOpen()
+}
+
+func (msgClient *MsgClient) ConnectionCheck() {
+	if msgClient.client == nil {
+		msgClient.Start()
+		return
+	}
+	if !msgClient.client.IsConnected() || !msgClient.client.IsConnectionOpen() {
+		log.Printf("Trying to reconnect. IsConnected: %v IsConnectionOpen: %v\n", msgClient.client.IsConnected(), msgClient.client.IsConnectionOpen())
+		msgClient.client.Disconnect(0)
+		msgClient.Start()
 	}
 	//log.Printf("ConnectionCheck() done.\n")
 }
 
-func HandlePriceUpdate(msgArr *[]byte, topicName string) {
+// the rejected message or entries are stored as dead letters
+func (msgClient *MsgClient) HandlePriceUpdate(msgArr *[]byte, topicName string, messageId uint16) gasstation.PriceUpdateResult {
+	result, deadLetters := msgClient.processPriceUpdate(*msgArr)
+	msgClient.storeDeadLetters(deadLetters, topicName, messageId)
+	return result
+}
+
+// returns the dead letters of the rejected message or entries without storing them
+func (msgClient *MsgClient) processPriceUpdate(msgArr []byte) (gasstation.PriceUpdateResult, []msgmodel.DeadLetter) {
 	var priceUpdateRawMap map[string]json.RawMessage
-	if err := json.Unmarshal(*msgArr, &priceUpdateRawMap); err != nil {
-		log.Printf("Message: %s received on topic: %s size: %d\n", msgArr, topicName, len(*msgArr))
+	if err := json.Unmarshal(msgArr, &priceUpdateRawMap); err != nil {
+		log.Printf("Message: %s size: %d\n", msgArr, len(msgArr))
 		log.Printf("Unmarshal failed: %v\n", err.Error())
-		return
+		return gasstation.PriceUpdateResult{}, []msgmodel.DeadLetter{createDeadLetter("", msgArr, fmt.Sprintf("unparsable message: %v", err))}
 	}
+	var deadLetters []msgmodel.DeadLetter
 	priceUpdateMap := make(map[string]PriceUpdates)
 	for key, value := range priceUpdateRawMap {
-		myPriceUpdates := PriceUpdates{Useconds: 0, Diesel: "0", E5: "0", E10: "0", Diesel_delta: 0, E5_delta: 0, E10_delta: 0}
+		myPriceUpdates := PriceUpdates{Useconds: 0, Diesel: "", E5: "", E10: ""}
 		if err := json.Unmarshal(value, &myPriceUpdates); err != nil {
 			log.Printf("PriceUpdate: %v\n", string(value))
 			log.Printf("Unmarshal failed: %v\n", err)
+			deadLetters = append(deadLetters, createEntryDeadLetter(key, value, fmt.Sprintf("unparsable entry: %v", err)))
 		} else {
 			myPriceUpdates.Diesel = json.Number(strings.TrimSpace(strings.ReplaceAll(myPriceUpdates.Diesel.String(), ".", "")))
 			myPriceUpdates.E5 = json.Number(strings.TrimSpace(strings.ReplaceAll(myPriceUpdates.E5.String(), ".", "")))
@@ -133,40 +200,88 @@ func HandlePriceUpdate(msgArr *[]byte, topicName string) {
 	//log.Default().Printf("PriceUpdateMap: %v", priceUpdateMap)
 	var myGasStationPrices []gasstation.GasStationPrices
 	for key, value := range priceUpdateMap {
-		myGasStationPrice := gasstation.GasStationPrices{GasStationID: key, E5: int(convertJsonNumberToInt(value.E5)), E10: int(convertJsonNumberToInt(value.E10)),
-			Diesel: int(convertJsonNumberToInt(value.Diesel)), Timestamp: time.Unix(value.Useconds, 0)}
-		if len(strings.TrimSpace(os.Getenv("MSG_MESSAGES"))) > 3 {
+		//the entry with an invalid price is not stored and is kept as dead letter only
+		myGasStationPrice, err := createGasStationPrices(key, value)
+		if err != nil {
+			deadLetters = append(deadLetters, createEntryDeadLetter(key, priceUpdateRawMap[key], err.Error()))
+			continue
+		}
+		if isTestMode() {
 			myGasStationPrice = scramblePrices(myGasStationPrice)
 		}
 		myGasStationPrices = append(myGasStationPrices, myGasStationPrice)
 	}
 	//log.Printf("GasStationPrices: %v", myGasStationPrices)
 	log.Printf("Priceupdates received: %v", len(myGasStationPrices))
-	updatePricesMutex.Lock()
-	log.Printf("Priceupdates started: %v", len(myGasStationPrices))
-	gasstation.UpdatePrice(&myGasStationPrices)
-	updatePricesMutex.Unlock()
+	//the change detection of UpdatePrice is safe for concurrent messages
+	result := msgClient.gasStationService.UpdatePrice(&myGasStationPrices)
+	msgClient.countDroppedUpdates(result)
+	for _, myStid := range result.RejectedStids {
+		deadLetters = append(deadLetters, createEntryDeadLetter(myStid, priceUpdateRawMap[myStid], "delta or missing price without a last price of the fuel or with a price below 1"))
+	}
+	return result, deadLetters
 }
 
-func subscribeToTopic(topicName string) {
-	token := client.Subscribe(topicName, 1, gasPriceMsgHandler)
+func (msgClient *MsgClient) subscribeToTopics() {
+	for _, myTopicConfig := range msgClient.msgConfig.Topics {
+		msgClient.subscribeToTopic(msgClient.msgConfig.topicFilter(myTopicConfig), myTopicConfig.Qos)
+	}
+}
+
+func (msgClient *MsgClient) subscribeToTopic(topicName string, qos byte) {
+	token := msgClient.client.Subscribe(topicName, qos, msgClient.gasPriceMsgHandler)
 	if token.Wait() && token.Error() != nil {
 		log.Printf("Topic subription to topic: %v failed: %v", topicName, token.Error().Error())
 	} else {
-		log.Printf("Subscribed to topic %s\n", topicName)
+		log.Printf("Subscribed to topic %s qos: %v\n", topicName, qos)
 	}
 }
 
-func convertJsonNumberToInt(value json.Number) int64 {
-	var result int64 = 0
-	var err error
-	if result, err = value.Int64(); err != nil {
+// an absolute price is used before a delta, a fuel without price and delta keeps its last price in a message with deltas
+func createGasStationPrices(stid string, priceUpdates PriceUpdates) (gasstation.GasStationPrices, error) {
+	hasDeltas := priceUpdates.Diesel_delta != nil || priceUpdates.E5_delta != nil || priceUpdates.E10_delta != nil
+	result := gasstation.GasStationPrices{GasStationID: stid, Timestamp: time.Unix(priceUpdates.Useconds, 0),
+		E5KeepLast: keepLastPrice(priceUpdates.E5, priceUpdates.E5_delta, hasDeltas), E10KeepLast: keepLastPrice(priceUpdates.E10, priceUpdates.E10_delta, hasDeltas),
+		DieselKeepLast: keepLastPrice(priceUpdates.Diesel, priceUpdates.Diesel_delta, hasDeltas)}
+	var e5Err, e10Err, dieselErr error
+	result.E5, result.E5Delta, e5Err = convertPrice(priceUpdates.E5, priceUpdates.E5_delta)
+	result.E10, result.E10Delta, e10Err = convertPrice(priceUpdates.E10, priceUpdates.E10_delta)
+	result.Diesel, result.DieselDelta, dieselErr = convertPrice(priceUpdates.Diesel, priceUpdates.Diesel_delta)
+	return result, errors.Join(e5Err, e10Err, dieselErr)
+}
+
+// the delta is converted to thousandths like the prices, a missing delta is nil
+func convertPrice(price json.Number, delta *float64) (int, *int, error) {
+	if len(price.String()) > 0 {
+		myPrice, err := convertJsonNumberToInt(price)
+		return int(myPrice), nil, err
+	}
+	if delta != nil {
+		myDelta := int(math.Round(*delta * 1000))
+		return 0, &myDelta, nil
+	}
+	return 0, nil, nil
+}
+
+func keepLastPrice(price json.Number, delta *float64, hasDeltas bool) bool {
+	return hasDeltas && len(price.String()) == 0 && delta == nil
+}
+
+func convertJsonNumberToInt(value json.Number) (int64, error) {
+	result, err := value.Int64()
+	if err != nil {
 		log.Printf("Failed to convert: %v", value)
+		return 0, fmt.Errorf("invalid price: %v", value)
 	}
-	return result
+	return result, nil
+}
+
+// the test messages of MSG_MESSAGES are sent again and again and bypass the ledger
+func isTestMode() bool {
+	return len(strings.TrimSpace(os.Getenv("MSG_MESSAGES"))) > 3
 }
 
-// to have new test prices every time
+// to have new test prices every time, the timestamp is the receive time to pass the sequence check
 func scramblePrices(myGasStationPrices gasstation.GasStationPrices) gasstation.GasStationPrices {
 	r1 := rand.New(randSource)
 	scrambleValue := r1.Intn(20) - 10
@@ -174,5 +289,6 @@ func scramblePrices(myGasStationPrices gasstation.GasStationPrices) gasstation.G
 	myGasStationPrices.E10 = myGasStationPrices.E10 + scrambleValue
 	myGasStationPrices.E5 = myGasStationPrices.E5 + scrambleValue
 	myGasStationPrices.Diesel = myGasStationPrices.Diesel + scrambleValue
+	myGasStationPrices.Timestamp = time.Now()
 	return myGasStationPrices
 }
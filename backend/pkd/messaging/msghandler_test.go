@@ -0,0 +1,183 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package messaging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"react-and-go/pkd/gasstation"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"react-and-go/pkd/messaging/msgmodel"
+	"react-and-go/pkd/postcode"
+	"react-and-go/pkd/workpool"
+	"testing"
+	"time"
+)
+
+type testPriceNotifier struct{}
+
+func (notifier testPriceNotifier) SendNotifications(gasStationIDToGasPriceMapPtr *map[string]gsmodel.GasPrice, gasStations []gsmodel.GasStation) {
+}
+
+// records the ledger entries at the time of the ack
+type testMessage struct {
+	topic   string
+	payload []byte
+	onAck   func()
+	acked   chan bool
+}
+
+func (msg *testMessage) Duplicate() bool   { return false }
+func (msg *testMessage) Qos() byte         { return 1 }
+func (msg *testMessage) Retained() bool    { return false }
+func (msg *testMessage) Topic() string     { return msg.topic }
+func (msg *testMessage) MessageID() uint16 { return 1 }
+func (msg *testMessage) Payload() []byte   { return msg.payload }
+func (msg *testMessage) Ack() {
+	if msg.onAck != nil {
+		msg.onAck()
+	}
+	msg.acked <- true
+}
+
+func newTestMsgClient(t *testing.T) (*MsgClient, gasstation.GasStationRepo) {
+	myGasStationRepo := gasstation.NewGasStationMemRepo()
+	myGasStationRepo.SaveGasStations([]gsmodel.GasStation{{ID: "stid1", StationName: "A", PostCode: "10115"}, {ID: "stid2", StationName: "B", PostCode: "10115"}})
+	myService := gasstation.NewGasStationService(myGasStationRepo, postcode.NewPostCodeMemRepo(), testPriceNotifier{})
+	myMsgClient := &MsgClient{gasStationService: myService, ingestionPool: workpool.NewWorkPool("priceIngestionTest", 1, 10),
+		msgConfig: MsgConfig{DeadLetterTopic: "prices/deadletter"}, msgRepo: NewMsgMemRepo()}
+	t.Cleanup(myMsgClient.ingestionPool.Stop)
+	return myMsgClient, myGasStationRepo
+}
+
+func createTestPayload(stid string, timestamp time.Time, e5 string) []byte {
+	return []byte(fmt.Sprintf(`{"%v":{"Useconds":%v,"E5":"%v","E10":"1.759","Diesel":"1.659"}}`, stid, timestamp.Unix(), e5))
+}
+
+func TestGasPriceMsgHandlerAck(t *testing.T) {
+	myTime := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name        string
+		topic       string
+		payload     []byte
+		wantLedger  bool
+		wantEntries int
+	}{
+		{"price message", "prices", createTestPayload("stid1", myTime, "1.859"), true, 1},
+		{"dead letter topic", "prices/deadletter", createTestPayload("stid1", myTime, "1.849"), false, 0},
+		{"invalid message", "prices", []byte("no json"), true, 0},
+	}
+	myMsgClient, _ := newTestMsgClient(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var myLedgerMessage msgmodel.PriceMessage
+			myFound := false
+			myMessage := &testMessage{topic: tt.topic, payload: tt.payload, acked: make(chan bool, 1)}
+			myMessage.onAck = func() {
+				myHash := sha256.Sum256(tt.payload)
+				myLedgerMessage, myFound = myMsgClient.msgRepo.FindPriceMessageByHash(hex.EncodeToString(myHash[:]))
+			}
+			myMsgClient.gasPriceMsgHandler(nil, myMessage)
+			select {
+			case <-myMessage.acked:
+			case <-time.After(5 * time.Second):
+				t.Fatal("message not acknowledged")
+			}
+			if myFound != tt.wantLedger || myLedgerMessage.Entries != tt.wantEntries {
+				t.Errorf("ledger entry at ack found: %v entries: %v want: %v %v", myFound, myLedgerMessage.Entries, tt.wantLedger, tt.wantEntries)
+			}
+		})
+	}
+}
+
+func TestCreateGasStationPrices(t *testing.T) {
+	delta := func(value float64) *float64 {
+		return &value
+	}
+	intValue := func(value int) *int {
+		return &value
+	}
+	tests := []struct {
+		name         string
+		priceUpdates PriceUpdates
+		want         gasstation.GasStationPrices
+		wantErr      bool
+	}{
+		{"absolute prices", PriceUpdates{E5: "1859", E10: "1759", Diesel: "1659"}, gasstation.GasStationPrices{E5: 1859, E10: 1759, Diesel: 1659}, false},
+		{"missing prices", PriceUpdates{E5: "1859"}, gasstation.GasStationPrices{E5: 1859}, false},
+		{"deltas", PriceUpdates{E5_delta: delta(-0.01), E10_delta: delta(0.005), Diesel_delta: delta(0)},
+			gasstation.GasStationPrices{E5Delta: intValue(-10), E10Delta: intValue(5), DieselDelta: intValue(0)}, false},
+		{"price before delta", PriceUpdates{E5: "1859", E5_delta: delta(-0.01)}, gasstation.GasStationPrices{E5: 1859, E10KeepLast: true, DieselKeepLast: true}, false},
+		{"keep last prices", PriceUpdates{E10_delta: delta(0.02)}, gasstation.GasStationPrices{E10Delta: intValue(20), E5KeepLast: true, DieselKeepLast: true}, false},
+		{"invalid price", PriceUpdates{E5: "18x9", Diesel: "1659"}, gasstation.GasStationPrices{E5: 0, Diesel: 1659}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := createGasStationPrices("stid1", tt.priceUpdates)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("createGasStationPrices() error = %v wantErr: %v", err, tt.wantErr)
+			}
+			if got.E5 != tt.want.E5 || got.E10 != tt.want.E10 || got.Diesel != tt.want.Diesel || got.E5KeepLast != tt.want.E5KeepLast ||
+				got.E10KeepLast != tt.want.E10KeepLast || got.DieselKeepLast != tt.want.DieselKeepLast {
+				t.Errorf("createGasStationPrices() = %+v want: %+v", got, tt.want)
+			}
+			for _, myDeltas := range [][2]*int{{got.E5Delta, tt.want.E5Delta}, {got.E10Delta, tt.want.E10Delta}, {got.DieselDelta, tt.want.DieselDelta}} {
+				if (myDeltas[0] == nil) != (myDeltas[1] == nil) || (myDeltas[0] != nil && *myDeltas[0] != *myDeltas[1]) {
+					t.Errorf("createGasStationPrices() deltas = %v %v %v want: %v %v %v", got.E5Delta, got.E10Delta, got.DieselDelta,
+						tt.want.E5Delta, tt.want.E10Delta, tt.want.DieselDelta)
+				}
+			}
+		})
+	}
+}
+
+func TestDeltaMessages(t *testing.T) {
+	myTime := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name        string
+		payload     string
+		wantUpdated int
+		wantReject  int
+		wantPrice   gsmodel.GasPrice
+	}{
+		{"delta without last price", `{"stid1":{"Useconds":%v,"E5_delta":-0.01}}`, 0, 1, gsmodel.GasPrice{}},
+		{"absolute prices", `{"stid1":{"Useconds":%v,"E5":"1.859","E10":"1.759","Diesel":"1.659"}}`, 1, 0, gsmodel.GasPrice{E5: 1859, E10: 1759, Diesel: 1659}},
+		{"delta keeps the other prices", `{"stid1":{"Useconds":%v,"E5_delta":-0.01}}`, 1, 0, gsmodel.GasPrice{E5: 1849, E10: 1759, Diesel: 1659}},
+		{"zero delta", `{"stid1":{"Useconds":%v,"E5_delta":0,"E10_delta":0.01}}`, 1, 0, gsmodel.GasPrice{E5: 1849, E10: 1769, Diesel: 1659}},
+		{"station without e10", `{"stid2":{"Useconds":%v,"E5":"1.859","Diesel":"1.659"}}`, 1, 0, gsmodel.GasPrice{GasStationID: "stid2", E5: 1859, Diesel: 1659}},
+		{"delta without the not sold fuel", `{"stid2":{"Useconds":%v,"Diesel_delta":-0.01}}`, 1, 0, gsmodel.GasPrice{GasStationID: "stid2", E5: 1859, Diesel: 1649}},
+		{"delta of the not sold fuel", `{"stid2":{"Useconds":%v,"E10_delta":-0.01}}`, 0, 1, gsmodel.GasPrice{}},
+	}
+	myMsgClient, myGasStationRepo := newTestMsgClient(t)
+	for index, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			myPayload := []byte(fmt.Sprintf(tt.payload, myTime.Add(time.Duration(index)*time.Minute).Unix()))
+			got := myMsgClient.HandlePriceUpdate(&myPayload, "prices", 1)
+			if got.Updated != tt.wantUpdated || got.Rejected != tt.wantReject {
+				t.Errorf("HandlePriceUpdate() = %+v want: Updated %v Rejected %v", got, tt.wantUpdated, tt.wantReject)
+			}
+			if tt.wantUpdated == 0 {
+				return
+			}
+			myStid := "stid1"
+			if len(tt.wantPrice.GasStationID) > 0 {
+				myStid = tt.wantPrice.GasStationID
+			}
+			myGasPrices := myGasStationRepo.FindPricesByStid(myStid)
+			if len(myGasPrices) == 0 || myGasPrices[0].E5 != tt.wantPrice.E5 || myGasPrices[0].E10 != tt.wantPrice.E10 || myGasPrices[0].Diesel != tt.wantPrice.Diesel {
+				t.Errorf("prices = %+v want: %+v", myGasPrices, tt.wantPrice)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package messaging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"react-and-go/pkd/gasstation"
+	"react-and-go/pkd/messaging/msgmodel"
+	"react-and-go/pkd/workpool"
+	"sync/atomic"
+	"time"
+)
+
+const priceMessagesLimit = 100
+
+type DroppedUpdates struct {
+	DuplicateMessages int64
+	StaleUpdates      int64
+	DuplicateUpdates  int64
+	SupersededUpdates int64
+	RejectedUpdates   int64
+	DeadLetters       int64
+}
+
+type droppedCounters struct {
+	duplicateMessages atomic.Int64
+	staleUpdates      atomic.Int64
+	duplicateUpdates  atomic.Int64
+	supersededUpdates atomic.Int64
+	rejectedUpdates   atomic.Int64
+	deadLetters       atomic.Int64
+}
+
+// a message with a known hash is a redelivery or a replay and is dropped, the hash is reserved before the message is processed
+// a failed ledger update is returned after the prices are processed
+func (msgClient *MsgClient) handlePriceMessage(payload []byte, topicName string, messageId uint16) error {
+	myHash := sha256.Sum256(payload)
+	myNow := time.Now()
+	myPriceMessage := msgmodel.PriceMessage{MessageId: int(messageId), Topic: topicName, Hash: hex.EncodeToString(myHash[:]), Received: myNow, LastReceived: myNow}
+	myDuplicate := false
+	msgClient.ledgerMutex.Lock()
+	err := msgClient.msgRepo.Transaction(func(repo MsgRepo) error {
+		if myLedgerMessage, found := repo.FindPriceMessageByHash(myPriceMessage.Hash); found {
+			myDuplicate = true
+			myLedgerMessage.Redeliveries += 1
+			myLedgerMessage.LastReceived = myNow
+			return repo.SavePriceMessage(&myLedgerMessage)
+		}
+		return repo.SavePriceMessage(&myPriceMessage)
+	})
+	msgClient.ledgerMutex.Unlock()
+	if myDuplicate {
+		msgClient.dropped.duplicateMessages.Add(1)
+		log.Printf("Duplicate message dropped on topic: %v id: %v\n", topicName, messageId)
+		return err
+	}
+	myPriceUpdateResult := msgClient.HandlePriceUpdate(&payload, topicName, messageId)
+	//the message is processed without a ledger entry if the reservation failed
+	if err != nil {
+		return fmt.Errorf("ledger update failed: %w", err)
+	}
+	myPriceMessage.Entries = myPriceUpdateResult.Received
+	myPriceMessage.Updated = myPriceUpdateResult.Updated
+	myPriceMessage.Unchanged = myPriceUpdateResult.Unchanged
+	myPriceMessage.Stale = myPriceUpdateResult.Stale
+	myPriceMessage.Duplicate = myPriceUpdateResult.Duplicate
+	myPriceMessage.Superseded = myPriceUpdateResult.Superseded
+	myPriceMessage.Rejected = myPriceUpdateResult.Rejected
+	if err := msgClient.msgRepo.SavePriceMessage(&myPriceMessage); err != nil {
+		return fmt.Errorf("ledger update failed: %w", err)
+	}
+	return nil
+}
+
+func (msgClient *MsgClient) countDroppedUpdates(priceUpdateResult gasstation.PriceUpdateResult) {
+	msgClient.dropped.staleUpdates.Add(int64(priceUpdateResult.Stale))
+	msgClient.dropped.duplicateUpdates.Add(int64(priceUpdateResult.Duplicate))
+	msgClient.dropped.supersededUpdates.Add(int64(priceUpdateResult.Superseded))
+	msgClient.dropped.rejectedUpdates.Add(int64(priceUpdateResult.Rejected))
+}
+
+func (msgClient *MsgClient) DroppedUpdates() DroppedUpdates {
+	return DroppedUpdates{DuplicateMessages: msgClient.dropped.duplicateMessages.Load(), StaleUpdates: msgClient.dropped.staleUpdates.Load(),
+		DuplicateUpdates: msgClient.dropped.duplicateUpdates.Load(), SupersededUpdates: msgClient.dropped.supersededUpdates.Load(),
+		RejectedUpdates: msgClient.dropped.rejectedUpdates.Load(), DeadLetters: msgClient.dropped.deadLetters.Load()}
+}
+
+func (msgClient *MsgClient) FindPriceMessages() []msgmodel.PriceMessage {
+	return msgClient.msgRepo.FindPriceMessages(priceMessagesLimit)
+}
+
+// the ledger entries and the closed dead letters are kept for MSG_LEDGER_DAYS, a replay of an older message is detected by the sequence of the stations
+func (msgClient *MsgClient) CleanupLedger() {
+	myBefore := time.Now().AddDate(0, 0, -workpool.EnvInt("MSG_LEDGER_DAYS", 7))
+	log.Printf("Ledger entries deleted: %v\n", msgClient.msgRepo.DeletePriceMessagesBefore(myBefore))
+	log.Printf("Closed dead letters deleted: %v\n", msgClient.msgRepo.DeleteClosedDeadLettersBefore(myBefore))
+}
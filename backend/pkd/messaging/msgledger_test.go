@@ -0,0 +1,57 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package messaging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+func TestHandlePriceMessageDedup(t *testing.T) {
+	myTime := time.Now().Add(-time.Hour)
+	myFirstPayload := createTestPayload("stid1", myTime, "1.859")
+	mySecondPayload := createTestPayload("stid1", myTime.Add(time.Minute), "1.849")
+	tests := []struct {
+		name             string
+		payload          []byte
+		wantDuplicates   int64
+		wantRedeliveries int
+		wantUpdated      int
+	}{
+		{"first message", myFirstPayload, 0, 0, 1},
+		{"redelivered message", myFirstPayload, 1, 1, 1},
+		{"second message", mySecondPayload, 1, 0, 1},
+		{"replayed message", myFirstPayload, 2, 2, 1},
+	}
+	myMsgClient, _ := newTestMsgClient(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := myMsgClient.handlePriceMessage(tt.payload, "prices", 1); err != nil {
+				t.Fatalf("handlePriceMessage() failed: %v", err)
+			}
+			if got := myMsgClient.DroppedUpdates().DuplicateMessages; got != tt.wantDuplicates {
+				t.Errorf("DuplicateMessages = %v want: %v", got, tt.wantDuplicates)
+			}
+			myHash := sha256.Sum256(tt.payload)
+			myPriceMessage, found := myMsgClient.msgRepo.FindPriceMessageByHash(hex.EncodeToString(myHash[:]))
+			if !found {
+				t.Fatal("ledger entry not found")
+			}
+			if myPriceMessage.Redeliveries != tt.wantRedeliveries || myPriceMessage.Updated != tt.wantUpdated {
+				t.Errorf("ledger entry = %+v want: Redeliveries %v Updated %v", myPriceMessage, tt.wantRedeliveries, tt.wantUpdated)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package msgmodel
+
+import "time"
+
+const (
+	DeadLetterNew         = "new"
+	DeadLetterReprocessed = "reprocessed"
+	DeadLetterDiscarded   = "discarded"
+)
+
+// a rejected price message or a rejected entry of a message, the payload of an entry is a message with the entry only
+type DeadLetter struct {
+	ID        int64  `gorm:"primaryKey"`
+	Topic     string `gorm:"size:256"`
+	MessageId int
+	Stid      string `gorm:"size:64"`
+	Payload   string
+	Reason    string
+	Status    string    `gorm:"size:16;index:idx_dl_status"`
+	Created   time.Time `gorm:"index:idx_dl_created"`
+	Updated   time.Time
+	Attempts  int
+}
+
+func (DeadLetter) TableName() string {
+	return "price_dead_letter"
+}
@@ -0,0 +1,37 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package msgmodel
+
+import "time"
+
+// the ingestion ledger entry of a received price message, a redelivered message has the same hash
+type PriceMessage struct {
+	ID           int64 `gorm:"primaryKey"`
+	MessageId    int
+	Topic        string    `gorm:"size:256"`
+	Hash         string    `gorm:"size:64;uniqueIndex:idx_pm_hash"`
+	Received     time.Time `gorm:"index:idx_pm_received"`
+	LastReceived time.Time
+	Redeliveries int
+	Entries      int
+	Updated      int
+	Unchanged    int
+	Stale        int
+	Duplicate    int
+	Superseded   int
+	Rejected     int
+}
+
+func (PriceMessage) TableName() string {
+	return "price_message_ledger"
+}
@@ -0,0 +1,226 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package messaging
+
+import (
+	"maps"
+	"react-and-go/pkd/memrepo"
+	"react-and-go/pkd/messaging/msgmodel"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type MsgRepo interface {
+	FindPriceMessageByHash(hash string) (msgmodel.PriceMessage, bool)
+	FindPriceMessages(limit int) []msgmodel.PriceMessage
+	SavePriceMessage(priceMessage *msgmodel.PriceMessage) error
+	DeletePriceMessagesBefore(before time.Time) int64
+	FindDeadLetters(status string, limit int) []msgmodel.DeadLetter
+	FindDeadLetterById(id int64) (msgmodel.DeadLetter, bool)
+	SaveDeadLetter(deadLetter *msgmodel.DeadLetter) error
+	DeleteClosedDeadLettersBefore(before time.Time) int64
+	Transaction(txFunc func(repo MsgRepo) error) error
+}
+
+type msgDbRepo struct {
+	db *gorm.DB
+}
+
+func NewMsgDbRepo(db *gorm.DB) MsgRepo {
+	return &msgDbRepo{db: db}
+}
+
+func (repo *msgDbRepo) FindPriceMessageByHash(hash string) (msgmodel.PriceMessage, bool) {
+	var priceMessages []msgmodel.PriceMessage
+	repo.db.Where("hash = ?", hash).Limit(1).Find(&priceMessages)
+	if len(priceMessages) == 0 {
+		return msgmodel.PriceMessage{}, false
+	}
+	return priceMessages[0], true
+}
+
+func (repo *msgDbRepo) FindPriceMessages(limit int) []msgmodel.PriceMessage {
+	priceMessages := []msgmodel.PriceMessage{}
+	repo.db.Order("received desc, id desc").Limit(limit).Find(&priceMessages)
+	return priceMessages
+}
+
+func (repo *msgDbRepo) SavePriceMessage(priceMessage *msgmodel.PriceMessage) error {
+	return repo.db.Save(priceMessage).Error
+}
+
+func (repo *msgDbRepo) DeletePriceMessagesBefore(before time.Time) int64 {
+	return repo.db.Where("received < ?", before).Delete(&msgmodel.PriceMessage{}).RowsAffected
+}
+
+// all dead letters are returned for an empty status
+func (repo *msgDbRepo) FindDeadLetters(status string, limit int) []msgmodel.DeadLetter {
+	deadLetters := []msgmodel.DeadLetter{}
+	myQuery := repo.db.Order("created desc, id desc").Limit(limit)
+	if len(status) > 0 {
+		myQuery = myQuery.Where("status = ?", status)
+	}
+	myQuery.Find(&deadLetters)
+	return deadLetters
+}
+
+func (repo *msgDbRepo) FindDeadLetterById(id int64) (msgmodel.DeadLetter, bool) {
+	var deadLetters []msgmodel.DeadLetter
+	repo.db.Where("id = ?", id).Limit(1).Find(&deadLetters)
+	if len(deadLetters) == 0 {
+		return msgmodel.DeadLetter{}, false
+	}
+	return deadLetters[0], true
+}
+
+func (repo *msgDbRepo) SaveDeadLetter(deadLetter *msgmodel.DeadLetter) error {
+	return repo.db.Save(deadLetter).Error
+}
+
+// the new dead letters are kept until they are reprocessed or discarded
+func (repo *msgDbRepo) DeleteClosedDeadLettersBefore(before time.Time) int64 {
+	return repo.db.Where("status <> ? and updated < ?", msgmodel.DeadLetterNew, before).Delete(&msgmodel.DeadLetter{}).RowsAffected
+}
+
+func (repo *msgDbRepo) Transaction(txFunc func(repo MsgRepo) error) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		return txFunc(&msgDbRepo{db: tx})
+	})
+}
+
+type msgMemRepo struct {
+	mutex         *sync.RWMutex
+	priceMessages map[string]msgmodel.PriceMessage
+	deadLetters   map[int64]msgmodel.DeadLetter
+	ids           *memrepo.IdSequence[int64]
+	transactor    *memrepo.Transactor
+}
+
+func NewMsgMemRepo() MsgRepo {
+	return &msgMemRepo{mutex: &sync.RWMutex{}, priceMessages: make(map[string]msgmodel.PriceMessage), deadLetters: make(map[int64]msgmodel.DeadLetter),
+		ids: memrepo.NewIdSequence[int64](), transactor: memrepo.NewTransactor()}
+}
+
+func (repo *msgMemRepo) FindPriceMessageByHash(hash string) (msgmodel.PriceMessage, bool) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	myPriceMessage, ok := repo.priceMessages[hash]
+	return myPriceMessage, ok
+}
+
+func (repo *msgMemRepo) FindPriceMessages(limit int) []msgmodel.PriceMessage {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	result := []msgmodel.PriceMessage{}
+	for _, myPriceMessage := range repo.priceMessages {
+		result = append(result, myPriceMessage)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Received.Equal(result[j].Received) {
+			return result[i].ID > result[j].ID
+		}
+		return result[i].Received.After(result[j].Received)
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+func (repo *msgMemRepo) SavePriceMessage(priceMessage *msgmodel.PriceMessage) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	if priceMessage.ID == 0 {
+		priceMessage.ID = repo.ids.Next()
+	}
+	repo.priceMessages[priceMessage.Hash] = *priceMessage
+	return nil
+}
+
+func (repo *msgMemRepo) DeletePriceMessagesBefore(before time.Time) int64 {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	var result int64 = 0
+	for myHash, myPriceMessage := range repo.priceMessages {
+		if myPriceMessage.Received.Before(before) {
+			delete(repo.priceMessages, myHash)
+			result += 1
+		}
+	}
+	return result
+}
+
+func (repo *msgMemRepo) FindDeadLetters(status string, limit int) []msgmodel.DeadLetter {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	result := []msgmodel.DeadLetter{}
+	for _, myDeadLetter := range repo.deadLetters {
+		if len(status) == 0 || myDeadLetter.Status == status {
+			result = append(result, myDeadLetter)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Created.Equal(result[j].Created) {
+			return result[i].ID > result[j].ID
+		}
+		return result[i].Created.After(result[j].Created)
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+func (repo *msgMemRepo) FindDeadLetterById(id int64) (msgmodel.DeadLetter, bool) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	myDeadLetter, ok := repo.deadLetters[id]
+	return myDeadLetter, ok
+}
+
+func (repo *msgMemRepo) SaveDeadLetter(deadLetter *msgmodel.DeadLetter) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	if deadLetter.ID == 0 {
+		deadLetter.ID = repo.ids.Next()
+	}
+	repo.deadLetters[deadLetter.ID] = *deadLetter
+	return nil
+}
+
+func (repo *msgMemRepo) DeleteClosedDeadLettersBefore(before time.Time) int64 {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	var result int64 = 0
+	for myId, myDeadLetter := range repo.deadLetters {
+		if myDeadLetter.Status != msgmodel.DeadLetterNew && myDeadLetter.Updated.Before(before) {
+			delete(repo.deadLetters, myId)
+			result += 1
+		}
+	}
+	return result
+}
+
+func (repo *msgMemRepo) Transaction(txFunc func(repo MsgRepo) error) error {
+	return repo.transactor.Run(repo.mutex, func() func() {
+		myPriceMessages := maps.Clone(repo.priceMessages)
+		myDeadLetters := maps.Clone(repo.deadLetters)
+		return func() {
+			repo.priceMessages = myPriceMessages
+			repo.deadLetters = myDeadLetters
+		}
+	}, func() error { return txFunc(repo) })
+}
@@ -16,7 +16,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"react-and-go/pkd/appuser"
 	"react-and-go/pkd/gasstation/gsmodel"
 	"time"
 )
@@ -42,16 +41,19 @@ type NotificationData struct {
 	Timestamp    time.Time
 }
 
-func SendNotifications(gasStationIDToGasPriceMapPtr *map[string]gsmodel.GasPrice, gasStations []gsmodel.GasStation) {
+func (service *NotificationService) SendNotifications(gasStationIDToGasPriceMapPtr *map[string]gsmodel.GasPrice, gasStations []gsmodel.GasStation) {
 	gasStationWithPricesMap := make(map[string]gasStationWithPrice)
 	for _, gasStation := range gasStations {
+		if !gsmodel.ParseOpeningTimes(gasStation.OtJson, gasStation.PublicHolidayIdentifier).IsOpenAt(time.Now()) {
+			continue
+		}
 		myGasStationWithPrice := gasStationWithPrice{}
 		gasStationIDToGasPriceMap := *gasStationIDToGasPriceMapPtr
 		myGasStationWithPrice.gasPrice = gasStationIDToGasPriceMap[gasStation.ID]
 		myGasStationWithPrice.gasStation = gasStation
 		gasStationWithPricesMap[gasStation.ID] = myGasStationWithPrice
 	}
-	allAppUsers := appuser.FindAllUsers()
+	allAppUsers := service.appUserRepo.FindAll()
 	myNotificationMsgs := []NotificationMsg{}
 	for _, appUser := range allAppUsers {
 		gsMatches := []gasStationWithPrice{}
@@ -93,5 +95,5 @@ func SendNotifications(gasStationIDToGasPriceMapPtr *map[string]gsmodel.GasPrice
 			myNotificationMsgs = append(myNotificationMsgs, myNotificationMsg)
 		}
 	}
-	StoreNotifications(&myNotificationMsgs)
+	service.StoreNotifications(&myNotificationMsgs)
 }
@@ -0,0 +1,85 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package notification
+
+import (
+	"fmt"
+	"react-and-go/pkd/appuser"
+	"react-and-go/pkd/appuser/aumodel"
+	"react-and-go/pkd/gasstation/gsmodel"
+	"strings"
+	"testing"
+	"time"
+)
+
+// the closed station is open on every day but today
+func closedTodayOtJson(t *testing.T) string {
+	myLocation, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("LoadLocation() error: %v", err)
+	}
+	myTodayBit := 1 << ((int(time.Now().In(myLocation).Weekday()) + 6) % 7)
+	return fmt.Sprintf(`{"openingTimes":[{"applicable_days":%v,"periods":[{"startp":"00:00","endp":"24:00"}]}]}`, gsmodel.Sunday*2-1-myTodayBit)
+}
+
+func TestSendNotifications(t *testing.T) {
+	myGasStations := []gsmodel.GasStation{{ID: "stid1", StationName: "Near", Place: "Hamburg", Latitude: 53.55, Longitude: 10.0},
+		{ID: "stid2", StationName: "Far", Place: "Berlin", Latitude: 52.52, Longitude: 13.4},
+		{ID: "stid3", StationName: "Closed", Place: "Hamburg", Latitude: 53.55, Longitude: 10.0,
+			OtJson: closedTodayOtJson(t)}}
+	myGasPrices := map[string]gsmodel.GasPrice{"stid1": {GasStationID: "stid1", E5: 1800, E10: 1700, Diesel: 1600, Date: time.Now()},
+		"stid2": {GasStationID: "stid2", E5: 1500, E10: 1400, Diesel: 1300, Date: time.Now()},
+		"stid3": {GasStationID: "stid3", E5: 1500, E10: 1400, Diesel: 1300, Date: time.Now()}}
+	tests := []struct {
+		name      string
+		appUser   aumodel.AppUser
+		wantStids []string
+	}{
+		{"target reached nearby", aumodel.AppUser{Username: "user1", Uuid: "uuid1", Latitude: 53.55, Longitude: 10.01, SearchRadius: 10, TargetE5: 1850}, []string{"stid1"}},
+		{"target not reached", aumodel.AppUser{Username: "user2", Uuid: "uuid2", Latitude: 53.55, Longitude: 10.01, SearchRadius: 10, TargetE5: 1700}, []string{}},
+		{"unavailable fuel", aumodel.AppUser{Username: "user3", Uuid: "uuid3", Latitude: 53.55, Longitude: 10.01, SearchRadius: 10, TargetDiesel: 0}, []string{}},
+		{"radius too small", aumodel.AppUser{Username: "user4", Uuid: "uuid4", Latitude: 52.0, Longitude: 10.0, SearchRadius: 10, TargetE5: 2000}, []string{}},
+	}
+	myAppUserRepo := appuser.NewAppUserMemRepo()
+	for _, tt := range tests {
+		myAppUser := tt.appUser
+		if err := myAppUserRepo.Save(&myAppUser); err != nil {
+			t.Fatalf("Save() error: %v", err)
+		}
+	}
+	myNotificationRepo := NewNotificationMemRepo()
+	myService := NewNotificationService(myNotificationRepo, myAppUserRepo)
+	myService.SendNotifications(&myGasPrices, myGasStations)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			myNotifications := myNotificationRepo.FindByUserUuid(tt.appUser.Uuid, true)
+			if len(tt.wantStids) == 0 {
+				if len(myNotifications) > 0 {
+					t.Errorf("SendNotifications() notifications: %v want none", myNotifications)
+				}
+				return
+			}
+			if len(myNotifications) != 1 {
+				t.Fatalf("SendNotifications() notifications: %v want: 1", len(myNotifications))
+			}
+			for _, myStid := range tt.wantStids {
+				if !strings.Contains(myNotifications[0].DataJson, `"GasStationID":"`+myStid+`"`) {
+					t.Errorf("SendNotifications() data: %v want station: %v", myNotifications[0].DataJson, myStid)
+				}
+			}
+			if strings.Contains(myNotifications[0].DataJson, "stid3") {
+				t.Errorf("SendNotifications() data: %v contains the closed station", myNotifications[0].DataJson)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package notification
+
+import (
+	"log"
+	unmodel "react-and-go/pkd/notification/model"
+	"sync"
+)
+
+const notificationBufferSize = 50
+
+type NotificationSubscription struct {
+	UserUuid      string
+	Notifications chan unmodel.UserNotification
+}
+
+type notificationHub struct {
+	mutex         sync.RWMutex
+	subscriptions map[string]map[*NotificationSubscription]bool
+}
+
+func newNotificationHub() *notificationHub {
+	return &notificationHub{subscriptions: make(map[string]map[*NotificationSubscription]bool)}
+}
+
+// the unacknowledged notifications have to be loaded after subscribing, the client gets them again after a reconnect
+func (service *NotificationService) SubscribeNotifications(userUuid string) *NotificationSubscription {
+	subscription := &NotificationSubscription{UserUuid: userUuid, Notifications: make(chan unmodel.UserNotification, notificationBufferSize)}
+	service.notificationHub.mutex.Lock()
+	defer service.notificationHub.mutex.Unlock()
+	if _, ok := service.notificationHub.subscriptions[userUuid]; !ok {
+		service.notificationHub.subscriptions[userUuid] = make(map[*NotificationSubscription]bool)
+	}
+	service.notificationHub.subscriptions[userUuid][subscription] = true
+	return subscription
+}
+
+func (service *NotificationService) UnsubscribeNotifications(subscription *NotificationSubscription) {
+	service.notificationHub.mutex.Lock()
+	defer service.notificationHub.mutex.Unlock()
+	if userSubscriptions, ok := service.notificationHub.subscriptions[subscription.UserUuid]; ok {
+		if _, ok := userSubscriptions[subscription]; ok {
+			delete(userSubscriptions, subscription)
+			close(subscription.Notifications)
+		}
+		if len(userSubscriptions) == 0 {
+			delete(service.notificationHub.subscriptions, subscription.UserUuid)
+		}
+	}
+}
+
+func (service *NotificationService) FindUnacknowledgedNotifications(userUuid string) []unmodel.UserNotification {
+	return service.notificationRepo.FindByUserUuid(userUuid, true)
+}
+
+func (service *NotificationService) AcknowledgeNotifications(userUuid string, notificationIds []int64) int {
+	notificationIdMap := make(map[int64]bool)
+	for _, notificationId := range notificationIds {
+		notificationIdMap[notificationId] = true
+	}
+	result := 0
+	service.notificationRepo.Transaction(func(repo NotificationRepo) error {
+		for _, userNotification := range repo.FindByUserUuid(userUuid, true) {
+			if notificationIdMap[userNotification.ID] {
+				userNotification.NotificationSend = true
+				repo.Save(&userNotification)
+				result += 1
+			}
+		}
+		return nil
+	})
+	return result
+}
+
+func (service *NotificationService) pushNotifications(userNotifications []unmodel.UserNotification) {
+	service.notificationHub.mutex.RLock()
+	defer service.notificationHub.mutex.RUnlock()
+	for _, userNotification := range userNotifications {
+		for subscription := range service.notificationHub.subscriptions[userNotification.UserUuid] {
+			//the notification stays unacknowledged and is sent again on reconnect
+			select {
+			case subscription.Notifications <- userNotification:
+			default:
+				log.Printf("Notification push buffer full for user: %v\n", userNotification.UserUuid)
+			}
+		}
+	}
+}
+
+func (service *NotificationService) IsUserUuidOfUser(username string, userUuid string) bool {
+	appUser, err := service.appUserRepo.FindByUsername(username)
+	return err == nil && len(appUser.Uuid) > 0 && appUser.Uuid == userUuid
+}
@@ -0,0 +1,106 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package notification
+
+import (
+	"react-and-go/pkd/appuser"
+	unmodel "react-and-go/pkd/notification/model"
+	"testing"
+	"time"
+)
+
+func newTestNotificationService() (*NotificationService, NotificationRepo, []unmodel.UserNotification) {
+	myNotificationRepo := NewNotificationMemRepo()
+	myUserNotifications := []unmodel.UserNotification{{UserUuid: "uuid1", Title: "n1", Timestamp: time.Now().Add(-time.Minute)},
+		{UserUuid: "uuid1", Title: "n2", Timestamp: time.Now()}, {UserUuid: "uuid2", Title: "n3", Timestamp: time.Now()}}
+	for index := range myUserNotifications {
+		myNotificationRepo.Save(&myUserNotifications[index])
+	}
+	return NewNotificationService(myNotificationRepo, appuser.NewAppUserMemRepo()), myNotificationRepo, myUserNotifications
+}
+
+func TestAcknowledgeNotifications(t *testing.T) {
+	tests := []struct {
+		name        string
+		userUuid    string
+		ackIndexes  []int
+		want        int
+		wantUnsent1 int
+		wantUnsent2 int
+	}{
+		{"own notification", "uuid1", []int{0}, 1, 1, 1},
+		{"all own notifications", "uuid1", []int{0, 1}, 2, 0, 1},
+		{"notification of another user", "uuid1", []int{2}, 0, 2, 1},
+		{"own and other notifications", "uuid2", []int{0, 1, 2}, 1, 2, 0},
+		{"no notifications", "uuid1", nil, 0, 2, 1},
+		{"unknown user", "uuid3", []int{0, 1, 2}, 0, 2, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			myService, myNotificationRepo, myUserNotifications := newTestNotificationService()
+			var myIds []int64
+			for _, myIndex := range tt.ackIndexes {
+				myIds = append(myIds, myUserNotifications[myIndex].ID)
+			}
+			if got := myService.AcknowledgeNotifications(tt.userUuid, myIds); got != tt.want {
+				t.Errorf("AcknowledgeNotifications() = %v want: %v", got, tt.want)
+			}
+			myUnsent1, myUnsent2 := len(myNotificationRepo.FindByUserUuid("uuid1", true)), len(myNotificationRepo.FindByUserUuid("uuid2", true))
+			if myUnsent1 != tt.wantUnsent1 || myUnsent2 != tt.wantUnsent2 {
+				t.Errorf("AcknowledgeNotifications() unsent = %v, %v want: %v, %v", myUnsent1, myUnsent2, tt.wantUnsent1, tt.wantUnsent2)
+			}
+			if len(myNotificationRepo.FindByUserUuid("uuid1", false)) != 2 || len(myNotificationRepo.FindByUserUuid("uuid2", false)) != 1 {
+				t.Errorf("AcknowledgeNotifications() changed the notification owners")
+			}
+		})
+	}
+}
+
+// the pushed notifications stay unsent until they are acknowledged
+func TestPushNotifications(t *testing.T) {
+	myService, myNotificationRepo, myUserNotifications := newTestNotificationService()
+	mySubscription := myService.SubscribeNotifications("uuid1")
+	mySecondSubscription := myService.SubscribeNotifications("uuid1")
+	myOtherSubscription := myService.SubscribeNotifications("uuid2")
+	myService.pushNotifications(myUserNotifications[:2])
+	if len(mySubscription.Notifications) != 2 || len(mySecondSubscription.Notifications) != 2 || len(myOtherSubscription.Notifications) != 0 {
+		t.Fatalf("pushNotifications() pushed = %v, %v, %v want: 2, 2, 0", len(mySubscription.Notifications), len(mySecondSubscription.Notifications),
+			len(myOtherSubscription.Notifications))
+	}
+	if myUserNotification := <-mySubscription.Notifications; myUserNotification.UserUuid != "uuid1" || myUserNotification.Title != "n1" {
+		t.Errorf("pushNotifications() = %+v want: n1 of uuid1", myUserNotification)
+	}
+	if myUnsent := myService.FindUnacknowledgedNotifications("uuid1"); len(myUnsent) != 2 {
+		t.Errorf("FindUnacknowledgedNotifications() = %v want: 2 after the push", len(myUnsent))
+	}
+	myService.UnsubscribeNotifications(mySecondSubscription)
+	if _, ok := <-mySecondSubscription.Notifications; !ok {
+		t.Errorf("UnsubscribeNotifications() dropped the buffered notifications")
+	}
+	//a full buffer drops the push, the notification is loaded again on reconnect
+	for index := 0; index < notificationBufferSize+5; index++ {
+		myService.pushNotifications(myUserNotifications[2:])
+	}
+	if len(myOtherSubscription.Notifications) != notificationBufferSize {
+		t.Errorf("pushNotifications() buffered = %v want: %v", len(myOtherSubscription.Notifications), notificationBufferSize)
+	}
+	if myUnsent := myNotificationRepo.FindByUserUuid("uuid2", true); len(myUnsent) != 1 {
+		t.Errorf("FindByUserUuid() unsent = %v want: 1", len(myUnsent))
+	}
+	myService.UnsubscribeNotifications(mySubscription)
+	myService.UnsubscribeNotifications(myOtherSubscription)
+	myService.UnsubscribeNotifications(myOtherSubscription)
+	if _, ok := myService.notificationHub.subscriptions["uuid1"]; ok {
+		t.Errorf("UnsubscribeNotifications() kept the subscriptions of uuid1")
+	}
+}
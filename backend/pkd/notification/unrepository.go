@@ -0,0 +1,114 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package notification
+
+import (
+	"maps"
+	"react-and-go/pkd/memrepo"
+	unmodel "react-and-go/pkd/notification/model"
+	"sort"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type NotificationRepo interface {
+	Save(userNotification *unmodel.UserNotification) error
+	Delete(userNotification *unmodel.UserNotification) error
+	FindByUserUuid(userUuid string, onlyNew bool) []unmodel.UserNotification
+	Transaction(txFunc func(repo NotificationRepo) error) error
+}
+
+type notificationDbRepo struct {
+	db *gorm.DB
+}
+
+func NewNotificationDbRepo(db *gorm.DB) NotificationRepo {
+	return &notificationDbRepo{db: db}
+}
+
+func (repo *notificationDbRepo) Save(userNotification *unmodel.UserNotification) error {
+	return repo.db.Save(userNotification).Error
+}
+
+func (repo *notificationDbRepo) Delete(userNotification *unmodel.UserNotification) error {
+	return repo.db.Delete(userNotification).Error
+}
+
+// returns the notifications ordered by timestamp descending
+func (repo *notificationDbRepo) FindByUserUuid(userUuid string, onlyNew bool) []unmodel.UserNotification {
+	var userNotifications []unmodel.UserNotification
+	if onlyNew {
+		repo.db.Where("user_uuid = ? and notification_send = ?", userUuid, false).Order("timestamp desc").Find(&userNotifications)
+	} else {
+		repo.db.Where("user_uuid = ?", userUuid).Order("timestamp desc").Find(&userNotifications)
+	}
+	return userNotifications
+}
+
+func (repo *notificationDbRepo) Transaction(txFunc func(repo NotificationRepo) error) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		return txFunc(&notificationDbRepo{db: tx})
+	})
+}
+
+type notificationMemRepo struct {
+	mutex             *sync.RWMutex
+	userNotifications map[int64]unmodel.UserNotification
+	ids               *memrepo.IdSequence[int64]
+	transactor        *memrepo.Transactor
+}
+
+func NewNotificationMemRepo() NotificationRepo {
+	return &notificationMemRepo{mutex: &sync.RWMutex{}, userNotifications: make(map[int64]unmodel.UserNotification), ids: memrepo.NewIdSequence[int64](),
+		transactor: memrepo.NewTransactor()}
+}
+
+func (repo *notificationMemRepo) Save(userNotification *unmodel.UserNotification) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	if userNotification.ID == 0 {
+		userNotification.ID = repo.ids.Next()
+	}
+	repo.userNotifications[userNotification.ID] = *userNotification
+	return nil
+}
+
+func (repo *notificationMemRepo) Delete(userNotification *unmodel.UserNotification) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	delete(repo.userNotifications, userNotification.ID)
+	return nil
+}
+
+func (repo *notificationMemRepo) FindByUserUuid(userUuid string, onlyNew bool) []unmodel.UserNotification {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	result := []unmodel.UserNotification{}
+	for _, myUserNotification := range repo.userNotifications {
+		if myUserNotification.UserUuid == userUuid && (!onlyNew || !myUserNotification.NotificationSend) {
+			result = append(result, myUserNotification)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp.After(result[j].Timestamp)
+	})
+	return result
+}
+
+func (repo *notificationMemRepo) Transaction(txFunc func(repo NotificationRepo) error) error {
+	return repo.transactor.Run(repo.mutex, func() func() {
+		myUserNotifications := maps.Clone(repo.userNotifications)
+		return func() { repo.userNotifications = myUserNotifications }
+	}, func() error { return txFunc(repo) })
+}
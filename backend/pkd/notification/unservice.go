@@ -14,11 +14,9 @@ package notification
 
 import (
 	"log"
-	"react-and-go/pkd/database"
+	"react-and-go/pkd/appuser"
 	unmodel "react-and-go/pkd/notification/model"
 	"time"
-
-	"gorm.io/gorm"
 )
 
 type NotificationMsg struct {
@@ -28,39 +26,58 @@ type NotificationMsg struct {
 	DataJson string
 }
 
-func StoreNotifications(notificationMsgs *[]NotificationMsg) {
-	database.DB.Transaction(func(tx *gorm.DB) error {
+type NotificationService struct {
+	notificationRepo NotificationRepo
+	appUserRepo      appuser.AppUserRepo
+	notificationHub  *notificationHub
+}
+
+func NewNotificationService(notificationRepo NotificationRepo, appUserRepo appuser.AppUserRepo) *NotificationService {
+	return &NotificationService{notificationRepo: notificationRepo, appUserRepo: appUserRepo, notificationHub: newNotificationHub()}
+}
+
+func (service *NotificationService) StoreNotifications(notificationMsgs *[]NotificationMsg) {
+	var userNotifications []unmodel.UserNotification
+	err := service.notificationRepo.Transaction(func(repo NotificationRepo) error {
 		for _, notificationMsg := range *notificationMsgs {
 			log.Printf("%v\n", notificationMsg.Title)
 			myUserNotification := unmodel.UserNotification{Timestamp: time.Now(), UserUuid: notificationMsg.UserUuid,
 				Title: notificationMsg.Title, Message: notificationMsg.Message, DataJson: notificationMsg.DataJson, NotificationSend: false}
-			tx.Save(&myUserNotification)
+			if err := repo.Save(&myUserNotification); err != nil {
+				return err
+			}
+			userNotifications = append(userNotifications, myUserNotification)
 		}
 		return nil
 	})
+	if err != nil {
+		log.Printf("Store notifications failed: %v\n", err)
+		return
+	}
+	service.pushNotifications(userNotifications)
 }
 
-func LoadNotifications(userUuid string, newNotifications bool) []unmodel.UserNotification {
+func (service *NotificationService) LoadNotifications(userUuid string, newNotifications bool) []unmodel.UserNotification {
 	var userNotifications []unmodel.UserNotification
 	if newNotifications {
-		database.DB.Transaction(func(tx *gorm.DB) error {
-			tx.Where("user_uuid = ? and notification_send = ?", userUuid, !newNotifications).Order("timestamp desc").Find(&userNotifications)
+		service.notificationRepo.Transaction(func(repo NotificationRepo) error {
+			userNotifications = repo.FindByUserUuid(userUuid, true)
 			for _, userNotification := range userNotifications {
 				userNotification.NotificationSend = true
-				tx.Save(&userNotification)
+				repo.Save(&userNotification)
 			}
 			return nil
 		})
 	} else {
-		database.DB.Transaction(func(tx *gorm.DB) error {
-			tx.Where("user_uuid = ?", userUuid).Order("timestamp desc").Find(&userNotifications)
+		service.notificationRepo.Transaction(func(repo NotificationRepo) error {
+			userNotifications = repo.FindByUserUuid(userUuid, false)
 			var myUserNotifications []unmodel.UserNotification
 			for index, userNotification := range userNotifications {
 				if index < 10 {
 					myUserNotifications = append(myUserNotifications, userNotification)
 					continue
 				}
-				tx.Delete(&userNotification)
+				repo.Delete(&userNotification)
 			}
 			userNotifications = myUserNotifications
 			return nil
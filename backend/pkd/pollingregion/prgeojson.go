@@ -0,0 +1,164 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package pollingregion
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	prmodel "react-and-go/pkd/pollingregion/prmodel"
+)
+
+const (
+	kmPerDegree   = 111.32
+	maxRegionSize = 2000
+)
+
+// a ring is a list of [longitude, latitude] points, the first ring of a polygon is the outline and the others are holes
+type geoRing [][2]float64
+
+type geoJsonObject struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Geometry    *geoJsonObject  `json:"geometry"`
+	Features    []geoJsonObject `json:"features"`
+}
+
+// supports Polygon and MultiPolygon geometries, also in Features and FeatureCollections
+func parsePolygons(geoJson json.RawMessage) ([][]geoRing, error) {
+	var myGeoJsonObject geoJsonObject
+	if err := json.Unmarshal(geoJson, &myGeoJsonObject); err != nil {
+		return nil, fmt.Errorf("invalid geojson: %v", err)
+	}
+	return extractPolygons(myGeoJsonObject)
+}
+
+func extractPolygons(geoJsonObject geoJsonObject) ([][]geoRing, error) {
+	switch geoJsonObject.Type {
+	case "Polygon":
+		var myPolygon []geoRing
+		if err := json.Unmarshal(geoJsonObject.Coordinates, &myPolygon); err != nil {
+			return nil, fmt.Errorf("invalid polygon: %v", err)
+		}
+		return [][]geoRing{myPolygon}, nil
+	case "MultiPolygon":
+		var myPolygons [][]geoRing
+		if err := json.Unmarshal(geoJsonObject.Coordinates, &myPolygons); err != nil {
+			return nil, fmt.Errorf("invalid multipolygon: %v", err)
+		}
+		return myPolygons, nil
+	case "Feature":
+		if geoJsonObject.Geometry == nil {
+			return nil, fmt.Errorf("feature without geometry")
+		}
+		return extractPolygons(*geoJsonObject.Geometry)
+	case "FeatureCollection":
+		var result [][]geoRing
+		for _, myFeature := range geoJsonObject.Features {
+			myPolygons, err := extractPolygons(myFeature)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, myPolygons...)
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("unsupported geojson type: %v", geoJsonObject.Type)
+}
+
+// covers the polygons with a grid of circles. The grid cells are the squares inscribed in the circles and a cell is used
+// if its center is in a polygon or the polygon border is closer than the radius.
+func coverWithCircles(polygons [][]geoRing, radiusKM float64) ([]prmodel.PollingCircle, error) {
+	minLat, minLng, maxLat, maxLng := 90.0, 180.0, -90.0, -180.0
+	for _, myPolygon := range polygons {
+		for _, myRing := range myPolygon {
+			for _, myPoint := range myRing {
+				minLng, maxLng = math.Min(minLng, myPoint[0]), math.Max(maxLng, myPoint[0])
+				minLat, maxLat = math.Min(minLat, myPoint[1]), math.Max(maxLat, myPoint[1])
+			}
+		}
+	}
+	if minLat > maxLat || minLng > maxLng {
+		return nil, fmt.Errorf("geojson without coordinates")
+	}
+	cellSizeKM := radiusKM * math.Sqrt2
+	latStep := cellSizeKM / kmPerDegree
+	result := []prmodel.PollingCircle{}
+	for rowLat := minLat; rowLat < maxLat+latStep; rowLat += latStep {
+		//the row edge closer to the equator is the widest
+		maxCos := math.Max(math.Cos(toRad(rowLat)), math.Cos(toRad(rowLat+latStep)))
+		lngStep := cellSizeKM / (kmPerDegree * maxCos)
+		centerLat := rowLat + latStep/2
+		for colLng := minLng; colLng < maxLng+lngStep; colLng += lngStep {
+			centerLng := colLng + lngStep/2
+			if !isInPolygons(polygons, centerLng, centerLat) && distanceToBorders(polygons, centerLng, centerLat) > radiusKM {
+				continue
+			}
+			result = append(result, prmodel.PollingCircle{Latitude: centerLat, Longitude: centerLng, RadiusKM: radiusKM})
+			if len(result) > maxRegionSize {
+				return nil, fmt.Errorf("region needs more than %v circles", maxRegionSize)
+			}
+		}
+	}
+	return result, nil
+}
+
+// even odd rule, the holes are excluded
+func isInPolygons(polygons [][]geoRing, lng float64, lat float64) bool {
+	for _, myPolygon := range polygons {
+		isInside := false
+		for _, myRing := range myPolygon {
+			for index, lastIndex := 0, len(myRing)-1; index < len(myRing); lastIndex, index = index, index+1 {
+				myPoint, myLastPoint := myRing[index], myRing[lastIndex]
+				if (myPoint[1] > lat) != (myLastPoint[1] > lat) &&
+					lng < (myLastPoint[0]-myPoint[0])*(lat-myPoint[1])/(myLastPoint[1]-myPoint[1])+myPoint[0] {
+					isInside = !isInside
+				}
+			}
+		}
+		if isInside {
+			return true
+		}
+	}
+	return false
+}
+
+// the distance in km in a local projection around the point
+func distanceToBorders(polygons [][]geoRing, lng float64, lat float64) float64 {
+	lngFactor := kmPerDegree * math.Cos(toRad(lat))
+	result := math.MaxFloat64
+	for _, myPolygon := range polygons {
+		for _, myRing := range myPolygon {
+			for index := 1; index < len(myRing); index++ {
+				x1, y1 := (myRing[index-1][0]-lng)*lngFactor, (myRing[index-1][1]-lat)*kmPerDegree
+				x2, y2 := (myRing[index][0]-lng)*lngFactor, (myRing[index][1]-lat)*kmPerDegree
+				result = math.Min(result, distanceToSegment(x1, y1, x2, y2))
+			}
+		}
+	}
+	return result
+}
+
+// distance of the origin to the segment
+func distanceToSegment(x1 float64, y1 float64, x2 float64, y2 float64) float64 {
+	dx, dy := x2-x1, y2-y1
+	t := 0.0
+	if dx != 0 || dy != 0 {
+		t = math.Max(0, math.Min(1, -(x1*dx+y1*dy)/(dx*dx+dy*dy)))
+	}
+	return math.Hypot(x1+t*dx, y1+t*dy)
+}
+
+func toRad(myValue float64) float64 {
+	return myValue * math.Pi / 180
+}
@@ -0,0 +1,174 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package pollingregion
+
+import (
+	"encoding/json"
+	"math"
+	prmodel "react-and-go/pkd/pollingregion/prmodel"
+	"testing"
+)
+
+// the great circle distance in km
+func calcTestDistance(lat1 float64, lng1 float64, lat2 float64, lng2 float64) float64 {
+	myDLat, myDLng := toRad(lat2-lat1), toRad(lng2-lng1)
+	myValue := math.Sin(myDLat/2)*math.Sin(myDLat/2) + math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(myDLng/2)*math.Sin(myDLng/2)
+	return 2 * 6371.0 * math.Asin(math.Sqrt(myValue))
+}
+
+func isCovered(pollingCircles []prmodel.PollingCircle, lng float64, lat float64) bool {
+	for _, myPollingCircle := range pollingCircles {
+		//1% for the flat grid on the sphere
+		if calcTestDistance(myPollingCircle.Latitude, myPollingCircle.Longitude, lat, lng) <= myPollingCircle.RadiusKM*1.01 {
+			return true
+		}
+	}
+	return false
+}
+
+func createTestRing(minLng float64, minLat float64, maxLng float64, maxLat float64) geoRing {
+	return geoRing{{minLng, minLat}, {maxLng, minLat}, {maxLng, maxLat}, {minLng, maxLat}, {minLng, minLat}}
+}
+
+func TestParsePolygons(t *testing.T) {
+	tests := []struct {
+		name         string
+		geoJson      string
+		wantPolygons int
+		wantErr      bool
+	}{
+		{"polygon", `{"type":"Polygon","coordinates":[[[9.9,53.5],[10.1,53.5],[10.1,53.6],[9.9,53.5]]]}`, 1, false},
+		{"multipolygon", `{"type":"MultiPolygon","coordinates":[[[[9.9,53.5],[10.1,53.5],[10.1,53.6],[9.9,53.5]]],[[[13.3,52.5],[13.5,52.5],[13.5,52.6],[13.3,52.5]]]]}`, 2, false},
+		{"feature collection", `{"type":"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[9.9,53.5],[10.1,53.5],[10.1,53.6],[9.9,53.5]]]}}]}`, 1, false},
+		{"feature without geometry", `{"type":"Feature"}`, 0, true},
+		{"point", `{"type":"Point","coordinates":[9.9,53.5]}`, 0, true},
+		{"invalid json", `{"type":`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			myPolygons, err := parsePolygons(json.RawMessage(tt.geoJson))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePolygons() error = %v wantErr: %v", err, tt.wantErr)
+			}
+			if len(myPolygons) != tt.wantPolygons {
+				t.Errorf("parsePolygons() = %v polygons want: %v", len(myPolygons), tt.wantPolygons)
+			}
+		})
+	}
+}
+
+func TestIsInPolygons(t *testing.T) {
+	myPolygons := [][]geoRing{{createTestRing(9.0, 53.0, 11.0, 54.0), createTestRing(9.8, 53.4, 10.2, 53.6)}, {createTestRing(13.0, 52.0, 14.0, 53.0)}}
+	tests := []struct {
+		name string
+		lng  float64
+		lat  float64
+		want bool
+	}{
+		{"inside", 9.5, 53.2, true},
+		{"in the hole", 10.0, 53.5, false},
+		{"second polygon", 13.5, 52.5, true},
+		{"between the polygons", 12.0, 52.5, false},
+		{"outside", 8.0, 53.5, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isInPolygons(myPolygons, tt.lng, tt.lat); got != tt.want {
+				t.Errorf("isInPolygons() = %v want: %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoverWithCircles(t *testing.T) {
+	myTriangle := geoRing{{9.7, 53.4}, {10.3, 53.45}, {10.0, 53.75}, {9.7, 53.4}}
+	tests := []struct {
+		name     string
+		polygons [][]geoRing
+		radiusKM float64
+		wantErr  bool
+	}{
+		{"square", [][]geoRing{{createTestRing(9.8, 53.4, 10.2, 53.7)}}, 5.0, false},
+		{"triangle", [][]geoRing{{myTriangle}}, 3.0, false},
+		{"smaller than a circle", [][]geoRing{{createTestRing(10.0, 53.5, 10.01, 53.51)}}, 25.0, false},
+		{"two polygons", [][]geoRing{{createTestRing(9.8, 53.4, 10.0, 53.5)}, {createTestRing(13.3, 52.4, 13.5, 52.6)}}, 10.0, false},
+		{"more than maxRegionSize circles", [][]geoRing{{createTestRing(6.0, 47.5, 15.0, 55.0)}}, 1.0, true},
+		{"no coordinates", [][]geoRing{{{}}}, 5.0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			myPollingCircles, err := coverWithCircles(tt.polygons, tt.radiusKM)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("coverWithCircles() error = %v wantErr: %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(myPollingCircles) == 0 || len(myPollingCircles) > maxRegionSize {
+				t.Fatalf("coverWithCircles() = %v circles", len(myPollingCircles))
+			}
+			for _, myPolygon := range tt.polygons {
+				for _, myPoint := range myPolygon[0] {
+					if !isCovered(myPollingCircles, myPoint[0], myPoint[1]) {
+						t.Errorf("coverWithCircles() vertex %v is not covered", myPoint)
+					}
+				}
+			}
+			//the points of a fine grid in the polygons are covered
+			for _, myPolygon := range tt.polygons {
+				minLng, minLat, maxLng, maxLat := myPolygon[0][0][0], myPolygon[0][0][1], myPolygon[0][0][0], myPolygon[0][0][1]
+				for _, myPoint := range myPolygon[0] {
+					minLng, maxLng = math.Min(minLng, myPoint[0]), math.Max(maxLng, myPoint[0])
+					minLat, maxLat = math.Min(minLat, myPoint[1]), math.Max(maxLat, myPoint[1])
+				}
+				for lat := minLat; lat <= maxLat; lat += (maxLat - minLat) / 20 {
+					for lng := minLng; lng <= maxLng; lng += (maxLng - minLng) / 20 {
+						if isInPolygons([][]geoRing{myPolygon}, lng, lat) && !isCovered(myPollingCircles, lng, lat) {
+							t.Errorf("coverWithCircles() point %v, %v is not covered", lat, lng)
+						}
+					}
+				}
+			}
+			for _, myPollingCircle := range myPollingCircles {
+				if myPollingCircle.RadiusKM != tt.radiusKM {
+					t.Errorf("coverWithCircles() radius = %v want: %v", myPollingCircle.RadiusKM, tt.radiusKM)
+				}
+			}
+		})
+	}
+}
+
+// the circles in a hole are farther than the radius from the hole border
+func TestCoverWithCirclesHole(t *testing.T) {
+	myHole := createTestRing(9.5, 53.2, 10.5, 53.8)
+	myPolygons := [][]geoRing{{createTestRing(9.0, 53.0, 11.0, 54.0), myHole}}
+	myRadiusKM := 2.0
+	myPollingCircles, err := coverWithCircles(myPolygons, myRadiusKM)
+	if err != nil {
+		t.Fatalf("coverWithCircles() error = %v", err)
+	}
+	for _, myPollingCircle := range myPollingCircles {
+		if isInPolygons([][]geoRing{{myHole}}, myPollingCircle.Longitude, myPollingCircle.Latitude) {
+			if distanceToBorders(myPolygons, myPollingCircle.Longitude, myPollingCircle.Latitude) > myRadiusKM {
+				t.Errorf("coverWithCircles() circle in the hole: %v, %v", myPollingCircle.Latitude, myPollingCircle.Longitude)
+			}
+		}
+	}
+	if !isCovered(myPollingCircles, 9.25, 53.5) || isCovered(myPollingCircles, 10.0, 53.5) {
+		t.Errorf("coverWithCircles() covers the hole center or misses the outline")
+	}
+	myFullCircles, _ := coverWithCircles([][]geoRing{{createTestRing(9.0, 53.0, 11.0, 54.0)}}, myRadiusKM)
+	if len(myPollingCircles) >= len(myFullCircles) {
+		t.Errorf("coverWithCircles() with hole = %v circles without hole: %v", len(myPollingCircles), len(myFullCircles))
+	}
+}
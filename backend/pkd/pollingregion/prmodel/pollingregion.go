@@ -0,0 +1,43 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package prmodel
+
+const (
+	SourceFile = "file"
+	SourceApi  = "api"
+)
+
+type PollingRegion struct {
+	ID              int64  `gorm:"primaryKey"`
+	Name            string `gorm:"size:128;uniqueIndex:idx_pr_name"`
+	IntervalMinutes int
+	Enabled         bool
+	Source          string          `gorm:"size:16"`
+	PollingCircles  []PollingCircle `gorm:"foreignKey:PollingRegionID"`
+}
+
+func (PollingRegion) TableName() string {
+	return "polling_region"
+}
+
+type PollingCircle struct {
+	ID              int64 `gorm:"primaryKey"`
+	PollingRegionID int64 `gorm:"index:idx_pc_polling_region_id"`
+	Latitude        float64
+	Longitude       float64
+	RadiusKM        float64
+}
+
+func (PollingCircle) TableName() string {
+	return "polling_circle"
+}
@@ -0,0 +1,140 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package pollingregion
+
+import (
+	"maps"
+	"react-and-go/pkd/memrepo"
+	prmodel "react-and-go/pkd/pollingregion/prmodel"
+	"sort"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type PollingRegionRepo interface {
+	FindAll() []prmodel.PollingRegion
+	FindByName(name string) (prmodel.PollingRegion, bool)
+	Save(pollingRegion *prmodel.PollingRegion) error
+	Transaction(txFunc func(repo PollingRegionRepo) error) error
+}
+
+type pollingRegionDbRepo struct {
+	db *gorm.DB
+}
+
+func NewPollingRegionDbRepo(db *gorm.DB) PollingRegionRepo {
+	return &pollingRegionDbRepo{db: db}
+}
+
+func (repo *pollingRegionDbRepo) FindAll() []prmodel.PollingRegion {
+	pollingRegions := []prmodel.PollingRegion{}
+	repo.db.Preload("PollingCircles").Order("name").Find(&pollingRegions)
+	return pollingRegions
+}
+
+func (repo *pollingRegionDbRepo) FindByName(name string) (prmodel.PollingRegion, bool) {
+	var pollingRegions []prmodel.PollingRegion
+	repo.db.Where("name = ?", name).Preload("PollingCircles").Limit(1).Find(&pollingRegions)
+	if len(pollingRegions) == 0 {
+		return prmodel.PollingRegion{}, false
+	}
+	return pollingRegions[0], true
+}
+
+// the circles of the region are replaced
+func (repo *pollingRegionDbRepo) Save(pollingRegion *prmodel.PollingRegion) error {
+	if err := repo.db.Omit("PollingCircles").Save(pollingRegion).Error; err != nil {
+		return err
+	}
+	if err := repo.db.Where("polling_region_id = ?", pollingRegion.ID).Delete(&prmodel.PollingCircle{}).Error; err != nil {
+		return err
+	}
+	for index := range pollingRegion.PollingCircles {
+		pollingRegion.PollingCircles[index].ID = 0
+		pollingRegion.PollingCircles[index].PollingRegionID = pollingRegion.ID
+	}
+	if len(pollingRegion.PollingCircles) == 0 {
+		return nil
+	}
+	return repo.db.CreateInBatches(&pollingRegion.PollingCircles, 500).Error
+}
+
+func (repo *pollingRegionDbRepo) Transaction(txFunc func(repo PollingRegionRepo) error) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		return txFunc(&pollingRegionDbRepo{db: tx})
+	})
+}
+
+type pollingRegionMemRepo struct {
+	mutex          *sync.RWMutex
+	pollingRegions map[string]prmodel.PollingRegion
+	ids            *memrepo.IdSequence[int64]
+	transactor     *memrepo.Transactor
+}
+
+func NewPollingRegionMemRepo() PollingRegionRepo {
+	return &pollingRegionMemRepo{mutex: &sync.RWMutex{}, pollingRegions: make(map[string]prmodel.PollingRegion), ids: memrepo.NewIdSequence[int64](),
+		transactor: memrepo.NewTransactor()}
+}
+
+func (repo *pollingRegionMemRepo) FindAll() []prmodel.PollingRegion {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	result := []prmodel.PollingRegion{}
+	for _, myPollingRegion := range repo.pollingRegions {
+		result = append(result, myPollingRegion)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func (repo *pollingRegionMemRepo) FindByName(name string) (prmodel.PollingRegion, bool) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	myPollingRegion, ok := repo.pollingRegions[name]
+	return myPollingRegion, ok
+}
+
+func (repo *pollingRegionMemRepo) Save(pollingRegion *prmodel.PollingRegion) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	if pollingRegion.ID == 0 {
+		pollingRegion.ID = repo.ids.Next()
+	}
+	for _, myPollingRegion := range repo.pollingRegions {
+		if myPollingRegion.ID == pollingRegion.ID && myPollingRegion.Name != pollingRegion.Name {
+			delete(repo.pollingRegions, myPollingRegion.Name)
+		}
+	}
+	myPollingCircles := make([]prmodel.PollingCircle, len(pollingRegion.PollingCircles))
+	for index, myPollingCircle := range pollingRegion.PollingCircles {
+		myPollingCircle.ID = repo.ids.Next()
+		myPollingCircle.PollingRegionID = pollingRegion.ID
+		myPollingCircles[index] = myPollingCircle
+	}
+	pollingRegion.PollingCircles = myPollingCircles
+	myPollingRegion := *pollingRegion
+	myPollingRegion.PollingCircles = append([]prmodel.PollingCircle{}, myPollingCircles...)
+	repo.pollingRegions[pollingRegion.Name] = myPollingRegion
+	return nil
+}
+
+func (repo *pollingRegionMemRepo) Transaction(txFunc func(repo PollingRegionRepo) error) error {
+	return repo.transactor.Run(repo.mutex, func() func() {
+		myPollingRegions := maps.Clone(repo.pollingRegions)
+		return func() { repo.pollingRegions = myPollingRegions }
+	}, func() error { return txFunc(repo) })
+}
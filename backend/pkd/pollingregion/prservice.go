@@ -0,0 +1,203 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package pollingregion
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	prmodel "react-and-go/pkd/pollingregion/prmodel"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultIntervalMinutes = 15
+	MaxRadiusKM            = 25.0
+)
+
+var ErrRegionNotFound = errors.New("polling region not found")
+var ErrRegionExists = errors.New("polling region exists")
+
+type CircleConfig struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	RadiusKM  float64 `json:"radiusKM"`
+}
+
+// a region has circles or a geojson polygon that is covered with circles of RadiusKM
+type RegionConfig struct {
+	Name            string          `json:"name"`
+	IntervalMinutes int             `json:"intervalMinutes"`
+	Enabled         *bool           `json:"enabled"`
+	RadiusKM        float64         `json:"radiusKM"`
+	Circles         []CircleConfig  `json:"circles"`
+	GeoJson         json.RawMessage `json:"geoJson"`
+}
+
+type PollingRegionService struct {
+	pollingRegionRepo PollingRegionRepo
+	mutex             sync.Mutex
+	lastPolled        map[string]time.Time
+}
+
+func NewPollingRegionService(pollingRegionRepo PollingRegionRepo) *PollingRegionService {
+	return &PollingRegionService{pollingRegionRepo: pollingRegionRepo, lastPolled: make(map[string]time.Time)}
+}
+
+// the regions of the POLLING_REGIONS_FILE are created or updated, the enabled flag of existing regions is kept
+func (service *PollingRegionService) LoadRegionsFile() error {
+	myFilePath := strings.TrimSpace(os.Getenv("POLLING_REGIONS_FILE"))
+	if len(myFilePath) == 0 {
+		return nil
+	}
+	myFileContent, err := os.ReadFile(myFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read polling regions file %v: %v", myFilePath, err)
+	}
+	var regionConfigs []RegionConfig
+	if err := json.Unmarshal(myFileContent, &regionConfigs); err != nil {
+		return fmt.Errorf("failed to parse polling regions file %v: %v", myFilePath, err)
+	}
+	var pollingRegions []prmodel.PollingRegion
+	for _, myRegionConfig := range regionConfigs {
+		myPollingRegion, err := createPollingRegion(myRegionConfig, prmodel.SourceFile)
+		if err != nil {
+			return err
+		}
+		pollingRegions = append(pollingRegions, myPollingRegion)
+	}
+	if err := service.pollingRegionRepo.Transaction(func(repo PollingRegionRepo) error {
+		for _, myPollingRegion := range pollingRegions {
+			if myExistingRegion, ok := repo.FindByName(myPollingRegion.Name); ok {
+				myPollingRegion.ID = myExistingRegion.ID
+				myPollingRegion.Enabled = myExistingRegion.Enabled
+			}
+			if err := repo.Save(&myPollingRegion); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	log.Printf("Polling regions loaded: %v\n", len(pollingRegions))
+	return nil
+}
+
+func (service *PollingRegionService) FindAll() []prmodel.PollingRegion {
+	return service.pollingRegionRepo.FindAll()
+}
+
+func (service *PollingRegionService) AddRegion(regionConfig RegionConfig) (prmodel.PollingRegion, error) {
+	myPollingRegion, err := createPollingRegion(regionConfig, prmodel.SourceApi)
+	if err != nil {
+		return myPollingRegion, err
+	}
+	err = service.pollingRegionRepo.Transaction(func(repo PollingRegionRepo) error {
+		if _, ok := repo.FindByName(myPollingRegion.Name); ok {
+			return ErrRegionExists
+		}
+		return repo.Save(&myPollingRegion)
+	})
+	return myPollingRegion, err
+}
+
+func (service *PollingRegionService) SetEnabled(name string, enabled bool) (prmodel.PollingRegion, error) {
+	var result prmodel.PollingRegion
+	err := service.pollingRegionRepo.Transaction(func(repo PollingRegionRepo) error {
+		myPollingRegion, ok := repo.FindByName(name)
+		if !ok {
+			return ErrRegionNotFound
+		}
+		myPollingRegion.Enabled = enabled
+		result = myPollingRegion
+		return repo.Save(&result)
+	})
+	return result, err
+}
+
+// the enabled regions whose interval has passed since the last poll
+func (service *PollingRegionService) FindDueRegions(now time.Time) []prmodel.PollingRegion {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	result := []prmodel.PollingRegion{}
+	for _, myPollingRegion := range service.pollingRegionRepo.FindAll() {
+		myLastPolled, ok := service.lastPolled[myPollingRegion.Name]
+		if myPollingRegion.Enabled && (!ok || !now.Before(myLastPolled.Add(time.Duration(myPollingRegion.IntervalMinutes)*time.Minute))) {
+			result = append(result, myPollingRegion)
+		}
+	}
+	return result
+}
+
+func (service *PollingRegionService) MarkPolled(name string, polled time.Time) {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	service.lastPolled[name] = polled
+}
+
+func createPollingRegion(regionConfig RegionConfig, source string) (prmodel.PollingRegion, error) {
+	result := prmodel.PollingRegion{Name: strings.TrimSpace(regionConfig.Name), IntervalMinutes: regionConfig.IntervalMinutes, Enabled: true, Source: source}
+	if len(result.Name) == 0 || len(result.Name) > 128 {
+		return result, fmt.Errorf("invalid region name: '%v'", regionConfig.Name)
+	}
+	if result.IntervalMinutes == 0 {
+		result.IntervalMinutes = DefaultIntervalMinutes
+	}
+	if result.IntervalMinutes < 1 {
+		return result, fmt.Errorf("region %v: invalid interval: %v", result.Name, regionConfig.IntervalMinutes)
+	}
+	if regionConfig.Enabled != nil {
+		result.Enabled = *regionConfig.Enabled
+	}
+	myRadiusKM := regionConfig.RadiusKM
+	if myRadiusKM == 0 {
+		myRadiusKM = MaxRadiusKM
+	}
+	for _, myCircleConfig := range regionConfig.Circles {
+		myPollingCircle := prmodel.PollingCircle{Latitude: myCircleConfig.Latitude, Longitude: myCircleConfig.Longitude, RadiusKM: myCircleConfig.RadiusKM}
+		if myPollingCircle.RadiusKM == 0 {
+			myPollingCircle.RadiusKM = myRadiusKM
+		}
+		result.PollingCircles = append(result.PollingCircles, myPollingCircle)
+	}
+	if len(regionConfig.GeoJson) > 0 && string(regionConfig.GeoJson) != "null" {
+		if myRadiusKM <= 0 || myRadiusKM > MaxRadiusKM {
+			return result, fmt.Errorf("region %v: invalid radius: %v", result.Name, myRadiusKM)
+		}
+		myPolygons, err := parsePolygons(regionConfig.GeoJson)
+		if err != nil {
+			return result, fmt.Errorf("region %v: %v", result.Name, err)
+		}
+		myPollingCircles, err := coverWithCircles(myPolygons, myRadiusKM)
+		if err != nil {
+			return result, fmt.Errorf("region %v: %v", result.Name, err)
+		}
+		result.PollingCircles = append(result.PollingCircles, myPollingCircles...)
+	}
+	if len(result.PollingCircles) == 0 {
+		return result, fmt.Errorf("region %v: no circles or geojson polygons", result.Name)
+	}
+	for _, myPollingCircle := range result.PollingCircles {
+		if myPollingCircle.Latitude < -90 || myPollingCircle.Latitude > 90 || myPollingCircle.Longitude < -180 || myPollingCircle.Longitude > 180 ||
+			myPollingCircle.RadiusKM <= 0 || myPollingCircle.RadiusKM > MaxRadiusKM {
+			return result, fmt.Errorf("region %v: invalid circle: %v, %v radius: %v", result.Name, myPollingCircle.Latitude, myPollingCircle.Longitude,
+				myPollingCircle.RadiusKM)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,90 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package pollingregion
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFindDueRegions(t *testing.T) {
+	myService := NewPollingRegionService(NewPollingRegionMemRepo())
+	myDisabled := false
+	for _, myRegionConfig := range []RegionConfig{
+		{Name: "default", Circles: []CircleConfig{{Latitude: 53.5, Longitude: 10.0}}},
+		{Name: "hourly", IntervalMinutes: 60, Circles: []CircleConfig{{Latitude: 52.5, Longitude: 13.4, RadiusKM: 10}}},
+		{Name: "disabled", Enabled: &myDisabled, Circles: []CircleConfig{{Latitude: 48.1, Longitude: 11.6}}},
+	} {
+		if _, err := myService.AddRegion(myRegionConfig); err != nil {
+			t.Fatalf("AddRegion() error = %v", err)
+		}
+	}
+	myNow := time.Date(2023, time.May, 4, 10, 0, 0, 0, time.Local)
+	tests := []struct {
+		name      string
+		polled    []string
+		now       time.Time
+		wantNames []string
+	}{
+		{"never polled", nil, myNow, []string{"default", "hourly"}},
+		{"just polled", []string{"default", "hourly"}, myNow, []string{}},
+		{"before the interval", nil, myNow.Add(14 * time.Minute), []string{}},
+		{"default interval passed", nil, myNow.Add(DefaultIntervalMinutes * time.Minute), []string{"default"}},
+		{"polled again", []string{"default"}, myNow.Add(15 * time.Minute), []string{}},
+		{"both intervals passed", nil, myNow.Add(60 * time.Minute), []string{"default", "hourly"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, myName := range tt.polled {
+				myService.MarkPolled(myName, tt.now)
+			}
+			myPollingRegions := myService.FindDueRegions(tt.now)
+			if len(myPollingRegions) != len(tt.wantNames) {
+				t.Fatalf("FindDueRegions() = %v regions want: %v", len(myPollingRegions), tt.wantNames)
+			}
+			for index, myPollingRegion := range myPollingRegions {
+				if myPollingRegion.Name != tt.wantNames[index] {
+					t.Errorf("FindDueRegions() = %v want: %v", myPollingRegion.Name, tt.wantNames[index])
+				}
+			}
+		})
+	}
+}
+
+func TestCreatePollingRegion(t *testing.T) {
+	tests := []struct {
+		name         string
+		regionConfig RegionConfig
+		wantCircles  int
+		wantErr      bool
+	}{
+		{"circle with default radius", RegionConfig{Name: "a", Circles: []CircleConfig{{Latitude: 53.5, Longitude: 10.0}}}, 1, false},
+		{"geojson", RegionConfig{Name: "b", RadiusKM: 10, GeoJson: []byte(`{"type":"Polygon","coordinates":[[[9.9,53.5],[10.1,53.5],[10.1,53.6],[9.9,53.5]]]}`)}, 2, false},
+		{"empty name", RegionConfig{Name: " ", Circles: []CircleConfig{{Latitude: 53.5, Longitude: 10.0}}}, 0, true},
+		{"negative interval", RegionConfig{Name: "c", IntervalMinutes: -5, Circles: []CircleConfig{{Latitude: 53.5, Longitude: 10.0}}}, 0, true},
+		{"radius too large", RegionConfig{Name: "d", Circles: []CircleConfig{{Latitude: 53.5, Longitude: 10.0, RadiusKM: 30}}}, 0, true},
+		{"invalid latitude", RegionConfig{Name: "e", Circles: []CircleConfig{{Latitude: 93.5, Longitude: 10.0}}}, 0, true},
+		{"no circles", RegionConfig{Name: "f"}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			myPollingRegion, err := createPollingRegion(tt.regionConfig, "test")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("createPollingRegion() error = %v wantErr: %v", err, tt.wantErr)
+			}
+			if err == nil && (len(myPollingRegion.PollingCircles) != tt.wantCircles || myPollingRegion.IntervalMinutes != DefaultIntervalMinutes) {
+				t.Errorf("createPollingRegion() = %+v want: %v circles", myPollingRegion, tt.wantCircles)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package postcode
+
+import (
+	"react-and-go/pkd/importdiff"
+	"sort"
+	"strconv"
+)
+
+// reports the postcodes that ImportPostCodeData would insert or update, the postcodes are never removed
+func (service *PostCodeService) DiffPostCodeData(postCodeData []PostCodeData, source string) importdiff.ImportDiff {
+	postCodeLocationsMap := make(map[int32]PostCodeData)
+	for _, myPostCodeLocation := range service.postCodeRepo.FindAllPostCodeLocations(false) {
+		postCodeLocationsMap[myPostCodeLocation.PostCode] = PostCodeData{Label: myPostCodeLocation.Label, PostCode: myPostCodeLocation.PostCode,
+			Population: myPostCodeLocation.Population, SquareKM: myPostCodeLocation.SquareKM, CenterLongitude: myPostCodeLocation.CenterLongitude,
+			CenterLatitude: myPostCodeLocation.CenterLatitude}
+	}
+	diffEntries := []importdiff.DiffEntry{}
+	for _, myPostCodeData := range postCodeData {
+		oriPostCodeData, exists := postCodeLocationsMap[myPostCodeData.PostCode]
+		myFieldChanges := comparePostCodeData(oriPostCodeData, myPostCodeData)
+		if !exists {
+			diffEntries = append(diffEntries, importdiff.DiffEntry{Key: FormatPostCode(myPostCodeData.PostCode), Action: importdiff.ActionInsert,
+				Changes: myFieldChanges})
+		} else if len(myFieldChanges) > 0 {
+			diffEntries = append(diffEntries, importdiff.DiffEntry{Key: FormatPostCode(myPostCodeData.PostCode), Action: importdiff.ActionUpdate,
+				Changes: myFieldChanges})
+		}
+	}
+	sortDiffEntries(diffEntries)
+	return importdiff.NewImportDiff(importdiff.KindPostCodes, source, diffEntries)
+}
+
+// reports the postcodes with a new county or state and the counties and states that UpdateStatesCounties would create or remove
+func (service *PostCodeService) DiffStatesCounties(plzToState map[string]string, plzToCounty map[string]string, source string) importdiff.ImportDiff {
+	oldCounties, newCounties := make(map[string]bool), make(map[string]bool)
+	oldStates, newStates := make(map[string]bool), make(map[string]bool)
+	diffEntries := []importdiff.DiffEntry{}
+	for _, pcLocation := range service.postCodeRepo.FindAllPostCodeLocations(true) {
+		myPostCode := FormatPostCode(pcLocation.PostCode)
+		oldCounties[pcLocation.CountyData.County] = true
+		oldStates[pcLocation.StateData.State] = true
+		newCounties[plzToCounty[myPostCode]] = true
+		newStates[plzToState[myPostCode]] = true
+		var myFieldChanges []importdiff.FieldChange
+		if pcLocation.CountyData.County != plzToCounty[myPostCode] {
+			myFieldChanges = append(myFieldChanges, importdiff.FieldChange{Field: "County", OldValue: pcLocation.CountyData.County,
+				NewValue: plzToCounty[myPostCode]})
+		}
+		if pcLocation.StateData.State != plzToState[myPostCode] {
+			myFieldChanges = append(myFieldChanges, importdiff.FieldChange{Field: "State", OldValue: pcLocation.StateData.State,
+				NewValue: plzToState[myPostCode]})
+		}
+		if len(myFieldChanges) > 0 {
+			diffEntries = append(diffEntries, importdiff.DiffEntry{Key: myPostCode, Action: importdiff.ActionUpdate, Changes: myFieldChanges})
+		}
+	}
+	diffEntries = append(diffEntries, diffNames("county:", oldCounties, newCounties)...)
+	diffEntries = append(diffEntries, diffNames("state:", oldStates, newStates)...)
+	sortDiffEntries(diffEntries)
+	return importdiff.NewImportDiff(importdiff.KindStatesCounties, source, diffEntries)
+}
+
+func comparePostCodeData(oldData PostCodeData, newData PostCodeData) []importdiff.FieldChange {
+	var result []importdiff.FieldChange
+	compareField := func(field string, oldValue string, newValue string) {
+		if oldValue != newValue {
+			result = append(result, importdiff.FieldChange{Field: field, OldValue: oldValue, NewValue: newValue})
+		}
+	}
+	compareField("Label", oldData.Label, newData.Label)
+	compareField("Population", strconv.Itoa(int(oldData.Population)), strconv.Itoa(int(newData.Population)))
+	compareField("SquareKM", strconv.FormatFloat(float64(oldData.SquareKM), 'f', -1, 32), strconv.FormatFloat(float64(newData.SquareKM), 'f', -1, 32))
+	compareField("CenterLongitude", strconv.FormatFloat(oldData.CenterLongitude, 'f', 6, 64), strconv.FormatFloat(newData.CenterLongitude, 'f', 6, 64))
+	compareField("CenterLatitude", strconv.FormatFloat(oldData.CenterLatitude, 'f', 6, 64), strconv.FormatFloat(newData.CenterLatitude, 'f', 6, 64))
+	return result
+}
+
+// the empty names are ignored
+func diffNames(keyPrefix string, oldNames map[string]bool, newNames map[string]bool) []importdiff.DiffEntry {
+	result := []importdiff.DiffEntry{}
+	for myName := range newNames {
+		if len(myName) > 0 && !oldNames[myName] {
+			result = append(result, importdiff.DiffEntry{Key: keyPrefix + myName, Action: importdiff.ActionInsert})
+		}
+	}
+	for myName := range oldNames {
+		if len(myName) > 0 && !newNames[myName] {
+			result = append(result, importdiff.DiffEntry{Key: keyPrefix + myName, Action: importdiff.ActionRemove})
+		}
+	}
+	return result
+}
+
+func sortDiffEntries(diffEntries []importdiff.DiffEntry) {
+	sort.SliceStable(diffEntries, func(i, j int) bool {
+		return diffEntries[i].Key < diffEntries[j].Key
+	})
+}
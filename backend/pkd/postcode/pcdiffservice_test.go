@@ -0,0 +1,109 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package postcode
+
+import (
+	"react-and-go/pkd/importdiff"
+	"react-and-go/pkd/postcode/pcmodel"
+	"testing"
+)
+
+func newTestPostCodeService() *PostCodeService {
+	myRepo := NewPostCodeMemRepo()
+	myRepo.SavePostCodeLocation(&pcmodel.PostCodeLocation{Label: "Berlin", PostCode: 10115, Population: 100, SquareKM: 2.5, CenterLongitude: 13.38,
+		CenterLatitude: 52.53, StateData: pcmodel.StateData{State: "Berlin"}, CountyData: pcmodel.CountyData{County: "Berlin"}})
+	myRepo.SavePostCodeLocation(&pcmodel.PostCodeLocation{Label: "Hamburg", PostCode: 20095, Population: 200, SquareKM: 1.5, CenterLongitude: 10.0,
+		CenterLatitude: 53.55, StateData: pcmodel.StateData{State: "Hamburg"}, CountyData: pcmodel.CountyData{County: "Hamburg"}})
+	return NewPostCodeService(myRepo)
+}
+
+func createTestPostCodeData(population int32) []PostCodeData {
+	return []PostCodeData{{Label: "Berlin", PostCode: 10115, Population: population, SquareKM: 2.5, CenterLongitude: 13.38, CenterLatitude: 52.53},
+		{Label: "Hamburg", PostCode: 20095, Population: 200, SquareKM: 1.5, CenterLongitude: 10.0, CenterLatitude: 53.55}}
+}
+
+func TestDiffPostCodeData(t *testing.T) {
+	tests := []struct {
+		name         string
+		postCodeData []PostCodeData
+		wantEntries  []importdiff.DiffEntry
+	}{
+		{"unchanged", createTestPostCodeData(100), []importdiff.DiffEntry{}},
+		{"population changed", createTestPostCodeData(150), []importdiff.DiffEntry{{Key: "10115", Action: importdiff.ActionUpdate,
+			Changes: []importdiff.FieldChange{{Field: "Population", OldValue: "100", NewValue: "150"}}}}},
+		{"new postcode", append(createTestPostCodeData(100), PostCodeData{Label: "Mitte", PostCode: 1067, Population: 10, SquareKM: 1,
+			CenterLongitude: 13.7, CenterLatitude: 51.05}), []importdiff.DiffEntry{{Key: "01067", Action: importdiff.ActionInsert}}},
+		{"missing postcode is not removed", createTestPostCodeData(100)[:1], []importdiff.DiffEntry{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			myImportDiff := newTestPostCodeService().DiffPostCodeData(tt.postCodeData, "test.csv")
+			if len(myImportDiff.Entries) != len(tt.wantEntries) {
+				t.Fatalf("DiffPostCodeData() = %+v want: %+v", myImportDiff.Entries, tt.wantEntries)
+			}
+			for index, myDiffEntry := range myImportDiff.Entries {
+				if myDiffEntry.Key != tt.wantEntries[index].Key || myDiffEntry.Action != tt.wantEntries[index].Action {
+					t.Errorf("DiffPostCodeData() entry = %+v want: %+v", myDiffEntry, tt.wantEntries[index])
+				}
+				//the changes of an insert are all fields
+				if tt.wantEntries[index].Changes != nil && (len(myDiffEntry.Changes) != len(tt.wantEntries[index].Changes) ||
+					myDiffEntry.Changes[0] != tt.wantEntries[index].Changes[0]) {
+					t.Errorf("DiffPostCodeData() changes = %+v want: %+v", myDiffEntry.Changes, tt.wantEntries[index].Changes)
+				}
+			}
+		})
+	}
+}
+
+// a dry run diff is outdated if the values changed since the dry run even with the same keys
+func TestDiffPostCodeDataOutdated(t *testing.T) {
+	myService := newTestPostCodeService()
+	myImportDiff := myService.DiffPostCodeData(createTestPostCodeData(150), "test.csv")
+	if !myImportDiff.Matches(myService.DiffPostCodeData(createTestPostCodeData(150), "test.csv")) {
+		t.Fatalf("Matches() = false for an unchanged repo")
+	}
+	myService.ImportPostCodeData(createTestPostCodeData(120))
+	if myImportDiff.Matches(myService.DiffPostCodeData(createTestPostCodeData(150), "test.csv")) {
+		t.Errorf("Matches() = true after the population changed")
+	}
+	myService.ImportPostCodeData(createTestPostCodeData(150))
+	if myEntries := myService.DiffPostCodeData(createTestPostCodeData(150), "test.csv").Entries; len(myEntries) != 0 {
+		t.Errorf("DiffPostCodeData() after the import = %+v want: no entries", myEntries)
+	}
+}
+
+func TestDiffStatesCounties(t *testing.T) {
+	tests := []struct {
+		name        string
+		plzToState  map[string]string
+		plzToCounty map[string]string
+		wantEntries []importdiff.DiffEntry
+	}{
+		{"unchanged", map[string]string{"10115": "Berlin", "20095": "Hamburg"}, map[string]string{"10115": "Berlin", "20095": "Hamburg"},
+			[]importdiff.DiffEntry{}},
+		{"new county", map[string]string{"10115": "Berlin", "20095": "Hamburg"}, map[string]string{"10115": "Berlin", "20095": "Altona"},
+			[]importdiff.DiffEntry{{Key: "20095", Action: importdiff.ActionUpdate, Changes: []importdiff.FieldChange{{Field: "County", OldValue: "Hamburg", NewValue: "Altona"}}},
+				{Key: "county:Altona", Action: importdiff.ActionInsert}, {Key: "county:Hamburg", Action: importdiff.ActionRemove}}},
+		{"merged state", map[string]string{"10115": "Berlin", "20095": "Berlin"}, map[string]string{"10115": "Berlin", "20095": "Hamburg"},
+			[]importdiff.DiffEntry{{Key: "20095", Action: importdiff.ActionUpdate, Changes: []importdiff.FieldChange{{Field: "State", OldValue: "Hamburg", NewValue: "Berlin"}}},
+				{Key: "state:Hamburg", Action: importdiff.ActionRemove}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			myImportDiff := newTestPostCodeService().DiffStatesCounties(tt.plzToState, tt.plzToCounty, "test.csv")
+			if !myImportDiff.Matches(importdiff.NewImportDiff(importdiff.KindStatesCounties, "test.csv", tt.wantEntries)) {
+				t.Errorf("DiffStatesCounties() = %+v want: %+v", myImportDiff.Entries, tt.wantEntries)
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package postcode
+
+import (
+	"fmt"
+	"maps"
+	"react-and-go/pkd/memrepo"
+	pcmodel "react-and-go/pkd/postcode/pcmodel"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PostCodeRepo interface {
+	FindLocation(locationStr string) []pcmodel.PostCodeLocation
+	FindAllPostCodeLocations(withStateCounty bool) []pcmodel.PostCodeLocation
+	FindByPostCodes(postCodes []int) []pcmodel.PostCodeLocation
+	SavePostCodeLocation(postCodeLocation *pcmodel.PostCodeLocation) error
+	SaveStateData(stateData *pcmodel.StateData) error
+	SaveCountyData(countyData *pcmodel.CountyData) error
+	DeleteEmptyStatesCounties()
+	SaveCountyTimeSlot(countyTimeSlot *pcmodel.CountyTimeSlot) error
+	DeleteAllCountyTimeSlots()
+	FindCountyTimeSlotsByCountyDataId(countyDataId uint) []pcmodel.CountyTimeSlot
+	Transaction(txFunc func(repo PostCodeRepo) error) error
+}
+
+type postCodeDbRepo struct {
+	db *gorm.DB
+}
+
+func NewPostCodeDbRepo(db *gorm.DB) PostCodeRepo {
+	return &postCodeDbRepo{db: db}
+}
+
+func (repo *postCodeDbRepo) FindLocation(locationStr string) []pcmodel.PostCodeLocation {
+	result := []pcmodel.PostCodeLocation{}
+	repo.db.Where("lower(label) like ?", fmt.Sprintf("%%%v%%", strings.ToLower(strings.TrimSpace(locationStr)))).Limit(20).Find(&result)
+	return result
+}
+
+func (repo *postCodeDbRepo) FindAllPostCodeLocations(withStateCounty bool) []pcmodel.PostCodeLocation {
+	var postCodeLocations []pcmodel.PostCodeLocation
+	if withStateCounty {
+		repo.db.Preload("StateData").Preload("CountyData").Find(&postCodeLocations)
+	} else {
+		repo.db.Find(&postCodeLocations)
+	}
+	return postCodeLocations
+}
+
+func (repo *postCodeDbRepo) FindByPostCodes(postCodes []int) []pcmodel.PostCodeLocation {
+	var postCodeLocations []pcmodel.PostCodeLocation
+	repo.db.Where("post_code in ?", postCodes).Preload("StateData").Preload("CountyData").Find(&postCodeLocations)
+	return postCodeLocations
+}
+
+func (repo *postCodeDbRepo) SavePostCodeLocation(postCodeLocation *pcmodel.PostCodeLocation) error {
+	return repo.db.Save(postCodeLocation).Error
+}
+
+func (repo *postCodeDbRepo) SaveStateData(stateData *pcmodel.StateData) error {
+	return repo.db.Save(stateData).Error
+}
+
+func (repo *postCodeDbRepo) SaveCountyData(countyData *pcmodel.CountyData) error {
+	return repo.db.Save(countyData).Error
+}
+
+func (repo *postCodeDbRepo) DeleteEmptyStatesCounties() {
+	myCountyData := pcmodel.CountyData{}
+	repo.db.Where("county = ? or county is null", "").Delete(&myCountyData)
+	myStateData := pcmodel.StateData{}
+	repo.db.Where("state = ? or state is null", "").Delete(&myStateData)
+}
+
+func (repo *postCodeDbRepo) SaveCountyTimeSlot(countyTimeSlot *pcmodel.CountyTimeSlot) error {
+	return repo.db.Save(countyTimeSlot).Error
+}
+
+func (repo *postCodeDbRepo) DeleteAllCountyTimeSlots() {
+	repo.db.Unscoped().Where("1=1").Delete(&pcmodel.CountyTimeSlot{})
+}
+
+func (repo *postCodeDbRepo) FindCountyTimeSlotsByCountyDataId(countyDataId uint) []pcmodel.CountyTimeSlot {
+	var countyTimeSlots []pcmodel.CountyTimeSlot
+	repo.db.Where("county_data_id = ?", countyDataId).Order("start_date").Preload("CountyData").Find(&countyTimeSlots)
+	return countyTimeSlots
+}
+
+func (repo *postCodeDbRepo) Transaction(txFunc func(repo PostCodeRepo) error) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		return txFunc(&postCodeDbRepo{db: tx})
+	})
+}
+
+type postCodeMemRepo struct {
+	mutex             *sync.RWMutex
+	postCodeLocations map[uint]pcmodel.PostCodeLocation
+	stateDatas        map[uint]pcmodel.StateData
+	countyDatas       map[uint]pcmodel.CountyData
+	countyTimeSlots   map[uint]pcmodel.CountyTimeSlot
+	ids               *memrepo.IdSequence[uint]
+	transactor        *memrepo.Transactor
+}
+
+func NewPostCodeMemRepo() PostCodeRepo {
+	return &postCodeMemRepo{mutex: &sync.RWMutex{}, postCodeLocations: make(map[uint]pcmodel.PostCodeLocation), stateDatas: make(map[uint]pcmodel.StateData),
+		countyDatas: make(map[uint]pcmodel.CountyData), countyTimeSlots: make(map[uint]pcmodel.CountyTimeSlot), ids: memrepo.NewIdSequence[uint](),
+		transactor: memrepo.NewTransactor()}
+}
+
+func (repo *postCodeMemRepo) FindLocation(locationStr string) []pcmodel.PostCodeLocation {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	result := []pcmodel.PostCodeLocation{}
+	for _, myPostCodeLocation := range repo.sortedPostCodeLocations() {
+		if strings.Contains(strings.ToLower(myPostCodeLocation.Label), strings.ToLower(strings.TrimSpace(locationStr))) && len(result) < 20 {
+			result = append(result, myPostCodeLocation)
+		}
+	}
+	return result
+}
+
+func (repo *postCodeMemRepo) FindAllPostCodeLocations(withStateCounty bool) []pcmodel.PostCodeLocation {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	result := []pcmodel.PostCodeLocation{}
+	for _, myPostCodeLocation := range repo.sortedPostCodeLocations() {
+		if withStateCounty {
+			myPostCodeLocation = repo.addStateCounty(myPostCodeLocation)
+		}
+		result = append(result, myPostCodeLocation)
+	}
+	return result
+}
+
+func (repo *postCodeMemRepo) FindByPostCodes(postCodes []int) []pcmodel.PostCodeLocation {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	postCodeMap := make(map[int32]bool)
+	for _, myPostCode := range postCodes {
+		postCodeMap[int32(myPostCode)] = true
+	}
+	result := []pcmodel.PostCodeLocation{}
+	for _, myPostCodeLocation := range repo.sortedPostCodeLocations() {
+		if postCodeMap[myPostCodeLocation.PostCode] {
+			result = append(result, repo.addStateCounty(myPostCodeLocation))
+		}
+	}
+	return result
+}
+
+func (repo *postCodeMemRepo) SavePostCodeLocation(postCodeLocation *pcmodel.PostCodeLocation) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	//the associations are created if they are new like gorm does it
+	if postCodeLocation.StateData.ID == 0 && len(postCodeLocation.StateData.State) > 0 {
+		repo.saveStateData(&postCodeLocation.StateData)
+		postCodeLocation.StateDataID = postCodeLocation.StateData.ID
+	}
+	if postCodeLocation.CountyData.ID == 0 && len(postCodeLocation.CountyData.County) > 0 {
+		repo.saveCountyData(&postCodeLocation.CountyData)
+		postCodeLocation.CountyDataID = postCodeLocation.CountyData.ID
+	}
+	if postCodeLocation.ID == 0 {
+		postCodeLocation.ID = repo.ids.Next()
+		postCodeLocation.CreatedAt = time.Now()
+	}
+	postCodeLocation.UpdatedAt = time.Now()
+	repo.postCodeLocations[postCodeLocation.ID] = *postCodeLocation
+	return nil
+}
+
+func (repo *postCodeMemRepo) SaveStateData(stateData *pcmodel.StateData) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	repo.saveStateData(stateData)
+	return nil
+}
+
+func (repo *postCodeMemRepo) SaveCountyData(countyData *pcmodel.CountyData) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	repo.saveCountyData(countyData)
+	return nil
+}
+
+func (repo *postCodeMemRepo) DeleteEmptyStatesCounties() {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	for key, myCountyData := range repo.countyDatas {
+		if len(myCountyData.County) == 0 {
+			delete(repo.countyDatas, key)
+		}
+	}
+	for key, myStateData := range repo.stateDatas {
+		if len(myStateData.State) == 0 {
+			delete(repo.stateDatas, key)
+		}
+	}
+}
+
+func (repo *postCodeMemRepo) SaveCountyTimeSlot(countyTimeSlot *pcmodel.CountyTimeSlot) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	if countyTimeSlot.ID == 0 {
+		countyTimeSlot.ID = repo.ids.Next()
+		countyTimeSlot.CreatedAt = time.Now()
+	}
+	countyTimeSlot.UpdatedAt = time.Now()
+	repo.countyTimeSlots[countyTimeSlot.ID] = *countyTimeSlot
+	return nil
+}
+
+func (repo *postCodeMemRepo) DeleteAllCountyTimeSlots() {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	repo.countyTimeSlots = make(map[uint]pcmodel.CountyTimeSlot)
+}
+
+func (repo *postCodeMemRepo) FindCountyTimeSlotsByCountyDataId(countyDataId uint) []pcmodel.CountyTimeSlot {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	result := []pcmodel.CountyTimeSlot{}
+	for _, myCountyTimeSlot := range repo.countyTimeSlots {
+		if myCountyTimeSlot.CountyDataID == countyDataId {
+			myCountyTimeSlot.CountyData = repo.countyDatas[countyDataId]
+			result = append(result, myCountyTimeSlot)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].StartDate.Before(result[j].StartDate)
+	})
+	return result
+}
+
+func (repo *postCodeMemRepo) Transaction(txFunc func(repo PostCodeRepo) error) error {
+	return repo.transactor.Run(repo.mutex, func() func() {
+		myPostCodeLocations := maps.Clone(repo.postCodeLocations)
+		myStateDatas := maps.Clone(repo.stateDatas)
+		myCountyDatas := maps.Clone(repo.countyDatas)
+		myCountyTimeSlots := maps.Clone(repo.countyTimeSlots)
+		return func() {
+			repo.postCodeLocations = myPostCodeLocations
+			repo.stateDatas = myStateDatas
+			repo.countyDatas = myCountyDatas
+			repo.countyTimeSlots = myCountyTimeSlots
+		}
+	}, func() error { return txFunc(repo) })
+}
+
+func (repo *postCodeMemRepo) saveStateData(stateData *pcmodel.StateData) {
+	if stateData.ID == 0 {
+		stateData.ID = repo.ids.Next()
+		stateData.CreatedAt = time.Now()
+	}
+	stateData.UpdatedAt = time.Now()
+	myStateData := *stateData
+	myStateData.PostCodeLocations = nil
+	repo.stateDatas[stateData.ID] = myStateData
+}
+
+func (repo *postCodeMemRepo) saveCountyData(countyData *pcmodel.CountyData) {
+	if countyData.ID == 0 {
+		countyData.ID = repo.ids.Next()
+		countyData.CreatedAt = time.Now()
+	}
+	countyData.UpdatedAt = time.Now()
+	myCountyData := *countyData
+	myCountyData.PostCodeLocations = nil
+	myCountyData.CountyTimeSlots = nil
+	repo.countyDatas[countyData.ID] = myCountyData
+}
+
+func (repo *postCodeMemRepo) addStateCounty(postCodeLocation pcmodel.PostCodeLocation) pcmodel.PostCodeLocation {
+	postCodeLocation.StateData = repo.stateDatas[postCodeLocation.StateDataID]
+	postCodeLocation.CountyData = repo.countyDatas[postCodeLocation.CountyDataID]
+	return postCodeLocation
+}
+
+func (repo *postCodeMemRepo) sortedPostCodeLocations() []pcmodel.PostCodeLocation {
+	result := []pcmodel.PostCodeLocation{}
+	for _, myPostCodeLocation := range repo.postCodeLocations {
+		result = append(result, myPostCodeLocation)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
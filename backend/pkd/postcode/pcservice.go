@@ -13,14 +13,9 @@
 package postcode
 
 import (
-	"fmt"
 	"log"
-	"react-and-go/pkd/database"
 	pcmodel "react-and-go/pkd/postcode/pcmodel"
 	"strconv"
-	"strings"
-
-	"gorm.io/gorm"
 )
 
 type GasPriceAvgs struct {
@@ -44,24 +39,28 @@ type PostCodeData struct {
 	CenterLatitude  float64
 }
 
-func FindLocation(locationStr string) []pcmodel.PostCodeLocation {
-	result := []pcmodel.PostCodeLocation{}
-	database.DB.Where("lower(label) like ?", fmt.Sprintf("%%%v%%", strings.ToLower(strings.TrimSpace(locationStr)))).Limit(20).Find(&result)
-	//log.Printf("Select: %v failed. %v", fmt.Sprintf("%%%v%%", strings.ToLower(strings.TrimSpace(locationStr))), err)
-	return result
+type PostCodeService struct {
+	postCodeRepo PostCodeRepo
+}
+
+func NewPostCodeService(postCodeRepo PostCodeRepo) *PostCodeService {
+	return &PostCodeService{postCodeRepo: postCodeRepo}
 }
 
-func ImportPostCodeData(postCodeData []PostCodeData) {
+func (service *PostCodeService) FindLocation(locationStr string) []pcmodel.PostCodeLocation {
+	return service.postCodeRepo.FindLocation(locationStr)
+}
+
+func (service *PostCodeService) ImportPostCodeData(postCodeData []PostCodeData) {
 	postCodeLocations := mapToPostCodeLocation(postCodeData)
-	var oriPostCodeLocations []pcmodel.PostCodeLocation
-	database.DB.Find(&oriPostCodeLocations)
+	oriPostCodeLocations := service.postCodeRepo.FindAllPostCodeLocations(false)
 	var myCountyData pcmodel.CountyData
 	var myStateData pcmodel.StateData
 	postCodeLocationsMap := make(map[int32]pcmodel.PostCodeLocation)
 	for _, oriPostCodeLocation := range oriPostCodeLocations {
 		postCodeLocationsMap[oriPostCodeLocation.PostCode] = oriPostCodeLocation
 	}
-	database.DB.Transaction(func(tx *gorm.DB) error {
+	service.postCodeRepo.Transaction(func(repo PostCodeRepo) error {
 		for _, postCodeLocation := range postCodeLocations {
 			oriPostCodeLocation, exists := postCodeLocationsMap[postCodeLocation.PostCode]
 			if exists {
@@ -71,15 +70,15 @@ func ImportPostCodeData(postCodeData []PostCodeData) {
 				oriPostCodeLocation.SquareKM = postCodeLocation.SquareKM
 				oriPostCodeLocation.CenterLongitude = postCodeLocation.CenterLongitude
 				oriPostCodeLocation.CenterLatitude = postCodeLocation.CenterLatitude
-				tx.Save(&oriPostCodeLocation)
+				repo.SavePostCodeLocation(&oriPostCodeLocation)
 			} else {
-				tx.Save(&myCountyData)
-				tx.Save(&myStateData)
+				repo.SaveCountyData(&myCountyData)
+				repo.SaveStateData(&myStateData)
 				postCodeLocation.CountyData = myCountyData
 				postCodeLocation.CountyDataID = myCountyData.ID
 				postCodeLocation.StateData = myStateData
 				postCodeLocation.StateDataID = myStateData.ID
-				tx.Save(&postCodeLocation)
+				repo.SavePostCodeLocation(&postCodeLocation)
 			}
 		}
 		return nil
@@ -87,16 +86,15 @@ func ImportPostCodeData(postCodeData []PostCodeData) {
 	log.Printf("PostCodeLocations saved: %v\n", len(postCodeLocations))
 }
 
-func UpdateStatesCounties(plzToState map[string]string, plzToCounty map[string]string) {
-	var pcLocations []pcmodel.PostCodeLocation
-	database.DB.Preload("StateData").Preload("CountyData").Find(&pcLocations)
+func (service *PostCodeService) UpdateStatesCounties(plzToState map[string]string, plzToCounty map[string]string) {
+	pcLocations := service.postCodeRepo.FindAllPostCodeLocations(true)
 	stateMap := make(map[string]*pcmodel.StateData)
 	countyMap := make(map[string]*pcmodel.CountyData)
 	//log.Printf("%d pcLocations.", len(pcLocations))
 	//log.Printf("%s, %s", plzToCounty[FormatPostCode(1159)], plzToState[FormatPostCode(1159)])
-	database.DB.Transaction(func(tx *gorm.DB) error {
+	service.postCodeRepo.Transaction(func(repo PostCodeRepo) error {
 		for _, pcLocation := range pcLocations {
-			if &pcLocation.CountyData.County == nil || pcLocation.CountyData.County == "" {
+			if pcLocation.CountyData.County == "" {
 				myCountyData := pcmodel.CountyData{}
 				if mapValue, ok := countyMap[plzToCounty[FormatPostCode(pcLocation.PostCode)]]; ok {
 					myCountyData = *mapValue
@@ -104,11 +102,11 @@ func UpdateStatesCounties(plzToState map[string]string, plzToCounty map[string]s
 					countyMap[plzToCounty[FormatPostCode(pcLocation.PostCode)]] = &myCountyData
 					myCountyData.County = plzToCounty[FormatPostCode(pcLocation.PostCode)]
 				}
-				tx.Save(&myCountyData)
+				repo.SaveCountyData(&myCountyData)
 				pcLocation.CountyData = myCountyData
 				pcLocation.CountyDataID = myCountyData.ID
 			}
-			if &pcLocation.StateData.State == nil || pcLocation.StateData.State == "" {
+			if pcLocation.StateData.State == "" {
 				myStateData := pcmodel.StateData{}
 				if myMapValue, ok := stateMap[plzToState[FormatPostCode(pcLocation.PostCode)]]; ok {
 					myStateData = *myMapValue
@@ -116,38 +114,32 @@ func UpdateStatesCounties(plzToState map[string]string, plzToCounty map[string]s
 					stateMap[plzToState[FormatPostCode(pcLocation.PostCode)]] = &myStateData
 					myStateData.State = plzToState[FormatPostCode(pcLocation.PostCode)]
 				}
-				tx.Save(&myStateData)
+				repo.SaveStateData(&myStateData)
 				pcLocation.StateData = myStateData
 				pcLocation.StateDataID = myStateData.ID
 			}
 			pcLocation.CountyData.County = plzToCounty[FormatPostCode(pcLocation.PostCode)]
 			pcLocation.StateData.State = plzToState[FormatPostCode(pcLocation.PostCode)]
-			tx.Save(&pcLocation)
+			repo.SavePostCodeLocation(&pcLocation)
 		}
-		myCountyData := pcmodel.CountyData{}
-		tx.Where("county = ? or county is null", "").Delete(&myCountyData)
-		myStateData := pcmodel.StateData{}
-		tx.Where("state = ? or state is null", "").Delete(&myStateData)
+		repo.DeleteEmptyStatesCounties()
 		return nil
 	})
 	log.Printf("UpdateStatesCounties updated: %v\n", len(pcLocations))
 }
 
-func FindByPlzs(plzs []string) *[]pcmodel.PostCodeLocation {
-	var pcLocations []pcmodel.PostCodeLocation
-	var plzInts = plzsToPlzInts(plzs)
-	database.DB.Where("post_code in ?", plzInts).Preload("StateData").Preload("CountyData").Find(&pcLocations)
+func (service *PostCodeService) FindByPlzs(plzs []string) *[]pcmodel.PostCodeLocation {
+	pcLocations := service.postCodeRepo.FindByPostCodes(plzsToPlzInts(plzs))
 	return &pcLocations
 }
 
-func FindAvgsByPostcode(postcode string) GasPriceAvgs {
+func (service *PostCodeService) FindAvgsByPostcode(postcode string) GasPriceAvgs {
 	var gasPriceAvgs GasPriceAvgs
 	var postCodeLocation pcmodel.PostCodeLocation
-	database.DB.Transaction(func(tx *gorm.DB) error {
-		intPostCode, _ := strconv.Atoi(postcode)
-		tx.Where("post_code = ?", intPostCode).Preload("StateData").Preload("CountyData").Find(&postCodeLocation)
-		return nil
-	})
+	intPostCode, _ := strconv.Atoi(postcode)
+	if postCodeLocations := service.postCodeRepo.FindByPostCodes([]int{intPostCode}); len(postCodeLocations) > 0 {
+		postCodeLocation = postCodeLocations[0]
+	}
 	gasPriceAvgs.Postcode = postcode
 	gasPriceAvgs.County = postCodeLocation.CountyData.County
 	gasPriceAvgs.State = postCodeLocation.StateData.State
@@ -173,20 +165,13 @@ func plzsToPlzInts(plzs []string) []int {
 	return plzInts
 }
 
-func FindCountyTimeSlotByPostcode(postcodeStr string) []pcmodel.CountyTimeSlot {
+func (service *PostCodeService) FindCountyTimeSlotByPostcode(postcodeStr string) []pcmodel.CountyTimeSlot {
 	var myCountyTimeSlots []pcmodel.CountyTimeSlot
 	myPostcode, err := strconv.Atoi(postcodeStr)
 	if err == nil {
-		var myPostCodeLocations []pcmodel.PostCodeLocation
-		database.DB.Transaction(func(tx *gorm.DB) error {
-			tx.Where("post_code = ?", myPostcode).Find(&myPostCodeLocations)
-			return nil
-		})
+		myPostCodeLocations := service.postCodeRepo.FindByPostCodes([]int{myPostcode})
 		if len(myPostCodeLocations) > 0 {
-			database.DB.Transaction(func(tx *gorm.DB) error {
-				tx.Where("county_data_id = ?", myPostCodeLocations[0].CountyDataID).Order("start_date").Preload("CountyData").Find(&myCountyTimeSlots)
-				return nil
-			})
+			myCountyTimeSlots = service.postCodeRepo.FindCountyTimeSlotsByCountyDataId(myPostCodeLocations[0].CountyDataID)
 		}
 	}
 	return myCountyTimeSlots
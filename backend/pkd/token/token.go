@@ -126,6 +126,14 @@ func CheckToken(c *gin.Context) {
 	c.Next()
 }
 
+// browsers can not set the authorization header on websocket requests, the token is accepted as query parameter
+func CheckTokenQueryParam(c *gin.Context) {
+	if tokenStr := strings.TrimSpace(c.Query("token")); len(strings.TrimSpace(c.Request.Header.Get(HeaderAuth))) == 0 && len(tokenStr) > 0 {
+		c.Request.Header.Set(HeaderAuth, HeaderBearer+" "+tokenStr)
+	}
+	CheckToken(c)
+}
+
 func LoggedOutUser(username string, uuid string) bool {
 	result := false
 	for _, myLoggedOutUser := range LoggedOutUsers {
@@ -0,0 +1,154 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package workpool
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type WorkPoolMetrics struct {
+	Name            string
+	Workers         int
+	QueueSize       int
+	QueueDepth      int
+	Busy            int64
+	Submitted       int64
+	Processed       int64
+	Failed          int64
+	Blocked         int64
+	LastLagMs       int64
+	MaxLagMs        int64
+	LastProcessedAt *time.Time `json:",omitempty"`
+}
+
+type workTask struct {
+	queued time.Time
+	run    func() error
+}
+
+// a fixed number of workers processes the tasks of a bounded queue, Submit blocks while the queue is full
+type WorkPool struct {
+	name            string
+	workers         int
+	tasks           chan workTask
+	busy            atomic.Int64
+	submitted       atomic.Int64
+	processed       atomic.Int64
+	failed          atomic.Int64
+	blocked         atomic.Int64
+	lastLag         atomic.Int64
+	maxLag          atomic.Int64
+	lastProcessedAt atomic.Int64
+	stopOnce        sync.Once
+	waitGroup       sync.WaitGroup
+}
+
+func NewWorkPool(name string, workers int, queueSize int) *WorkPool {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 1 {
+		queueSize = 1
+	}
+	result := &WorkPool{name: name, workers: workers, tasks: make(chan workTask, queueSize)}
+	for index := 0; index < workers; index++ {
+		result.waitGroup.Add(1)
+		go result.work()
+	}
+	log.Printf("WorkPool %v started with %v workers and queue size %v.\n", name, workers, queueSize)
+	return result
+}
+
+// the backpressure of a full queue reaches the caller, a task that returns an error or panics is counted as failed
+func (pool *WorkPool) Submit(task func() error) {
+	myTask := workTask{queued: time.Now(), run: task}
+	pool.submitted.Add(1)
+	select {
+	case pool.tasks <- myTask:
+	default:
+		pool.blocked.Add(1)
+		pool.tasks <- myTask
+	}
+}
+
+// the queued tasks are processed before the workers stop
+func (pool *WorkPool) Stop() {
+	pool.stopOnce.Do(func() {
+		close(pool.tasks)
+	})
+	pool.waitGroup.Wait()
+}
+
+func (pool *WorkPool) Metrics() WorkPoolMetrics {
+	result := WorkPoolMetrics{Name: pool.name, Workers: pool.workers, QueueSize: cap(pool.tasks), QueueDepth: len(pool.tasks), Busy: pool.busy.Load(),
+		Submitted: pool.submitted.Load(), Processed: pool.processed.Load(), Failed: pool.failed.Load(), Blocked: pool.blocked.Load(), LastLagMs: pool.lastLag.Load(), MaxLagMs: pool.maxLag.Load()}
+	if myLastProcessedAt := pool.lastProcessedAt.Load(); myLastProcessedAt > 0 {
+		myTime := time.UnixMilli(myLastProcessedAt)
+		result.LastProcessedAt = &myTime
+	}
+	return result
+}
+
+// the lag is the time a task waited in the queue
+func (pool *WorkPool) work() {
+	defer pool.waitGroup.Done()
+	for myTask := range pool.tasks {
+		myLag := time.Since(myTask.queued).Milliseconds()
+		pool.lastLag.Store(myLag)
+		for {
+			myMaxLag := pool.maxLag.Load()
+			if myLag <= myMaxLag || pool.maxLag.CompareAndSwap(myMaxLag, myLag) {
+				break
+			}
+		}
+		pool.busy.Add(1)
+		if err := pool.runTask(myTask); err != nil {
+			pool.failed.Add(1)
+			log.Printf("WorkPool %v task failed: %v\n", pool.name, err)
+		}
+		pool.busy.Add(-1)
+		pool.processed.Add(1)
+		pool.lastProcessedAt.Store(time.Now().UnixMilli())
+	}
+}
+
+// a panicking task must not stop the worker
+func (pool *WorkPool) runTask(task workTask) (err error) {
+	defer func() {
+		if myPanic := recover(); myPanic != nil {
+			err = fmt.Errorf("panic: %v", myPanic)
+		}
+	}()
+	return task.run()
+}
+
+// reads a positive int from the env variable or returns the default value
+func EnvInt(name string, defaultValue int) int {
+	myValue := strings.TrimSpace(os.Getenv(name))
+	if myValue == "" {
+		return defaultValue
+	}
+	result, err := strconv.Atoi(myValue)
+	if err != nil || result < 1 {
+		log.Printf("Invalid value for %v: %v, using %v\n", name, myValue, defaultValue)
+		return defaultValue
+	}
+	return result
+}
@@ -0,0 +1,77 @@
+/*
+  - Copyright 2022 Sven Loesekann
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+package workpool
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWorkPoolMetrics(t *testing.T) {
+	myTests := []struct {
+		name          string
+		tasks         []func() error
+		wantProcessed int64
+		wantFailed    int64
+	}{
+		{"no tasks", nil, 0, 0},
+		{"successful tasks", []func() error{func() error { return nil }, func() error { return nil }}, 2, 0},
+		{"failed task", []func() error{func() error { return nil }, func() error { return errors.New("failed") }}, 2, 1},
+		{"panicking task", []func() error{func() error { panic("failed") }, func() error { return nil }}, 2, 1},
+	}
+	for _, myTest := range myTests {
+		t.Run(myTest.name, func(t *testing.T) {
+			myPool := NewWorkPool(myTest.name, 2, 1)
+			for _, myTask := range myTest.tasks {
+				myPool.Submit(myTask)
+			}
+			myPool.Stop()
+			myMetrics := myPool.Metrics()
+			if myMetrics.Submitted != int64(len(myTest.tasks)) {
+				t.Errorf("Submitted = %v, want %v", myMetrics.Submitted, len(myTest.tasks))
+			}
+			if myMetrics.Processed != myTest.wantProcessed {
+				t.Errorf("Processed = %v, want %v", myMetrics.Processed, myTest.wantProcessed)
+			}
+			if myMetrics.Failed != myTest.wantFailed {
+				t.Errorf("Failed = %v, want %v", myMetrics.Failed, myTest.wantFailed)
+			}
+			if myMetrics.Busy != 0 || myMetrics.QueueDepth != 0 {
+				t.Errorf("Busy = %v QueueDepth = %v, want 0", myMetrics.Busy, myMetrics.QueueDepth)
+			}
+		})
+	}
+}
+
+func TestEnvInt(t *testing.T) {
+	myTests := []struct {
+		name  string
+		value string
+		want  int
+	}{
+		{"unset", "", 5},
+		{"valid", "3", 3},
+		{"blanks", " 7 ", 7},
+		{"zero", "0", 5},
+		{"negative", "-2", 5},
+		{"invalid", "abc", 5},
+	}
+	for _, myTest := range myTests {
+		t.Run(myTest.name, func(t *testing.T) {
+			t.Setenv("WORKPOOL_TEST_VALUE", myTest.value)
+			if got := EnvInt("WORKPOOL_TEST_VALUE", 5); got != myTest.want {
+				t.Errorf("EnvInt() = %v, want %v", got, myTest.want)
+			}
+		})
+	}
+}